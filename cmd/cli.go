@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"idm/inner/auth"
+	"idm/inner/client"
+	"idm/inner/common"
+	"idm/inner/migration"
+	"idm/inner/storage"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+)
+
+const usage = `usage: idm <command> [flags]
+
+commands:
+  serve                                     запуск веб-сервера (команда по умолчанию)
+  employee list [-name part]                список работников
+  employee create -name name [-roles 1,2]   создание работника, сразу с ролями
+  employee delete -id id                    удаление работника
+  role list [-name part]                    список ролей
+  role create -name name                    создание роли
+  role delete -id id                        удаление роли
+  assign -employee id -roles 1,2            назначение ролей работнику
+  service-account list                      список сервисных аккаунтов и их ключей
+  service-account create -name name -scopes employees:read,roles:*
+                                            создание сервисного аккаунта, выводит API-ключ
+  service-account rotate -id id [-overlap 24h]
+                                            новый API-ключ, прежние действуют ещё -overlap
+  import -file hr.csv [-apply]              импорт работников из CSV или XLSX (без -apply - предварительный просмотр)
+  export employees|roles [-format csv]      выгрузка в csv, ndjson или xlsx (-out - файл вместо stdout)
+  migrate up|down|status|redo               миграции базы данных
+  config check                              проверка конфигурации, подключения к базе данных и миграций
+  config print [-redacted]                  итоговая конфигурация со всеми источниками (-redacted скрывает секреты)
+
+Команды работают напрямую с базой данных из конфигурации или, если задан -server
+(или переменная окружения IDM_SERVER), через HTTP API запущенного сервера.
+Конфигурация собирается из значений по умолчанию, YAML-файла (-config или CONFIG_FILE),
+.env файла (-env), переменных окружения и флагов вида -db-dsn (в порядке возрастания приоритета).
+Флаг -output table|json выбирает формат вывода, -api-key (IDM_API_KEY) - ключ для сервера
+с включённой аутентификацией. Команды service-account работают только напрямую с базой данных,
+чтобы первый ключ можно было выпустить до обращения к API.
+`
+
+// errUsage неверные аргументы команды: выводится справка и код выхода 2
+var errUsage = errors.New("invalid arguments")
+
+// command команда командной строки: args - аргументы после имени команды
+type command func(ctx context.Context, args []string) error
+
+var commands = map[string]command{
+	"employee":        employeeCommand,
+	"role":            roleCommand,
+	"assign":          assignCommand,
+	"service-account": serviceAccountCommand,
+	"import":          importCommand,
+	"export":          exportCommand,
+	"migrate":         migrateCommand,
+	"config":          configCommand,
+}
+
+// run выполнение команды и код выхода процесса
+func run(args []string) int {
+	if len(args) > 0 && (args[0] == "help" || args[0] == "-h" || args[0] == "--help") {
+		fmt.Print(usage)
+		return 0
+	}
+	if len(args) == 0 || args[0] == "serve" || strings.HasPrefix(args[0], "-") {
+		if len(args) > 0 && args[0] == "serve" {
+			args = args[1:]
+		}
+		return serve(args)
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	// Ctrl+C прерывает запросы команды, а незавершённая транзакция откатывается
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err := cmd(ctx, args[1:])
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp):
+		fmt.Fprintf(os.Stderr, "%v\n\n%s", err, usage)
+		return 2
+	default:
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+}
+
+// options общие флаги команд
+type options struct {
+	envFile    string
+	configFile string
+	server     string
+	apiKey     string
+	output     string
+	// значения параметров конфигурации, явно переданные флагами
+	configFlags func() map[string]string
+}
+
+// форматы вывода
+const (
+	outputTable = "table"
+	outputJson  = "json"
+)
+
+func newFlags(name string) (*flag.FlagSet, *options) {
+	var opts options
+	var flags = flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.StringVar(&opts.envFile, "env", ".env", "путь к .env файлу для прямого подключения к базе данных")
+	flags.StringVar(&opts.configFile, "config", os.Getenv(common.ConfigFileEnv), "путь к YAML-файлу конфигурации (CONFIG_FILE)")
+	flags.StringVar(&opts.server, "server", os.Getenv("IDM_SERVER"), "адрес сервера IDM, например http://localhost:8080 (IDM_SERVER)")
+	flags.StringVar(&opts.apiKey, "api-key", os.Getenv("IDM_API_KEY"), "API-ключ сервисного аккаунта для сервера с включённой аутентификацией (IDM_API_KEY)")
+	flags.StringVar(&opts.output, "output", outputTable, "формат вывода: table или json")
+	opts.configFlags = common.BindFlags(flags)
+	return flags, &opts
+}
+
+// parse разбор флагов команды: ошибки разбора - ошибки использования
+func parse(flags *flag.FlagSet, opts *options, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if opts.output != outputTable && opts.output != outputJson {
+		return fmt.Errorf("%w: unknown output format %s", errUsage, opts.output)
+	}
+	return nil
+}
+
+// client клиент для выполнения команды: HTTP API сервера или сервисы поверх базы данных из .env
+func (opts *options) client() (cli client.Client, closeFn func(), err error) {
+	if opts.server != "" {
+		var remote = client.NewRemote(opts.server, nil)
+		if opts.apiKey != "" {
+			remote.WithAuthorization(auth.SchemeApiKey + " " + opts.apiKey)
+		}
+		return remote, func() {}, nil
+	}
+
+	store, err := opts.openStorage()
+	if err != nil {
+		return nil, nil, err
+	}
+	return client.NewLocal(store), func() {
+		if err := store.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "error closing storage: %v\n", err)
+		}
+	}, nil
+}
+
+// openStorage хранилища для прямого подключения к базе данных. Команды CLI не меняют схему:
+// при MIGRATIONS=auto миграции только проверяются, применяет их команда migrate up
+func (opts *options) openStorage() (*storage.Storage, error) {
+	cfg, err := opts.config()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Migrations == common.MigrationsAuto {
+		cfg.Migrations = common.MigrationsVerify
+	}
+	store, err := storage.Open(cfg)
+	if errors.Is(err, migration.ErrPending) {
+		return nil, fmt.Errorf("%w: run \"idm migrate up\" first", err)
+	}
+	return store, err
+}
+
+// config конфигурация из всех источников, включая флаги команды
+func (opts *options) config() (common.Config, error) {
+	return common.Loader{ConfigFile: opts.configFile, EnvFile: opts.envFile, Flags: opts.configFlags()}.Load()
+}
+
+// print вывод результата: JSON-значение data или таблица с заголовком header
+func (opts *options) print(data any, header []string, rows [][]string) error {
+	if opts.output == outputJson {
+		return printJson(os.Stdout, data)
+	}
+	return printTable(os.Stdout, header, rows)
+}
+
+func printJson(out io.Writer, data any) error {
+	var encoder = json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+func printTable(out io.Writer, header []string, rows [][]string) error {
+	var writer = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+	return writer.Flush()
+}
+
+// parseIds список id через запятую, например "1,2,3"
+func parseIds(value string) (ids []int64, err error) {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(item, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("%w: invalid id %s", errUsage, item)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// isSet флаг передан в командной строке явно
+func isSet(flags *flag.FlagSet, name string) (set bool) {
+	flags.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+// subcommand имя подкоманды и её аргументы: "list -name x" -> "list", ["-name", "x"]
+func subcommand(args []string) (name string, rest []string, err error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return "", nil, fmt.Errorf("%w: subcommand is required", errUsage)
+	}
+	return args[0], args[1:], nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"idm/inner/migration"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenStorage(t *testing.T) {
+	a := assert.New(t)
+	var flags, opts = newFlags("employee list")
+	var dsn = filepath.Join(t.TempDir(), "idm.db")
+	var args = []string{"-env", "", "-app-name", "idm", "-app-version", "1.0",
+		"-db-driver-name", "sqlite", "-db-dsn", dsn, "-migrations", "auto"}
+	if err := parse(flags, opts, args); err != nil {
+		t.Fatal(err)
+	}
+
+	// команда без migrate up не создаёт схему в пустой базе данных, а сообщает о неприменённых миграциях
+	_, err := opts.openStorage()
+	a.ErrorIs(err, migration.ErrPending)
+	a.ErrorContains(err, "idm migrate up")
+	_, err = opts.openStorage()
+	a.ErrorIs(err, migration.ErrPending)
+}
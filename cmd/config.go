@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/migration"
+	"os"
+)
+
+// configCheck результат проверки конфигурации
+type configCheck struct {
+	Check  string `json:"check"`
+	Ok     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// configCommand idm config check|print: конфигурация читается и проверяется так же, как при запуске сервера
+func configCommand(ctx context.Context, args []string) error {
+	name, args, err := subcommand(args)
+	if err != nil {
+		return err
+	}
+	switch name {
+	case "check":
+		return configCheckCommand(ctx, args)
+	case "print":
+		return configPrintCommand(args)
+	default:
+		return fmt.Errorf("%w: unknown subcommand config %s", errUsage, name)
+	}
+}
+
+// configCheckCommand idm config check: проверка конфигурации, подключения к базе данных и применённых миграций
+func configCheckCommand(ctx context.Context, args []string) error {
+	var flags, opts = newFlags("config check")
+	if err := parse(flags, opts, args); err != nil {
+		return err
+	}
+
+	var checks = checkConfig(ctx, opts)
+	var rows = make([][]string, len(checks))
+	var failed int
+	for i, check := range checks {
+		var status = "ok"
+		if !check.Ok {
+			status = "FAILED"
+			failed++
+		}
+		rows[i] = []string{check.Check, status, check.Detail}
+	}
+	if err := opts.print(checks, []string{"CHECK", "STATUS", "DETAIL"}, rows); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
+
+// configPrintCommand idm config print [-redacted]: итоговые значения параметров после применения всех источников
+func configPrintCommand(args []string) error {
+	var flags, opts = newFlags("config print")
+	var redacted = flags.Bool("redacted", false, "скрыть пароли и ключи")
+	if err := parse(flags, opts, args); err != nil {
+		return err
+	}
+
+	cfg, err := opts.config()
+	if err != nil {
+		return err
+	}
+	var settings = cfg.Settings(*redacted)
+	if opts.output == outputJson {
+		return printJson(os.Stdout, settings)
+	}
+	// формат .env файла: вывод можно сохранить и использовать как конфигурацию
+	for _, setting := range settings {
+		fmt.Printf("%s=%s\n", setting.Name, setting.Value)
+	}
+	return nil
+}
+
+func checkConfig(ctx context.Context, opts *options) (checks []configCheck) {
+	cfg, err := opts.config()
+	if err != nil {
+		return append(checks, configCheck{Check: "config", Detail: err.Error()})
+	}
+	checks = append(checks, configCheck{Check: "config", Ok: true, Detail: "storage " + cfg.Storage})
+	if cfg.Storage != common.StorageDatabase {
+		return checks
+	}
+
+	db, err := database.ConnectDbWithCfg(cfg)
+	if err != nil {
+		return append(checks, configCheck{Check: "database", Detail: err.Error()})
+	}
+	defer db.Close()
+	checks = append(checks, configCheck{Check: "database", Ok: true, Detail: cfg.DbDriverName})
+
+	migrator, err := migration.NewMigrator(db)
+	if err == nil {
+		err = migrator.Verify(ctx)
+	}
+	if err != nil {
+		return append(checks, configCheck{Check: "migrations", Detail: err.Error()})
+	}
+	return append(checks, configCheck{Check: "migrations", Ok: true, Detail: "up to date"})
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"idm/inner/assignment"
+	"idm/inner/common"
+	"idm/inner/employee"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// employeeCommand idm employee list|create|delete
+func employeeCommand(ctx context.Context, args []string) error {
+	name, args, err := subcommand(args)
+	if err != nil {
+		return err
+	}
+
+	var flags, opts = newFlags("employee " + name)
+	switch name {
+	case "list":
+		var filter employee.Filter
+		flags.StringVar(&filter.Name, "name", "", "часть имени без учёта регистра")
+		if err = parse(flags, opts, args); err != nil {
+			return err
+		}
+		cli, closeFn, err := opts.client()
+		if err != nil {
+			return err
+		}
+		defer closeFn()
+
+		employees, err := cli.ListEmployees(ctx, filter)
+		if err != nil {
+			return err
+		}
+		var rows = make([][]string, len(employees))
+		for i, resp := range employees {
+			rows[i] = []string{strconv.FormatInt(resp.Id, 10), resp.Name, strconv.FormatBool(resp.Active),
+				strconv.FormatInt(resp.Version, 10), resp.Create.Format(time.DateTime), resp.Update.Format(time.DateTime)}
+		}
+		return opts.print(employees, []string{"ID", "NAME", "ACTIVE", "VERSION", "CREATE AT", "UPDATE AT"}, rows)
+
+	case "create":
+		var employeeName = flags.String("name", "", "имя работника")
+		var roles = flags.String("roles", "", "id ролей через запятую")
+		if err = parse(flags, opts, args); err != nil {
+			return err
+		}
+		roleIds, err := parseIds(*roles)
+		if err != nil {
+			return err
+		}
+		cli, closeFn, err := opts.client()
+		if err != nil {
+			return err
+		}
+		defer closeFn()
+
+		var now = time.Now()
+		id, err := cli.CreateEmployee(ctx, assignment.CreateEmployeeRequest{
+			Request: employee.Request{Name: *employeeName, Create: now, Update: now},
+			RoleIds: roleIds,
+		})
+		if err != nil {
+			return err
+		}
+		return printId(opts, "created employee", id)
+
+	case "delete":
+		var id = flags.Int64("id", 0, "id работника")
+		if err = parse(flags, opts, args); err != nil {
+			return err
+		}
+		if *id <= 0 {
+			return fmt.Errorf("%w: -id is required", errUsage)
+		}
+		cli, closeFn, err := opts.client()
+		if err != nil {
+			return err
+		}
+		defer closeFn()
+
+		if err = cli.DeleteEmployee(ctx, *id); err != nil {
+			return err
+		}
+		return printId(opts, "deleted employee", *id)
+
+	default:
+		return fmt.Errorf("%w: unknown subcommand employee %s", errUsage, name)
+	}
+}
+
+// assignCommand idm assign -employee id -roles 1,2
+func assignCommand(ctx context.Context, args []string) error {
+	var flags, opts = newFlags("assign")
+	var employeeId = flags.Int64("employee", 0, "id работника")
+	var roles = flags.String("roles", "", "id ролей через запятую")
+	if err := parse(flags, opts, args); err != nil {
+		return err
+	}
+	roleIds, err := parseIds(*roles)
+	if err != nil {
+		return err
+	}
+	if *employeeId <= 0 || len(roleIds) == 0 {
+		return fmt.Errorf("%w: -employee and -roles are required", errUsage)
+	}
+	cli, closeFn, err := opts.client()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	if err = cli.AssignRoles(ctx, *employeeId, roleIds); err != nil {
+		return err
+	}
+	return printId(opts, "assigned roles to employee", *employeeId)
+}
+
+// importCommand idm import -file hr.csv [-apply]
+func importCommand(ctx context.Context, args []string) error {
+	var flags, opts = newFlags("import")
+	var fileName = flags.String("file", "", "файл импорта .csv или .xlsx")
+	var apply = flags.Bool("apply", false, "применить импорт (по умолчанию только предварительный просмотр)")
+	if err := parse(flags, opts, args); err != nil {
+		return err
+	}
+	if *fileName == "" {
+		return fmt.Errorf("%w: -file is required", errUsage)
+	}
+
+	file, err := os.Open(*fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	cli, closeFn, err := opts.client()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	// отчёт выводится и при ошибке: в нём видно, какие строки файла некорректны
+	report, err := cli.ImportEmployees(ctx, filepath.Base(*fileName), file, *apply)
+	if err != nil && !errors.As(err, &common.RequestValidationError{}) {
+		return err
+	}
+	var rows = make([][]string, len(report.Rows))
+	for i, row := range report.Rows {
+		var id string
+		if row.Id > 0 {
+			id = strconv.FormatInt(row.Id, 10)
+		}
+		rows[i] = []string{strconv.Itoa(row.Line), row.Name, row.Action, id, strings.Join(row.Errors, "; ")}
+	}
+	if errPrint := opts.print(report, []string{"LINE", "NAME", "ACTION", "ID", "ERRORS"}, rows); errPrint != nil {
+		return errPrint
+	}
+	if opts.output == outputTable {
+		fmt.Printf("\ntotal %d, created %d, updated %d, invalid %d, dry run %t\n",
+			report.Total, report.Created, report.Updated, report.Invalid, report.DryRun)
+	}
+	return err
+}
+
+// printId вывод id созданной или изменённой записи
+func printId(opts *options, message string, id int64) error {
+	if opts.output == outputJson {
+		return printJson(os.Stdout, map[string]int64{"id": id})
+	}
+	fmt.Printf("%s with id %d\n", message, id)
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"idm/inner/tabular"
+	"io"
+	"os"
+)
+
+// exportCommand idm export employees|roles [-format csv] [-out file] [-name part]
+func exportCommand(ctx context.Context, args []string) (err error) {
+	resource, args, err := subcommand(args)
+	if err != nil {
+		return err
+	}
+
+	var flags, opts = newFlags("export " + resource)
+	var formatName = flags.String("format", string(tabular.CSV), "формат выгрузки: csv, ndjson или xlsx")
+	var outFile = flags.String("out", "", "файл выгрузки (по умолчанию stdout)")
+	var name = flags.String("name", "", "часть имени без учёта регистра")
+	if err = parse(flags, opts, args); err != nil {
+		return err
+	}
+	format, err := tabular.ParseFormat(*formatName)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if resource != "employees" && resource != "roles" {
+		return fmt.Errorf("%w: unknown export %s", errUsage, resource)
+	}
+
+	cli, closeFn, err := opts.client()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	var out io.Writer = os.Stdout
+	if *outFile != "" {
+		file, err := os.Create(*outFile)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if errClose := file.Close(); err == nil {
+				err = errClose
+			}
+		}()
+		out = file
+	}
+
+	if resource == "employees" {
+		return cli.ExportEmployees(ctx, employee.Filter{Name: *name}, format, out)
+	}
+	return cli.ExportRoles(ctx, role.Filter{Name: *name}, format, out)
+}
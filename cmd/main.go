@@ -1,62 +1,305 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"idm/inner/account"
+	"idm/inner/assignment"
+	"idm/inner/auth"
 	"idm/inner/common"
 	"idm/inner/database"
 	"idm/inner/employee"
+	"idm/inner/health"
+	"idm/inner/idempotency"
 	"idm/inner/info"
+	"idm/inner/logging"
+	"idm/inner/metrics"
+	"idm/inner/migration"
+	"idm/inner/oidc"
+	"idm/inner/ratelimit"
 	"idm/inner/role"
+	"idm/inner/serviceaccount"
+	"idm/inner/storage"
+	"idm/inner/tracing"
 	"idm/inner/validator"
 	"idm/inner/web"
-
-	"github.com/jmoiron/sqlx"
+	"idm/inner/worker"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
+// как часто проверять изменение файлов конфигурации
+const configWatchInterval = 5 * time.Second
+
+// idm - веб-сервер и команды администрирования, список команд: idm help
 func main() {
-	cfg, err := common.GetConfig(".env")
+	os.Exit(run(os.Args[1:]))
+}
+
+// serve запуск веб-сервера: idm [serve] [-env .env] [-config idm.yaml] [-http-address :8080 ...]
+func serve(args []string) int {
+	var flags = flag.NewFlagSet("serve", flag.ExitOnError)
+	var envFile = flags.String("env", ".env", "путь к .env файлу")
+	var configFile = flags.String("config", os.Getenv(common.ConfigFileEnv), "путь к YAML-файлу конфигурации (CONFIG_FILE)")
+	var configFlags = common.BindFlags(flags)
+	_ = flags.Parse(args)
+
+	var loader = common.Loader{ConfigFile: *configFile, EnvFile: *envFile, Flags: configFlags()}
+	cfg, err := loader.Load()
 	if err != nil {
-		panic(err.Error())
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
 	}
 
-	// создаём подключение к базе данных
-	database, err := database.ConnectDbWithCfg(cfg)
+	// таймауты, время хранения ключей идемпотентности и уровень логов перечитываются
+	// при изменении файлов конфигурации и по сигналу SIGHUP без перезапуска
+	var live = common.NewLiveConfig(loader, cfg)
+	// структурированные логи в формате LOG_FORMAT, уровень читается из действующей конфигурации;
+	// пакет log тоже пишет через этот логгер
+	slog.SetDefault(logging.New(os.Stderr, cfg.LogFormat, logging.LevelFrom(func() string { return live.Get().LogLevel })))
+
+	// трассировка (TRACING_EXPORTER): span запросов, сервисов и запросов к базе данных
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
 	if err != nil {
-		panic(err.Error())
+		slog.Error("error setting up tracing", "error", err)
+		return 1
 	}
-	// закрываем соединение с базой данных после выхода из функции main
+	// накопленные span отправляются последними, после завершения запросов
 	defer func() {
-		if err := database.Close(); err != nil {
-			fmt.Printf("error closing db: %v", err)
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("error flushing traces", "error", err)
 		}
 	}()
-	var server = build(database, cfg)
-	err = server.App.Listen(":8080")
+
+	// создаём хранилища: подключение к базе данных или хранилище в памяти (STORAGE=memory);
+	// при подключении к базе данных применяются миграции (MIGRATIONS=auto|verify|off)
+	store, err := storage.Open(cfg)
+	if err != nil {
+		slog.Error("error opening storage", "error", err)
+		return 1
+	}
+	// закрываем соединение с базой данных последним: после завершения запросов и фоновых задач
+	defer func() {
+		if err := store.Close(); err != nil {
+			slog.Error("error closing db", "error", err)
+		}
+	}()
+
+	var hangup = make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var workers = worker.NewGroup()
+	workers.Go("config-watch", func(ctx context.Context) {
+		live.Watch(ctx, configWatchInterval, hangup)
+	})
+	workers.Go("idempotency-cleanup", func(ctx context.Context) {
+		idempotency.RunCleanup(ctx, store.Idempotency, time.Hour)
+	})
+	workers.Go("rate-limit-cleanup", func(ctx context.Context) {
+		ratelimit.RunCleanup(ctx, store.RateLimits, time.Minute)
+	})
+	workers.Go("password-reset-cleanup", func(ctx context.Context) {
+		account.RunCleanup(ctx, store.Accounts, time.Hour)
+	})
+
+	// встроенный OIDC-провайдер (OIDC_ISSUER): ключ подписи выпускается до приёма запросов,
+	// дальше ключи ротируются в фоне
+	var oidcKeys *oidc.Keys
+	if cfg.OidcIssuer != "" {
+		oidcKeys = oidc.NewKeys(store.Oidc, cfg.OidcKeyRotation)
+		if err = oidcKeys.Rotate(context.Background()); err != nil {
+			slog.Error("error preparing oidc signing keys", "error", err)
+			return 1
+		}
+		workers.Go("oidc-key-rotation", func(ctx context.Context) {
+			oidc.RunRotation(ctx, oidcKeys, time.Hour)
+		})
+	}
+
+	checks, err := healthChecks(store, workers, cfg)
+	if err != nil {
+		slog.Error("error creating health checks", "error", err)
+		return 1
+	}
+	var appMetrics = newMetrics(store, cfg)
+	defer database.ObserveQueries(nil)
+	var server = build(store, live, checks, appMetrics, oidcKeys)
+	var listenErr = make(chan error, 1)
+	go func() {
+		listenErr <- server.App.Listen(cfg.HttpAddress)
+	}()
+
+	// SIGTERM (остановка пода в Kubernetes) и Ctrl+C запускают плавную остановку
+	stopCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var exitCode = 0
+	select {
+	case err = <-listenErr:
+		slog.Error("http server error", "error", err)
+		exitCode = 1
+	case <-stopCtx.Done():
+	}
+	// повторный сигнал завершает процесс сразу
+	stop()
+
+	if err = shutdown(server, workers, cfg); err != nil {
+		slog.Error("shutdown error", "error", err)
+		exitCode = 1
+	}
+	return exitCode
+}
+
+// shutdown плавная остановка: проверка готовности начинает отвечать ошибкой, через SHUTDOWN_DELAY
+// сервер перестаёт принимать подключения и ждёт завершения запросов, затем останавливаются фоновые задачи.
+// Каждому этапу отводится свой SHUTDOWN_TIMEOUT: по его истечении оставшиеся запросы отменяются,
+// а фоновые задачи всё равно получают полный срок на остановку.
+func shutdown(server *web.Server, workers *worker.Group, cfg common.Config) error {
+	slog.Info("shutting down, waiting for in-flight requests", "timeout", cfg.ShutdownTimeout)
+	server.BeginShutdown()
+	time.Sleep(cfg.ShutdownDelay)
+
+	var serverErr = stopWithin(cfg.ShutdownTimeout, server.Shutdown)
+	var workersErr = stopWithin(cfg.ShutdownTimeout, workers.Stop)
+	if err := errors.Join(serverErr, workersErr); err != nil {
+		return err
+	}
+	slog.Info("server stopped")
+	return nil
+}
+
+// stopWithin вызов stop с контекстом, который отменяется через timeout
+func stopWithin(timeout time.Duration, stop func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return stop(ctx)
+}
+
+// healthChecks проверки для проб Kubernetes: живость зависит только от самого процесса,
+// а готовность и запуск - ещё и от базы данных с актуальной схемой
+func healthChecks(store *storage.Storage, workers *worker.Group, cfg common.Config) (*health.Registry, error) {
+	var checks = health.NewRegistry(cfg.HealthCheckTimeout, cfg.HealthCacheTTL)
+	checks.Register("workers", health.Workers(workers.Running), health.Live, health.Ready)
+	if store.DB == nil {
+		return checks, nil
+	}
+
+	migrator, err := migration.NewMigrator(store.DB)
 	if err != nil {
-		panic(fmt.Sprintf("http server error: %s", err))
+		return nil, err
+	}
+	checks.Register("database", health.Ping(store.DB), health.Ready, health.Startup)
+	checks.Register("migrations", migrator.Verify, health.Ready, health.Startup)
+	return checks, nil
+}
+
+// newMetrics метрики приложения: запросы репозиториев, пул подключений к базе данных и количество сущностей
+func newMetrics(store *storage.Storage, cfg common.Config) *metrics.Metrics {
+	var appMetrics = metrics.New(cfg)
+	database.ObserveQueries(appMetrics.ObserveQuery)
+	if store.DB != nil {
+		appMetrics.RegisterDB(store.DB.DB)
 	}
+	appMetrics.RegisterCounts(cfg.HealthCheckTimeout, map[string]metrics.Counter{
+		"employees":        store.Employees.Count,
+		"roles":            store.Roles.Count,
+		"role_assignments": store.Roles.CountAssignments,
+	})
+	return appMetrics
 }
 
 // buil функция, конструирующая наш веб-сервер
-func build(database *sqlx.DB, cfg common.Config) *web.Server {
+// oidcKeys ключи подписи OIDC-провайдера, nil - провайдер выключен
+func build(store *storage.Storage, live *common.LiveConfig, checks *health.Registry, appMetrics *metrics.Metrics, oidcKeys *oidc.Keys) *web.Server {
 	// создаём веб-сервер
 	var server = web.NewServer()
-	// создаём репозиторий
-	var employeeRepo = employee.NewEmployeeRepository(database)
-	var roleRepo = role.NewRoleRepository(database)
+	// метрики учитывают все запросы, включая не найденные маршруты
+	server.App.Use(appMetrics.Middleware())
+	// контекст с таймаутом создаётся до всех маршрутов (API, "/auth", "/oauth2", "/internal"), его используют
+	// все следующие middleware и хендлеры; таймауты читаются из действующей конфигурации при каждом запросе
+	server.App.Use(web.TimeoutFrom(func() web.Timeouts {
+		var cfg = live.Get()
+		return web.Timeouts{Default: cfg.RequestTimeout, Routes: cfg.RouteTimeouts}
+	}))
+	// полный путь будет "/internal/metrics"
+	server.GroupInternal.Get("/metrics", appMetrics.Handler())
 	// создаём валидатор
 	var vld = validator.NewRequestValidator()
+	var serviceAccountService = serviceaccount.NewService(store.ServiceAccounts, vld)
+	var cfg = live.Get()
+	// токены доступа OIDC-провайдера принимаются API наравне с токенами HS256
+	var tokenVerifier = auth.NewTokenVerifier(cfg.AuthSigningKey)
+	if oidcKeys != nil {
+		tokenVerifier.WithKeys(cfg.OidcIssuer, oidcKeys)
+	}
+	// аутентификация (AUTH_ENABLED) до ограничения частоты запросов: счётчики ведутся по вызывающему,
+	// а не по IP-адресу; сервисные аккаунты передают API-ключ, остальные - JWT.
+	// Запросы без верных учётных данных до вызывающего не доходят, поэтому ответы 401 ограничиваются
+	// по IP-адресу ещё до аутентификации (AUTH_FAILURE_LIMIT)
+	if cfg.AuthEnabled {
+		server.GroupApiV1.Use(ratelimit.UnauthorizedFrom(store.RateLimits, func() common.RateLimit { return live.Get().AuthFailureLimit }))
+		server.GroupApiV1.Use(auth.Middleware(map[string]auth.Authenticator{
+			auth.SchemeApiKey: serviceAccountService,
+			auth.SchemeBearer: tokenVerifier,
+		}))
+	}
+	// ограничение частоты запросов каждого клиента проверяется до сохранения ключа идемпотентности,
+	// чтобы отклонённый запрос не занимал ключ; ограничения читаются из действующей конфигурации
+	var rateLimit = ratelimit.MiddlewareFrom(store.RateLimits, func() ratelimit.Limits {
+		var cfg = live.Get()
+		return ratelimit.Limits{Default: cfg.RateLimit, Routes: cfg.RateLimitRoutes}
+	})
+	server.GroupApiV1.Use(rateLimit)
+	// вход, смена и сброс пароля и выдача токенов OIDC вне "/api/v1" и без аутентификации API:
+	// их запросы считаются по IP-адресу, иначе перебор паролей по многим именам ничем не ограничен
+	server.App.Use("/auth", rateLimit)
+	server.App.Use(oidc.PathToken, rateLimit)
+	// повторные POST-запросы с тем же Idempotency-Key получают сохранённый ответ;
+	// middleware регистрируется до маршрутов, иначе fiber не вызовет его для них
+	server.GroupApiV1.Use(idempotency.MiddlewareFrom(store.Idempotency, func() time.Duration { return live.Get().IdempotencyTTL }))
 	// создаём сервис
-	var employeeService = employee.NewService(employeeRepo, vld)
-	var roleService = role.NewService(roleRepo, vld)
-	var connectionService = &info.Service{}
+	var employeeService = employee.NewService(store.Employees, vld)
+	var roleService = role.NewService(store.Roles, vld)
+	var assignmentService = assignment.NewService(store.Tx, store.Employees, store.Roles, vld)
 	// создаём контроллер
 	var employeeController = employee.NewController(server, employeeService)
 	var roleController = role.NewController(server, roleService)
-	var infoController = info.NewController(server, cfg, connectionService)
+	var assignmentController = assignment.NewController(server, assignmentService)
+	var serviceAccountController = serviceaccount.NewController(server, serviceAccountService)
+	var infoController = info.NewController(server, live, checks)
 	employeeController.RegisterRoutes()
 	roleController.RegisterRoutes()
+	assignmentController.RegisterRoutes()
+	serviceAccountController.RegisterRoutes()
 	infoController.RegisterRoutes()
+	// без аутентификации API учётные записи и клиенты провайдера не регистрируются
+	// (конфигурация такое сочетание не пропускает, проверка на случай сборки сервера в обход неё)
+	if !cfg.AuthEnabled || cfg.AuthSigningKey == "" {
+		return server
+	}
+	// вход по паролю: сессии подписываются ключом AUTH_SIGNING_KEY
+	var accountService = account.NewService(store.Accounts, store.Employees, vld, cfg.AuthSigningKey, account.Policy{
+		MinLength:   cfg.PasswordMinLength,
+		MaxAttempts: cfg.LoginMaxAttempts,
+		Lockout:     cfg.LoginLockout,
+		SessionTTL:  cfg.SessionTTL,
+		ResetTTL:    cfg.PasswordResetTTL,
+	})
+	account.NewController(server, accountService).RegisterRoutes()
+	if oidcKeys != nil {
+		// сессии работников проверяет сервис учётных записей: токен доступа OIDC сессией не является,
+		// а сессия, выданная до смены пароля или увольнения работника, не действует
+		var provider = oidc.NewProvider(store.Oidc, oidcKeys, store.Employees, store.Roles,
+			accountService, vld, cfg.OidcIssuer, cfg.OidcTokenTTL)
+		oidc.NewController(server, provider, oidcKeys).RegisterRoutes()
+	}
 
 	return server
 }
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"idm/inner/common"
+	"idm/inner/health"
+	"idm/inner/logging"
+	"idm/inner/metrics"
+	"idm/inner/oidc"
+	"idm/inner/storage"
+	"idm/inner/web"
+	"idm/inner/worker"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestServer сервер из build с хранилищами в памяти и включённым OIDC-провайдером
+func newTestServer(t *testing.T, cfg common.Config) *fiber.App {
+	var store = storage.NewMemory()
+	var keys = oidc.NewKeys(store.Oidc, cfg.OidcKeyRotation)
+	if err := keys.Rotate(context.Background()); err != nil {
+		t.Fatalf("error preparing oidc signing keys: %v", err)
+	}
+	var checks = health.NewRegistry(time.Second, 0)
+	return build(store, common.NewLiveConfig(common.Loader{}, cfg), checks, metrics.New(cfg), keys).App
+}
+
+var testConfig = common.Config{
+	AppName:         "idm",
+	AppVersion:      "1.0",
+	RequestTimeout:  time.Second,
+	IdempotencyTTL:  time.Hour,
+	AuthSigningKey:  "session-signing-key",
+	OidcIssuer:      "https://idm.example.com",
+	OidcTokenTTL:    time.Hour,
+	OidcKeyRotation: 720 * time.Hour,
+}
+
+// status код ответа на запрос с пустым JSON в теле
+func status(t *testing.T, app *fiber.App, method string, path string) int {
+	var req = httptest.NewRequest(method, path, strings.NewReader("{}"))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("error sending request %s %s: %v", method, path, err)
+	}
+	return resp.StatusCode
+}
+
+func TestBuildAuthRoutes(t *testing.T) {
+	var cfg = testConfig
+	var requests = map[string]string{
+		"/auth/login":                       fiber.MethodPost,
+		"/auth/password/reset":              fiber.MethodPost,
+		"/api/v1/accounts/id/1/reset":       fiber.MethodPost,
+		"/api/v1/oidc-clients":              fiber.MethodGet,
+		"/.well-known/openid-configuration": fiber.MethodGet,
+	}
+
+	t.Run("should not register account and oidc routes without auth", func(t *testing.T) {
+		a := assert.New(t)
+		var app = newTestServer(t, cfg)
+
+		for path, method := range requests {
+			a.Equal(fiber.StatusNotFound, status(t, app, method, path), path)
+		}
+	})
+
+	t.Run("should require authentication for account and oidc management", func(t *testing.T) {
+		a := assert.New(t)
+		var authCfg = cfg
+		authCfg.AuthEnabled = true
+		var app = newTestServer(t, authCfg)
+
+		a.Equal(fiber.StatusUnauthorized, status(t, app, fiber.MethodPost, "/api/v1/accounts/id/1/reset"))
+		a.Equal(fiber.StatusUnauthorized, status(t, app, fiber.MethodGet, "/api/v1/oidc-clients"))
+		a.Equal(fiber.StatusOK, status(t, app, fiber.MethodGet, "/.well-known/openid-configuration"))
+	})
+}
+
+func TestBuildRequestContext(t *testing.T) {
+	a := assert.New(t)
+	var app = newTestServer(t, testConfig)
+	// хендлеры вне "/api/v1" получают контекст с таймаутом и идентификатором запроса так же, как API
+	for _, path := range []string{"/auth/probe", "/oauth2/probe", "/internal/probe"} {
+		var deadline, requestId = make(chan bool, 1), make(chan string, 1)
+		app.Get(path, func(c *fiber.Ctx) {
+			_, ok := web.Context(c).Deadline()
+			deadline <- ok
+			requestId <- logging.RequestID(web.Context(c))
+		})
+
+		a.Equal(fiber.StatusOK, status(t, app, fiber.MethodGet, path), path)
+		a.True(<-deadline, path)
+		a.NotEmpty(<-requestId, path)
+	}
+}
+
+func TestBuildRateLimitsLogin(t *testing.T) {
+	a := assert.New(t)
+	var cfg = testConfig
+	cfg.AuthEnabled = true
+	cfg.RateLimitRoutes = map[string]common.RateLimit{
+		"POST /auth":         {Requests: 2, Period: time.Minute},
+		"POST /oauth2/token": {Requests: 1, Period: time.Minute},
+	}
+	var app = newTestServer(t, cfg)
+
+	// запросы без аутентификации считаются по IP-адресу: неверные данные тоже расходуют лимит
+	a.Equal(fiber.StatusBadRequest, status(t, app, fiber.MethodPost, "/auth/login"))
+	a.Equal(fiber.StatusBadRequest, status(t, app, fiber.MethodPost, "/auth/password/reset"))
+	a.Equal(fiber.StatusTooManyRequests, status(t, app, fiber.MethodPost, "/auth/login"))
+
+	a.NotEqual(fiber.StatusTooManyRequests, status(t, app, fiber.MethodPost, oidc.PathToken))
+	a.Equal(fiber.StatusTooManyRequests, status(t, app, fiber.MethodPost, oidc.PathToken))
+}
+
+func TestBuildRateLimitsUnauthorized(t *testing.T) {
+	a := assert.New(t)
+	var cfg = testConfig
+	cfg.AuthEnabled = true
+	cfg.AuthFailureLimit = common.RateLimit{Requests: 3, Period: time.Minute}
+	var app = newTestServer(t, cfg)
+
+	// перебор API-ключей с одного IP-адреса получает 429, не доходя до проверки ключа
+	var codes []int
+	for i := 0; i < 5; i++ {
+		var req = httptest.NewRequest(fiber.MethodGet, "/api/v1/employees", nil)
+		req.Header.Set(fiber.HeaderAuthorization, fmt.Sprintf("ApiKey bad-key-%d", i))
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		codes = append(codes, resp.StatusCode)
+	}
+	a.Equal([]int{
+		fiber.StatusUnauthorized, fiber.StatusUnauthorized, fiber.StatusUnauthorized,
+		fiber.StatusTooManyRequests, fiber.StatusTooManyRequests,
+	}, codes)
+}
+
+func TestShutdown(t *testing.T) {
+	a := assert.New(t)
+	var cfg = testConfig
+	cfg.ShutdownTimeout = 200 * time.Millisecond
+
+	// запрос не завершается до конца остановки сервера и расходует весь его срок
+	var server = web.NewServer()
+	var started = make(chan struct{})
+	var release = make(chan struct{})
+	defer close(release)
+	server.App.Get("/slow", func(c *fiber.Ctx) {
+		close(started)
+		<-release
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.App.Settings.DisableStartupMessage = true
+	go func() {
+		_ = server.App.Listener(listener)
+	}()
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/slow")
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+	<-started
+
+	// фоновой задаче нужно время на остановку после отмены контекста
+	var workers = worker.NewGroup()
+	workers.Go("slow-stop", func(ctx context.Context) {
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond)
+	})
+
+	err = shutdown(server, workers, cfg)
+	a.ErrorIs(err, context.DeadlineExceeded)
+	a.Contains(err.Error(), "in-flight requests were canceled")
+	a.NotContains(err.Error(), "background workers did not stop")
+	a.Equal(map[string]bool{"slow-stop": false}, workers.Running())
+}
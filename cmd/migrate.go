@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/migration"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+// migrateCommand миграции базы данных:
+//
+//	idm migrate up      - применение всех недостающих миграций
+//	idm migrate down    - откат последней миграции
+//	idm migrate status  - состояние миграций
+//	idm migrate redo    - откат и повторное применение последней миграции
+func migrateCommand(ctx context.Context, args []string) error {
+	name, args, err := subcommand(args)
+	if err != nil {
+		return err
+	}
+	var flags, opts = newFlags("migrate " + name)
+	if err = parse(flags, opts, args); err != nil {
+		return err
+	}
+	// адрес сервера из IDM_SERVER не мешает миграциям, ошибка - только при явном флаге
+	if isSet(flags, "server") {
+		return fmt.Errorf("%w: migrations are applied directly to the database, -server is not supported", errUsage)
+	}
+
+	cfg, err := opts.config()
+	if err != nil {
+		return err
+	}
+	if cfg.Storage != common.StorageDatabase {
+		return fmt.Errorf("migrations require STORAGE=%s, got %s", common.StorageDatabase, cfg.Storage)
+	}
+	return migrate(ctx, cfg, name, os.Stdout)
+}
+
+func migrate(ctx context.Context, cfg common.Config, command string, out io.Writer) error {
+	db, err := database.ConnectDbWithCfg(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := migration.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		results, err := migrator.Up(ctx)
+		printResults(out, results)
+		return err
+	case "down":
+		result, err := migrator.Down(ctx)
+		if result != nil {
+			printResults(out, []*goose.MigrationResult{result})
+		}
+		return err
+	case "redo":
+		results, err := migrator.Redo(ctx)
+		printResults(out, results)
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		var writer = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tSTATE\tAPPLIED AT\tFILE")
+		for _, status := range statuses {
+			var appliedAt = "-"
+			if !status.AppliedAt.IsZero() {
+				appliedAt = status.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Fprintf(writer, "%d\t%s\t%s\t%s\n", status.Source.Version, status.State, appliedAt, filepath.Base(status.Source.Path))
+		}
+		return writer.Flush()
+	default:
+		return fmt.Errorf("%w: unknown subcommand migrate %s", errUsage, command)
+	}
+}
+
+func printResults(out io.Writer, results []*goose.MigrationResult) {
+	if len(results) == 0 {
+		fmt.Fprintln(out, "no migrations to apply")
+	}
+	for _, result := range results {
+		fmt.Fprintf(out, "%-4s %s (%s)\n", result.Direction, filepath.Base(result.Source.Path), result.Duration.Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/reconcile"
+	"idm/inner/validator"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// сверка таблицы employee с выгрузкой кадровой системы:
+//
+//	go run ./cmd/reconcile -file hr.csv            - отчёт о планируемых изменениях
+//	go run ./cmd/reconcile -file hr.csv -apply     - применение изменений
+func main() {
+	var envFile = flag.String("env", ".env", "путь к .env файлу")
+	var snapshot = flag.String("file", "", "файл выгрузки кадровой системы (.csv или .json)")
+	var apply = flag.Bool("apply", false, "применить изменения (по умолчанию только отчёт)")
+	var maxPercent = flag.Float64("max-deactivate-percent", 10, "максимальная доля действующих работников в процентах, которую можно деактивировать")
+	flag.Parse()
+
+	if *snapshot == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Ctrl+C прерывает запросы к базе данных, а незавершённая транзакция откатывается
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	report, err := run(ctx, *envFile, *snapshot, !*apply, *maxPercent)
+	stop()
+	var encoder = json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(report)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reconciliation error: %v\n", err)
+		if errors.Is(err, reconcile.ErrTooManyDeactivations) {
+			os.Exit(3)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, envFile string, snapshot string, dryRun bool, maxPercent float64) (reconcile.Report, error) {
+	file, err := os.Open(snapshot)
+	if err != nil {
+		return reconcile.Report{}, err
+	}
+	defer file.Close()
+
+	records, err := reconcile.ReadSnapshotFile(snapshot, file)
+	if err != nil {
+		return reconcile.Report{}, err
+	}
+
+	cfg, err := common.GetConfig(envFile)
+	if err != nil {
+		return reconcile.Report{}, err
+	}
+	db, err := database.ConnectDbWithCfg(cfg)
+	if err != nil {
+		return reconcile.Report{}, err
+	}
+	defer db.Close()
+
+	var employeeService = employee.NewService(employee.NewEmployeeRepository(db), validator.NewRequestValidator())
+	var tx = database.NewTxManager(db, database.DefaultTxOptions)
+	return reconcile.NewReconciler(tx, employeeService, maxPercent).Run(ctx, records, dryRun)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"idm/inner/role"
+	"strconv"
+	"time"
+)
+
+// roleCommand idm role list|create|delete
+func roleCommand(ctx context.Context, args []string) error {
+	name, args, err := subcommand(args)
+	if err != nil {
+		return err
+	}
+
+	var flags, opts = newFlags("role " + name)
+	switch name {
+	case "list":
+		var filter role.Filter
+		flags.StringVar(&filter.Name, "name", "", "часть имени без учёта регистра")
+		if err = parse(flags, opts, args); err != nil {
+			return err
+		}
+		cli, closeFn, err := opts.client()
+		if err != nil {
+			return err
+		}
+		defer closeFn()
+
+		roles, err := cli.ListRoles(ctx, filter)
+		if err != nil {
+			return err
+		}
+		var rows = make([][]string, len(roles))
+		for i, resp := range roles {
+			rows[i] = []string{strconv.FormatInt(resp.Id, 10), resp.Name, strconv.FormatInt(resp.Version, 10),
+				resp.Create.Format(time.DateTime), resp.Update.Format(time.DateTime)}
+		}
+		return opts.print(roles, []string{"ID", "NAME", "VERSION", "CREATE AT", "UPDATE AT"}, rows)
+
+	case "create":
+		var roleName = flags.String("name", "", "имя роли")
+		if err = parse(flags, opts, args); err != nil {
+			return err
+		}
+		cli, closeFn, err := opts.client()
+		if err != nil {
+			return err
+		}
+		defer closeFn()
+
+		var now = time.Now()
+		id, err := cli.CreateRole(ctx, role.Request{Name: *roleName, Create: now, Update: now})
+		if err != nil {
+			return err
+		}
+		return printId(opts, "created role", id)
+
+	case "delete":
+		var id = flags.Int64("id", 0, "id роли")
+		if err = parse(flags, opts, args); err != nil {
+			return err
+		}
+		if *id <= 0 {
+			return fmt.Errorf("%w: -id is required", errUsage)
+		}
+		cli, closeFn, err := opts.client()
+		if err != nil {
+			return err
+		}
+		defer closeFn()
+
+		if err = cli.DeleteRole(ctx, *id); err != nil {
+			return err
+		}
+		return printId(opts, "deleted role", *id)
+
+	default:
+		return fmt.Errorf("%w: unknown subcommand role %s", errUsage, name)
+	}
+}
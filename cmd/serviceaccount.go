@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"idm/inner/serviceaccount"
+	"idm/inner/validator"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// serviceAccountCommand idm service-account list|create|rotate.
+// Команда работает напрямую с базой данных: при включённой аутентификации первый ключ
+// с разрешением service-accounts:write иначе выпустить нечем.
+func serviceAccountCommand(ctx context.Context, args []string) error {
+	name, args, err := subcommand(args)
+	if err != nil {
+		return err
+	}
+
+	var flags, opts = newFlags("service-account " + name)
+	var open = func() (*serviceaccount.Service, func(), error) {
+		if isSet(flags, "server") {
+			return nil, nil, fmt.Errorf("%w: service accounts are managed directly in the database, -server is not supported", errUsage)
+		}
+		store, err := opts.openStorage()
+		if err != nil {
+			return nil, nil, err
+		}
+		return serviceaccount.NewService(store.ServiceAccounts, validator.NewRequestValidator()), func() {
+			if err := store.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "error closing storage: %v\n", err)
+			}
+		}, nil
+	}
+
+	switch name {
+	case "list":
+		if err = parse(flags, opts, args); err != nil {
+			return err
+		}
+		serv, closeFn, err := open()
+		if err != nil {
+			return err
+		}
+		defer closeFn()
+
+		accounts, err := serv.GetAll(ctx)
+		if err != nil {
+			return err
+		}
+		var rows [][]string
+		for _, account := range accounts {
+			for _, key := range account.Keys {
+				rows = append(rows, []string{strconv.FormatInt(account.Id, 10), account.Name, strings.Join(account.Scopes, " "),
+					strconv.FormatInt(key.Id, 10), key.Prefix, formatTime(key.ExpiresAt), formatTime(key.LastUsedAt)})
+			}
+		}
+		return opts.print(accounts, []string{"ID", "NAME", "SCOPES", "KEY ID", "KEY PREFIX", "EXPIRES AT", "LAST USED AT"}, rows)
+
+	case "create":
+		var req serviceaccount.Request
+		flags.StringVar(&req.Name, "name", "", "имя сервисного аккаунта")
+		flags.StringVar(&req.Description, "description", "", "описание")
+		var scopes = flags.String("scopes", "", "разрешения через запятую, например employees:read,roles:*")
+		flags.StringVar(&req.ExpiresIn, "expires-in", "", "срок действия ключа, например 2160h (по умолчанию бессрочный)")
+		if err = parse(flags, opts, args); err != nil {
+			return err
+		}
+		req.Scopes = strings.FieldsFunc(*scopes, func(r rune) bool { return r == ',' || r == ' ' })
+		serv, closeFn, err := open()
+		if err != nil {
+			return err
+		}
+		defer closeFn()
+
+		created, err := serv.Create(ctx, req)
+		if err != nil {
+			return err
+		}
+		return printKey(opts, created)
+
+	case "rotate":
+		var id = flags.Int64("id", 0, "id сервисного аккаунта")
+		var req serviceaccount.RotateRequest
+		flags.StringVar(&req.Overlap, "overlap", "", "сколько ещё действуют прежние ключи, например 24h")
+		flags.StringVar(&req.ExpiresIn, "expires-in", "", "срок действия нового ключа (по умолчанию бессрочный)")
+		if err = parse(flags, opts, args); err != nil {
+			return err
+		}
+		if *id <= 0 {
+			return fmt.Errorf("%w: -id is required", errUsage)
+		}
+		serv, closeFn, err := open()
+		if err != nil {
+			return err
+		}
+		defer closeFn()
+
+		created, err := serv.RotateKey(ctx, *id, req)
+		if err != nil {
+			return err
+		}
+		return printKey(opts, created)
+
+	default:
+		return fmt.Errorf("%w: unknown subcommand service-account %s", errUsage, name)
+	}
+}
+
+// printKey вывод выпущенного ключа: повторно получить его нельзя
+func printKey(opts *options, created serviceaccount.CreatedKey) error {
+	if opts.output == outputJson {
+		return printJson(os.Stdout, created)
+	}
+	fmt.Printf("created api key %d for service account %d, it is shown only once:\n%s\n", created.Id, created.AccountId, created.Key)
+	return nil
+}
+
+func formatTime(value *time.Time) string {
+	if value == nil {
+		return "-"
+	}
+	return value.Local().Format(time.DateTime)
+}
@@ -0,0 +1,267 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"idm/inner/auth"
+	"idm/inner/common"
+	"idm/inner/crud"
+	"idm/inner/web"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber"
+)
+
+type Controller struct {
+	server  *web.Server
+	service Srv
+}
+
+// интерфейс сервиса account.Service
+type Srv interface {
+	Create(ctx context.Context, req Request) (Created, error)
+	GetAll(ctx context.Context) ([]Response, error)
+	FindById(ctx context.Context, id int64) (Response, error)
+	Delete(ctx context.Context, id int64) error
+	Unlock(ctx context.Context, id int64) error
+	IssueReset(ctx context.Context, id int64) (ResetToken, error)
+	Login(ctx context.Context, req LoginRequest) (Session, error)
+	ChangePassword(ctx context.Context, session string, req ChangePasswordRequest) (Session, error)
+	ResetPassword(ctx context.Context, req ResetPasswordRequest) error
+}
+
+func NewController(server *web.Server, service Srv) *Controller {
+	return &Controller{server: server, service: service}
+}
+
+// функция для регистрации маршрутов: вход и смена пароля - "/auth/...", вне "/api/v1" и без аутентификации API;
+// управление учётными записями - "/api/v1/accounts" с разрешениями accounts:read и accounts:write
+func (contr *Controller) RegisterRoutes() {
+	contr.server.App.Post("/auth/login", contr.Login)
+	contr.server.App.Post("/auth/logout", contr.Logout)
+	contr.server.App.Post("/auth/password", contr.ChangePassword)
+	contr.server.App.Post("/auth/password/reset", contr.ResetPassword)
+
+	contr.server.GroupApiV1.Post("/accounts", contr.Create)
+	contr.server.GroupApiV1.Get("/accounts", contr.GetAll)
+	contr.server.GroupApiV1.Get("/accounts/id/:id", contr.FindById)
+	contr.server.GroupApiV1.Delete("/accounts/id/:id", contr.Delete)
+	contr.server.GroupApiV1.Post("/accounts/id/:id/unlock", contr.Unlock)
+	contr.server.GroupApiV1.Post("/accounts/id/:id/reset", contr.IssueReset)
+}
+
+// Login хендлер POST-запроса "/auth/login": сессия в ответе и в cookie idm_session
+func (contr *Controller) Login(ctx *fiber.Ctx) {
+	var req LoginRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+		return
+	}
+
+	session, err := contr.service.Login(web.Context(ctx), req)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	setSessionCookie(ctx, session.Token, session.ExpiresAt)
+	if err = common.OkResponse(ctx, session); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning session")
+		return
+	}
+}
+
+// Logout хендлер POST-запроса "/auth/logout": удаление cookie сессии; сам токен действует до истечения срока
+func (contr *Controller) Logout(ctx *fiber.Ctx) {
+	setSessionCookie(ctx, "", time.Unix(0, 0))
+	if err := common.ResponseWithoutData(ctx); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning result logout")
+		return
+	}
+}
+
+// ChangePassword хендлер POST-запроса "/auth/password": смена пароля работником с действующей сессией
+func (contr *Controller) ChangePassword(ctx *fiber.Ctx) {
+	var req ChangePasswordRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+		return
+	}
+
+	session, err := contr.service.ChangePassword(web.Context(ctx), sessionToken(ctx), req)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	setSessionCookie(ctx, session.Token, session.ExpiresAt)
+	if err = common.OkResponse(ctx, session); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning session")
+		return
+	}
+}
+
+// ResetPassword хендлер POST-запроса "/auth/password/reset": установка пароля по токену сброса
+func (contr *Controller) ResetPassword(ctx *fiber.Ctx) {
+	var req ResetPasswordRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := contr.service.ResetPassword(web.Context(ctx), req); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	if err := common.ResponseWithoutData(ctx); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning result reset password")
+		return
+	}
+}
+
+// Create хендлер POST-запроса "/api/v1/accounts"
+func (contr *Controller) Create(ctx *fiber.Ctx) {
+	var req Request
+	if err := ctx.BodyParser(&req); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+		return
+	}
+
+	created, err := contr.service.Create(web.Context(ctx), req)
+	if err != nil {
+		crud.WriteError(ctx, err)
+		return
+	}
+
+	if err = common.OkResponse(ctx, created); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning created account")
+		return
+	}
+}
+
+// GetAll хендлер GET-запроса "/api/v1/accounts"
+func (contr *Controller) GetAll(ctx *fiber.Ctx) {
+	responses, err := contr.service.GetAll(web.Context(ctx))
+	if err != nil {
+		crud.WriteError(ctx, err)
+		return
+	}
+
+	if err = common.OkResponse(ctx, responses); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning all accounts")
+		return
+	}
+}
+
+// FindById хендлер GET-запроса "/api/v1/accounts/id/:id"
+func (contr *Controller) FindById(ctx *fiber.Ctx) {
+	id, ok := crud.ParamId(ctx)
+	if !ok {
+		return
+	}
+
+	response, err := contr.service.FindById(web.Context(ctx), id)
+	if err != nil {
+		crud.WriteError(ctx, err)
+		return
+	}
+
+	if err = common.OkResponse(ctx, response); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning found account")
+		return
+	}
+}
+
+// Delete хендлер DELETE-запроса "/api/v1/accounts/id/:id"
+func (contr *Controller) Delete(ctx *fiber.Ctx) {
+	id, ok := crud.ParamId(ctx)
+	if !ok {
+		return
+	}
+
+	if err := contr.service.Delete(web.Context(ctx), id); err != nil {
+		crud.WriteError(ctx, err)
+		return
+	}
+
+	if err := common.ResponseWithoutData(ctx); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning result delete account")
+		return
+	}
+}
+
+// Unlock хендлер POST-запроса "/api/v1/accounts/id/:id/unlock"
+func (contr *Controller) Unlock(ctx *fiber.Ctx) {
+	id, ok := crud.ParamId(ctx)
+	if !ok {
+		return
+	}
+
+	if err := contr.service.Unlock(web.Context(ctx), id); err != nil {
+		crud.WriteError(ctx, err)
+		return
+	}
+
+	if err := common.ResponseWithoutData(ctx); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning result unlock account")
+		return
+	}
+}
+
+// IssueReset хендлер POST-запроса "/api/v1/accounts/id/:id/reset": токен сброса пароля, который передаётся работнику
+func (contr *Controller) IssueReset(ctx *fiber.Ctx) {
+	id, ok := crud.ParamId(ctx)
+	if !ok {
+		return
+	}
+
+	reset, err := contr.service.IssueReset(web.Context(ctx), id)
+	if err != nil {
+		crud.WriteError(ctx, err)
+		return
+	}
+
+	if err = common.OkResponse(ctx, reset); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning password reset token")
+		return
+	}
+}
+
+// writeError ответ на ошибку входа: неверные учётные данные - 401, блокировка - 429 с Retry-After
+func writeError(ctx *fiber.Ctx, err error) {
+	var locked LockedError
+	switch {
+	case errors.As(err, &locked):
+		var seconds = int64(time.Until(locked.Until).Seconds()) + 1
+		ctx.Set(fiber.HeaderRetryAfter, strconv.FormatInt(seconds, 10))
+		_ = common.ErrResponse(ctx, fiber.StatusTooManyRequests, err.Error())
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		_ = common.ErrResponse(ctx, fiber.StatusUnauthorized, err.Error())
+	default:
+		crud.WriteError(ctx, err)
+	}
+}
+
+// sessionToken сессия из "Authorization: Bearer" или cookie idm_session
+func sessionToken(ctx *fiber.Ctx) string {
+	if scheme, token, ok := strings.Cut(ctx.Get(fiber.HeaderAuthorization), " "); ok && strings.EqualFold(scheme, auth.SchemeBearer) {
+		return token
+	}
+	return ctx.Cookies(auth.SessionCookie)
+}
+
+// setSessionCookie cookie недоступна скриптам страницы и отправляется только с переходами на сервис (SameSite=Lax)
+func setSessionCookie(ctx *fiber.Ctx, token string, expires time.Time) {
+	ctx.Cookie(&fiber.Cookie{
+		Name:     auth.SessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   ctx.Secure(),
+		HTTPOnly: true,
+		SameSite: "Lax",
+	})
+}
@@ -0,0 +1,96 @@
+package account
+
+import "time"
+
+// Entity учётная запись работника для входа по паролю
+type Entity struct {
+	Id         int64  `db:"id"`
+	EmployeeId int64  `db:"employee_id"`
+	Username   string `db:"username"`
+	// хеш пароля в формате PHC, см. HashPassword
+	PasswordHash string `db:"password_hash"`
+	// неудачные попытки входа подряд; при достижении Policy.MaxAttempts учётная запись блокируется
+	FailedAttempts int        `db:"failed_attempts"`
+	LockedUntil    *time.Time `db:"locked_until"`
+	// время смены пароля: сессии, выданные раньше, не принимаются (см. Service.VerifySession)
+	PasswordChanged time.Time  `db:"password_changed_at"`
+	LastLoginAt     *time.Time `db:"last_login_at"`
+	Create          time.Time  `db:"create_at"`
+	Update          time.Time  `db:"update_at"`
+}
+
+// Locked учётная запись заблокирована в момент now
+func (e *Entity) Locked(now time.Time) bool {
+	return e.LockedUntil != nil && now.Before(*e.LockedUntil)
+}
+
+// ResetEntity токен сброса пароля: сам токен не хранится, только его SHA-256
+type ResetEntity struct {
+	Hash      string    `db:"hash"`
+	AccountId int64     `db:"account_id"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+type Request struct {
+	EmployeeId int64  `json:"employee_id" validate:"required,gt=0"`
+	Username   string `json:"username" validate:"required,min=3,max=64"`
+	// начальный пароль; пустой - работник задаёт пароль сам по токену сброса из ответа
+	Password string `json:"password"`
+}
+
+type LoginRequest struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// ChangePasswordRequest смена пароля работником: нужен текущий пароль
+type ChangePasswordRequest struct {
+	Password    string `json:"password" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+// ResetPasswordRequest установка пароля по токену сброса
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+type Response struct {
+	Id          int64      `json:"id"`
+	EmployeeId  int64      `json:"employee_id"`
+	Username    string     `json:"username"`
+	LockedUntil *time.Time `json:"locked_until"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	Create      time.Time  `json:"create_at"`
+	Update      time.Time  `json:"update_at"`
+}
+
+// Created созданная учётная запись; ResetToken возвращается, если пароль не был задан
+type Created struct {
+	Response
+	ResetToken *ResetToken `json:"reset_token,omitempty"`
+}
+
+// ResetToken выпущенный токен сброса пароля: возвращается только в ответе, повторно получить его нельзя
+type ResetToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Session выданная при входе сессия: JWT для "Authorization: Bearer" или cookie idm_session
+type Session struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (e *Entity) toResponse() Response {
+	return Response{
+		Id:          e.Id,
+		EmployeeId:  e.EmployeeId,
+		Username:    e.Username,
+		LockedUntil: e.LockedUntil,
+		LastLoginAt: e.LastLoginAt,
+		Create:      e.Create,
+		Update:      e.Update,
+	}
+}
@@ -0,0 +1,144 @@
+package account
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"idm/inner/common"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// максимальная длина пароля: хеширование длинного пароля не должно стать способом нагрузить сервис
+const maxPasswordLength = 128
+
+// hashParams параметры Argon2id
+type hashParams struct {
+	memory  uint32 // КиБ
+	time    uint32
+	threads uint8
+	saltLen int
+	keyLen  uint32
+}
+
+// параметры новых хешей (RFC 9106, раздел 4, второй рекомендованный вариант);
+// хеши с другими параметрами пересчитываются при следующем входе
+var currentParams = hashParams{memory: 64 * 1024, time: 3, threads: 4, saltLen: 16, keyLen: 32}
+
+// HashPassword хеш Argon2id в формате PHC: $argon2id$v=19$m=65536,t=3,p=4$<соль>$<хеш>
+func HashPassword(password string) (string, error) {
+	var salt = make([]byte, currentParams.saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("error generating salt: %w", err)
+	}
+	var key = argon2.IDKey([]byte(password), salt, currentParams.time, currentParams.memory, currentParams.threads, currentParams.keyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version,
+		currentParams.memory, currentParams.time, currentParams.threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifyPassword проверка пароля по хешу Argon2id или bcrypt (учётные записи, перенесённые из других систем);
+// rehash - хеш устарел и его нужно пересчитать с текущими параметрами
+func VerifyPassword(hash string, password string) (ok bool, rehash bool, err error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return verifyArgon2(hash, password)
+	case strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$"):
+		err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		return err == nil, true, err
+	case hash == "":
+		// пароль ещё не задан: вход возможен только после сброса пароля
+		return false, false, nil
+	default:
+		return false, false, errors.New("unknown password hash format")
+	}
+}
+
+func verifyArgon2(hash string, password string) (bool, bool, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", соль, хеш
+	var parts = strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, false, errors.New("invalid argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+	var params hashParams
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return false, false, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, false, fmt.Errorf("invalid argon2id hash: %w", err)
+	}
+	params.saltLen, params.keyLen = len(salt), uint32(len(expected))
+
+	var key = argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, params.keyLen)
+	if subtle.ConstantTimeCompare(key, expected) != 1 {
+		return false, false, nil
+	}
+	return true, params != currentParams, nil
+}
+
+// dummyHash хеш для проверки пароля неизвестного пользователя: ответ на вход с неизвестным именем
+// занимает столько же времени, сколько и с известным, и не выдаёт существование учётной записи
+var dummyHash = sync.OnceValue(func() string {
+	hash, _ := HashPassword("dummy password")
+	return hash
+})
+
+// Policy правила паролей и входа
+type Policy struct {
+	// минимальная длина пароля в символах
+	MinLength int
+	// неудачных попыток входа подряд до блокировки и длительность блокировки
+	MaxAttempts int
+	Lockout     time.Duration
+	// время жизни сессии, выдаваемой при входе
+	SessionTTL time.Duration
+	// время жизни токена сброса пароля
+	ResetTTL time.Duration
+}
+
+// CheckPassword проверка пароля на соответствие политике: длина, буквы и цифры или другие символы,
+// пароль не содержит имя пользователя
+func (policy Policy) CheckPassword(username string, password string) error {
+	var length = utf8.RuneCountInString(password)
+	if length < policy.MinLength {
+		return common.RequestValidationError{Message: fmt.Sprintf("password must be at least %d characters long", policy.MinLength)}
+	}
+	if length > maxPasswordLength {
+		return common.RequestValidationError{Message: fmt.Sprintf("password must be at most %d characters long", maxPasswordLength)}
+	}
+	var letters, others bool
+	for _, r := range password {
+		if unicode.IsLetter(r) {
+			letters = true
+		} else if !unicode.IsSpace(r) {
+			others = true
+		}
+	}
+	if !letters || !others {
+		return common.RequestValidationError{Message: "password must contain letters and digits or symbols"}
+	}
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return common.RequestValidationError{Message: "password must not contain username"}
+	}
+	return nil
+}
@@ -0,0 +1,451 @@
+package account
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"idm/inner/auth"
+	"idm/inner/common"
+	"idm/inner/employee"
+	"idm/inner/tracing"
+	"log/slog"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// LockedError учётная запись заблокирована после неудачных попыток входа подряд
+type LockedError struct {
+	Until time.Time
+}
+
+func (err LockedError) Error() string {
+	return "account is locked until " + err.Until.Format(time.RFC3339)
+}
+
+// errInvalidLogin одна ошибка для неизвестного имени, неверного пароля и неактивного работника:
+// ответ на вход не выдаёт, какая из проверок не прошла
+var errInvalidLogin = fmt.Errorf("%w: invalid username or password", auth.ErrInvalidCredentials)
+
+// Employees работники, которым принадлежат учётные записи
+type Employees interface {
+	FindById(ctx context.Context, id int64) (employee.Entity, error)
+}
+
+type Validator interface {
+	Validate(request any) error
+}
+
+// Service учётные записи работников: вход по паролю, смена и сброс пароля.
+// Вход возможен, пока работник активен: уволенный работник не входит и не продлевает сессии.
+type Service struct {
+	store     Store
+	employees Employees
+	valid     Validator
+	// ключ подписи сессий (AUTH_SIGNING_KEY) и проверка подписи
+	signingKey string
+	sessions   *auth.TokenVerifier
+	policy     Policy
+	// текущее время, подменяется в тестах
+	now func() time.Time
+}
+
+func NewService(store Store, employees Employees, validator Validator, signingKey string, policy Policy) *Service {
+	return &Service{
+		store:      store,
+		employees:  employees,
+		valid:      validator,
+		signingKey: signingKey,
+		sessions:   auth.NewTokenVerifier(signingKey),
+		policy:     policy,
+		now:        time.Now,
+	}
+}
+
+// Create создание учётной записи работника: без пароля в ответе возвращается токен сброса,
+// по которому работник задаёт пароль сам
+func (serv *Service) Create(ctx context.Context, req Request) (created Created, err error) {
+	ctx, span := tracing.Start(ctx, "account.Create")
+	defer tracing.End(span, &err)
+
+	if err = tracing.Validate(ctx, serv.valid, req); err != nil {
+		return Created{}, common.RequestValidationError{Message: err.Error()}
+	}
+	found, err := serv.employees.FindById(ctx, req.EmployeeId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Created{}, common.NotFoundError{Message: fmt.Sprintf("employee with id %d not found", req.EmployeeId)}
+	}
+	if err != nil {
+		return Created{}, common.DbOperationError{Message: fmt.Errorf("error finding employee: %w", err).Error()}
+	}
+	if !found.Active {
+		return Created{}, common.RequestValidationError{Message: fmt.Sprintf("employee with id %d is not active", req.EmployeeId)}
+	}
+
+	var now = serv.now().UTC()
+	var account = Entity{EmployeeId: req.EmployeeId, Username: req.Username, PasswordChanged: now, Create: now, Update: now}
+	if req.Password != "" {
+		if err = serv.policy.CheckPassword(req.Username, req.Password); err != nil {
+			return Created{}, err
+		}
+		if account.PasswordHash, err = HashPassword(req.Password); err != nil {
+			return Created{}, err
+		}
+	}
+	account.Id, err = serv.store.Create(ctx, &account)
+	if errors.As(err, &common.AlreadyExistsError{}) {
+		return Created{}, common.AlreadyExistsError{Message: fmt.Sprintf("account with username %s or for employee %d already exists", req.Username, req.EmployeeId)}
+	}
+	if err != nil {
+		return Created{}, common.DbOperationError{Message: fmt.Errorf("error creating account: %w", err).Error()}
+	}
+
+	created.Response = account.toResponse()
+	if req.Password == "" {
+		reset, err := serv.issueReset(ctx, account.Id, now)
+		if err != nil {
+			return Created{}, err
+		}
+		created.ResetToken = &reset
+	}
+	return created, nil
+}
+
+func (serv *Service) GetAll(ctx context.Context) (responses []Response, err error) {
+	ctx, span := tracing.Start(ctx, "account.GetAll")
+	defer tracing.End(span, &err)
+
+	accounts, err := serv.store.FindAll(ctx)
+	if err != nil {
+		return nil, common.DbOperationError{Message: fmt.Errorf("error finding accounts: %w", err).Error()}
+	}
+	responses = make([]Response, len(accounts))
+	for i := range accounts {
+		responses[i] = accounts[i].toResponse()
+	}
+	return responses, nil
+}
+
+func (serv *Service) FindById(ctx context.Context, id int64) (response Response, err error) {
+	ctx, span := tracing.Start(ctx, "account.FindById")
+	defer tracing.End(span, &err)
+
+	account, err := serv.find(ctx, id)
+	if err != nil {
+		return Response{}, err
+	}
+	return account.toResponse(), nil
+}
+
+func (serv *Service) Delete(ctx context.Context, id int64) (err error) {
+	ctx, span := tracing.Start(ctx, "account.Delete")
+	defer tracing.End(span, &err)
+
+	deleted, err := serv.store.Delete(ctx, id)
+	if err != nil {
+		return common.DbOperationError{Message: fmt.Errorf("error deleting account: %w", err).Error()}
+	}
+	if !deleted {
+		return common.NotFoundError{Message: fmt.Sprintf("account with id %d not found", id)}
+	}
+	return nil
+}
+
+// Unlock снятие блокировки после неудачных попыток входа
+func (serv *Service) Unlock(ctx context.Context, id int64) (err error) {
+	ctx, span := tracing.Start(ctx, "account.Unlock")
+	defer tracing.End(span, &err)
+
+	found, err := serv.store.Unlock(ctx, id, serv.now().UTC())
+	if err != nil {
+		return common.DbOperationError{Message: fmt.Errorf("error unlocking account: %w", err).Error()}
+	}
+	if !found {
+		return common.NotFoundError{Message: fmt.Sprintf("account with id %d not found", id)}
+	}
+	return nil
+}
+
+// IssueReset выпуск токена сброса пароля: токен возвращается только в ответе и передаётся работнику
+func (serv *Service) IssueReset(ctx context.Context, id int64) (reset ResetToken, err error) {
+	ctx, span := tracing.Start(ctx, "account.IssueReset")
+	defer tracing.End(span, &err)
+
+	if _, err = serv.find(ctx, id); err != nil {
+		return ResetToken{}, err
+	}
+	return serv.issueReset(ctx, id, serv.now().UTC())
+}
+
+func (serv *Service) issueReset(ctx context.Context, id int64, now time.Time) (ResetToken, error) {
+	token, err := randomString(32)
+	if err != nil {
+		return ResetToken{}, err
+	}
+	var reset = ResetEntity{Hash: hash(token), AccountId: id, ExpiresAt: now.Add(serv.policy.ResetTTL)}
+	if err = serv.store.SaveReset(ctx, &reset); err != nil {
+		return ResetToken{}, common.DbOperationError{Message: fmt.Errorf("error saving password reset token: %w", err).Error()}
+	}
+	return ResetToken{Token: token, ExpiresAt: reset.ExpiresAt}, nil
+}
+
+// Login вход по имени пользователя и паролю: сессия для OIDC-провайдера и смены пароля.
+// Неудачные попытки подряд блокируют учётную запись на Policy.Lockout. О блокировке сообщается только
+// после верного пароля: с неверным паролем ответ тот же, что и для неизвестного имени, и не выдаёт,
+// что учётная запись существует.
+func (serv *Service) Login(ctx context.Context, req LoginRequest) (session Session, err error) {
+	ctx, span := tracing.Start(ctx, "account.Login")
+	defer tracing.End(span, &err)
+
+	if err = tracing.Validate(ctx, serv.valid, req); err != nil {
+		return Session{}, common.RequestValidationError{Message: err.Error()}
+	}
+	var now = serv.now().UTC()
+	account, err := serv.store.FindByUsername(ctx, req.Username)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, _, _ = VerifyPassword(dummyHash(), req.Password)
+		return Session{}, errInvalidLogin
+	}
+	if err != nil {
+		return Session{}, common.DbOperationError{Message: fmt.Errorf("error finding account: %w", err).Error()}
+	}
+
+	if err = serv.checkPassword(ctx, &account, req.Password, now); err != nil {
+		return Session{}, err
+	}
+	if err = serv.checkEmployee(ctx, account.EmployeeId); err != nil {
+		return Session{}, err
+	}
+	if err = serv.store.RecordLogin(ctx, account.Id, now); err != nil {
+		return Session{}, common.DbOperationError{Message: fmt.Errorf("error recording login: %w", err).Error()}
+	}
+	return serv.issueSession(account.EmployeeId, now)
+}
+
+// checkPassword проверка пароля с учётом неудачных попыток; устаревший хеш пересчитывается.
+// Блокировка возвращается только при верном пароле, во время блокировки неудачные попытки не учитываются.
+func (serv *Service) checkPassword(ctx context.Context, account *Entity, password string, now time.Time) error {
+	ok, rehash, err := VerifyPassword(account.PasswordHash, password)
+	if err != nil {
+		return fmt.Errorf("error verifying password of account %d: %w", account.Id, err)
+	}
+	if account.Locked(now) {
+		if !ok {
+			return errInvalidLogin
+		}
+		return LockedError{Until: *account.LockedUntil}
+	}
+	if !ok {
+		locked, err := serv.store.RecordFailure(ctx, account.Id, serv.policy.MaxAttempts, now.Add(serv.policy.Lockout))
+		if err != nil {
+			return common.DbOperationError{Message: fmt.Errorf("error recording failed login: %w", err).Error()}
+		}
+		if locked {
+			slog.WarnContext(ctx, "account locked after failed logins", "account_id", account.Id)
+		}
+		return errInvalidLogin
+	}
+	if rehash {
+		newHash, err := HashPassword(password)
+		if err == nil {
+			err = serv.store.SetPassword(ctx, account.Id, newHash, nil)
+		}
+		// вход не зависит от пересчёта хеша: попробуем при следующем входе
+		if err != nil {
+			slog.WarnContext(ctx, "error rehashing password", "account_id", account.Id, "error", err)
+		}
+	}
+	return nil
+}
+
+// checkEmployee работник учётной записи существует и активен
+func (serv *Service) checkEmployee(ctx context.Context, employeeId int64) error {
+	found, err := serv.employees.FindById(ctx, employeeId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errInvalidLogin
+	}
+	if err != nil {
+		return common.DbOperationError{Message: fmt.Errorf("error finding employee: %w", err).Error()}
+	}
+	if !found.Active {
+		return errInvalidLogin
+	}
+	return nil
+}
+
+func (serv *Service) issueSession(employeeId int64, now time.Time) (Session, error) {
+	var expiresAt = now.Add(serv.policy.SessionTTL)
+	token, err := auth.SignToken(serv.signingKey, auth.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   auth.EmployeeSubject(employeeId),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}})
+	if err != nil {
+		return Session{}, fmt.Errorf("error signing session: %w", err)
+	}
+	return Session{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// Verify проверка сессии работника (oidc.Sessions): кроме подписи и срока действия, сессия должна быть
+// выдана после последней смены пароля, а работник - оставаться активным
+func (serv *Service) Verify(ctx context.Context, token string) (auth.TokenClaims, error) {
+	claims, err := serv.sessions.Verify(ctx, token)
+	if err != nil {
+		return auth.TokenClaims{}, err
+	}
+	employeeId, ok := auth.EmployeeId(claims.Subject)
+	if !ok || claims.IssuedAt == nil {
+		return auth.TokenClaims{}, fmt.Errorf("%w: token is not an employee session", auth.ErrInvalidCredentials)
+	}
+	account, err := serv.store.FindByEmployeeId(ctx, employeeId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return auth.TokenClaims{}, fmt.Errorf("%w: employee has no account", auth.ErrInvalidCredentials)
+	}
+	if err != nil {
+		return auth.TokenClaims{}, fmt.Errorf("error finding account: %w", err)
+	}
+	// iat хранится с точностью до секунды
+	if claims.IssuedAt.Before(account.PasswordChanged.Truncate(time.Second)) {
+		return auth.TokenClaims{}, fmt.Errorf("%w: session was issued before password change", auth.ErrInvalidCredentials)
+	}
+	if err = serv.checkEmployee(ctx, employeeId); err != nil {
+		return auth.TokenClaims{}, err
+	}
+	return claims, nil
+}
+
+// ChangePassword смена пароля работником с действующей сессией: прежние сессии и токены сброса перестают действовать,
+// в ответе новая сессия
+func (serv *Service) ChangePassword(ctx context.Context, session string, req ChangePasswordRequest) (created Session, err error) {
+	ctx, span := tracing.Start(ctx, "account.ChangePassword")
+	defer tracing.End(span, &err)
+
+	if err = tracing.Validate(ctx, serv.valid, req); err != nil {
+		return Session{}, common.RequestValidationError{Message: err.Error()}
+	}
+	claims, err := serv.Verify(ctx, session)
+	if err != nil {
+		return Session{}, err
+	}
+	employeeId, _ := auth.EmployeeId(claims.Subject)
+	account, err := serv.store.FindByEmployeeId(ctx, employeeId)
+	if err != nil {
+		return Session{}, common.DbOperationError{Message: fmt.Errorf("error finding account: %w", err).Error()}
+	}
+
+	var now = serv.now().UTC()
+	if err = serv.checkPassword(ctx, &account, req.Password, now); err != nil {
+		return Session{}, err
+	}
+	if req.NewPassword == req.Password {
+		return Session{}, common.RequestValidationError{Message: "new password must differ from the current one"}
+	}
+	if err = serv.setPassword(ctx, &account, req.NewPassword, now); err != nil {
+		return Session{}, err
+	}
+	return serv.issueSession(employeeId, now)
+}
+
+// ResetPassword установка пароля по токену сброса; токен одноразовый, но пароль,
+// не прошедший проверку политики, токен не расходует
+func (serv *Service) ResetPassword(ctx context.Context, req ResetPasswordRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "account.ResetPassword")
+	defer tracing.End(span, &err)
+
+	if err = tracing.Validate(ctx, serv.valid, req); err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	var invalidToken = common.RequestValidationError{Message: "password reset token is invalid or expired"}
+	var now = serv.now().UTC()
+	reset, err := serv.store.FindReset(ctx, hash(req.Token))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !now.Before(reset.ExpiresAt)) {
+		return invalidToken
+	}
+	if err != nil {
+		return common.DbOperationError{Message: fmt.Errorf("error finding password reset token: %w", err).Error()}
+	}
+	account, err := serv.store.FindById(ctx, reset.AccountId)
+	if err != nil {
+		return common.DbOperationError{Message: fmt.Errorf("error finding account: %w", err).Error()}
+	}
+	if err = serv.checkEmployee(ctx, account.EmployeeId); err != nil {
+		return invalidToken
+	}
+	if err = serv.policy.CheckPassword(account.Username, req.NewPassword); err != nil {
+		return err
+	}
+
+	// из одновременных запросов с одним токеном пароль меняет только один
+	_, err = serv.store.ConsumeReset(ctx, reset.Hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return invalidToken
+	}
+	if err != nil {
+		return common.DbOperationError{Message: fmt.Errorf("error deleting password reset token: %w", err).Error()}
+	}
+	return serv.setPassword(ctx, &account, req.NewPassword, now)
+}
+
+// setPassword новый пароль по политике: блокировка снимается, прежние сессии и токены сброса перестают действовать
+func (serv *Service) setPassword(ctx context.Context, account *Entity, password string, now time.Time) error {
+	if err := serv.policy.CheckPassword(account.Username, password); err != nil {
+		return err
+	}
+	newHash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err = serv.store.SetPassword(ctx, account.Id, newHash, &now); err != nil {
+		return common.DbOperationError{Message: fmt.Errorf("error saving password: %w", err).Error()}
+	}
+	if err = serv.store.DeleteResets(ctx, account.Id); err != nil {
+		return common.DbOperationError{Message: fmt.Errorf("error deleting password reset tokens: %w", err).Error()}
+	}
+	return nil
+}
+
+func (serv *Service) find(ctx context.Context, id int64) (Entity, error) {
+	account, err := serv.store.FindById(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Entity{}, common.NotFoundError{Message: fmt.Sprintf("account with id %d not found", id)}
+	}
+	if err != nil {
+		return Entity{}, common.DbOperationError{Message: fmt.Errorf("error finding account: %w", err).Error()}
+	}
+	return account, nil
+}
+
+// RunCleanup периодическое удаление истёкших токенов сброса пароля до отмены контекста
+func RunCleanup(ctx context.Context, store Store, interval time.Duration) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := store.DeleteExpiredResets(ctx, time.Now().UTC()); err != nil {
+				slog.ErrorContext(ctx, "error deleting expired password reset tokens", "error", err)
+			}
+		}
+	}
+}
+
+func randomString(size int) (string, error) {
+	var random = make([]byte, size)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("error generating random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+func hash(value string) string {
+	var sum = sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
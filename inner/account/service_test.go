@@ -0,0 +1,283 @@
+package account
+
+import (
+	"context"
+	"idm/inner/auth"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/memory"
+	"idm/inner/migration"
+	"idm/inner/validator"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testSigningKey = "session-signing-key"
+
+type employeeRepo interface {
+	Employees
+	Save(ctx context.Context, entity *employee.Entity) (int64, error)
+	SetActive(ctx context.Context, ids []int64, active bool) error
+}
+
+func newMemoryStores(t *testing.T) (Store, employeeRepo) {
+	return NewMemoryStore(), employee.NewEmployeeMemoryRepository(memory.NewDB())
+}
+
+// newDbStores учётные записи и работники в одной базе данных: account ссылается на employee
+func newDbStores(t *testing.T) (Store, employeeRepo) {
+	db, err := database.ConnectDbWithCfg(common.Config{DbDriverName: database.DriverSQLite, Dsn: filepath.Join(t.TempDir(), "idm.db")})
+	if err != nil {
+		t.Fatalf("error connecting to db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err = migration.OnStartup(context.Background(), db, common.MigrationsAuto); err != nil {
+		t.Fatalf("error applying migrations: %v", err)
+	}
+	return NewDbStore(db), employee.NewEmployeeRepository(db)
+}
+
+// fastHashing дешёвые параметры Argon2id на время теста: с параметрами по умолчанию каждый вход занимает заметное время
+func fastHashing(t *testing.T) {
+	var saved = currentParams
+	currentParams = hashParams{memory: 64, time: 1, threads: 1, saltLen: 16, keyLen: 32}
+	t.Cleanup(func() { currentParams = saved })
+}
+
+var testPolicy = Policy{MinLength: 12, MaxAttempts: 3, Lockout: 15 * time.Minute, SessionTTL: 8 * time.Hour, ResetTTL: time.Hour}
+
+func TestPassword(t *testing.T) {
+	a := assert.New(t)
+
+	hash, err := HashPassword("correct horse 42")
+	a.Nil(err)
+	a.True(strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=4$"))
+	ok, rehash, err := VerifyPassword(hash, "correct horse 42")
+	a.Nil(err)
+	a.True(ok)
+	a.False(rehash)
+	ok, _, err = VerifyPassword(hash, "correct horse 43")
+	a.Nil(err)
+	a.False(ok)
+
+	// хеш перенесённой учётной записи принимается и требует пересчёта
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("correct horse 42"), bcrypt.MinCost)
+	ok, rehash, err = VerifyPassword(string(legacy), "correct horse 42")
+	a.Nil(err)
+	a.True(ok)
+	a.True(rehash)
+
+	// хеш с прежними параметрами тоже требует пересчёта
+	fastHashing(t)
+	ok, rehash, err = VerifyPassword(hash, "correct horse 42")
+	a.Nil(err)
+	a.True(ok)
+	a.True(rehash)
+
+	ok, _, err = VerifyPassword("", "")
+	a.Nil(err)
+	a.False(ok)
+	_, _, err = VerifyPassword("plain", "plain")
+	a.NotNil(err)
+
+	a.Nil(testPolicy.CheckPassword("ivan", "correct horse 42"))
+	a.ErrorContains(testPolicy.CheckPassword("ivan", "short 1"), "at least 12 characters")
+	a.ErrorContains(testPolicy.CheckPassword("ivan", "onlylettershere"), "letters and digits")
+	a.ErrorContains(testPolicy.CheckPassword("ivan", "123456789012"), "letters and digits")
+	a.ErrorContains(testPolicy.CheckPassword("ivan", "Ivan-2026-secret"), "must not contain username")
+	a.ErrorContains(testPolicy.CheckPassword("ivan", strings.Repeat("a1", 65)), "at most 128")
+}
+
+func TestService(t *testing.T) {
+	var stores = map[string]func(t *testing.T) (Store, employeeRepo){
+		"memory":   newMemoryStores,
+		"database": newDbStores,
+	}
+	fastHashing(t)
+	for name, newStores := range stores {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+			var ctx = context.Background()
+			var now = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+			var newService = func(t *testing.T) (*Service, employeeRepo) {
+				store, employees := newStores(t)
+				var serv = NewService(store, employees, validator.NewRequestValidator(), testSigningKey, testPolicy)
+				serv.now = func() time.Time { return now }
+				return serv, employees
+			}
+			var newEmployee = func(t *testing.T, employees employeeRepo) int64 {
+				id, err := employees.Save(ctx, &employee.Entity{Name: t.Name()})
+				if err != nil {
+					t.Fatalf("error saving employee: %v", err)
+				}
+				return id
+			}
+			const password = "correct horse 42"
+
+			t.Run("should log in and verify session", func(t *testing.T) {
+				var serv, employees = newService(t)
+				var employeeId = newEmployee(t, employees)
+				created, err := serv.Create(ctx, Request{EmployeeId: employeeId, Username: "ipetrov", Password: password})
+				a.Nil(err)
+				a.Nil(created.ResetToken)
+
+				session, err := serv.Login(ctx, LoginRequest{Username: "IPetrov", Password: password})
+				a.Nil(err)
+				a.True(session.ExpiresAt.Equal(now.Add(testPolicy.SessionTTL)))
+				claims, err := serv.Verify(ctx, session.Token)
+				a.Nil(err)
+				a.Equal(auth.EmployeeSubject(employeeId), claims.Subject)
+
+				found, err := serv.FindById(ctx, created.Id)
+				a.Nil(err)
+				a.NotNil(found.LastLoginAt)
+
+				_, err = serv.Login(ctx, LoginRequest{Username: "ipetrov", Password: "wrong password 1"})
+				a.ErrorIs(err, auth.ErrInvalidCredentials)
+				_, err = serv.Login(ctx, LoginRequest{Username: "unknown", Password: password})
+				a.ErrorIs(err, auth.ErrInvalidCredentials)
+			})
+
+			t.Run("should validate account", func(t *testing.T) {
+				var serv, employees = newService(t)
+				var employeeId = newEmployee(t, employees)
+
+				_, err := serv.Create(ctx, Request{EmployeeId: employeeId, Username: "ipetrov", Password: "short"})
+				a.ErrorAs(err, &common.RequestValidationError{})
+				_, err = serv.Create(ctx, Request{EmployeeId: employeeId + 100, Username: "ipetrov", Password: password})
+				a.ErrorAs(err, &common.NotFoundError{})
+
+				_, err = serv.Create(ctx, Request{EmployeeId: employeeId, Username: "ipetrov", Password: password})
+				a.Nil(err)
+				_, err = serv.Create(ctx, Request{EmployeeId: employeeId, Username: "ivan", Password: password})
+				a.ErrorAs(err, &common.AlreadyExistsError{})
+				other, err := employees.Save(ctx, &employee.Entity{Name: "Petr Ivanov"})
+				a.Nil(err)
+				_, err = serv.Create(ctx, Request{EmployeeId: other, Username: "IPETROV", Password: password})
+				a.ErrorAs(err, &common.AlreadyExistsError{})
+			})
+
+			t.Run("should lock account after failed attempts", func(t *testing.T) {
+				var serv, employees = newService(t)
+				created, err := serv.Create(ctx, Request{EmployeeId: newEmployee(t, employees), Username: "ipetrov", Password: password})
+				a.Nil(err)
+
+				var wrong = LoginRequest{Username: "ipetrov", Password: "wrong password 1"}
+				for range testPolicy.MaxAttempts - 1 {
+					_, err = serv.Login(ctx, wrong)
+					a.ErrorIs(err, auth.ErrInvalidCredentials)
+				}
+				// попытка, после которой учётная запись заблокирована, и попытки во время блокировки
+				// получают тот же ответ, что и неизвестное имя: блокировка не выдаёт существование учётной записи
+				_, err = serv.Login(ctx, wrong)
+				a.ErrorIs(err, auth.ErrInvalidCredentials)
+				a.NotErrorAs(err, &LockedError{})
+				_, err = serv.Login(ctx, wrong)
+				a.ErrorIs(err, auth.ErrInvalidCredentials)
+				a.NotErrorAs(err, &LockedError{})
+				// о блокировке сообщается только после верного пароля, сам вход не выполняется
+				_, err = serv.Login(ctx, LoginRequest{Username: "ipetrov", Password: password})
+				a.Equal(LockedError{Until: now.Add(testPolicy.Lockout)}, err)
+
+				now = now.Add(testPolicy.Lockout)
+				_, err = serv.Login(ctx, LoginRequest{Username: "ipetrov", Password: password})
+				a.Nil(err)
+
+				for range testPolicy.MaxAttempts {
+					_, _ = serv.Login(ctx, wrong)
+				}
+				_, err = serv.Login(ctx, LoginRequest{Username: "ipetrov", Password: password})
+				a.ErrorAs(err, &LockedError{})
+				a.Nil(serv.Unlock(ctx, created.Id))
+				_, err = serv.Login(ctx, LoginRequest{Username: "ipetrov", Password: password})
+				a.Nil(err)
+			})
+
+			t.Run("should set password by reset token once", func(t *testing.T) {
+				var serv, employees = newService(t)
+				created, err := serv.Create(ctx, Request{EmployeeId: newEmployee(t, employees), Username: "ipetrov"})
+				a.Nil(err)
+				a.NotNil(created.ResetToken)
+				a.True(created.ResetToken.ExpiresAt.Equal(now.Add(testPolicy.ResetTTL)))
+
+				// пароль ещё не задан
+				_, err = serv.Login(ctx, LoginRequest{Username: "ipetrov", Password: password})
+				a.ErrorIs(err, auth.ErrInvalidCredentials)
+
+				// пароль не по политике не расходует токен
+				var reset = ResetPasswordRequest{Token: created.ResetToken.Token, NewPassword: "weak"}
+				a.ErrorContains(serv.ResetPassword(ctx, reset), "at least 12 characters")
+				reset.NewPassword = password
+				a.Nil(serv.ResetPassword(ctx, reset))
+				a.ErrorAs(serv.ResetPassword(ctx, reset), &common.RequestValidationError{})
+				_, err = serv.Login(ctx, LoginRequest{Username: "ipetrov", Password: password})
+				a.Nil(err)
+
+				token, err := serv.IssueReset(ctx, created.Id)
+				a.Nil(err)
+				now = now.Add(testPolicy.ResetTTL)
+				a.ErrorContains(serv.ResetPassword(ctx, ResetPasswordRequest{Token: token.Token, NewPassword: "another secret 7"}), "invalid or expired")
+			})
+
+			t.Run("should invalidate sessions on password change", func(t *testing.T) {
+				var serv, employees = newService(t)
+				_, err := serv.Create(ctx, Request{EmployeeId: newEmployee(t, employees), Username: "ipetrov", Password: password})
+				a.Nil(err)
+				old, err := serv.Login(ctx, LoginRequest{Username: "ipetrov", Password: password})
+				a.Nil(err)
+
+				now = now.Add(time.Minute)
+				_, err = serv.ChangePassword(ctx, old.Token, ChangePasswordRequest{Password: "wrong password 1", NewPassword: "another secret 7"})
+				a.ErrorIs(err, auth.ErrInvalidCredentials)
+				_, err = serv.ChangePassword(ctx, old.Token, ChangePasswordRequest{Password: password, NewPassword: password})
+				a.ErrorAs(err, &common.RequestValidationError{})
+				session, err := serv.ChangePassword(ctx, old.Token, ChangePasswordRequest{Password: password, NewPassword: "another secret 7"})
+				a.Nil(err)
+
+				_, err = serv.Verify(ctx, old.Token)
+				a.ErrorIs(err, auth.ErrInvalidCredentials)
+				_, err = serv.Verify(ctx, session.Token)
+				a.Nil(err)
+				_, err = serv.Login(ctx, LoginRequest{Username: "ipetrov", Password: "another secret 7"})
+				a.Nil(err)
+			})
+
+			t.Run("should deny login to inactive employee", func(t *testing.T) {
+				var serv, employees = newService(t)
+				var employeeId = newEmployee(t, employees)
+				_, err := serv.Create(ctx, Request{EmployeeId: employeeId, Username: "ipetrov", Password: password})
+				a.Nil(err)
+				session, err := serv.Login(ctx, LoginRequest{Username: "ipetrov", Password: password})
+				a.Nil(err)
+
+				a.Nil(employees.SetActive(ctx, []int64{employeeId}, false))
+				_, err = serv.Login(ctx, LoginRequest{Username: "ipetrov", Password: password})
+				a.ErrorIs(err, auth.ErrInvalidCredentials)
+				_, err = serv.Verify(ctx, session.Token)
+				a.ErrorIs(err, auth.ErrInvalidCredentials)
+				_, err = serv.Create(ctx, Request{EmployeeId: employeeId, Username: "ivan", Password: password})
+				a.ErrorAs(err, &common.RequestValidationError{})
+			})
+
+			t.Run("should rehash migrated bcrypt password on login", func(t *testing.T) {
+				var serv, employees = newService(t)
+				created, err := serv.Create(ctx, Request{EmployeeId: newEmployee(t, employees), Username: "ipetrov", Password: password})
+				a.Nil(err)
+				legacy, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+				a.Nil(serv.store.SetPassword(ctx, created.Id, string(legacy), nil))
+
+				_, err = serv.Login(ctx, LoginRequest{Username: "ipetrov", Password: password})
+				a.Nil(err)
+				found, err := serv.store.FindById(ctx, created.Id)
+				a.Nil(err)
+				a.True(strings.HasPrefix(found.PasswordHash, "$argon2id$"))
+			})
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"idm/inner/common"
+	"idm/inner/database"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Store хранилище учётных записей и токенов сброса пароля.
+// Методы поиска одной записи возвращают sql.ErrNoRows, если запись не найдена.
+type Store interface {
+	Create(ctx context.Context, account *Entity) (id int64, err error)
+	FindAll(ctx context.Context) ([]Entity, error)
+	FindById(ctx context.Context, id int64) (Entity, error)
+	// FindByUsername поиск без учёта регистра
+	FindByUsername(ctx context.Context, username string) (Entity, error)
+	FindByEmployeeId(ctx context.Context, employeeId int64) (Entity, error)
+	// RecordFailure учёт неудачной попытки входа: после maxAttempts попыток подряд
+	// учётная запись блокируется до lockUntil, счётчик начинается заново
+	RecordFailure(ctx context.Context, id int64, maxAttempts int, lockUntil time.Time) (locked bool, err error)
+	// RecordLogin сброс счётчика неудачных попыток и время входа
+	RecordLogin(ctx context.Context, id int64, now time.Time) error
+	// SetPassword новый хеш пароля; changed - время смены пароля, nil - пароль не менялся (пересчёт хеша)
+	SetPassword(ctx context.Context, id int64, hash string, changed *time.Time) error
+	Unlock(ctx context.Context, id int64, now time.Time) (found bool, err error)
+	Delete(ctx context.Context, id int64) (deleted bool, err error)
+
+	SaveReset(ctx context.Context, reset *ResetEntity) error
+	FindReset(ctx context.Context, hash string) (ResetEntity, error)
+	// ConsumeReset удаляет токен и возвращает его: повторное использование токена получит sql.ErrNoRows
+	ConsumeReset(ctx context.Context, hash string) (ResetEntity, error)
+	// DeleteResets удаляет все токены учётной записи
+	DeleteResets(ctx context.Context, accountId int64) error
+	DeleteExpiredResets(ctx context.Context, now time.Time) (deleted int64, err error)
+}
+
+// DbStore хранение в таблицах account и password_reset
+type DbStore struct {
+	db *sqlx.DB
+}
+
+func NewDbStore(db *sqlx.DB) *DbStore {
+	return &DbStore{db: db}
+}
+
+// conn транзакция из контекста или подключение к базе данных
+func (store *DbStore) conn(ctx context.Context) database.Executor {
+	return database.ConnFor(ctx, store.db, "account")
+}
+
+func (store *DbStore) Create(ctx context.Context, account *Entity) (id int64, err error) {
+	query := `INSERT INTO account (employee_id, username, password_hash, password_changed_at, create_at, update_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	err = store.conn(ctx).GetContext(ctx, &id, query, account.EmployeeId, account.Username, account.PasswordHash,
+		account.PasswordChanged, account.Create, account.Update)
+	return id, database.TranslateError(err)
+}
+
+func (store *DbStore) FindAll(ctx context.Context) (accounts []Entity, err error) {
+	err = store.conn(ctx).SelectContext(ctx, &accounts, "SELECT * FROM account ORDER BY id")
+	return accounts, err
+}
+
+func (store *DbStore) FindById(ctx context.Context, id int64) (account Entity, err error) {
+	err = store.conn(ctx).GetContext(ctx, &account, "SELECT * FROM account WHERE id = $1", id)
+	return account, err
+}
+
+func (store *DbStore) FindByUsername(ctx context.Context, username string) (account Entity, err error) {
+	err = store.conn(ctx).GetContext(ctx, &account, "SELECT * FROM account WHERE LOWER(username) = LOWER($1)", username)
+	return account, err
+}
+
+func (store *DbStore) FindByEmployeeId(ctx context.Context, employeeId int64) (account Entity, err error) {
+	err = store.conn(ctx).GetContext(ctx, &account, "SELECT * FROM account WHERE employee_id = $1", employeeId)
+	return account, err
+}
+
+func (store *DbStore) RecordFailure(ctx context.Context, id int64, maxAttempts int, lockUntil time.Time) (bool, error) {
+	// одним запросом: одновременные неудачные попытки не теряются;
+	// в SET справа используется значение failed_attempts до изменения
+	query := `UPDATE account SET
+			failed_attempts = CASE WHEN failed_attempts + 1 >= $2 THEN 0 ELSE failed_attempts + 1 END,
+			locked_until = CASE WHEN failed_attempts + 1 >= $2 THEN $3 ELSE locked_until END
+		WHERE id = $1 RETURNING failed_attempts`
+	var attempts int
+	if err := store.conn(ctx).GetContext(ctx, &attempts, query, id, maxAttempts, lockUntil); err != nil {
+		return false, err
+	}
+	// после неудачной попытки счётчик обнуляется, только если учётная запись заблокирована
+	return attempts == 0, nil
+}
+
+func (store *DbStore) RecordLogin(ctx context.Context, id int64, now time.Time) error {
+	query := "UPDATE account SET failed_attempts = 0, locked_until = NULL, last_login_at = $2 WHERE id = $1"
+	_, err := store.conn(ctx).ExecContext(ctx, query, id, now)
+	return err
+}
+
+func (store *DbStore) SetPassword(ctx context.Context, id int64, hash string, changed *time.Time) error {
+	if changed == nil {
+		_, err := store.conn(ctx).ExecContext(ctx, "UPDATE account SET password_hash = $2 WHERE id = $1", id, hash)
+		return err
+	}
+	query := `UPDATE account SET password_hash = $2, password_changed_at = $3, update_at = $3,
+			failed_attempts = 0, locked_until = NULL
+		WHERE id = $1`
+	_, err := store.conn(ctx).ExecContext(ctx, query, id, hash, *changed)
+	return err
+}
+
+func (store *DbStore) Unlock(ctx context.Context, id int64, now time.Time) (bool, error) {
+	query := "UPDATE account SET failed_attempts = 0, locked_until = NULL, update_at = $2 WHERE id = $1"
+	result, err := store.conn(ctx).ExecContext(ctx, query, id, now)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+func (store *DbStore) Delete(ctx context.Context, id int64) (bool, error) {
+	result, err := store.conn(ctx).ExecContext(ctx, "DELETE FROM account WHERE id = $1", id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+func (store *DbStore) SaveReset(ctx context.Context, reset *ResetEntity) error {
+	query := "INSERT INTO password_reset (hash, account_id, expires_at) VALUES ($1, $2, $3)"
+	_, err := store.conn(ctx).ExecContext(ctx, query, reset.Hash, reset.AccountId, reset.ExpiresAt)
+	return err
+}
+
+func (store *DbStore) FindReset(ctx context.Context, hash string) (reset ResetEntity, err error) {
+	err = store.conn(ctx).GetContext(ctx, &reset, "SELECT * FROM password_reset WHERE hash = $1", hash)
+	return reset, err
+}
+
+func (store *DbStore) ConsumeReset(ctx context.Context, hash string) (reset ResetEntity, err error) {
+	err = store.conn(ctx).GetContext(ctx, &reset, "DELETE FROM password_reset WHERE hash = $1 RETURNING *", hash)
+	return reset, err
+}
+
+func (store *DbStore) DeleteResets(ctx context.Context, accountId int64) error {
+	_, err := store.conn(ctx).ExecContext(ctx, "DELETE FROM password_reset WHERE account_id = $1", accountId)
+	return err
+}
+
+func (store *DbStore) DeleteExpiredResets(ctx context.Context, now time.Time) (int64, error) {
+	result, err := store.conn(ctx).ExecContext(ctx, "DELETE FROM password_reset WHERE expires_at < $1", now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// MemoryStore хранение в памяти процесса (STORAGE=memory) и для тестов
+type MemoryStore struct {
+	mutex    sync.Mutex
+	accounts map[int64]Entity
+	lastId   int64
+	resets   map[string]ResetEntity
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{accounts: make(map[int64]Entity), resets: make(map[string]ResetEntity)}
+}
+
+func (store *MemoryStore) Create(ctx context.Context, account *Entity) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for _, existing := range store.accounts {
+		if strings.EqualFold(existing.Username, account.Username) {
+			return 0, common.AlreadyExistsError{Message: fmt.Sprintf("account already exists: username %s", account.Username)}
+		}
+		if existing.EmployeeId == account.EmployeeId {
+			return 0, common.AlreadyExistsError{Message: fmt.Sprintf("account already exists: employee_id %d", account.EmployeeId)}
+		}
+	}
+	store.lastId++
+	var row = *account
+	row.Id = store.lastId
+	store.accounts[row.Id] = row
+	return row.Id, nil
+}
+
+func (store *MemoryStore) FindAll(ctx context.Context) ([]Entity, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var accounts []Entity
+	for _, id := range slices.Sorted(maps.Keys(store.accounts)) {
+		accounts = append(accounts, store.accounts[id])
+	}
+	return accounts, nil
+}
+
+func (store *MemoryStore) FindById(ctx context.Context, id int64) (Entity, error) {
+	return store.find(func(account Entity) bool { return account.Id == id })
+}
+
+func (store *MemoryStore) FindByUsername(ctx context.Context, username string) (Entity, error) {
+	return store.find(func(account Entity) bool { return strings.EqualFold(account.Username, username) })
+}
+
+func (store *MemoryStore) FindByEmployeeId(ctx context.Context, employeeId int64) (Entity, error) {
+	return store.find(func(account Entity) bool { return account.EmployeeId == employeeId })
+}
+
+func (store *MemoryStore) find(match func(account Entity) bool) (Entity, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for _, account := range store.accounts {
+		if match(account) {
+			return account, nil
+		}
+	}
+	return Entity{}, sql.ErrNoRows
+}
+
+// modify изменение учётной записи под блокировкой, found - запись существует
+func (store *MemoryStore) modify(id int64, fn func(account *Entity)) bool {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	account, ok := store.accounts[id]
+	if !ok {
+		return false
+	}
+	fn(&account)
+	store.accounts[id] = account
+	return true
+}
+
+func (store *MemoryStore) RecordFailure(ctx context.Context, id int64, maxAttempts int, lockUntil time.Time) (bool, error) {
+	var locked bool
+	if !store.modify(id, func(account *Entity) {
+		account.FailedAttempts++
+		if account.FailedAttempts >= maxAttempts {
+			account.FailedAttempts, account.LockedUntil, locked = 0, &lockUntil, true
+		}
+	}) {
+		return false, sql.ErrNoRows
+	}
+	return locked, nil
+}
+
+func (store *MemoryStore) RecordLogin(ctx context.Context, id int64, now time.Time) error {
+	store.modify(id, func(account *Entity) {
+		account.FailedAttempts, account.LockedUntil, account.LastLoginAt = 0, nil, &now
+	})
+	return nil
+}
+
+func (store *MemoryStore) SetPassword(ctx context.Context, id int64, hash string, changed *time.Time) error {
+	store.modify(id, func(account *Entity) {
+		account.PasswordHash = hash
+		if changed != nil {
+			account.PasswordChanged, account.Update = *changed, *changed
+			account.FailedAttempts, account.LockedUntil = 0, nil
+		}
+	})
+	return nil
+}
+
+func (store *MemoryStore) Unlock(ctx context.Context, id int64, now time.Time) (bool, error) {
+	return store.modify(id, func(account *Entity) {
+		account.FailedAttempts, account.LockedUntil, account.Update = 0, nil, now
+	}), nil
+}
+
+func (store *MemoryStore) Delete(ctx context.Context, id int64) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	_, ok := store.accounts[id]
+	delete(store.accounts, id)
+	// как ON DELETE CASCADE в базе данных
+	for hash, reset := range store.resets {
+		if reset.AccountId == id {
+			delete(store.resets, hash)
+		}
+	}
+	return ok, nil
+}
+
+func (store *MemoryStore) SaveReset(ctx context.Context, reset *ResetEntity) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.resets[reset.Hash] = *reset
+	return nil
+}
+
+func (store *MemoryStore) FindReset(ctx context.Context, hash string) (ResetEntity, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	reset, ok := store.resets[hash]
+	if !ok {
+		return ResetEntity{}, sql.ErrNoRows
+	}
+	return reset, nil
+}
+
+func (store *MemoryStore) ConsumeReset(ctx context.Context, hash string) (ResetEntity, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	reset, ok := store.resets[hash]
+	if !ok {
+		return ResetEntity{}, sql.ErrNoRows
+	}
+	delete(store.resets, hash)
+	return reset, nil
+}
+
+func (store *MemoryStore) DeleteResets(ctx context.Context, accountId int64) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for hash, reset := range store.resets {
+		if reset.AccountId == accountId {
+			delete(store.resets, hash)
+		}
+	}
+	return nil
+}
+
+func (store *MemoryStore) DeleteExpiredResets(ctx context.Context, now time.Time) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var deleted int64
+	for hash, reset := range store.resets {
+		if reset.ExpiresAt.Before(now) {
+			delete(store.resets, hash)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
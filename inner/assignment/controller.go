@@ -0,0 +1,98 @@
+package assignment
+
+import (
+	"context"
+	"idm/inner/common"
+	"idm/inner/crud"
+	"idm/inner/role"
+	"idm/inner/web"
+
+	"github.com/gofiber/fiber"
+)
+
+type Controller struct {
+	server            *web.Server
+	assignmentService Srv
+}
+
+// интерфейс сервиса assignment.Service
+type Srv interface {
+	CreateEmployee(ctx context.Context, req CreateEmployeeRequest) (id int64, err error)
+	AssignRoles(ctx context.Context, employeeId int64, req AssignRequest) error
+	FindRoles(ctx context.Context, employeeId int64) ([]role.Response, error)
+}
+
+func NewController(server *web.Server, assignmentService Srv) *Controller {
+	return &Controller{
+		server:            server,
+		assignmentService: assignmentService,
+	}
+}
+
+// функция для регистрации маршрутов
+func (contr *Controller) RegisterRoutes() {
+	// полный маршрут получится "/api/v1/employees/with-roles"
+	contr.server.GroupApiV1.Post("/employees/with-roles", contr.CreateEmployee)
+	contr.server.GroupApiV1.Post("/employees/id/:id/roles", contr.AssignRoles)
+	contr.server.GroupApiV1.Get("/employees/id/:id/roles", contr.FindRoles)
+}
+
+// функция-хендлер, которая будет вызываться при POST запросе по маршруту "/api/v1/employees/with-roles"
+func (contr *Controller) CreateEmployee(ctx *fiber.Ctx) {
+	var req CreateEmployeeRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+		return
+	}
+
+	var newId, err = contr.assignmentService.CreateEmployee(web.Context(ctx), req)
+	if err != nil {
+		crud.WriteError(ctx, err)
+		return
+	}
+
+	if err = common.OkResponse(ctx, newId); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning created employee id")
+	}
+}
+
+// функция-хендлер, которая будет вызываться при POST запросе по маршруту "/api/v1/employees/id/:id/roles"
+func (contr *Controller) AssignRoles(ctx *fiber.Ctx) {
+	id, ok := crud.ParamId(ctx)
+	if !ok {
+		return
+	}
+
+	var req AssignRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := contr.assignmentService.AssignRoles(web.Context(ctx), id, req); err != nil {
+		crud.WriteError(ctx, err)
+		return
+	}
+
+	if err := common.OkResponse(ctx, id); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning employee id")
+	}
+}
+
+// функция-хендлер, которая будет вызываться при GET запросе по маршруту "/api/v1/employees/id/:id/roles"
+func (contr *Controller) FindRoles(ctx *fiber.Ctx) {
+	id, ok := crud.ParamId(ctx)
+	if !ok {
+		return
+	}
+
+	roles, err := contr.assignmentService.FindRoles(web.Context(ctx), id)
+	if err != nil {
+		crud.WriteError(ctx, err)
+		return
+	}
+
+	if err = common.OkResponse(ctx, roles); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning roles")
+	}
+}
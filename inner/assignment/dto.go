@@ -0,0 +1,14 @@
+package assignment
+
+import "idm/inner/employee"
+
+// CreateEmployeeRequest запрос на создание работника вместе с ролями
+type CreateEmployeeRequest struct {
+	employee.Request
+	RoleIds []int64 `json:"role_ids" validate:"dive,gt=0"`
+}
+
+// AssignRequest запрос на назначение ролей работнику
+type AssignRequest struct {
+	RoleIds []int64 `json:"role_ids" validate:"required,min=1,dive,gt=0"`
+}
@@ -0,0 +1,150 @@
+package assignment
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"idm/inner/common"
+	"idm/inner/crud"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"idm/inner/tracing"
+	"slices"
+)
+
+// Service назначение ролей работникам: изменения в employee и role выполняются в одной транзакции
+type Service struct {
+	tx        Transactor
+	employees EmployeeRepo
+	roles     RoleRepo
+	valid     Validator
+}
+
+// Transactor выполнение функции в транзакции, переданной через контекст (database.TxManager)
+type Transactor interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type EmployeeRepo interface {
+	FindById(ctx context.Context, id int64) (entity employee.Entity, err error)
+	FindByNameTx(ctx context.Context, name string) (isExists bool, err error)
+	SaveTx(ctx context.Context, entity *employee.Entity) (id int64, err error)
+}
+
+type RoleRepo interface {
+	FindByIds(ctx context.Context, ids []int64) (entities []role.Entity, err error)
+	FindByEmployeeId(ctx context.Context, employeeId int64) (entities []role.Entity, err error)
+	AssignTx(ctx context.Context, employeeId int64, roleIds []int64) error
+}
+
+type Validator interface {
+	Validate(request any) error
+}
+
+func NewService(tx Transactor, employees EmployeeRepo, roles RoleRepo, validator Validator) *Service {
+	return &Service{
+		tx:        tx,
+		employees: employees,
+		roles:     roles,
+		valid:     validator,
+	}
+}
+
+// CreateEmployee создание работника сразу с ролями: если хотя бы одной роли нет, работник не создаётся
+func (serv *Service) CreateEmployee(ctx context.Context, req CreateEmployeeRequest) (id int64, err error) {
+	ctx, span := tracing.Start(ctx, "assignment.CreateEmployee")
+	defer tracing.End(span, &err)
+
+	err = tracing.Validate(ctx, serv.valid, req)
+	if err != nil {
+		return 0, common.RequestValidationError{Message: err.Error()}
+	}
+
+	err = serv.tx.Do(ctx, func(ctx context.Context) error {
+		isExists, err := serv.employees.FindByNameTx(ctx, req.Name)
+		if err != nil {
+			return fmt.Errorf("error finding employee by name: %s, %w", req.Name, err)
+		}
+		if isExists {
+			return common.AlreadyExistsError{Message: fmt.Sprintf("employee with name %s already exists", req.Name)}
+		}
+
+		id, err = serv.employees.SaveTx(ctx, req.Request.ToEntity())
+		if err != nil {
+			return fmt.Errorf("error creating employee with name: %s %w", req.Name, err)
+		}
+		return serv.assign(ctx, id, req.RoleIds)
+	})
+	if err != nil {
+		return 0, crud.DbError(err)
+	}
+	return id, nil
+}
+
+// AssignRoles назначение ролей существующему работнику, уже назначенные роли пропускаются
+func (serv *Service) AssignRoles(ctx context.Context, employeeId int64, req AssignRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "assignment.AssignRoles")
+	defer tracing.End(span, &err)
+
+	err = tracing.Validate(ctx, serv.valid, req)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+
+	err = serv.tx.Do(ctx, func(ctx context.Context) error {
+		_, err := serv.employees.FindById(ctx, employeeId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return common.NotFoundError{Message: fmt.Sprintf("employee with id %d not found", employeeId)}
+		}
+		if err != nil {
+			return fmt.Errorf("error finding employee with id %d: %w", employeeId, err)
+		}
+		return serv.assign(ctx, employeeId, req.RoleIds)
+	})
+	return crud.DbError(err)
+}
+
+// FindRoles роли, назначенные работнику
+func (serv *Service) FindRoles(ctx context.Context, employeeId int64) (responses []role.Response, err error) {
+	ctx, span := tracing.Start(ctx, "assignment.FindRoles")
+	defer tracing.End(span, &err)
+
+	entities, err := serv.roles.FindByEmployeeId(ctx, employeeId)
+	if err != nil {
+		return []role.Response{}, common.DbOperationError{Message: fmt.Errorf("error finding roles of employee with id %d: %w", employeeId, err).Error()}
+	}
+
+	responses = make([]role.Response, 0, len(entities))
+	for _, entity := range entities {
+		responses = append(responses, entity.ToResponse())
+	}
+	return responses, nil
+}
+
+// assign проверка существования ролей и их назначение в транзакции из ctx
+func (serv *Service) assign(ctx context.Context, employeeId int64, roleIds []int64) error {
+	if len(roleIds) == 0 {
+		return nil
+	}
+
+	found, err := serv.roles.FindByIds(ctx, roleIds)
+	if err != nil {
+		return fmt.Errorf("error finding roles with ids %d: %w", roleIds, err)
+	}
+	var missing []int64
+	for _, roleId := range roleIds {
+		if !slices.ContainsFunc(found, func(entity role.Entity) bool { return entity.Id == roleId }) {
+			missing = append(missing, roleId)
+		}
+	}
+	if len(missing) > 0 {
+		return common.NotFoundError{Message: fmt.Sprintf("roles with ids %d not found", missing)}
+	}
+
+	err = serv.roles.AssignTx(ctx, employeeId, roleIds)
+	if err != nil {
+		return fmt.Errorf("error assigning roles %d to employee with id %d: %w", roleIds, employeeId, err)
+	}
+	return nil
+}
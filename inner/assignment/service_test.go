@@ -0,0 +1,94 @@
+package assignment
+
+import (
+	"context"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock" // библиотека для мокирования SQL-запросов в тестах
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubValidator struct{}
+
+func (stubValidator) Validate(request any) error {
+	return nil
+}
+
+func newService(t *testing.T) (*Service, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	var sqlxDB = sqlx.NewDb(db, "sqlmock")
+	var srv = NewService(
+		database.NewTxManager(sqlxDB, database.DefaultTxOptions),
+		employee.NewEmployeeRepository(sqlxDB),
+		role.NewRoleRepository(sqlxDB),
+		stubValidator{},
+	)
+	return srv, mock
+}
+
+var createRequest = CreateEmployeeRequest{
+	Request: employee.Request{Name: "Pupkin", Create: time.Now(), Update: time.Now()},
+	RoleIds: []int64{1, 2},
+}
+
+// работник и его роли создаются в одной транзакции
+func TestCreateEmployee(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should create employee and assign roles in one transaction", func(t *testing.T) {
+		srv, mock := newService(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT EXISTS").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectQuery("INSERT INTO employee").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(7)))
+		mock.ExpectQuery("SELECT \\* FROM role WHERE id IN").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(int64(1), "admin").AddRow(int64(2), "user"))
+		mock.ExpectExec("INSERT INTO employee_role").WithArgs(int64(7), int64(1), int64(2)).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		id, err := srv.CreateEmployee(context.Background(), createRequest)
+		a.NoError(err)
+		a.Equal(int64(7), id)
+		a.NoError(mock.ExpectationsWereMet())
+	})
+
+	t.Run("should not create employee when role does not exist", func(t *testing.T) {
+		srv, mock := newService(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT EXISTS").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectQuery("INSERT INTO employee").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(7)))
+		mock.ExpectQuery("SELECT \\* FROM role WHERE id IN").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(int64(1), "admin"))
+		mock.ExpectRollback()
+
+		id, err := srv.CreateEmployee(context.Background(), createRequest)
+		a.Equal(int64(0), id)
+		a.ErrorAs(err, &common.NotFoundError{})
+		a.Equal("roles with ids [2] not found", err.Error())
+		a.NoError(mock.ExpectationsWereMet())
+	})
+}
+
+func TestAssignRoles(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return NotFoundError when employee does not exist", func(t *testing.T) {
+		srv, mock := newService(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT \\* FROM employee WHERE id").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		err := srv.AssignRoles(context.Background(), 5, AssignRequest{RoleIds: []int64{1}})
+		a.ErrorAs(err, &common.NotFoundError{})
+		a.NoError(mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"idm/inner/common"
+	"idm/inner/web"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber"
+)
+
+// схемы заголовка Authorization
+const (
+	SchemeApiKey = "ApiKey"
+	SchemeBearer = "Bearer"
+)
+
+// ErrInvalidCredentials учётные данные неверны, истекли или отозваны: ответ 401
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Authenticator проверка учётных данных одной схемы заголовка Authorization
+type Authenticator interface {
+	// Authenticate возвращает вызывающего или ошибку, оборачивающую ErrInvalidCredentials.
+	// Другие ошибки означают, что проверить учётные данные не удалось (например, база данных недоступна).
+	Authenticate(ctx context.Context, credentials string) (Principal, error)
+}
+
+// Middleware аутентификация запросов заголовком "Authorization: <схема> <учётные данные>"
+// и проверка разрешения на маршрут (см. RequiredScope).
+// Без заголовка или с неверными учётными данными - ответ 401 со списком схем в WWW-Authenticate,
+// без разрешения на маршрут - ответ 403.
+func Middleware(schemes map[string]Authenticator) fiber.Handler {
+	var challenge = strings.Join(slices.Sorted(maps.Keys(schemes)), ", ")
+
+	return func(c *fiber.Ctx) {
+		var unauthorized = func(message string) {
+			c.Set(fiber.HeaderWWWAuthenticate, challenge)
+			_ = common.ErrResponse(c, fiber.StatusUnauthorized, message)
+		}
+
+		scheme, credentials, _ := strings.Cut(strings.TrimSpace(c.Get(fiber.HeaderAuthorization)), " ")
+		if scheme == "" {
+			unauthorized("authorization required")
+			return
+		}
+		var authenticator = lookup(schemes, scheme)
+		if authenticator == nil {
+			unauthorized(fmt.Sprintf("unsupported authorization scheme %s", scheme))
+			return
+		}
+
+		var ctx = web.Context(c)
+		principal, err := authenticator.Authenticate(ctx, strings.TrimSpace(credentials))
+		if errors.Is(err, ErrInvalidCredentials) {
+			unauthorized(err.Error())
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "error authenticating request", "scheme", scheme, "error", err)
+			_ = common.ErrResponse(c, fiber.StatusInternalServerError, "error authenticating request")
+			return
+		}
+
+		web.SetCaller(c, principal.Subject)
+		SetPrincipal(c, principal)
+		if scope := RequiredScope(c.Method(), c.Path()); !principal.Allows(scope) {
+			_ = common.ErrResponse(c, fiber.StatusForbidden, fmt.Sprintf("scope %s required", scope))
+			return
+		}
+		c.Next()
+	}
+}
+
+// lookup проверка схемы без учёта регистра, как требует RFC 9110
+func lookup(schemes map[string]Authenticator, scheme string) Authenticator {
+	for name, authenticator := range schemes {
+		if strings.EqualFold(name, scheme) {
+			return authenticator
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"idm/inner/web"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// staticAuthenticator принимает только один ключ
+type staticAuthenticator struct {
+	key       string
+	principal Principal
+	err       error
+}
+
+func (a staticAuthenticator) Authenticate(ctx context.Context, credentials string) (Principal, error) {
+	if a.err != nil {
+		return Principal{}, a.err
+	}
+	if credentials != a.key {
+		return Principal{}, fmt.Errorf("%w: unknown api key", ErrInvalidCredentials)
+	}
+	return a.principal, nil
+}
+
+func TestRequiredScope(t *testing.T) {
+	a := assert.New(t)
+	a.Equal("employees:read", RequiredScope(fiber.MethodGet, "/api/v1/employees/export"))
+	a.Equal("employees:write", RequiredScope(fiber.MethodPost, "/api/v1/employees/id/1/roles"))
+	a.Equal("roles:write", RequiredScope(fiber.MethodDelete, "/api/v1/roles/ids"))
+	a.Equal("service-accounts:read", RequiredScope(fiber.MethodGet, "/api/v1/service-accounts"))
+}
+
+func TestPrincipalAllows(t *testing.T) {
+	a := assert.New(t)
+	var principal = Principal{Scopes: []string{"employees:read", "roles:*"}}
+	a.True(principal.Allows("employees:read"))
+	a.False(principal.Allows("employees:write"))
+	a.True(principal.Allows("roles:write"))
+	a.False(principal.Allows("service-accounts:read"))
+	a.True(Principal{Scopes: []string{AllScopes}}.Allows("service-accounts:write"))
+
+	a.True(ValidScope("service-accounts:write"))
+	a.True(ValidScope("*"))
+	a.False(ValidScope("employees"))
+	a.False(ValidScope("employees:delete"))
+}
+
+func TestMiddleware(t *testing.T) {
+	a := assert.New(t)
+	const signingKey = "test-signing-key"
+
+	var newApp = func(apiKeys Authenticator) *fiber.App {
+		var app = fiber.New()
+		app.Use(Middleware(map[string]Authenticator{
+			SchemeApiKey: apiKeys,
+			SchemeBearer: NewTokenVerifier(signingKey),
+		}))
+		// вызывающий доступен сервисам через контекст запроса
+		app.Get("/api/v1/employees", func(c *fiber.Ctx) {
+			principal, _ := PrincipalFrom(web.Context(c))
+			c.SendString(principal.Subject)
+		})
+		app.Delete("/api/v1/employees/ids", func(c *fiber.Ctx) { c.SendString(web.Caller(c)) })
+		return app
+	}
+	var request = func(app *fiber.App, method string, authorization string) (*http.Response, string) {
+		var req = httptest.NewRequest(method, "/api/v1/employees", nil)
+		if method == fiber.MethodDelete {
+			req = httptest.NewRequest(method, "/api/v1/employees/ids", nil)
+		}
+		if authorization != "" {
+			req.Header.Set(fiber.HeaderAuthorization, authorization)
+		}
+		resp, err := app.Test(req)
+		a.Nil(err)
+		body, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		return resp, string(body)
+	}
+	var token = func(claims TokenClaims, key string) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(key))
+		a.Nil(err)
+		return signed
+	}
+	var reader = staticAuthenticator{key: "secret", principal: Principal{Subject: "service-account:bot", Scopes: []string{"employees:read"}}}
+
+	t.Run("should reject request without credentials", func(t *testing.T) {
+		resp, _ := request(newApp(reader), fiber.MethodGet, "")
+		a.Equal(fiber.StatusUnauthorized, resp.StatusCode)
+		a.Equal("ApiKey, Bearer", resp.Header.Get(fiber.HeaderWWWAuthenticate))
+
+		resp, _ = request(newApp(reader), fiber.MethodGet, "Basic dXNlcjpwYXNz")
+		a.Equal(fiber.StatusUnauthorized, resp.StatusCode)
+		resp, _ = request(newApp(reader), fiber.MethodGet, "ApiKey wrong")
+		a.Equal(fiber.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("should authenticate api key and check scope", func(t *testing.T) {
+		resp, body := request(newApp(reader), fiber.MethodGet, "apikey secret")
+		a.Equal(fiber.StatusOK, resp.StatusCode)
+		a.Equal("service-account:bot", body)
+
+		resp, _ = request(newApp(reader), fiber.MethodDelete, "ApiKey secret")
+		a.Equal(fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("should authenticate bearer token", func(t *testing.T) {
+		var claims = TokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "employee:1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+			Scope:            "employees:*",
+		}
+		resp, body := request(newApp(reader), fiber.MethodDelete, "Bearer "+token(claims, signingKey))
+		a.Equal(fiber.StatusOK, resp.StatusCode)
+		a.Equal("employee:1", body)
+
+		resp, _ = request(newApp(reader), fiber.MethodGet, "Bearer "+token(claims, "other-key"))
+		a.Equal(fiber.StatusUnauthorized, resp.StatusCode)
+
+		claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		resp, _ = request(newApp(reader), fiber.MethodGet, "Bearer "+token(claims, signingKey))
+		a.Equal(fiber.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("should answer 500 when credentials cannot be checked", func(t *testing.T) {
+		resp, _ := request(newApp(staticAuthenticator{err: errors.New("connection refused")}), fiber.MethodGet, "ApiKey secret")
+		a.Equal(fiber.StatusInternalServerError, resp.StatusCode)
+	})
+}
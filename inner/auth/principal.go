@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"idm/inner/common"
+	"idm/inner/web"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber"
+)
+
+// ApiPrefix префикс маршрутов, доступ к которым проверяет Middleware
+const ApiPrefix = "/api/v1/"
+
+// действия в разрешениях: чтение для GET, HEAD и OPTIONS, изменение для остальных методов
+const (
+	ActionRead  = "read"
+	ActionWrite = "write"
+)
+
+// AllScopes разрешение на все маршруты API
+const AllScopes = "*"
+
+// разрешение "ресурс:действие", где ресурс - первая часть пути после ApiPrefix, а действие read, write или *
+var scopePattern = regexp.MustCompile(`^(\*|[a-z][a-z0-9-]*:(read|write|\*))$`)
+
+// ValidScope разрешение записано в формате "employees:read", "roles:*" или "*"
+func ValidScope(scope string) bool {
+	return scopePattern.MatchString(scope)
+}
+
+// RequiredScope разрешение, нужное для вызова маршрута API,
+// например для "POST /api/v1/employees/import" - "employees:write"
+func RequiredScope(method string, path string) string {
+	var resource, _, _ = strings.Cut(strings.TrimPrefix(path, ApiPrefix), "/")
+	var action = ActionWrite
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		action = ActionRead
+	}
+	return strings.ToLower(resource) + ":" + action
+}
+
+// Principal аутентифицированный вызывающий, от имени которого выполняется запрос
+type Principal struct {
+	// имя вызывающего с типом, например "service-account:ticket-bot"; попадает в журнал запросов
+	// и служит ключом ограничения частоты запросов
+	Subject string
+	// разрешения вызывающего, см. RequiredScope
+	Scopes []string
+}
+
+// Allows есть ли у вызывающего разрешение scope: то же разрешение, "ресурс:*" или "*"
+func (principal Principal) Allows(scope string) bool {
+	var resource, _, _ = strings.Cut(scope, ":")
+	return slices.ContainsFunc(principal.Scopes, func(granted string) bool {
+		return granted == scope || granted == AllScopes || granted == resource+":*"
+	})
+}
+
+// EmployeeSubjectPrefix префикс вызывающего-работника в sub выдаваемых токенов, например "employee:42"
+const EmployeeSubjectPrefix = "employee:"
+
+// EmployeeSubject вызывающий-работник с id
+func EmployeeSubject(id int64) string {
+	return EmployeeSubjectPrefix + strconv.FormatInt(id, 10)
+}
+
+// EmployeeId id работника из sub токена; false, если вызывающий не работник
+func EmployeeId(subject string) (int64, bool) {
+	value, ok := strings.CutPrefix(subject, EmployeeSubjectPrefix)
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	return id, err == nil && id > 0
+}
+
+// ключ вызывающего в Locals запроса
+const localsPrincipal = "principal"
+
+// ключ вызывающего в контексте запроса
+type principalKey struct{}
+
+// SetPrincipal сохранение вызывающего в запросе для хендлеров и в контексте запроса (web.Context) для сервисов
+func SetPrincipal(c *fiber.Ctx, principal Principal) {
+	c.Locals(localsPrincipal, principal)
+	web.SetContext(c, WithPrincipal(web.Context(c), principal))
+}
+
+// WithPrincipal контекст с вызывающим
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFrom вызывающий из контекста запроса; false, если аутентификация выключена
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// CheckGrant вызывающий может выдать разрешения scopes (сервисному аккаунту или клиенту OIDC) только из своих:
+// иначе вызывающий с правом на управление ключами выдал бы себе доступ ко всему API.
+// Без аутентификации (вызывающего в контексте нет) проверка не выполняется.
+func CheckGrant(ctx context.Context, scopes []string) error {
+	principal, ok := PrincipalFrom(ctx)
+	if !ok {
+		return nil
+	}
+	if denied := slices.IndexFunc(scopes, func(scope string) bool { return !principal.Allows(scope) }); denied >= 0 {
+		return common.ForbiddenError{Message: fmt.Sprintf("scope %s cannot be granted: caller does not have it", scopes[denied])}
+	}
+	return nil
+}
+
+// PrincipalOf вызывающий, аутентифицированный Middleware; false, если аутентификация выключена
+func PrincipalOf(c *fiber.Ctx) (Principal, bool) {
+	principal, ok := c.Locals(localsPrincipal).(Principal)
+	return principal, ok
+}
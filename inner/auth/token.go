@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenClaims утверждения токена доступа: sub - вызывающий, scope - разрешения через пробел (RFC 8693)
+type TokenClaims struct {
+	jwt.RegisteredClaims
+	Scope string `json:"scope,omitempty"`
+}
+
+// SessionCookie cookie с сессией работника: тот же токен, что и в "Authorization: Bearer" (см. account.Service.Login)
+const SessionCookie = "idm_session"
+
+// SignToken подпись токена HS256 ключом AUTH_SIGNING_KEY: так выдаются сессии работников
+func SignToken(signingKey string, claims TokenClaims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(signingKey))
+}
+
+// KeySource открытые ключи проверки токенов RS256 по kid из заголовка токена (см. oidc.Keys)
+type KeySource interface {
+	PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// TokenVerifier проверка JWT "Authorization: Bearer ...": токены HS256 подписаны ключом AUTH_SIGNING_KEY,
+// а токены RS256 выданы встроенным OIDC-провайдером (см. WithKeys).
+// Токен должен содержать sub и срок действия exp.
+type TokenVerifier struct {
+	key []byte
+	// издатель и ключи токенов RS256, nil - токены RS256 не принимаются
+	issuer string
+	keys   KeySource
+}
+
+func NewTokenVerifier(signingKey string) *TokenVerifier {
+	return &TokenVerifier{key: []byte(signingKey)}
+}
+
+// WithKeys приём токенов RS256 издателя issuer, подписанных ключами из keys
+func (verifier *TokenVerifier) WithKeys(issuer string, keys KeySource) *TokenVerifier {
+	verifier.issuer, verifier.keys = issuer, keys
+	return verifier
+}
+
+func (verifier *TokenVerifier) Authenticate(ctx context.Context, credentials string) (Principal, error) {
+	claims, err := verifier.Verify(ctx, credentials)
+	if err != nil {
+		return Principal{}, err
+	}
+	return Principal{Subject: claims.Subject, Scopes: strings.Fields(claims.Scope)}, nil
+}
+
+// Verify проверка подписи, срока действия и издателя токена; ошибка оборачивает ErrInvalidCredentials
+func (verifier *TokenVerifier) Verify(ctx context.Context, token string) (TokenClaims, error) {
+	var methods = []string{jwt.SigningMethodHS256.Alg()}
+	if verifier.keys != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	var parser = jwt.NewParser(jwt.WithValidMethods(methods), jwt.WithExpirationRequired())
+
+	var claims TokenClaims
+	parsed, err := parser.ParseWithClaims(token, &claims, func(token *jwt.Token) (any, error) {
+		if token.Method == jwt.SigningMethodHS256 {
+			return verifier.key, nil
+		}
+		kid, _ := token.Header["kid"].(string)
+		return verifier.keys.PublicKey(ctx, kid)
+	})
+	if err != nil {
+		return TokenClaims{}, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+	if parsed.Method != jwt.SigningMethodHS256 && claims.Issuer != verifier.issuer {
+		return TokenClaims{}, fmt.Errorf("%w: unknown token issuer %s", ErrInvalidCredentials, claims.Issuer)
+	}
+	if claims.Subject == "" {
+		return TokenClaims{}, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+	return claims, nil
+}
@@ -0,0 +1,30 @@
+package client
+
+import (
+	"context"
+	"idm/inner/assignment"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"idm/inner/tabular"
+	"io"
+)
+
+// Client операции администрирования IDM для командной строки.
+// Local выполняет их через сервисы напрямую в хранилище, Remote - через HTTP API запущенного сервера.
+type Client interface {
+	ListEmployees(ctx context.Context, filter employee.Filter) ([]employee.Response, error)
+	// CreateEmployee создание работника, сразу с ролями, если они переданы
+	CreateEmployee(ctx context.Context, req assignment.CreateEmployeeRequest) (id int64, err error)
+	DeleteEmployee(ctx context.Context, id int64) error
+	// ImportEmployees импорт работников из файла CSV или XLSX: при apply == false - только предварительный просмотр
+	ImportEmployees(ctx context.Context, fileName string, file io.Reader, apply bool) (employee.ImportReport, error)
+	ExportEmployees(ctx context.Context, filter employee.Filter, format tabular.Format, writer io.Writer) error
+
+	ListRoles(ctx context.Context, filter role.Filter) ([]role.Response, error)
+	CreateRole(ctx context.Context, req role.Request) (id int64, err error)
+	DeleteRole(ctx context.Context, id int64) error
+	ExportRoles(ctx context.Context, filter role.Filter, format tabular.Format, writer io.Writer) error
+
+	// AssignRoles назначение ролей работнику, уже назначенные роли пропускаются
+	AssignRoles(ctx context.Context, employeeId int64, roleIds []int64) error
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"idm/inner/assignment"
+	"idm/inner/common"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"idm/inner/storage"
+	"idm/inner/tabular"
+	"idm/inner/validator"
+	"idm/inner/web"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber"
+	"github.com/stretchr/testify/assert"
+)
+
+// fiberTransport передаёт запросы HTTP-клиента напрямую в приложение fiber без сетевого подключения
+type fiberTransport struct {
+	app *fiber.App
+}
+
+func (transport fiberTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return transport.app.Test(req, -1)
+}
+
+// newRemote клиент сервера с маршрутами API поверх хранилища store
+func newRemote(store *storage.Storage) *Remote {
+	var server = web.NewServer()
+	var vld = validator.NewRequestValidator()
+	employee.NewController(server, employee.NewService(store.Employees, vld)).RegisterRoutes()
+	role.NewController(server, role.NewService(store.Roles, vld)).RegisterRoutes()
+	assignment.NewController(server, assignment.NewService(store.Tx, store.Employees, store.Roles, vld)).RegisterRoutes()
+	return NewRemote("http://idm/", &http.Client{Transport: fiberTransport{app: server.App}})
+}
+
+// локальный и удалённый клиенты должны вести себя одинаково
+func TestClient(t *testing.T) {
+	var clients = map[string]func(store *storage.Storage) Client{
+		"local":  func(store *storage.Storage) Client { return NewLocal(store) },
+		"remote": func(store *storage.Storage) Client { return newRemote(store) },
+	}
+
+	for name, newClient := range clients {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+			var ctx = context.Background()
+			var client = newClient(storage.NewMemory())
+
+			roleId, err := client.CreateRole(ctx, role.Request{Name: "admin", Create: time.Now(), Update: time.Now()})
+			a.NoError(err)
+			employeeId, err := client.CreateEmployee(ctx, assignment.CreateEmployeeRequest{
+				Request: employee.Request{Name: "Pupkin", Create: time.Now(), Update: time.Now()},
+			})
+			a.NoError(err)
+			a.NoError(client.AssignRoles(ctx, employeeId, []int64{roleId}))
+			a.True(errors.As(client.AssignRoles(ctx, employeeId, []int64{roleId + 100}), &common.NotFoundError{}))
+
+			employees, err := client.ListEmployees(ctx, employee.Filter{Name: "pup"})
+			a.NoError(err)
+			a.Len(employees, 1)
+			roles, err := client.ListRoles(ctx, role.Filter{})
+			a.NoError(err)
+			a.Len(roles, 1)
+
+			var file = "name,create_at,update_at\nIvanov,2025-01-02,2025-01-02\nPupkin,2025-01-02,2025-01-02\n"
+			report, err := client.ImportEmployees(ctx, "hr.csv", strings.NewReader(file), false)
+			a.NoError(err)
+			a.True(report.DryRun)
+			a.Equal(1, report.Created)
+			a.Equal(1, report.Updated)
+			report, err = client.ImportEmployees(ctx, "hr.csv", strings.NewReader("name\nX\n"), true)
+			a.True(errors.As(err, &common.RequestValidationError{}))
+			a.Equal(1, report.Invalid)
+			_, err = client.ImportEmployees(ctx, "hr.csv", strings.NewReader(file), true)
+			a.NoError(err)
+
+			var exported bytes.Buffer
+			a.NoError(client.ExportEmployees(ctx, employee.Filter{}, tabular.CSV, &exported))
+			a.Contains(exported.String(), "Ivanov")
+			exported.Reset()
+			a.NoError(client.ExportRoles(ctx, role.Filter{}, tabular.CSV, &exported))
+			a.Contains(exported.String(), "admin")
+
+			a.NoError(client.DeleteEmployee(ctx, employeeId))
+			a.True(errors.As(client.DeleteEmployee(ctx, employeeId), &common.NotFoundError{}))
+			a.NoError(client.DeleteRole(ctx, roleId))
+		})
+	}
+}
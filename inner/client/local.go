@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"idm/inner/assignment"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"idm/inner/storage"
+	"idm/inner/tabular"
+	"idm/inner/validator"
+	"io"
+	"time"
+)
+
+// Local операции через сервисы приложения напрямую в хранилище, без запущенного сервера
+type Local struct {
+	employees   *employee.Service
+	roles       *role.Service
+	assignments *assignment.Service
+}
+
+func NewLocal(store *storage.Storage) *Local {
+	var vld = validator.NewRequestValidator()
+	return &Local{
+		employees:   employee.NewService(store.Employees, vld),
+		roles:       role.NewService(store.Roles, vld),
+		assignments: assignment.NewService(store.Tx, store.Employees, store.Roles, vld),
+	}
+}
+
+func (local *Local) ListEmployees(ctx context.Context, filter employee.Filter) ([]employee.Response, error) {
+	return local.employees.GetAll(ctx, filter)
+}
+
+func (local *Local) CreateEmployee(ctx context.Context, req assignment.CreateEmployeeRequest) (int64, error) {
+	return local.assignments.CreateEmployee(ctx, req)
+}
+
+func (local *Local) DeleteEmployee(ctx context.Context, id int64) error {
+	// версия 0 - удаление без проверки версии строки, как If-Match: *
+	return local.employees.DeleteById(ctx, id, 0)
+}
+
+func (local *Local) ImportEmployees(ctx context.Context, fileName string, file io.Reader, apply bool) (employee.ImportReport, error) {
+	rows, err := employee.ReadImportFile(fileName, file, employee.DefaultImportMapping, time.Now())
+	if err != nil {
+		return employee.ImportReport{}, err
+	}
+	return local.employees.Import(ctx, rows, !apply)
+}
+
+func (local *Local) ExportEmployees(ctx context.Context, filter employee.Filter, format tabular.Format, writer io.Writer) error {
+	return local.employees.Export(ctx, filter, format, writer)
+}
+
+func (local *Local) ListRoles(ctx context.Context, filter role.Filter) ([]role.Response, error) {
+	return local.roles.GetAll(ctx, filter)
+}
+
+func (local *Local) CreateRole(ctx context.Context, req role.Request) (int64, error) {
+	return local.roles.Save(ctx, req)
+}
+
+func (local *Local) DeleteRole(ctx context.Context, id int64) error {
+	return local.roles.DeleteById(ctx, id, 0)
+}
+
+func (local *Local) ExportRoles(ctx context.Context, filter role.Filter, format tabular.Format, writer io.Writer) error {
+	return local.roles.Export(ctx, filter, format, writer)
+}
+
+func (local *Local) AssignRoles(ctx context.Context, employeeId int64, roleIds []int64) error {
+	return local.assignments.AssignRoles(ctx, employeeId, assignment.AssignRequest{RoleIds: roleIds})
+}
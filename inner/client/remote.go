@@ -0,0 +1,212 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"idm/inner/assignment"
+	"idm/inner/common"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"idm/inner/tabular"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Remote операции через HTTP API запущенного сервера IDM
+type Remote struct {
+	// адрес сервера, например http://localhost:8080
+	baseUrl string
+	http    *http.Client
+	// значение заголовка Authorization, если на сервере включена аутентификация
+	authorization string
+}
+
+// NewRemote клиент сервера baseUrl; если httpClient не передан, используется http.DefaultClient
+func NewRemote(baseUrl string, httpClient *http.Client) *Remote {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Remote{baseUrl: strings.TrimSuffix(baseUrl, "/") + "/api/v1", http: httpClient}
+}
+
+// WithAuthorization заголовок Authorization всех запросов, например "ApiKey idm_..."
+func (remote *Remote) WithAuthorization(authorization string) *Remote {
+	remote.authorization = authorization
+	return remote
+}
+
+func (remote *Remote) ListEmployees(ctx context.Context, filter employee.Filter) (responses []employee.Response, err error) {
+	err = remote.getJson(ctx, "/employees"+nameQuery(filter.Name), &responses)
+	return responses, err
+}
+
+func (remote *Remote) CreateEmployee(ctx context.Context, req assignment.CreateEmployeeRequest) (id int64, err error) {
+	err = remote.postJson(ctx, "/employees/with-roles", req, &id)
+	return id, err
+}
+
+func (remote *Remote) DeleteEmployee(ctx context.Context, id int64) error {
+	return remote.deleteById(ctx, "/employees", id)
+}
+
+func (remote *Remote) ImportEmployees(ctx context.Context, fileName string, file io.Reader, apply bool) (report employee.ImportReport, err error) {
+	var body bytes.Buffer
+	var form = multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", fileName)
+	if err != nil {
+		return report, err
+	}
+	if _, err = io.Copy(part, file); err != nil {
+		return report, err
+	}
+	if err = form.Close(); err != nil {
+		return report, err
+	}
+
+	var mode = "preview"
+	if apply {
+		mode = "apply"
+	}
+	// при некорректных строках сервер отвечает ошибкой, но отчёт всё равно приходит в данных ответа
+	err = remote.do(ctx, http.MethodPost, "/employees/import?mode="+mode, &body, form.FormDataContentType(), nil, &report)
+	return report, err
+}
+
+func (remote *Remote) ExportEmployees(ctx context.Context, filter employee.Filter, format tabular.Format, writer io.Writer) error {
+	return remote.export(ctx, "/employees/export", filter.Name, format, writer)
+}
+
+func (remote *Remote) ListRoles(ctx context.Context, filter role.Filter) (responses []role.Response, err error) {
+	err = remote.getJson(ctx, "/roles"+nameQuery(filter.Name), &responses)
+	return responses, err
+}
+
+func (remote *Remote) CreateRole(ctx context.Context, req role.Request) (id int64, err error) {
+	err = remote.postJson(ctx, "/roles", req, &id)
+	return id, err
+}
+
+func (remote *Remote) DeleteRole(ctx context.Context, id int64) error {
+	return remote.deleteById(ctx, "/roles", id)
+}
+
+func (remote *Remote) ExportRoles(ctx context.Context, filter role.Filter, format tabular.Format, writer io.Writer) error {
+	return remote.export(ctx, "/roles/export", filter.Name, format, writer)
+}
+
+func (remote *Remote) AssignRoles(ctx context.Context, employeeId int64, roleIds []int64) error {
+	return remote.postJson(ctx, "/employees/id/"+strconv.FormatInt(employeeId, 10)+"/roles", assignment.AssignRequest{RoleIds: roleIds}, nil)
+}
+
+// nameQuery параметр запроса с фильтром по части имени
+func nameQuery(name string) string {
+	if name == "" {
+		return ""
+	}
+	return "?" + url.Values{"name": {name}}.Encode()
+}
+
+func (remote *Remote) getJson(ctx context.Context, path string, data any) error {
+	return remote.do(ctx, http.MethodGet, path, nil, "", nil, data)
+}
+
+func (remote *Remote) postJson(ctx context.Context, path string, req any, data any) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return remote.do(ctx, http.MethodPost, path, bytes.NewReader(body), "application/json", nil, data)
+}
+
+// deleteById удаление без проверки версии строки: If-Match: * подходит к любой существующей версии
+func (remote *Remote) deleteById(ctx context.Context, path string, id int64) error {
+	var headers = http.Header{"If-Match": {"*"}}
+	return remote.do(ctx, http.MethodDelete, path+"/id/"+strconv.FormatInt(id, 10), nil, "", headers, nil)
+}
+
+// do запрос к API: данные успешного ответа (и ответа с ошибкой, если они есть) декодируются в data,
+// а ошибка ответа переводится в ошибку из пакета common по коду ответа
+func (remote *Remote) do(ctx context.Context, method string, path string, body io.Reader, contentType string, headers http.Header, data any) error {
+	resp, err := remote.send(ctx, method, path, body, contentType, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result common.ResponseBody[json.RawMessage]
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("error decoding response of %s %s (status %d): %w", method, path, resp.StatusCode, err)
+	}
+	if data != nil && len(result.Data) > 0 && string(result.Data) != "null" {
+		if err = json.Unmarshal(result.Data, data); err != nil {
+			return fmt.Errorf("error decoding response data of %s %s: %w", method, path, err)
+		}
+	}
+	if resp.StatusCode >= http.StatusBadRequest || !result.Success {
+		return statusError(resp.StatusCode, result.Message)
+	}
+	return nil
+}
+
+// export потоковая выгрузка: тело ответа копируется в writer без накопления в памяти
+func (remote *Remote) export(ctx context.Context, path string, name string, format tabular.Format, writer io.Writer) error {
+	var query = url.Values{"format": {string(format)}}
+	if name != "" {
+		query.Set("name", name)
+	}
+	resp, err := remote.send(ctx, http.MethodGet, path+"?"+query.Encode(), nil, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var result common.ResponseBody[json.RawMessage]
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return statusError(resp.StatusCode, result.Message)
+	}
+	_, err = io.Copy(writer, resp.Body)
+	return err
+}
+
+func (remote *Remote) send(ctx context.Context, method string, path string, body io.Reader, contentType string, headers http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, remote.baseUrl+path, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if remote.authorization != "" {
+		req.Header.Set("Authorization", remote.authorization)
+	}
+
+	resp, err := remote.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending %s %s: %w", method, path, err)
+	}
+	return resp, nil
+}
+
+// statusError ошибка из пакета common по коду ответа сервера, как её выбирает crud.WriteError
+func statusError(status int, message string) error {
+	switch status {
+	case http.StatusBadRequest:
+		return common.RequestValidationError{Message: message}
+	case http.StatusNotFound:
+		return common.NotFoundError{Message: message}
+	case http.StatusPreconditionFailed:
+		return common.PreconditionFailedError{Message: message}
+	default:
+		return fmt.Errorf("server responded with status %d: %s", status, message)
+	}
+}
@@ -1,6 +1,8 @@
 package common
 
 import (
+	"fmt"
+
 	"github.com/gofiber/fiber"
 )
 
@@ -16,10 +18,56 @@ type DbOperationError struct {
 	Message string
 }
 
+type NotFoundError struct {
+	Message string
+}
+
+// PreconditionFailedError версия строки не совпала с ожидаемой (If-Match)
+type PreconditionFailedError struct {
+	Message string
+}
+
+// ForbiddenError у вызывающего нет разрешения на операцию
+type ForbiddenError struct {
+	Message string
+}
+
+// MaxBatchSize максимальное количество элементов в одном пакетном запросе
+const MaxBatchSize = 5000
+
+// BatchItemResult результат обработки одного элемента пакетного запроса
+type BatchItemResult struct {
+	Index int    `json:"index"`
+	Id    int64  `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// режимы пакетной обработки
+const (
+	// все элементы обрабатываются в одной транзакции: либо все, либо ничего
+	BatchModeAtomic = "atomic"
+	// обрабатываются только корректные элементы, по остальным возвращается ошибка
+	BatchModePartial = "partial"
+)
+
+// ParseBatchMode получение режима пакетной обработки из параметра запроса "mode", по умолчанию atomic
+func ParseBatchMode(c *fiber.Ctx) (atomic bool, err error) {
+	switch mode := c.Query("mode", BatchModeAtomic); mode {
+	case BatchModeAtomic:
+		return true, nil
+	case BatchModePartial:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown batch mode: %s", mode)
+	}
+}
+
 type ResponseBody[T any] struct {
 	Success bool   `json:"success"`
 	Message string `json:"error"`
 	Data    T      `json:"data"`
+	// идентификатор запроса из заголовка X-Request-ID (только в ответах с ошибкой), по нему ищутся записи в логах
+	RequestId string `json:"requestId,omitempty"`
 }
 
 func ErrResponse(
@@ -28,12 +76,33 @@ func ErrResponse(
 	message string,
 ) error {
 	return c.Status(code).JSON(&ResponseBody[any]{
-		Success: false,
-		Message: message,
-		Data:    nil,
+		Success:   false,
+		Message:   message,
+		Data:      nil,
+		RequestId: requestId(c),
+	})
+}
+
+// ErrResponseWithData ответ с ошибкой, дополненный данными (например, результатами по элементам пакета)
+func ErrResponseWithData[T any](
+	c *fiber.Ctx,
+	code int,
+	message string,
+	data T,
+) error {
+	return c.Status(code).JSON(&ResponseBody[T]{
+		Success:   false,
+		Message:   message,
+		Data:      data,
+		RequestId: requestId(c),
 	})
 }
 
+// requestId идентификатор запроса, назначенный middleware web.RequestID (заголовок ответа X-Request-ID)
+func requestId(c *fiber.Ctx) string {
+	return string(c.Fasthttp.Response.Header.Peek(fiber.HeaderXRequestID))
+}
+
 func OkResponse[T any](
 	c *fiber.Ctx,
 	data T,
@@ -63,3 +132,15 @@ func (err AlreadyExistsError) Error() string {
 func (err DbOperationError) Error() string {
 	return err.Message
 }
+
+func (err NotFoundError) Error() string {
+	return err.Message
+}
+
+func (err PreconditionFailedError) Error() string {
+	return err.Message
+}
+
+func (err ForbiddenError) Error() string {
+	return err.Message
+}
@@ -0,0 +1,91 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber"
+)
+
+// ETag строгий ETag по версии строки
+func ETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ParseIfMatch получение ожидаемой версии из заголовка If-Match.
+// Для "*" возвращается 0: подходит любая существующая версия.
+func ParseIfMatch(header string) (version int64, err error) {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return 0, nil
+	}
+	// для If-Match допускается только строгое сравнение, поэтому слабые ETag не принимаются
+	if !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) || len(header) < 3 {
+		return 0, fmt.Errorf("invalid If-Match header: %s", header)
+	}
+
+	version, err = strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("invalid If-Match header: %s", header)
+	}
+	return version, nil
+}
+
+// MatchesETag проверка заголовка If-None-Match: список ETag через запятую или "*", сравнение слабое
+func MatchesETag(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireIfMatch чтение обязательного заголовка If-Match.
+// При отсутствии заголовка отвечает 428 Precondition Required, при неверном формате - 400.
+func RequireIfMatch(c *fiber.Ctx) (version int64, ok bool) {
+	var header = c.Get(fiber.HeaderIfMatch)
+	if header == "" {
+		_ = ErrResponse(c, fiber.StatusPreconditionRequired, "If-Match header is required")
+		return 0, false
+	}
+
+	version, err := ParseIfMatch(header)
+	if err != nil {
+		_ = ErrResponse(c, fiber.StatusBadRequest, err.Error())
+		return 0, false
+	}
+	return version, true
+}
+
+// RequireIfMatchList чтение обязательного заголовка If-Match для пакетной операции над count записями:
+// список ETag через запятую в том же порядке, что и id, или "*" для любых существующих версий.
+// При отсутствии заголовка отвечает 428 Precondition Required, при неверном формате или количестве - 400.
+func RequireIfMatchList(c *fiber.Ctx, count int) (versions []int64, ok bool) {
+	var header = c.Get(fiber.HeaderIfMatch)
+	if header == "" {
+		_ = ErrResponse(c, fiber.StatusPreconditionRequired, "If-Match header is required")
+		return nil, false
+	}
+
+	versions = make([]int64, count)
+	if strings.TrimSpace(header) == "*" {
+		return versions, true
+	}
+	var etags = strings.Split(header, ",")
+	if len(etags) != count {
+		_ = ErrResponse(c, fiber.StatusBadRequest, fmt.Sprintf("If-Match header must contain %d ETags, one for each id", count))
+		return nil, false
+	}
+	for i, etag := range etags {
+		version, err := ParseIfMatch(etag)
+		if err != nil || version == 0 {
+			_ = ErrResponse(c, fiber.StatusBadRequest, fmt.Sprintf("invalid If-Match header: %s", strings.TrimSpace(etag)))
+			return nil, false
+		}
+		versions[i] = version
+	}
+	return versions, true
+}
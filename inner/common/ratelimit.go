@@ -0,0 +1,47 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit ограничение частоты запросов: не больше Requests запросов за Period.
+// Запросы можно выполнить подряд, после чего разрешение на очередной запрос появляется раз в Period/Requests.
+// Нулевое значение - без ограничения.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseRateLimit разбор ограничения в формате "100/1m" (100 запросов в минуту), пустая строка или "off" - без ограничения
+func ParseRateLimit(value string) (RateLimit, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "off" {
+		return RateLimit{}, nil
+	}
+	requestsValue, periodValue, found := strings.Cut(value, "/")
+	requests, err := strconv.Atoi(strings.TrimSpace(requestsValue))
+	if !found || err != nil || requests <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit: %s", value)
+	}
+	period, err := time.ParseDuration(strings.TrimSpace(periodValue))
+	if err != nil || period <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit: %s", value)
+	}
+	return RateLimit{Requests: requests, Period: period}, nil
+}
+
+// Unlimited ограничение не задано
+func (limit RateLimit) Unlimited() bool {
+	return limit.Requests <= 0 || limit.Period <= 0
+}
+
+// String ограничение в том же формате, в котором оно задаётся
+func (limit RateLimit) String() string {
+	if limit.Unlimited() {
+		return ""
+	}
+	return fmt.Sprintf("%d/%s", limit.Requests, limit.Period)
+}
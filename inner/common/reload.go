@@ -0,0 +1,147 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LiveConfig конфигурация, которую можно перечитать без перезапуска процесса.
+// Перечитываются только поля с тегом reload:"true", остальные изменения вступят в силу после перезапуска.
+// Компоненты, поддерживающие перезагрузку, получают значения через Get при каждом запросе.
+type LiveConfig struct {
+	loader  Loader
+	current atomic.Pointer[versionedConfig]
+	// перезагрузки по сигналу и по изменению файла не должны выполняться одновременно
+	reloadMu sync.Mutex
+}
+
+type versionedConfig struct {
+	cfg      Config
+	version  int64
+	loadedAt time.Time
+}
+
+// ReloadResult результат перезагрузки конфигурации
+type ReloadResult struct {
+	// версия действующей конфигурации: увеличивается при каждом применённом изменении
+	Version int64
+	// применённые параметры
+	Applied []string
+	// изменённые параметры, которые требуют перезапуска
+	RestartRequired []string
+}
+
+// NewLiveConfig действующая конфигурация cfg версии 1, перезагрузка читает те же источники loader
+func NewLiveConfig(loader Loader, cfg Config) *LiveConfig {
+	var live = &LiveConfig{loader: loader}
+	live.current.Store(&versionedConfig{cfg: cfg, version: 1, loadedAt: time.Now()})
+	return live
+}
+
+// Get действующая конфигурация
+func (live *LiveConfig) Get() Config {
+	return live.current.Load().cfg
+}
+
+// Version версия действующей конфигурации и время её применения
+func (live *LiveConfig) Version() (int64, time.Time) {
+	var current = live.current.Load()
+	return current.version, current.loadedAt
+}
+
+// Reload чтение и валидация конфигурации, затем атомарная замена перезагружаемых параметров.
+// При ошибке чтения или валидации действующая конфигурация не меняется.
+func (live *LiveConfig) Reload() (ReloadResult, error) {
+	live.reloadMu.Lock()
+	defer live.reloadMu.Unlock()
+
+	var current = live.current.Load()
+	loaded, err := live.loader.Load()
+	if err != nil {
+		return ReloadResult{Version: current.version}, err
+	}
+
+	var next = current.cfg
+	var result ReloadResult
+	var source, target = reflect.ValueOf(loaded), reflect.ValueOf(&next).Elem()
+	for _, field := range configFields {
+		if reflect.DeepEqual(source.Field(field.index).Interface(), target.Field(field.index).Interface()) {
+			continue
+		}
+		if !field.reloadable {
+			result.RestartRequired = append(result.RestartRequired, field.env)
+			continue
+		}
+		target.Field(field.index).Set(source.Field(field.index))
+		result.Applied = append(result.Applied, field.env)
+	}
+
+	result.Version = current.version
+	if len(result.Applied) > 0 {
+		result.Version++
+		live.current.Store(&versionedConfig{cfg: next, version: result.Version, loadedAt: time.Now()})
+	}
+	return result, nil
+}
+
+// Watch перезагрузка конфигурации при изменении YAML-файла или .env файла (проверка раз в interval)
+// и при получении значения из trigger (например, сигнала SIGHUP). Работает до отмены ctx.
+func (live *LiveConfig) Watch(ctx context.Context, interval time.Duration, trigger <-chan os.Signal) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var files = []string{live.loader.ConfigFile, live.loader.EnvFile}
+	var stamps = fileStamps(files)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-trigger:
+			stamps = fileStamps(files)
+			live.reloadAndLog("signal")
+		case <-ticker.C:
+			var changed = fileStamps(files)
+			if changed != stamps {
+				stamps = changed
+				live.reloadAndLog("file change")
+			}
+		}
+	}
+}
+
+func (live *LiveConfig) reloadAndLog(reason string) {
+	result, err := live.Reload()
+	if err != nil {
+		slog.Error("config reload failed, keeping current version", "reason", reason, "version", result.Version, "error", err)
+		return
+	}
+	if len(result.Applied) > 0 {
+		slog.Info("config reloaded", "reason", reason, "version", result.Version, "applied", strings.Join(result.Applied, ", "))
+	}
+	if len(result.RestartRequired) > 0 {
+		slog.Warn("config reload: restart required", "reason", reason, "changed", strings.Join(result.RestartRequired, ", "))
+	}
+	if len(result.Applied) == 0 && len(result.RestartRequired) == 0 {
+		slog.Info("config reload: no changes", "reason", reason, "version", result.Version)
+	}
+}
+
+// fileStamps время изменения и размер файлов: по ним видно, что файл изменился
+func fileStamps(files []string) (stamps string) {
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil {
+			stamps += fmt.Sprintf("%s %s %d;", file, info.ModTime(), info.Size())
+		}
+	}
+	return stamps
+}
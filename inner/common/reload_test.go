@@ -0,0 +1,66 @@
+package common
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiveConfigReload(t *testing.T) {
+	a := assert.New(t)
+	clearConfigEnv(t)
+
+	var configFile = writeFile(t, "idm.yaml", "storage: memory\napp_name: idm\napp_version: '1.0'\nrequest_timeout: 10s\n")
+	var loader = Loader{ConfigFile: configFile}
+	cfg, err := loader.Load()
+	a.Nil(err)
+	var live = NewLiveConfig(loader, cfg)
+
+	t.Run("should apply reloadable settings and report settings requiring restart", func(t *testing.T) {
+		a.Nil(os.WriteFile(configFile, []byte("storage: memory\napp_name: idm\napp_version: '1.0'\nrequest_timeout: 30s\nlog_level: debug\nhttp_address: ':9000'\n"), 0o600))
+
+		result, err := live.Reload()
+
+		a.Nil(err)
+		a.Equal(int64(2), result.Version)
+		a.Equal([]string{"REQUEST_TIMEOUT", "LOG_LEVEL"}, result.Applied)
+		a.Equal([]string{"HTTP_ADDRESS"}, result.RestartRequired)
+		a.Equal(30*time.Second, live.Get().RequestTimeout)
+		a.Equal("debug", live.Get().LogLevel)
+		a.Equal(":8080", live.Get().HttpAddress)
+	})
+
+	t.Run("should keep version without changes", func(t *testing.T) {
+		result, err := live.Reload()
+
+		a.Nil(err)
+		a.Equal(int64(2), result.Version)
+		a.Empty(result.Applied)
+	})
+
+	t.Run("should keep active config when new config is invalid", func(t *testing.T) {
+		a.Nil(os.WriteFile(configFile, []byte("storage: memory\napp_name: idm\napp_version: '1.0'\nrequest_timeout: 1m\nlog_level: trace\n"), 0o600))
+
+		_, err := live.Reload()
+
+		a.NotNil(err)
+		version, _ := live.Version()
+		a.Equal(int64(2), version)
+		a.Equal(30*time.Second, live.Get().RequestTimeout)
+	})
+
+	t.Run("should reload on trigger", func(t *testing.T) {
+		a.Nil(os.WriteFile(configFile, []byte("storage: memory\napp_name: idm\napp_version: '1.0'\nrequest_timeout: 1m\n"), 0o600))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var trigger = make(chan os.Signal, 1)
+		go live.Watch(ctx, time.Hour, trigger)
+
+		trigger <- os.Interrupt
+
+		a.Eventually(func() bool { return live.Get().RequestTimeout == time.Minute }, time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,342 @@
+package crud
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"idm/inner/common"
+	"idm/inner/tabular"
+	"idm/inner/web"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber"
+)
+
+// Srv операции сервиса, которые используют обобщённые хендлеры
+type Srv[F any, Req any, R any] interface {
+	SaveBatch(ctx context.Context, reqs []Req, atomic bool) ([]common.BatchItemResult, error)
+	FindById(ctx context.Context, id int64) (R, error)
+	FindByIds(ctx context.Context, ids []int64) ([]R, error)
+	GetAll(ctx context.Context, filter F) ([]R, error)
+	Export(ctx context.Context, filter F, format tabular.Format, writer io.Writer) error
+	Update(ctx context.Context, id int64, version int64, req Req) (R, error)
+	DeleteById(ctx context.Context, id int64, version int64) error
+	DeleteByIds(ctx context.Context, ids []int64, versions []int64) error
+}
+
+// Handlers обобщённые хендлеры: маршруты, одинаковые для всех ресурсов
+type Handlers[F any, Req any, R any] struct {
+	service Srv[F, Req, R]
+	// название ресурса в сообщениях об ошибках, в единственном и множественном числе
+	name   string
+	plural string
+	// версия строки для ETag
+	version func(resp R) int64
+}
+
+func NewHandlers[F any, Req any, R any](service Srv[F, Req, R], name string, plural string, version func(resp R) int64) *Handlers[F, Req, R] {
+	return &Handlers[F, Req, R]{
+		service: service,
+		name:    name,
+		plural:  plural,
+		version: version,
+	}
+}
+
+// RegisterRoutes регистрация маршрутов ресурса с путём path, например "/employees"
+func (h *Handlers[F, Req, R]) RegisterRoutes(router fiber.Router, path string) {
+	router.Post(path+"/batch", h.CreateBatch)
+	router.Get(path, h.GetAll)
+	router.Get(path+"/export", h.Export)
+	router.Get(path+"/id/:id", h.FindById)
+	router.Get(path+"/ids", h.FindByIds)
+	router.Put(path+"/id/:id", h.Update)
+	router.Delete(path+"/id/:id", h.DeleteById)
+	router.Delete(path+"/ids", h.DeleteByIds)
+}
+
+// CreateBatch хендлер POST-запроса "<path>/batch": создание списка в режиме ?mode=atomic|partial
+func (h *Handlers[F, Req, R]) CreateBatch(ctx *fiber.Ctx) {
+	atomic, err := common.ParseBatchMode(ctx)
+	if err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+		return
+	}
+
+	var reqs []Req
+	if err = ctx.BodyParser(&reqs); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(reqs) == 0 || len(reqs) > common.MaxBatchSize {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, fmt.Sprintf("batch size must be between 1 and %d", common.MaxBatchSize))
+		return
+	}
+
+	results, err := h.service.SaveBatch(web.Context(ctx), reqs, atomic)
+	if err != nil {
+		switch {
+
+		// в ответ добавляем результаты по элементам, чтобы клиент увидел, какие из них некорректны
+		case errors.As(err, &common.RequestValidationError{}) || errors.As(err, &common.AlreadyExistsError{}):
+			_ = common.ErrResponseWithData(ctx, fiber.StatusBadRequest, err.Error(), results)
+
+		default:
+			_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	if err = common.OkResponse(ctx, results); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning created "+h.plural)
+		return
+	}
+}
+
+// FindById хендлер GET-запроса "<path>/id/:id": ответ содержит ETag с версией строки,
+// а при совпадении с If-None-Match возвращается 304 Not Modified
+func (h *Handlers[F, Req, R]) FindById(ctx *fiber.Ctx) {
+	num, ok := ParamId(ctx)
+	if !ok {
+		return
+	}
+
+	foundResponse, err := h.service.FindById(web.Context(ctx), num)
+	if err != nil {
+		WriteError(ctx, err)
+		return
+	}
+
+	var etag = common.ETag(h.version(foundResponse))
+	ctx.Set(fiber.HeaderETag, etag)
+	if noneMatch := ctx.Get(fiber.HeaderIfNoneMatch); noneMatch != "" && common.MatchesETag(noneMatch, etag) {
+		ctx.SendStatus(fiber.StatusNotModified)
+		return
+	}
+
+	if err = common.OkResponse(ctx, foundResponse); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning found "+h.name)
+		return
+	}
+}
+
+// FindByIds хендлер GET-запроса "<path>/ids?ids=1,2,3"
+func (h *Handlers[F, Req, R]) FindByIds(ctx *fiber.Ctx) {
+	ids, ok := queryIds(ctx)
+	if !ok {
+		return
+	}
+
+	var foundResponses, err = h.service.FindByIds(web.Context(ctx), ids)
+	if err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = common.OkResponse(ctx, foundResponses); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning found "+h.plural)
+		return
+	}
+}
+
+// GetAll хендлер GET-запроса "<path>" с фильтрами из параметров запроса
+func (h *Handlers[F, Req, R]) GetAll(ctx *fiber.Ctx) {
+	var filter F
+	if err := ctx.QueryParser(&filter); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid query parameters")
+		return
+	}
+
+	var foundResponses, err = h.service.GetAll(web.Context(ctx), filter)
+	if err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = common.OkResponse(ctx, foundResponses); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning all "+h.plural)
+		return
+	}
+}
+
+// Export хендлер GET-запроса "<path>/export".
+// Формат выбирается параметром запроса format (csv, ndjson, xlsx) или заголовком Accept.
+// Строки пишутся в ответ по мере чтения из базы данных, поэтому ответ не накапливается в памяти.
+func (h *Handlers[F, Req, R]) Export(ctx *fiber.Ctx) {
+	var filter F
+	if err := ctx.QueryParser(&filter); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid query parameters")
+		return
+	}
+
+	format, err := tabular.NegotiateFormat(ctx.Query("format"), ctx.Get(fiber.HeaderAccept))
+	if err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx.Set(fiber.HeaderContentType, tabular.ContentType(format))
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.%s"`, h.plural, format))
+	// тело ответа пишется уже после выхода из хендлера, поэтому статус ответа при ошибке изменить нельзя,
+	// а контекст запроса к этому моменту уже отменён - выгрузка получает свой контекст с тем же таймаутом
+	var timeout = web.RequestTimeout(ctx)
+	// значения контекста запроса (идентификатор запроса, span) нужны выгрузке для логов и трассировки
+	var requestCtx = context.WithoutCancel(web.Context(ctx))
+	ctx.Fasthttp.SetBodyStreamWriter(func(writer *bufio.Writer) {
+		var exportCtx = requestCtx
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			exportCtx, cancel = context.WithTimeout(exportCtx, timeout)
+			defer cancel()
+		}
+		if err := h.service.Export(exportCtx, filter, format, writer); err != nil {
+			slog.ErrorContext(exportCtx, "error exporting "+h.plural, "error", err)
+		}
+	})
+}
+
+// Update хендлер PUT-запроса "<path>/id/:id".
+// Заголовок If-Match обязателен: изменение применяется, только если версия записи не изменилась.
+func (h *Handlers[F, Req, R]) Update(ctx *fiber.Ctx) {
+	num, ok := ParamId(ctx)
+	if !ok {
+		return
+	}
+
+	version, ok := common.RequireIfMatch(ctx)
+	if !ok {
+		return
+	}
+
+	var req Req
+	if err := ctx.BodyParser(&req); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+		return
+	}
+
+	updated, err := h.service.Update(web.Context(ctx), num, version, req)
+	if err != nil {
+		WriteError(ctx, err)
+		return
+	}
+
+	ctx.Set(fiber.HeaderETag, common.ETag(h.version(updated)))
+	if err = common.OkResponse(ctx, updated); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning updated "+h.name)
+		return
+	}
+}
+
+// DeleteById хендлер DELETE-запроса "<path>/id/:id" с обязательным заголовком If-Match
+func (h *Handlers[F, Req, R]) DeleteById(ctx *fiber.Ctx) {
+	num, ok := ParamId(ctx)
+	if !ok {
+		return
+	}
+
+	version, ok := common.RequireIfMatch(ctx)
+	if !ok {
+		return
+	}
+
+	err := h.service.DeleteById(web.Context(ctx), num, version)
+	if err != nil {
+		WriteError(ctx, err)
+		return
+	}
+
+	if err = common.ResponseWithoutData(ctx); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning result delete "+h.name)
+		return
+	}
+}
+
+// DeleteByIds хендлер DELETE-запроса "<path>/ids?ids=1,2,3" с обязательным заголовком If-Match:
+// ETag каждой записи в порядке ids (If-Match: "3", "1", "7") или "*" для любых версий.
+// Если хотя бы одна запись изменилась или не найдена, не удаляется ни одна.
+func (h *Handlers[F, Req, R]) DeleteByIds(ctx *fiber.Ctx) {
+	ids, ok := queryIds(ctx)
+	if !ok {
+		return
+	}
+
+	versions, ok := common.RequireIfMatchList(ctx, len(ids))
+	if !ok {
+		return
+	}
+
+	var err = h.service.DeleteByIds(web.Context(ctx), ids, versions)
+	if err != nil {
+		WriteError(ctx, err)
+		return
+	}
+
+	if err = common.ResponseWithoutData(ctx); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning result delete "+h.plural)
+		return
+	}
+}
+
+// WriteError ответ на ошибку сервиса: код ответа выбирается по типу ошибки
+func WriteError(ctx *fiber.Ctx, err error) {
+	switch {
+	case errors.As(err, &common.RequestValidationError{}) || errors.As(err, &common.AlreadyExistsError{}):
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	case errors.As(err, &common.NotFoundError{}):
+		_ = common.ErrResponse(ctx, fiber.StatusNotFound, err.Error())
+	case errors.As(err, &common.ForbiddenError{}):
+		_ = common.ErrResponse(ctx, fiber.StatusForbidden, err.Error())
+	case errors.As(err, &common.PreconditionFailedError{}):
+		_ = common.ErrResponse(ctx, fiber.StatusPreconditionFailed, err.Error())
+	default:
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, err.Error())
+	}
+}
+
+// ParamId id из параметра маршрута ":id", при ошибке ответ уже отправлен
+func ParamId(ctx *fiber.Ctx) (id int64, ok bool) {
+	var idStr string
+	if idStr = ctx.Params("id"); idStr == "" {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "error retrieving id")
+		return 0, false
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "error converted id tot int64")
+		return 0, false
+	}
+	return id, true
+}
+
+// queryIds список id из параметра запроса "ids" через запятую, при ошибке ответ уже отправлен
+func queryIds(ctx *fiber.Ctx) (ids []int64, ok bool) {
+	var req struct {
+		IDs string `query:"ids"`
+	}
+
+	if err := ctx.QueryParser(&req); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid query parameters")
+		return nil, false
+	}
+
+	if req.IDs == "" {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "ids parameter is required")
+		return nil, false
+	}
+
+	for _, idStr := range strings.Split(req.IDs, ",") {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id format: "+idStr)
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
@@ -0,0 +1,313 @@
+package crud
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"idm/inner/common"
+	"idm/inner/memory"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// MemoryTable описание сущности для обобщённого репозитория в памяти
+type MemoryTable[E any, F any] struct {
+	// имя таблицы в сообщениях об ошибках
+	Name       string
+	Id         func(entity *E) int64
+	SetId      func(entity *E, id int64)
+	Version    func(entity *E) int64
+	SetVersion func(entity *E, version int64)
+	// уникальное имя сущности (уникальность без учёта регистра)
+	Key func(entity *E) string
+	// подходит ли сущность под фильтры списка
+	Match func(filter F, entity *E) bool
+	// Apply копирование в строку изменяемых полей (столбцов Table.Columns) из changes
+	Apply func(row *E, changes *E)
+	// Defaults значения по умолчанию для полей, не заполняемых при создании (DEFAULT в таблице)
+	Defaults func(entity *E)
+}
+
+// MemoryRepository обобщённый репозиторий в памяти с теми же методами, что и Repository.
+// Все запросы выполняются под блокировкой memory.DB, а внутри InTransaction - в её транзакции.
+type MemoryRepository[E any, F any] struct {
+	db     *memory.DB
+	table  MemoryTable[E, F]
+	rows   map[int64]E
+	lastId int64
+}
+
+func NewMemoryRepository[E any, F any](db *memory.DB, table MemoryTable[E, F]) *MemoryRepository[E, F] {
+	var rep = &MemoryRepository[E, F]{db: db, table: table, rows: make(map[int64]E)}
+	db.Register(rep)
+	return rep
+}
+
+// Snapshot копия строк таблицы для отката транзакции
+func (rep *MemoryRepository[E, F]) Snapshot() (restore func()) {
+	var rows, lastId = maps.Clone(rep.rows), rep.lastId
+	return func() {
+		rep.rows, rep.lastId = rows, lastId
+	}
+}
+
+// Lock монопольный доступ к таблице для собственных запросов ресурса
+func (rep *MemoryRepository[E, F]) Lock(ctx context.Context) (unlock func(), err error) {
+	return rep.db.Lock(ctx)
+}
+
+func (rep *MemoryRepository[E, F]) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return rep.db.Do(ctx, fn)
+}
+
+// sorted строки в порядке id, как в ORDER BY id
+func (rep *MemoryRepository[E, F]) sorted(match func(entity *E) bool) []E {
+	var entities []E
+	for _, id := range slices.Sorted(maps.Keys(rep.rows)) {
+		var entity = rep.rows[id]
+		if match(&entity) {
+			entities = append(entities, entity)
+		}
+	}
+	return entities
+}
+
+// checkUnique проверка уникальности имени без учёта регистра, как у уникального индекса по LOWER(name)
+func (rep *MemoryRepository[E, F]) checkUnique(entity *E) error {
+	var key = rep.table.Key(entity)
+	for id, row := range rep.rows {
+		if id != rep.table.Id(entity) && strings.EqualFold(rep.table.Key(&row), key) {
+			return common.AlreadyExistsError{Message: fmt.Sprintf("%s already exists: name %s", rep.table.Name, key)}
+		}
+	}
+	return nil
+}
+
+// Insert создание сущности с новым id и версией 1
+func (rep *MemoryRepository[E, F]) Insert(ctx context.Context, entity *E) (id int64, err error) {
+	unlock, err := rep.Lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	return rep.insert(entity)
+}
+
+func (rep *MemoryRepository[E, F]) insert(entity *E) (id int64, err error) {
+	rep.table.SetId(entity, 0)
+	if err = rep.checkUnique(entity); err != nil {
+		return 0, err
+	}
+
+	if rep.table.Defaults != nil {
+		rep.table.Defaults(entity)
+	}
+	rep.lastId++
+	rep.table.SetId(entity, rep.lastId)
+	rep.table.SetVersion(entity, 1)
+	rep.rows[rep.lastId] = *entity
+	return rep.lastId, nil
+}
+
+// ExistsByName есть ли сущность с таким именем без учёта регистра
+func (rep *MemoryRepository[E, F]) ExistsByName(ctx context.Context, name string) (isExists bool, err error) {
+	names, err := rep.FindExistingNamesTx(ctx, []string{name})
+	return len(names) > 0, err
+}
+
+func (rep *MemoryRepository[E, F]) FindExistingNamesTx(ctx context.Context, names []string) (existing []string, err error) {
+	entities, err := rep.FindByNamesTx(ctx, names)
+	for _, entity := range entities {
+		existing = append(existing, rep.table.Key(&entity))
+	}
+	return existing, err
+}
+
+func (rep *MemoryRepository[E, F]) FindByNamesTx(ctx context.Context, names []string) (entities []E, err error) {
+	unlock, err := rep.Lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var lowered = LowerNames(names)
+	return rep.sorted(func(entity *E) bool {
+		return slices.Contains(lowered, strings.ToLower(rep.table.Key(entity)))
+	}), nil
+}
+
+func (rep *MemoryRepository[E, F]) SaveBatchTx(ctx context.Context, entities []*E) (ids []int64, err error) {
+	unlock, err := rep.Lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// как и многострочный INSERT, пакет создаётся целиком или не создаётся совсем
+	var restore = rep.Snapshot()
+	for _, entity := range entities {
+		id, err := rep.insert(entity)
+		if err != nil {
+			restore()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Count количество сущностей в таблице
+func (rep *MemoryRepository[E, F]) Count(ctx context.Context) (count int64, err error) {
+	unlock, err := rep.Lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	return int64(len(rep.rows)), nil
+}
+
+func (rep *MemoryRepository[E, F]) FindById(ctx context.Context, id int64) (entity E, err error) {
+	unlock, err := rep.Lock(ctx)
+	if err != nil {
+		return entity, err
+	}
+	defer unlock()
+
+	entity, ok := rep.rows[id]
+	if !ok {
+		return entity, sql.ErrNoRows
+	}
+	return entity, nil
+}
+
+func (rep *MemoryRepository[E, F]) FindByIds(ctx context.Context, ids []int64) (entities []E, err error) {
+	unlock, err := rep.Lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return rep.sorted(func(entity *E) bool {
+		return slices.Contains(ids, rep.table.Id(entity))
+	}), nil
+}
+
+func (rep *MemoryRepository[E, F]) GetAll(ctx context.Context, filter F) (entities []E, err error) {
+	unlock, err := rep.Lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return rep.sorted(func(entity *E) bool {
+		return rep.table.Match(filter, entity)
+	}), nil
+}
+
+// Stream обход сущностей по копии списка: fn вызывается без блокировки таблицы
+func (rep *MemoryRepository[E, F]) Stream(ctx context.Context, filter F, fn func(entity E) error) error {
+	entities, err := rep.GetAll(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	for _, entity := range entities {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if err = fn(entity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Modify изменение сущностей с переданными id через fn, версия строки увеличивается
+func (rep *MemoryRepository[E, F]) Modify(ctx context.Context, ids []int64, fn func(entity *E)) error {
+	unlock, err := rep.Lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, id := range ids {
+		if entity, ok := rep.rows[id]; ok {
+			fn(&entity)
+			rep.table.SetVersion(&entity, rep.table.Version(&entity)+1)
+			rep.rows[id] = entity
+		}
+	}
+	return nil
+}
+
+// UpdateTx обновление сущности по её id без проверки версии строки
+func (rep *MemoryRepository[E, F]) UpdateTx(ctx context.Context, entity *E) error {
+	// как и UPDATE без подходящих строк, отсутствие сущности не ошибка
+	_, err := rep.Update(ctx, entity, 0)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+// Update обновление сущности с проверкой версии строки (при expectedVersion == 0 версия не проверяется).
+// Если строка не найдена или версия не совпала, возвращается sql.ErrNoRows.
+func (rep *MemoryRepository[E, F]) Update(ctx context.Context, entity *E, expectedVersion int64) (updated E, err error) {
+	unlock, err := rep.Lock(ctx)
+	if err != nil {
+		return updated, err
+	}
+	defer unlock()
+
+	current, ok := rep.rows[rep.table.Id(entity)]
+	if !ok || (expectedVersion > 0 && rep.table.Version(&current) != expectedVersion) {
+		return updated, sql.ErrNoRows
+	}
+	if err = rep.checkUnique(entity); err != nil {
+		return updated, err
+	}
+
+	updated = current
+	rep.table.Apply(&updated, entity)
+	rep.table.SetVersion(&updated, rep.table.Version(&current)+1)
+	rep.rows[rep.table.Id(entity)] = updated
+	return updated, nil
+}
+
+// DeleteById удаление с проверкой версии строки (при version == 0 версия не проверяется)
+func (rep *MemoryRepository[E, F]) DeleteById(ctx context.Context, id int64, version int64) (deleted bool, err error) {
+	unlock, err := rep.Lock(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	current, ok := rep.rows[id]
+	if !ok || (version > 0 && rep.table.Version(&current) != version) {
+		return false, nil
+	}
+	delete(rep.rows, id)
+	return true, nil
+}
+
+func (rep *MemoryRepository[E, F]) DeleteByIds(ctx context.Context, ids []int64) error {
+	unlock, err := rep.Lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, id := range ids {
+		delete(rep.rows, id)
+	}
+	return nil
+}
+
+// ContainsFold поиск подстроки без учёта регистра, как у NameFilter
+func ContainsFold(value string, substr string) bool {
+	return strings.Contains(strings.ToLower(value), strings.ToLower(substr))
+}
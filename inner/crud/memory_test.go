@@ -0,0 +1,79 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"idm/inner/common"
+	"idm/inner/memory"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var itemMemoryTable = MemoryTable[item, string]{
+	Name:       "item",
+	Id:         func(entity *item) int64 { return entity.Id },
+	SetId:      func(entity *item, id int64) { entity.Id = id },
+	Version:    func(entity *item) int64 { return entity.Version },
+	SetVersion: func(entity *item, version int64) { entity.Version = version },
+	Key:        func(entity *item) string { return entity.Name },
+	Match:      func(filter string, entity *item) bool { return ContainsFold(entity.Name, filter) },
+	Apply:      func(row *item, changes *item) { row.Name = changes.Name },
+}
+
+func TestMemoryRepository(t *testing.T) {
+	a := assert.New(t)
+	var ctx = context.Background()
+
+	t.Run("should keep names unique ignoring case", func(t *testing.T) {
+		var rep = NewMemoryRepository(memory.NewDB(), itemMemoryTable)
+		id, err := rep.Insert(ctx, &item{Name: "Admin"})
+		a.NoError(err)
+		a.Equal(int64(1), id)
+
+		_, err = rep.Insert(ctx, &item{Name: "ADMIN"})
+		a.ErrorAs(err, &common.AlreadyExistsError{})
+		_, err = rep.SaveBatchTx(ctx, []*item{{Name: "user"}, {Name: "admin"}})
+		a.ErrorAs(err, &common.AlreadyExistsError{})
+
+		entities, err := rep.GetAll(ctx, "")
+		a.NoError(err)
+		a.Len(entities, 1)
+	})
+
+	t.Run("should roll back all changes of failed transaction", func(t *testing.T) {
+		var rep = NewMemoryRepository(memory.NewDB(), itemMemoryTable)
+		id, err := rep.Insert(ctx, &item{Name: "first"})
+		a.NoError(err)
+
+		var txErr = errors.New("tx error")
+		err = rep.InTransaction(ctx, func(ctx context.Context) error {
+			_, err := rep.Insert(ctx, &item{Name: "second"})
+			a.NoError(err)
+			_, err = rep.Update(ctx, &item{Id: id, Name: "renamed"}, 1)
+			a.NoError(err)
+			return txErr
+		})
+		a.ErrorIs(err, txErr)
+
+		entities, err := rep.GetAll(ctx, "")
+		a.NoError(err)
+		a.Equal([]item{{Id: 1, Name: "first", Version: 1}}, entities)
+	})
+
+	t.Run("should check row version on update and delete", func(t *testing.T) {
+		var rep = NewMemoryRepository(memory.NewDB(), itemMemoryTable)
+		id, err := rep.Insert(ctx, &item{Name: "first"})
+		a.NoError(err)
+
+		updated, err := rep.Update(ctx, &item{Id: id, Name: "renamed"}, 1)
+		a.NoError(err)
+		a.Equal(int64(2), updated.Version)
+		deleted, err := rep.DeleteById(ctx, id, 1)
+		a.NoError(err)
+		a.False(deleted)
+		deleted, err = rep.DeleteById(ctx, id, 2)
+		a.NoError(err)
+		a.True(deleted)
+	})
+}
@@ -0,0 +1,277 @@
+package crud
+
+import (
+	"context"
+	"fmt"
+	"idm/inner/database"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// Table описание таблицы ресурса для обобщённого репозитория.
+// Таблица должна содержать столбцы id, version и столбец уникального имени.
+type Table[E any, F any] struct {
+	// имя таблицы
+	Name string
+	// столбцы, заполняемые при создании и изменении строки (без id и version)
+	Columns []string
+	// значения столбцов Columns в том же порядке
+	Values func(entity *E) []any
+	// id сущности
+	Id func(entity *E) int64
+	// столбец уникального имени (уникальность без учёта регистра) и его значение у сущности
+	KeyColumn string
+	Key       func(entity *E) string
+	// условие WHERE для фильтров списка: пустая строка, если фильтров нет
+	Filter func(filter F) (where string, args []any)
+}
+
+// Repository обобщённый репозиторий: запросы, одинаковые для всех ресурсов.
+// Ресурс встраивает его в свой репозиторий и добавляет только собственные запросы.
+// Все запросы выполняются в транзакции из контекста, если она есть (см. database.TxManager),
+// а методы с суффиксом Tx рассчитаны на вызов только внутри InTransaction.
+type Repository[E any, F any] struct {
+	db    *sqlx.DB
+	tx    *database.TxManager
+	table Table[E, F]
+}
+
+func NewRepository[E any, F any](db *sqlx.DB, table Table[E, F]) *Repository[E, F] {
+	return &Repository[E, F]{db: db, tx: database.NewTxManager(db, database.DefaultTxOptions), table: table}
+}
+
+// Conn транзакция из контекста или подключение к базе данных для собственных запросов ресурса
+func (rep *Repository[E, F]) Conn(ctx context.Context) database.Executor {
+	return database.ConnFor(ctx, rep.db, rep.table.Name)
+}
+
+// InTransaction выполнение fn в транзакции (или в точке сохранения, если транзакция уже есть в контексте)
+func (rep *Repository[E, F]) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return rep.tx.Do(ctx, fn)
+}
+
+// FindExistingNamesTx возвращает имена из базы данных, совпадающие с переданными без учёта регистра
+func (rep *Repository[E, F]) FindExistingNamesTx(ctx context.Context, names []string) (existing []string, err error) {
+	if len(names) == 0 {
+		return existing, nil
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE LOWER(%s) IN (?)", rep.table.KeyColumn, rep.table.Name, rep.table.KeyColumn)
+	query, args, err := sqlx.In(query, LowerNames(names))
+	if err != nil {
+		return nil, err
+	}
+
+	err = rep.Conn(ctx).SelectContext(ctx, &existing, query, args...)
+	return existing, err
+}
+
+// FindByNamesTx поиск сущностей по списку имён без учёта регистра
+func (rep *Repository[E, F]) FindByNamesTx(ctx context.Context, names []string) (entities []E, err error) {
+	if len(names) == 0 {
+		return entities, nil
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %s WHERE LOWER(%s) IN (?)", rep.table.Name, rep.table.KeyColumn)
+	query, args, err := sqlx.In(query, LowerNames(names))
+	if err != nil {
+		return nil, err
+	}
+
+	err = rep.Conn(ctx).SelectContext(ctx, &entities, query, args...)
+	return entities, err
+}
+
+// SaveBatchTx создание списка сущностей многострочными INSERT-ами,
+// id возвращаются в том же порядке, что и переданные сущности
+func (rep *Repository[E, F]) SaveBatchTx(ctx context.Context, entities []*E) (ids []int64, err error) {
+	// количество строк в одном INSERT-е (у PostgreSQL ограничение в 65535 параметров на запрос)
+	var chunkSize = 65535 / len(rep.table.Columns)
+	chunkSize = min(chunkSize, batchChunkSize)
+
+	ids = make([]int64, 0, len(entities))
+	for start := 0; start < len(entities); start += chunkSize {
+		end := min(start+chunkSize, len(entities))
+		chunkIds, err := rep.saveChunkTx(ctx, entities[start:end])
+		if err != nil {
+			return nil, database.TranslateError(err)
+		}
+		ids = append(ids, chunkIds...)
+	}
+
+	return ids, nil
+}
+
+// максимальное количество строк в одном INSERT-е
+const batchChunkSize = 1000
+
+func (rep *Repository[E, F]) saveChunkTx(ctx context.Context, entities []*E) (ids []int64, err error) {
+	var columnCount = len(rep.table.Columns)
+	var values = make([]string, 0, len(entities))
+	var args = make([]any, 0, len(entities)*columnCount)
+	for i, entity := range entities {
+		var placeholders = make([]string, columnCount)
+		for k := range placeholders {
+			placeholders[k] = fmt.Sprintf("$%d", i*columnCount+k+1)
+		}
+		values = append(values, "("+strings.Join(placeholders, ", ")+")")
+		args = append(args, rep.table.Values(entity)...)
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s RETURNING id, %s",
+		rep.table.Name, strings.Join(rep.table.Columns, ", "), strings.Join(values, ", "), rep.table.KeyColumn)
+	rows, err := rep.Conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// порядок строк в RETURNING не гарантируется, поэтому сопоставляем id по имени
+	var idByKey = make(map[string]int64, len(entities))
+	for rows.Next() {
+		var id int64
+		var key string
+		if err = rows.Scan(&id, &key); err != nil {
+			return nil, err
+		}
+		idByKey[key] = id
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, entity := range entities {
+		ids = append(ids, idByKey[rep.table.Key(entity)])
+	}
+
+	return ids, nil
+}
+
+// Count количество строк таблицы
+func (rep *Repository[E, F]) Count(ctx context.Context) (count int64, err error) {
+	err = rep.Conn(ctx).GetContext(ctx, &count, fmt.Sprintf("SELECT COUNT(*) FROM %s", rep.table.Name))
+	return count, err
+}
+
+func (rep *Repository[E, F]) FindById(ctx context.Context, id int64) (entity E, err error) {
+	query := fmt.Sprintf("SELECT * FROM %s WHERE id = $1", rep.table.Name)
+	err = rep.Conn(ctx).GetContext(ctx, &entity, query, id)
+	return entity, err
+}
+
+func (rep *Repository[E, F]) FindByIds(ctx context.Context, ids []int64) (entities []E, err error) {
+	query := fmt.Sprintf("SELECT * FROM %s WHERE id IN (?)", rep.table.Name)
+	query, args, err := sqlx.In(query, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	err = rep.Conn(ctx).SelectContext(ctx, &entities, query, args...)
+	return entities, err
+}
+
+func (rep *Repository[E, F]) GetAll(ctx context.Context, filter F) (entities []E, err error) {
+	where, args := rep.table.Filter(filter)
+	query := "SELECT * FROM " + rep.table.Name + where
+	err = rep.Conn(ctx).SelectContext(ctx, &entities, query, args...)
+	return entities, err
+}
+
+// Stream построчное чтение курсором: в памяти одновременно находится только одна строка
+func (rep *Repository[E, F]) Stream(ctx context.Context, filter F, fn func(entity E) error) error {
+	where, args := rep.table.Filter(filter)
+	rows, err := rep.Conn(ctx).QueryxContext(ctx, "SELECT * FROM "+rep.table.Name+where+" ORDER BY id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entity E
+		if err = rows.StructScan(&entity); err != nil {
+			return err
+		}
+		if err = fn(entity); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// setClause "c1 = $1, c2 = $2, ..." для столбцов Columns и значения параметров
+func (rep *Repository[E, F]) setClause(entity *E) (set string, args []any) {
+	var assignments = make([]string, len(rep.table.Columns))
+	for i, column := range rep.table.Columns {
+		assignments[i] = fmt.Sprintf("%s = $%d", column, i+1)
+	}
+	return strings.Join(assignments, ", "), rep.table.Values(entity)
+}
+
+// UpdateTx обновление сущности по её id без проверки версии строки
+func (rep *Repository[E, F]) UpdateTx(ctx context.Context, entity *E) error {
+	set, args := rep.setClause(entity)
+	query := fmt.Sprintf("UPDATE %s SET %s, version = version + 1 WHERE id = $%d", rep.table.Name, set, len(args)+1)
+	_, err := rep.Conn(ctx).ExecContext(ctx, query, append(args, rep.table.Id(entity))...)
+	return database.TranslateError(err)
+}
+
+// Update обновление сущности с проверкой версии строки (при expectedVersion == 0 версия не проверяется).
+// Если строка не найдена или версия не совпала, возвращается sql.ErrNoRows.
+func (rep *Repository[E, F]) Update(ctx context.Context, entity *E, expectedVersion int64) (updated E, err error) {
+	set, args := rep.setClause(entity)
+	args = append(args, rep.table.Id(entity))
+	query := fmt.Sprintf("UPDATE %s SET %s, version = version + 1 WHERE id = $%d", rep.table.Name, set, len(args))
+	if expectedVersion > 0 {
+		args = append(args, expectedVersion)
+		query += fmt.Sprintf(" AND version = $%d", len(args))
+	}
+
+	err = rep.Conn(ctx).GetContext(ctx, &updated, query+" RETURNING *", args...)
+	return updated, database.TranslateError(err)
+}
+
+// DeleteById удаление с проверкой версии строки (при version == 0 версия не проверяется)
+func (rep *Repository[E, F]) DeleteById(ctx context.Context, id int64, version int64) (deleted bool, err error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", rep.table.Name)
+	var args = []any{id}
+	if version > 0 {
+		query += " AND version = $2"
+		args = append(args, version)
+	}
+
+	result, err := rep.Conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (rep *Repository[E, F]) DeleteByIds(ctx context.Context, ids []int64) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id IN (?)", rep.table.Name)
+	query, args, err := sqlx.In(query, ids)
+	if err != nil {
+		return err
+	}
+
+	_, err = rep.Conn(ctx).ExecContext(ctx, query, args...)
+	return err
+}
+
+// NameFilter условие WHERE для поиска по части имени без учёта регистра
+func NameFilter(column string, name string) (where string, args []any) {
+	if name == "" {
+		return "", nil
+	}
+	return " WHERE LOWER(" + column + ") LIKE LOWER($1) ESCAPE '\\'", []any{database.ContainsPattern(name)}
+}
+
+// LowerNames имена в нижнем регистре для сравнения с LOWER(name), по которому построен уникальный индекс
+func LowerNames(names []string) []string {
+	var lowered = make([]string, len(names))
+	for i, name := range names {
+		lowered[i] = strings.ToLower(name)
+	}
+	return lowered
+}
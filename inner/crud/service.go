@@ -0,0 +1,344 @@
+package crud
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"idm/inner/common"
+	"idm/inner/tabular"
+	"idm/inner/tracing"
+	"io"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Store операции хранилища, которые использует обобщённый сервис.
+// Их реализует Repository, а ресурс может подменить любую из них своей.
+type Store[E any, F any] interface {
+	InTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	FindExistingNamesTx(ctx context.Context, names []string) (existing []string, err error)
+	SaveBatchTx(ctx context.Context, entities []*E) (ids []int64, err error)
+	FindById(ctx context.Context, id int64) (entity E, err error)
+	GetAll(ctx context.Context, filter F) (entities []E, err error)
+	Stream(ctx context.Context, filter F, fn func(entity E) error) error
+	FindByIds(ctx context.Context, ids []int64) (entities []E, err error)
+	Update(ctx context.Context, entity *E, expectedVersion int64) (updated E, err error)
+	DeleteById(ctx context.Context, id int64, version int64) (deleted bool, err error)
+	DeleteByIds(ctx context.Context, ids []int64) error
+}
+
+type Validator interface {
+	Validate(request any) error
+}
+
+// Resource описание ресурса для обобщённого сервиса: преобразования DTO и необязательные хуки
+type Resource[E any, Req any, R any] struct {
+	// название ресурса в сообщениях об ошибках, в единственном и множественном числе
+	Name   string
+	Plural string
+	// уникальное имя из запроса: по нему проверяются дубликаты в пакете и в базе данных
+	Key        func(req Req) string
+	ToEntity   func(req Req) *E
+	SetId      func(entity *E, id int64)
+	Version    func(entity E) int64
+	ToResponse func(entity E) R
+	// колонки выгрузки в табличные форматы
+	ExportColumns []tabular.Column[R]
+	// BeforeCreate вызывается в транзакции перед созданием сущностей пакетом (после проверки уникальности имён),
+	// транзакция передаётся в ctx, ошибка отменяет создание
+	BeforeCreate func(ctx context.Context, entities []*E) error
+	// BeforeUpdate вызывается перед изменением сущности, ошибка отменяет изменение
+	BeforeUpdate func(ctx context.Context, entity *E) error
+}
+
+// Service обобщённый сервис: операции, одинаковые для всех ресурсов
+type Service[E any, F any, Req any, R any] struct {
+	store    Store[E, F]
+	valid    Validator
+	resource Resource[E, Req, R]
+}
+
+func NewService[E any, F any, Req any, R any](store Store[E, F], validator Validator, resource Resource[E, Req, R]) *Service[E, F, Req, R] {
+	return &Service[E, F, Req, R]{
+		store:    store,
+		valid:    validator,
+		resource: resource,
+	}
+}
+
+// SaveBatch создание списка сущностей.
+// В режиме atomic все сущности создаются в одной транзакции, и при ошибке хотя бы в одном элементе не создаётся ни одна.
+// В частичном режиме создаются только корректные элементы, а по остальным ошибка возвращается в результате элемента.
+func (serv *Service[E, F, Req, R]) SaveBatch(ctx context.Context, reqs []Req, atomic bool) (results []common.BatchItemResult, err error) {
+	ctx, span := tracing.Start(ctx, serv.resource.Name+".SaveBatch", attribute.Int("batch.size", len(reqs)), attribute.Bool("batch.atomic", atomic))
+	defer tracing.End(span, &err)
+
+	var name, plural = serv.resource.Name, serv.resource.Plural
+	results = make([]common.BatchItemResult, len(reqs))
+	// индекс первого элемента с таким именем (имена уникальны без учёта регистра)
+	var indexByName = make(map[string]int, len(reqs))
+	var hasErrors bool
+	// все элементы пакета проверяются в одном span, а не в span на каждый элемент
+	_, validateSpan := tracing.Start(ctx, "validate", attribute.Int("batch.size", len(reqs)))
+	for i, req := range reqs {
+		results[i].Index = i
+		if errVld := serv.valid.Validate(req); errVld != nil {
+			results[i].Error = errVld.Error()
+			hasErrors = true
+			continue
+		}
+		var key = serv.resource.Key(req)
+		if first, ok := indexByName[strings.ToLower(key)]; ok {
+			results[i].Error = fmt.Sprintf("%s with name %s is duplicated in batch (item %d)", name, key, first)
+			hasErrors = true
+			continue
+		}
+		indexByName[strings.ToLower(key)] = i
+	}
+	validateSpan.End()
+
+	if hasErrors && atomic {
+		return results, common.RequestValidationError{Message: "batch contains invalid " + plural}
+	}
+
+	// ошибки проверки элементов до транзакции: при её повторе результаты восстанавливаются по ним
+	var validated = make([]string, len(results))
+	for i := range results {
+		validated[i] = results[i].Error
+	}
+
+	err = serv.store.InTransaction(ctx, func(ctx context.Context) error {
+		for i := range results {
+			results[i].Error = validated[i]
+			results[i].Id = 0
+		}
+
+		var names = make([]string, 0, len(indexByName))
+		for key := range indexByName {
+			names = append(names, key)
+		}
+		existing, err := serv.store.FindExistingNamesTx(ctx, names)
+		if err != nil {
+			return fmt.Errorf("error finding %s by names: %w", plural, err)
+		}
+		for _, key := range existing {
+			results[indexByName[strings.ToLower(key)]].Error = fmt.Sprintf("%s with name %s already exists", name, key)
+		}
+		if len(existing) > 0 && atomic {
+			return common.AlreadyExistsError{Message: fmt.Sprintf("%s with names %v already exist", plural, existing)}
+		}
+
+		// собираем корректные элементы в исходном порядке
+		var indexes = make([]int, 0, len(reqs))
+		var entities = make([]*E, 0, len(reqs))
+		for i, req := range reqs {
+			if results[i].Error == "" {
+				indexes = append(indexes, i)
+				entities = append(entities, serv.resource.ToEntity(req))
+			}
+		}
+		if len(entities) == 0 {
+			return nil
+		}
+
+		if serv.resource.BeforeCreate != nil {
+			if err = serv.resource.BeforeCreate(ctx, entities); err != nil {
+				return err
+			}
+		}
+
+		// имя могли занять параллельным запросом после проверки выше, это отлавливает уникальный индекс
+		ids, err := serv.store.SaveBatchTx(ctx, entities)
+		if errors.As(err, &common.AlreadyExistsError{}) {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("error creating %s: %w", plural, err)
+		}
+		for k, i := range indexes {
+			results[i].Id = ids[k]
+		}
+		return nil
+	})
+
+	return results, DbError(err)
+}
+
+// DbError ошибка для ответа API: ошибки из пакета common возвращаются как есть,
+// остальные (ошибки базы данных и транзакции) - как DbOperationError
+func DbError(err error) error {
+	switch {
+	case err == nil,
+		errors.As(err, &common.RequestValidationError{}),
+		errors.As(err, &common.AlreadyExistsError{}),
+		errors.As(err, &common.NotFoundError{}),
+		errors.As(err, &common.PreconditionFailedError{}),
+		errors.As(err, &common.DbOperationError{}):
+		return err
+	default:
+		return common.DbOperationError{Message: err.Error()}
+	}
+}
+
+func (serv *Service[E, F, Req, R]) FindById(ctx context.Context, id int64) (response R, err error) {
+	ctx, span := tracing.Start(ctx, serv.resource.Name+".FindById")
+	defer tracing.End(span, &err)
+
+	var empty R
+	entity, err := serv.store.FindById(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return empty, common.NotFoundError{Message: fmt.Sprintf("%s with id %d not found", serv.resource.Name, id)}
+	}
+	if err != nil {
+		return empty, common.DbOperationError{Message: fmt.Errorf("error finding %s with id %d: %w", serv.resource.Name, id, err).Error()}
+	}
+
+	return serv.resource.ToResponse(entity), nil
+}
+
+func (serv *Service[E, F, Req, R]) FindByIds(ctx context.Context, ids []int64) (responses []R, err error) {
+	ctx, span := tracing.Start(ctx, serv.resource.Name+".FindByIds", attribute.Int("ids.count", len(ids)))
+	defer tracing.End(span, &err)
+
+	entities, err := serv.store.FindByIds(ctx, ids)
+	if err != nil {
+		return []R{}, common.DbOperationError{Message: fmt.Errorf("error finding %s with ids %d: %w", serv.resource.Name, ids, err).Error()}
+	}
+
+	return serv.toResponses(entities), nil
+}
+
+func (serv *Service[E, F, Req, R]) GetAll(ctx context.Context, filter F) (responses []R, err error) {
+	ctx, span := tracing.Start(ctx, serv.resource.Name+".GetAll")
+	defer tracing.End(span, &err)
+
+	entities, err := serv.store.GetAll(ctx, filter)
+	if err != nil {
+		return []R{}, common.DbOperationError{Message: fmt.Errorf("error get all %s: %w", serv.resource.Plural, err).Error()}
+	}
+
+	return serv.toResponses(entities), nil
+}
+
+// Export потоковая выгрузка в writer в заданном формате
+func (serv *Service[E, F, Req, R]) Export(ctx context.Context, filter F, format tabular.Format, writer io.Writer) (err error) {
+	ctx, span := tracing.Start(ctx, serv.resource.Name+".Export", attribute.String("export.format", string(format)))
+	defer tracing.End(span, &err)
+
+	encoder, err := tabular.NewEncoder(writer, format, serv.resource.ExportColumns)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			encoder.Discard()
+		}
+	}()
+
+	err = serv.store.Stream(ctx, filter, func(entity E) error {
+		return encoder.Encode(serv.resource.ToResponse(entity))
+	})
+	if err != nil {
+		return common.DbOperationError{Message: fmt.Errorf("error export %s: %w", serv.resource.Plural, err).Error()}
+	}
+
+	return encoder.Close()
+}
+
+// Update обновление сущности: если version > 0, то изменение применяется только к этой версии строки
+func (serv *Service[E, F, Req, R]) Update(ctx context.Context, id int64, version int64, req Req) (response R, err error) {
+	ctx, span := tracing.Start(ctx, serv.resource.Name+".Update")
+	defer tracing.End(span, &err)
+
+	var empty R
+	err = tracing.Validate(ctx, serv.valid, req)
+	if err != nil {
+		return empty, common.RequestValidationError{Message: err.Error()}
+	}
+
+	var entity = serv.resource.ToEntity(req)
+	serv.resource.SetId(entity, id)
+	if serv.resource.BeforeUpdate != nil {
+		if err = serv.resource.BeforeUpdate(ctx, entity); err != nil {
+			return empty, err
+		}
+	}
+
+	updated, err := serv.store.Update(ctx, entity, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return empty, serv.versionConflict(ctx, id, version)
+	}
+	if errors.As(err, &common.AlreadyExistsError{}) {
+		return empty, err
+	}
+	if err != nil {
+		return empty, common.DbOperationError{Message: fmt.Errorf("error update %s with id %d: %w", serv.resource.Name, id, err).Error()}
+	}
+
+	return serv.resource.ToResponse(updated), nil
+}
+
+// DeleteById удаление сущности: если version > 0, то удаляется только эта версия строки
+func (serv *Service[E, F, Req, R]) DeleteById(ctx context.Context, id int64, version int64) (err error) {
+	ctx, span := tracing.Start(ctx, serv.resource.Name+".DeleteById")
+	defer tracing.End(span, &err)
+
+	deleted, err := serv.store.DeleteById(ctx, id, version)
+	if err != nil {
+		return common.DbOperationError{Message: fmt.Errorf("error delete %s by id %d: %w", serv.resource.Name, id, err).Error()}
+	}
+	if !deleted {
+		return serv.versionConflict(ctx, id, version)
+	}
+
+	return nil
+}
+
+// DeleteByIds удаление списка сущностей в одной транзакции: versions[i] - ожидаемая версия ids[i]
+// (0 - любая). Если хотя бы одной записи нет или её версия другая, не удаляется ни одна.
+func (serv *Service[E, F, Req, R]) DeleteByIds(ctx context.Context, ids []int64, versions []int64) (err error) {
+	ctx, span := tracing.Start(ctx, serv.resource.Name+".DeleteByIds", attribute.Int("ids.count", len(ids)))
+	defer tracing.End(span, &err)
+
+	if len(versions) != len(ids) {
+		return common.RequestValidationError{Message: fmt.Sprintf("expected %d versions, got %d", len(ids), len(versions))}
+	}
+	err = serv.store.InTransaction(ctx, func(ctx context.Context) error {
+		for i, id := range ids {
+			deleted, err := serv.store.DeleteById(ctx, id, versions[i])
+			if err != nil {
+				return fmt.Errorf("error delete %s by id %d: %w", serv.resource.Name, id, err)
+			}
+			if !deleted {
+				return serv.versionConflict(ctx, id, versions[i])
+			}
+		}
+		return nil
+	})
+
+	return DbError(err)
+}
+
+// versionConflict причина, по которой изменение не применилось: строки нет или её версия уже другая
+func (serv *Service[E, F, Req, R]) versionConflict(ctx context.Context, id int64, version int64) error {
+	current, err := serv.store.FindById(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return common.NotFoundError{Message: fmt.Sprintf("%s with id %d not found", serv.resource.Name, id)}
+	}
+	if err != nil {
+		return common.DbOperationError{Message: fmt.Errorf("error finding %s with id %d: %w", serv.resource.Name, id, err).Error()}
+	}
+	return common.PreconditionFailedError{
+		Message: fmt.Sprintf("%s with id %d has version %d, expected %d", serv.resource.Name, id, serv.resource.Version(current), version),
+	}
+}
+
+func (serv *Service[E, F, Req, R]) toResponses(entities []E) []R {
+	var responses = make([]R, 0, len(entities))
+	for _, entity := range entities {
+		responses = append(responses, serv.resource.ToResponse(entity))
+	}
+	return responses
+}
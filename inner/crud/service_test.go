@@ -0,0 +1,258 @@
+package crud
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"idm/inner/common"
+	"idm/inner/tabular"
+	"maps"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type item struct {
+	Id      int64
+	Name    string
+	Version int64
+}
+
+type itemRequest struct {
+	Name string
+}
+
+type itemResponse struct {
+	Id      int64
+	Name    string
+	Version int64
+}
+
+// хранилище в памяти: только то, что нужно для проверок изменения и удаления
+type fakeStore struct {
+	items     map[int64]item
+	streamErr error
+}
+
+// InTransaction при ошибке восстанавливает записи, как это сделал бы откат транзакции
+func (s *fakeStore) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	var snapshot = maps.Clone(s.items)
+	if err := fn(ctx); err != nil {
+		s.items = snapshot
+		return err
+	}
+	return nil
+}
+
+func (s *fakeStore) FindExistingNamesTx(ctx context.Context, names []string) ([]string, error) {
+	return nil, nil
+}
+
+func (s *fakeStore) SaveBatchTx(ctx context.Context, entities []*item) ([]int64, error) {
+	return nil, nil
+}
+
+func (s *fakeStore) FindById(ctx context.Context, id int64) (item, error) {
+	entity, ok := s.items[id]
+	if !ok {
+		return item{}, sql.ErrNoRows
+	}
+	return entity, nil
+}
+
+func (s *fakeStore) GetAll(ctx context.Context, filter string) ([]item, error) {
+	var entities []item
+	for _, entity := range s.items {
+		entities = append(entities, entity)
+	}
+	return entities, nil
+}
+
+func (s *fakeStore) Stream(ctx context.Context, filter string, fn func(entity item) error) error {
+	for _, entity := range s.items {
+		if err := fn(entity); err != nil {
+			return err
+		}
+	}
+	return s.streamErr
+}
+
+func (s *fakeStore) FindByIds(ctx context.Context, ids []int64) ([]item, error) {
+	return nil, nil
+}
+
+func (s *fakeStore) Update(ctx context.Context, entity *item, expectedVersion int64) (item, error) {
+	current, ok := s.items[entity.Id]
+	if !ok || (expectedVersion > 0 && current.Version != expectedVersion) {
+		return item{}, sql.ErrNoRows
+	}
+	entity.Version = current.Version + 1
+	s.items[entity.Id] = *entity
+	return *entity, nil
+}
+
+func (s *fakeStore) DeleteById(ctx context.Context, id int64, version int64) (bool, error) {
+	current, ok := s.items[id]
+	if !ok || (version > 0 && current.Version != version) {
+		return false, nil
+	}
+	delete(s.items, id)
+	return true, nil
+}
+
+func (s *fakeStore) DeleteByIds(ctx context.Context, ids []int64) error {
+	return nil
+}
+
+type passValidator struct{}
+
+func (passValidator) Validate(request any) error {
+	return nil
+}
+
+var itemResource = Resource[item, itemRequest, itemResponse]{
+	Name:       "item",
+	Plural:     "items",
+	Key:        func(req itemRequest) string { return req.Name },
+	ToEntity:   func(req itemRequest) *item { return &item{Name: req.Name} },
+	SetId:      func(entity *item, id int64) { entity.Id = id },
+	Version:    func(entity item) int64 { return entity.Version },
+	ToResponse: func(entity item) itemResponse { return itemResponse(entity) },
+}
+
+func newItemService(resource Resource[item, itemRequest, itemResponse]) *Service[item, string, itemRequest, itemResponse] {
+	var store = &fakeStore{items: map[int64]item{1: {Id: 1, Name: "first", Version: 3}}}
+	return NewService[item, string](store, passValidator{}, resource)
+}
+
+func TestUpdate(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should update item with expected version", func(t *testing.T) {
+		var srv = newItemService(itemResource)
+		got, err := srv.Update(context.Background(), 1, 3, itemRequest{Name: "renamed"})
+		a.NoError(err)
+		a.Equal(itemResponse{Id: 1, Name: "renamed", Version: 4}, got)
+	})
+
+	t.Run("should return PreconditionFailedError when version differs", func(t *testing.T) {
+		var srv = newItemService(itemResource)
+		_, err := srv.Update(context.Background(), 1, 2, itemRequest{Name: "renamed"})
+		a.ErrorAs(err, &common.PreconditionFailedError{})
+		a.Equal("item with id 1 has version 3, expected 2", err.Error())
+	})
+
+	t.Run("should return NotFoundError when item does not exist", func(t *testing.T) {
+		var srv = newItemService(itemResource)
+		_, err := srv.Update(context.Background(), 2, 0, itemRequest{Name: "renamed"})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+
+	t.Run("should not update item when BeforeUpdate fails", func(t *testing.T) {
+		var resource = itemResource
+		var hookErr = common.RequestValidationError{Message: "name is reserved"}
+		resource.BeforeUpdate = func(ctx context.Context, entity *item) error {
+			return hookErr
+		}
+		var srv = newItemService(resource)
+		_, err := srv.Update(context.Background(), 1, 0, itemRequest{Name: "renamed"})
+		a.Equal(hookErr, err)
+		got, err := srv.FindById(context.Background(), 1)
+		a.NoError(err)
+		a.Equal("first", got.Name)
+	})
+}
+
+func TestFindById(t *testing.T) {
+	a := assert.New(t)
+
+	var srv = newItemService(itemResource)
+	_, err := srv.FindById(context.Background(), 2)
+	a.ErrorAs(err, &common.NotFoundError{})
+	a.Equal("item with id 2 not found", err.Error())
+}
+
+func TestDeleteById(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return PreconditionFailedError when version differs", func(t *testing.T) {
+		var srv = newItemService(itemResource)
+		err := srv.DeleteById(context.Background(), 1, 1)
+		a.ErrorAs(err, &common.PreconditionFailedError{})
+	})
+
+	t.Run("should delete item without version check", func(t *testing.T) {
+		var srv = newItemService(itemResource)
+		a.NoError(srv.DeleteById(context.Background(), 1, 0))
+		err := srv.DeleteById(context.Background(), 1, 0)
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+}
+
+func TestDeleteByIds(t *testing.T) {
+	a := assert.New(t)
+
+	newService := func() (*Service[item, string, itemRequest, itemResponse], *fakeStore) {
+		var store = &fakeStore{items: map[int64]item{
+			1: {Id: 1, Name: "first", Version: 3},
+			2: {Id: 2, Name: "second", Version: 1},
+		}}
+		return NewService[item, string](store, passValidator{}, itemResource), store
+	}
+
+	t.Run("should delete items with expected versions", func(t *testing.T) {
+		var srv, store = newService()
+		a.NoError(srv.DeleteByIds(context.Background(), []int64{1, 2}, []int64{3, 0}))
+		a.Empty(store.items)
+	})
+
+	t.Run("should delete nothing when one version differs", func(t *testing.T) {
+		var srv, store = newService()
+		err := srv.DeleteByIds(context.Background(), []int64{1, 2}, []int64{3, 2})
+		a.ErrorAs(err, &common.PreconditionFailedError{})
+		a.Equal("item with id 2 has version 1, expected 2", err.Error())
+		a.Len(store.items, 2)
+	})
+
+	t.Run("should delete nothing when one item does not exist", func(t *testing.T) {
+		var srv, store = newService()
+		err := srv.DeleteByIds(context.Background(), []int64{1, 5}, []int64{0, 0})
+		a.ErrorAs(err, &common.NotFoundError{})
+		a.Len(store.items, 2)
+	})
+
+	t.Run("should reject versions that do not match ids", func(t *testing.T) {
+		var srv, store = newService()
+		err := srv.DeleteByIds(context.Background(), []int64{1, 2}, []int64{3})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.Len(store.items, 2)
+	})
+}
+
+func TestExport(t *testing.T) {
+	a := assert.New(t)
+	var resource = itemResource
+	resource.ExportColumns = []tabular.Column[itemResponse]{
+		{Title: "name", Value: func(it itemResponse) any { return it.Name }},
+	}
+
+	t.Run("should write nothing when stream fails", func(t *testing.T) {
+		var store = &fakeStore{items: map[int64]item{1: {Id: 1, Name: "first"}}, streamErr: errors.New("connection reset")}
+		var srv = NewService[item, string](store, passValidator{}, resource)
+
+		var buffer bytes.Buffer
+		err := srv.Export(context.Background(), "", tabular.XLSX, &buffer)
+		a.ErrorAs(err, &common.DbOperationError{})
+		a.Zero(buffer.Len())
+	})
+}
+
+func TestGetAll(t *testing.T) {
+	a := assert.New(t)
+
+	var srv = newItemService(itemResource)
+	got, err := srv.GetAll(context.Background(), "")
+	a.NoError(err)
+	a.Equal([]itemResponse{{Id: 1, Name: "first", Version: 3}}, got)
+}
@@ -1,11 +1,14 @@
 package database
 
 import (
+	"database/sql/driver"
+	"fmt"
 	"idm/inner/common"
-	"time"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	"modernc.org/sqlite"
 )
 
 // ConnectDb получить конфиг и подключиться с ним к базе данных
@@ -20,29 +23,75 @@ func ConnectDb(envFile string) (*sqlx.DB, error) {
 
 // ConnectDbWithCfg подключиться к базе данных с переданным конфигом
 func ConnectDbWithCfg(cfg common.Config) (*sqlx.DB, error) {
+	if cfg.DbDriverName == DriverSQLite {
+		return connectSQLite(cfg.Dsn)
+	}
 
+	// ошибка подключения возвращается вызывающему, чтобы приложение завершилось с сообщением, а не паникой
 	db, err := sqlx.Connect(cfg.DbDriverName, cfg.Dsn)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error connecting to database: %w", err)
 	}
-	db.Close()
-
-	db = sqlx.MustConnect(cfg.DbDriverName, cfg.Dsn)
 	// Настройки ниже конфигурируют пулл подключений к базе данных. Их названия стандартны для большинства библиотек.
 	// Ознакомиться с их описанием можно на примере документации Hikari pool:
 	// https://github.com/brettwooldridge/HikariCP?tab=readme-ov-file#gear-configuration-knobs-baby
-	db.SetMaxIdleConns(5)
-	db.SetMaxOpenConns(20)
-	db.SetConnMaxLifetime(1 * time.Minute)
-	db.SetConnMaxIdleTime(10 * time.Minute)
+	db.SetMaxIdleConns(cfg.DbMaxIdleConns)
+	db.SetMaxOpenConns(cfg.DbMaxOpenConns)
+	db.SetConnMaxLifetime(cfg.DbConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.DbConnMaxIdleTime)
 	return db, nil
 }
 
-func CheckDbConnection(cfg common.Config) bool {
-	db, err := sqlx.Connect(cfg.DbDriverName, cfg.Dsn)
+// connectSQLite подключение к файлу SQLite.
+// SQLite допускает только одну пишущую транзакцию, поэтому пул ограничен одним подключением:
+// запросы ждут своей очереди в пуле, а не получают ошибку SQLITE_BUSY.
+func connectSQLite(dsn string) (*sqlx.DB, error) {
+	db, err := sqlx.Connect(DriverSQLite, sqliteDsn(dsn))
 	if err != nil {
-		return false
+		return nil, fmt.Errorf("error connecting to database: %w", err)
 	}
-	db.Close()
-	return true
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(0)
+	return db, nil
+}
+
+// встроенная функция LOWER в SQLite меняет регистр только латинских букв, поэтому для всех подключений
+// она заменяется на strings.ToLower: иначе уникальные индексы по LOWER(name), поиск по имени и сравнение
+// с именами, приведёнными к нижнему регистру в Go, не работают для кириллицы
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("lower", 1, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		switch value := args[0].(type) {
+		case string:
+			return strings.ToLower(value), nil
+		case []byte:
+			return strings.ToLower(string(value)), nil
+		default:
+			return value, nil
+		}
+	})
+}
+
+// sqliteDsn DSN с настройками, которые SQLite применяет к каждому подключению:
+// проверка внешних ключей (ON DELETE CASCADE) и ожидание блокировки базы данных другим процессом
+func sqliteDsn(dsn string) string {
+	var separator = "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	if !strings.Contains(dsn, "foreign_keys") {
+		dsn += separator + "_pragma=foreign_keys(1)"
+		separator = "&"
+	}
+	if !strings.Contains(dsn, "busy_timeout") {
+		dsn += separator + "_pragma=busy_timeout(5000)"
+	}
+	return dsn
+}
+
+// экранирование спецсимволов шаблона LIKE, используется вместе с ESCAPE '\'
+var likeReplacer = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ContainsPattern шаблон LIKE для поиска подстроки
+func ContainsPattern(value string) string {
+	return "%" + likeReplacer.Replace(value) + "%"
 }
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"idm/inner/common"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectDbWithCfg(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return error when postgres is unreachable", func(t *testing.T) {
+		var cfg = common.Config{
+			DbDriverName: "postgres",
+			Dsn:          "host=127.0.0.1 port=1 user=idm dbname=idm sslmode=disable connect_timeout=1",
+		}
+		a.NotPanics(func() {
+			db, err := ConnectDbWithCfg(cfg)
+			a.Nil(db)
+			a.ErrorContains(err, "error connecting to database")
+		})
+	})
+
+	t.Run("should return error when sqlite file cannot be created", func(t *testing.T) {
+		var cfg = common.Config{
+			DbDriverName: DriverSQLite,
+			Dsn:          filepath.Join(t.TempDir(), "missing", "idm.db"),
+		}
+		db, err := ConnectDbWithCfg(cfg)
+		a.Nil(db)
+		a.ErrorContains(err, "error connecting to database")
+	})
+}
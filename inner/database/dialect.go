@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"idm/inner/tracing"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+)
+
+// Dialect диалект SQL базы данных
+type Dialect string
+
+const (
+	DialectPostgres Dialect = "postgres"
+	DialectSQLite   Dialect = "sqlite"
+)
+
+// DriverSQLite имя драйвера SQLite (DB_DRIVER_NAME), DB_DSN - путь к файлу базы данных
+const DriverSQLite = "sqlite"
+
+// DialectOf диалект подключения или транзакции по имени драйвера, по умолчанию - PostgreSQL
+func DialectOf(db interface{ DriverName() string }) Dialect {
+	if db.DriverName() == DriverSQLite {
+		return DialectSQLite
+	}
+	return DialectPostgres
+}
+
+// параметры PostgreSQL в запросе: $1, $2, ...
+var dollarParam = regexp.MustCompile(`\$(\d+)`)
+
+// Rebind перевод параметров запроса в формат диалекта.
+// Запросы пишутся с параметрами PostgreSQL ($1, $2, ...) или "?" (результат sqlx.In).
+func (dialect Dialect) Rebind(query string) string {
+	if dialect == DialectSQLite {
+		// ?NNN в SQLite - параметр с номером NNN, поэтому повторы и порядок параметров сохраняются
+		return dollarParam.ReplaceAllString(query, "?$1")
+	}
+	if strings.Contains(query, "?") {
+		return sqlx.Rebind(sqlx.DOLLAR, query)
+	}
+	return query
+}
+
+// QueryObserver получает длительность каждого запроса репозитория к базе данных
+// (кроме этого, каждый запрос записывается в span трассировки, см. tracing):
+// operation - get, select, exec или query, err - ошибка запроса (sql.ErrNoRows не считается ошибкой)
+type QueryObserver func(repository string, operation string, duration time.Duration, err error)
+
+var queryObserver atomic.Pointer[QueryObserver]
+
+// ObserveQueries подписка на запросы всех репозиториев (для метрик), nil - отписка
+func ObserveQueries(observer QueryObserver) {
+	if observer == nil {
+		queryObserver.Store(nil)
+		return
+	}
+	queryObserver.Store(&observer)
+}
+
+// rebinder выполнение запросов с переводом параметров в формат диалекта
+type rebinder struct {
+	exec       Executor
+	dialect    Dialect
+	repository string
+}
+
+// begin начало запроса: span запроса и функция завершения, которая вызывается через defer с указателем
+// на ошибку запроса, завершает span и передаёт длительность запроса подписчику ObserveQueries, если он есть
+func (r rebinder) begin(ctx context.Context, operation string, query string) (context.Context, func(errPtr *error)) {
+	var started = time.Now()
+	var repository = r.repository
+	if repository == "" {
+		repository = "other"
+	}
+	ctx, span := tracing.Start(ctx, operation+" "+repository,
+		r.dialect.system(),
+		semconv.DBOperationName(operation),
+		semconv.DBCollectionName(repository),
+		semconv.DBQueryText(query),
+	)
+
+	return ctx, func(errPtr *error) {
+		var err = *errPtr
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+		}
+		tracing.End(span, &err)
+		if observer := queryObserver.Load(); observer != nil {
+			(*observer)(repository, operation, time.Since(started), err)
+		}
+	}
+}
+
+// system название базы данных для span запросов
+func (dialect Dialect) system() attribute.KeyValue {
+	if dialect == DialectSQLite {
+		return semconv.DBSystemNameSQLite
+	}
+	return semconv.DBSystemNamePostgreSQL
+}
+
+func (r rebinder) GetContext(ctx context.Context, dest any, query string, args ...any) (err error) {
+	query = r.dialect.Rebind(query)
+	ctx, done := r.begin(ctx, "get", query)
+	defer done(&err)
+	return r.exec.GetContext(ctx, dest, query, args...)
+}
+
+func (r rebinder) SelectContext(ctx context.Context, dest any, query string, args ...any) (err error) {
+	query = r.dialect.Rebind(query)
+	ctx, done := r.begin(ctx, "select", query)
+	defer done(&err)
+	return r.exec.SelectContext(ctx, dest, query, args...)
+}
+
+func (r rebinder) ExecContext(ctx context.Context, query string, args ...any) (result sql.Result, err error) {
+	query = r.dialect.Rebind(query)
+	ctx, done := r.begin(ctx, "exec", query)
+	defer done(&err)
+	return r.exec.ExecContext(ctx, query, args...)
+}
+
+func (r rebinder) QueryContext(ctx context.Context, query string, args ...any) (rows *sql.Rows, err error) {
+	query = r.dialect.Rebind(query)
+	ctx, done := r.begin(ctx, "query", query)
+	defer done(&err)
+	return r.exec.QueryContext(ctx, query, args...)
+}
+
+func (r rebinder) QueryxContext(ctx context.Context, query string, args ...any) (rows *sqlx.Rows, err error) {
+	query = r.dialect.Rebind(query)
+	ctx, done := r.begin(ctx, "query", query)
+	defer done(&err)
+	return r.exec.QueryxContext(ctx, query, args...)
+}
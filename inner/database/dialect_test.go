@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialectRebind(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should keep PostgreSQL parameters and rebind sqlx.In result", func(t *testing.T) {
+		a.Equal("SELECT * FROM role WHERE id = $1", DialectPostgres.Rebind("SELECT * FROM role WHERE id = $1"))
+		a.Equal("DELETE FROM role WHERE id IN ($1, $2)", DialectPostgres.Rebind("DELETE FROM role WHERE id IN (?, ?)"))
+	})
+
+	t.Run("should number SQLite parameters keeping repeats", func(t *testing.T) {
+		a.Equal("INSERT INTO employee_role (employee_id, role_id) VALUES (?1, ?2), (?1, ?3)",
+			DialectSQLite.Rebind("INSERT INTO employee_role (employee_id, role_id) VALUES ($1, $2), ($1, $3)"))
+		a.Equal("DELETE FROM role WHERE id IN (?, ?)", DialectSQLite.Rebind("DELETE FROM role WHERE id IN (?, ?)"))
+	})
+}
+
+func TestObserveQueries(t *testing.T) {
+	a := assert.New(t)
+	mockDb, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer mockDb.Close()
+	var db = sqlx.NewDb(mockDb, "sqlmock")
+
+	var observed []string
+	ObserveQueries(func(repository string, operation string, duration time.Duration, err error) {
+		observed = append(observed, fmt.Sprintf("%s %s %v", repository, operation, err))
+	})
+	defer ObserveQueries(nil)
+
+	mock.ExpectQuery("SELECT name FROM role").WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("DELETE FROM role").WillReturnError(errors.New("connection refused"))
+	var name string
+	_ = ConnFor(context.Background(), db, "role").GetContext(context.Background(), &name, "SELECT name FROM role WHERE id = $1", 1)
+	_, _ = Conn(context.Background(), db).ExecContext(context.Background(), "DELETE FROM role")
+
+	a.Equal([]string{"role get <nil>", "other exec connection refused"}, observed)
+}
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"idm/inner/common"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// код ошибки PostgreSQL unique_violation
+const uniqueViolation pq.ErrorCode = "23505"
+
+// TranslateError перевод ошибок драйвера базы данных в ошибки приложения.
+// Нарушение уникального ограничения (PostgreSQL и SQLite) превращается в common.AlreadyExistsError,
+// остальные ошибки возвращаются без изменений.
+func TranslateError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return common.AlreadyExistsError{Message: fmt.Sprintf("%s already exists: %s", pqErr.Table, pqErr.Detail)}
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		// в SQLite имя таблицы есть только в тексте ошибки
+		return common.AlreadyExistsError{Message: fmt.Sprintf("already exists: %s", sqliteErr.Error())}
+	}
+	return err
+}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"idm/inner/common"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateError(t *testing.T) {
+	var a = assert.New(t)
+
+	t.Run("should translate unique violation into AlreadyExistsError", func(t *testing.T) {
+		var pqErr = &pq.Error{Code: "23505", Table: "employee", Detail: "Key (lower(name))=(john doe) already exists."}
+
+		var err = TranslateError(fmt.Errorf("insert: %w", pqErr))
+
+		a.True(errors.As(err, &common.AlreadyExistsError{}))
+		a.Equal("employee already exists: Key (lower(name))=(john doe) already exists.", err.Error())
+	})
+
+	t.Run("should return other errors unchanged", func(t *testing.T) {
+		var pqErr = &pq.Error{Code: "23502", Table: "employee"}
+		var plain = errors.New("database error")
+
+		a.Same(pqErr, TranslateError(pqErr))
+		a.Equal(plain, TranslateError(plain))
+		a.Nil(TranslateError(nil))
+	})
+}
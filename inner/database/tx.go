@@ -0,0 +1,210 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"idm/inner/tracing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// коды ошибок PostgreSQL, после которых транзакцию можно повторить целиком
+const (
+	pqSerializationFailure = "40001"
+	pqDeadlockDetected     = "40P01"
+)
+
+// ErrRollback возвращается из функции транзакции, чтобы откатить её без ошибки (например, при предварительном просмотре)
+var ErrRollback = errors.New("rollback transaction")
+
+// TxOptions параметры транзакции
+type TxOptions struct {
+	// уровень изоляции, sql.LevelDefault - уровень по умолчанию базы данных (в PostgreSQL - READ COMMITTED)
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+	// количество повторов транзакции после ошибки сериализации или взаимной блокировки, 0 - без повторов
+	MaxRetries int
+	// пауза перед первым повтором, каждая следующая пауза вдвое длиннее
+	RetryDelay time.Duration
+}
+
+// DefaultTxOptions параметры транзакций по умолчанию
+var DefaultTxOptions = TxOptions{
+	Isolation:  sql.LevelDefault,
+	MaxRetries: 3,
+	RetryDelay: 10 * time.Millisecond,
+}
+
+// Executor запросы, одинаковые для подключения и транзакции
+type Executor interface {
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error)
+}
+
+// ключ транзакции в контексте
+type txKey struct{}
+
+// транзакция в контексте и количество открытых в ней точек сохранения
+type txState struct {
+	tx         *sqlx.Tx
+	savepoints int
+}
+
+// WithTx контекст с транзакцией: запросы репозиториев с этим контекстом выполняются в ней
+func WithTx(ctx context.Context, tx *sqlx.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, &txState{tx: tx})
+}
+
+// TxFromContext транзакция из контекста, если она есть
+func TxFromContext(ctx context.Context) (*sqlx.Tx, bool) {
+	state, ok := ctx.Value(txKey{}).(*txState)
+	if !ok {
+		return nil, false
+	}
+	return state.tx, true
+}
+
+// Conn транзакция из контекста или, если её нет, подключение к базе данных.
+// Параметры запросов переводятся в формат диалекта подключения (см. Dialect.Rebind).
+func Conn(ctx context.Context, db *sqlx.DB) Executor {
+	return ConnFor(ctx, db, "")
+}
+
+// ConnFor Conn для запросов репозитория repository: под этим именем запросы попадают в ObserveQueries
+func ConnFor(ctx context.Context, db *sqlx.DB, repository string) Executor {
+	if tx, ok := TxFromContext(ctx); ok {
+		return rebinder{exec: tx, dialect: DialectOf(tx), repository: repository}
+	}
+	return rebinder{exec: db, dialect: DialectOf(db), repository: repository}
+}
+
+// IsRetryable ошибка сериализации или взаимной блокировки (в SQLite - занятая база данных): транзакцию можно повторить
+func IsRetryable(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == pqSerializationFailure || pqErr.Code == pqDeadlockDetected
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		// младший байт расширенного кода - основной код ошибки
+		var code = sqliteErr.Code() & 0xff
+		return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+	}
+	return false
+}
+
+// TxManager выполнение функций в транзакции, переданной через контекст.
+// Все репозитории, получившие этот контекст, работают в одной транзакции.
+type TxManager struct {
+	db      *sqlx.DB
+	options TxOptions
+}
+
+func NewTxManager(db *sqlx.DB, options TxOptions) *TxManager {
+	return &TxManager{db: db, options: options}
+}
+
+// Do выполнение fn в транзакции с параметрами менеджера
+func (manager *TxManager) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return manager.DoWithOptions(ctx, manager.options, fn)
+}
+
+// DoWithOptions выполнение fn в транзакции: если fn вернула ошибку или запаниковала, транзакция откатывается, иначе коммитится.
+// Если в контексте уже есть транзакция, fn выполняется во вложенной точке сохранения, а параметры не применяются.
+// Ошибки сериализации и взаимной блокировки повторяются целиком не более options.MaxRetries раз.
+func (manager *TxManager) DoWithOptions(ctx context.Context, options TxOptions, fn func(ctx context.Context) error) error {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return doSavepoint(ctx, state, fn)
+	}
+
+	var delay = options.RetryDelay
+	for attempt := 0; ; attempt++ {
+		err := manager.doOnce(ctx, options, fn)
+		if err == nil || !IsRetryable(err) || attempt >= options.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (manager *TxManager) doOnce(ctx context.Context, options TxOptions, fn func(ctx context.Context) error) (err error) {
+	// каждая попытка - отдельный span, поэтому повторы транзакции видны в трассировке
+	ctx, span := tracing.Start(ctx, "transaction", attribute.Bool("db.transaction.read_only", options.ReadOnly))
+	defer tracing.End(span, &err)
+
+	tx, err := manager.db.BeginTxx(ctx, &sql.TxOptions{Isolation: options.Isolation, ReadOnly: options.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("error creating transaction: %w", err)
+	}
+
+	defer func() {
+		// проверяем, не было ли паники
+		if r := recover(); r != nil {
+			err = fmt.Errorf("transaction panic: %v", r)
+		}
+		if errors.Is(err, ErrRollback) {
+			err = tx.Rollback()
+			if err != nil {
+				err = fmt.Errorf("rolling back transaction error: %w", err)
+			}
+		} else if err != nil {
+			errTx := tx.Rollback()
+			if errTx != nil {
+				err = fmt.Errorf("rolling back transaction errors: %w, %w", err, errTx)
+			}
+		} else {
+			errTx := tx.Commit()
+			if errTx != nil {
+				err = fmt.Errorf("commiting transaction error: %w", errTx)
+			}
+		}
+	}()
+
+	return fn(WithTx(ctx, tx))
+}
+
+// doSavepoint выполнение fn во вложенной точке сохранения: при ошибке откатываются только её изменения
+func doSavepoint(ctx context.Context, state *txState, fn func(ctx context.Context) error) (err error) {
+	state.savepoints++
+	var name = fmt.Sprintf("sp_%d", state.savepoints)
+	defer func() { state.savepoints-- }()
+
+	if _, err = state.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("error creating savepoint: %w", err)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("transaction panic: %v", r)
+		}
+		if err != nil {
+			if _, errTx := state.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); errTx != nil {
+				err = fmt.Errorf("rolling back to savepoint errors: %w, %w", err, errTx)
+			} else if errors.Is(err, ErrRollback) {
+				err = nil
+			}
+			return
+		}
+		if _, errTx := state.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); errTx != nil {
+			err = fmt.Errorf("releasing savepoint error: %w", errTx)
+		}
+	}()
+
+	return fn(ctx)
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTxManager(t *testing.T, maxRetries int) (*TxManager, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	var options = TxOptions{MaxRetries: maxRetries, RetryDelay: time.Millisecond}
+	return NewTxManager(sqlx.NewDb(db, "sqlmock"), options), mock
+}
+
+func TestTxManagerDo(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should commit and pass transaction via context", func(t *testing.T) {
+		manager, mock := newTxManager(t, 0)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE employee").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := manager.Do(context.Background(), func(ctx context.Context) error {
+			_, ok := TxFromContext(ctx)
+			a.True(ok)
+			_, err := Conn(ctx, nil).ExecContext(ctx, "UPDATE employee SET active = false")
+			return err
+		})
+		a.NoError(err)
+		a.NoError(mock.ExpectationsWereMet())
+	})
+
+	t.Run("should rollback on error and panic", func(t *testing.T) {
+		manager, mock := newTxManager(t, 0)
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		var fnErr = errors.New("fn error")
+		err := manager.Do(context.Background(), func(ctx context.Context) error { return fnErr })
+		a.ErrorIs(err, fnErr)
+		err = manager.Do(context.Background(), func(ctx context.Context) error { panic("boom") })
+		a.EqualError(err, "transaction panic: boom")
+		a.NoError(mock.ExpectationsWereMet())
+	})
+
+	t.Run("should rollback without error on ErrRollback", func(t *testing.T) {
+		manager, mock := newTxManager(t, 0)
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		err := manager.Do(context.Background(), func(ctx context.Context) error { return ErrRollback })
+		a.NoError(err)
+		a.NoError(mock.ExpectationsWereMet())
+	})
+
+	t.Run("should retry serialization failure", func(t *testing.T) {
+		manager, mock := newTxManager(t, 2)
+		var serializationErr = &pq.Error{Code: pqSerializationFailure}
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectCommit().WillReturnError(&pq.Error{Code: pqDeadlockDetected})
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+
+		var attempts int
+		err := manager.Do(context.Background(), func(ctx context.Context) error {
+			attempts++
+			if attempts == 1 {
+				return serializationErr
+			}
+			return nil
+		})
+		a.NoError(err)
+		a.Equal(3, attempts)
+		a.NoError(mock.ExpectationsWereMet())
+	})
+
+	t.Run("should stop retrying after MaxRetries", func(t *testing.T) {
+		manager, mock := newTxManager(t, 1)
+		var serializationErr = &pq.Error{Code: pqSerializationFailure}
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		var attempts int
+		err := manager.Do(context.Background(), func(ctx context.Context) error {
+			attempts++
+			return serializationErr
+		})
+		a.ErrorIs(err, serializationErr)
+		a.Equal(2, attempts)
+		a.NoError(mock.ExpectationsWereMet())
+	})
+
+	t.Run("should rollback nested call to savepoint only", func(t *testing.T) {
+		manager, mock := newTxManager(t, 0)
+		mock.ExpectBegin()
+		mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("ROLLBACK TO SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("RELEASE SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		var nestedErr = errors.New("nested error")
+		err := manager.Do(context.Background(), func(ctx context.Context) error {
+			err := manager.Do(ctx, func(ctx context.Context) error { return nestedErr })
+			a.ErrorIs(err, nestedErr)
+			return manager.Do(ctx, func(ctx context.Context) error { return nil })
+		})
+		a.NoError(err)
+		a.NoError(mock.ExpectationsWereMet())
+	})
+}
@@ -1,11 +1,13 @@
 package employee
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"idm/inner/common"
+	"idm/inner/crud"
 	"idm/inner/web"
-	"strconv"
-	"strings"
+	"time"
 
 	"github.com/gofiber/fiber"
 )
@@ -13,22 +15,22 @@ import (
 type Controller struct {
 	server          *web.Server
 	employeeService Srv
+	// общие для ресурсов маршруты: пакетное создание, списки, выгрузка, изменение и удаление
+	handlers *crud.Handlers[Filter, Request, Response]
 }
 
 // интерфейс сервиса employee.Service
 type Srv interface {
-	FindById(id int64) (Response, error)
-	SaveTx(req Request) (id int64, err error)
-	FindByIds(ids []int64) ([]Response, error)
-	GetAll() ([]Response, error)
-	DeleteById(id int64) error
-	DeleteByIds(ids []int64) error
+	crud.Srv[Filter, Request, Response]
+	SaveTx(ctx context.Context, req Request) (id int64, err error)
+	Import(ctx context.Context, rows []ImportRow, dryRun bool) (ImportReport, error)
 }
 
 func NewController(server *web.Server, employeeService Srv) *Controller {
 	return &Controller{
 		server:          server,
 		employeeService: employeeService,
+		handlers:        crud.NewHandlers(employeeService, "employee", "employees", func(resp Response) int64 { return resp.Version }),
 	}
 }
 
@@ -37,11 +39,8 @@ func (contr *Controller) RegisterRoutes() {
 
 	// полный маршрут получится "/api/v1/employees"
 	contr.server.GroupApiV1.Post("/employees", contr.CreateEmployee)
-	contr.server.GroupApiV1.Get("/employees", contr.GetAllEmployee)
-	contr.server.GroupApiV1.Get("/employees/id/:id", contr.FindEmployeeById)
-	contr.server.GroupApiV1.Get("/employees/ids", contr.FindEmployeeByIds)
-	contr.server.GroupApiV1.Delete("/employees/id/:id", contr.DeleteEmployeeById)
-	contr.server.GroupApiV1.Delete("/employees/ids", contr.DeleteEmployeeByIds)
+	contr.server.GroupApiV1.Post("/employees/import", contr.ImportEmployees)
+	contr.handlers.RegisterRoutes(contr.server.GroupApiV1, "/employees")
 }
 
 // функция-хендлер, которая будет вызываться при POST запросе по маршруту "/api/v1/employees"
@@ -55,7 +54,7 @@ func (contr *Controller) CreateEmployee(ctx *fiber.Ctx) {
 	}
 
 	// вызываем метод SaveTx сервиса employee.Service
-	var newId, err = contr.employeeService.SaveTx(req)
+	var newId, err = contr.employeeService.SaveTx(web.Context(ctx), req)
 	if err != nil {
 		switch {
 
@@ -80,143 +79,73 @@ func (contr *Controller) CreateEmployee(ctx *fiber.Ctx) {
 	}
 }
 
-func (contr *Controller) FindEmployeeById(ctx *fiber.Ctx) {
-	var idStr string
-	if idStr = ctx.Params("id"); idStr == "" {
-		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "error retrieving id")
-		return
-	}
-
-	num, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error converted id tot int64")
-		return
-	}
-
-	foundResponse, err := contr.employeeService.FindById(num)
-	if err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, err.Error())
-		return
-	}
-
-	if err = common.OkResponse(ctx, foundResponse); err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning found employee")
-		return
-	}
-}
-
-func (contr *Controller) FindEmployeeByIds(ctx *fiber.Ctx) {
-	var req struct {
-		IDs string `query:"ids"`
-	}
-
-	if err := ctx.QueryParser(&req); err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid query parameters")
-		return
-	}
-
-	if req.IDs == "" {
-		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "ids parameter is required")
-		return
-	}
-
-	idStrings := strings.Split(req.IDs, ",")
-	var ids []int64
+// режимы импорта работников из файла
+const (
+	// предварительный просмотр: проверка строк без изменения базы данных
+	importModePreview = "preview"
+	// применение импорта в одной транзакции
+	importModeApply = "apply"
+)
 
-	for _, idStr := range idStrings {
-		id, err := strconv.ParseInt(idStr, 10, 64)
-		if err != nil {
-			_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id format: "+idStr)
+// функция-хендлер, которая будет вызываться при POST запросе по маршруту "/api/v1/employees/import".
+// Ожидает multipart-форму с файлом CSV или XLSX в поле "file" и необязательным
+// соответствием заголовков полям работника в поле "mapping" (JSON-объект).
+func (contr *Controller) ImportEmployees(ctx *fiber.Ctx) {
+	var dryRun bool
+	switch mode := ctx.Query("mode", importModePreview); mode {
+	case importModePreview:
+		dryRun = true
+	case importModeApply:
+		dryRun = false
+	default:
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "unknown import mode: "+mode)
+		return
+	}
+
+	var mapping = DefaultImportMapping
+	if rawMapping := ctx.FormValue("mapping"); rawMapping != "" {
+		mapping = ImportMapping{}
+		if err := json.Unmarshal([]byte(rawMapping), &mapping); err != nil {
+			_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid mapping: "+err.Error())
 			return
 		}
-		ids = append(ids, id)
 	}
 
-	var foundResponses, err = contr.employeeService.FindByIds(ids)
+	fileHeader, err := ctx.FormFile("file")
 	if err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, err.Error())
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "error retrieving file: "+err.Error())
 		return
 	}
 
-	if err = common.OkResponse(ctx, foundResponses); err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning found employees")
-		return
-	}
-}
-
-func (contr *Controller) GetAllEmployee(ctx *fiber.Ctx) {
-	var foundResponses, err = contr.employeeService.GetAll()
+	file, err := fileHeader.Open()
 	if err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, err.Error())
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error opening file: "+err.Error())
 		return
 	}
+	defer file.Close()
 
-	if err = common.OkResponse(ctx, foundResponses); err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning all employees")
-		return
-	}
-}
-
-func (contr *Controller) DeleteEmployeeById(ctx *fiber.Ctx) {
-	var idStr string
-	if idStr = ctx.Params("id"); idStr == "" {
-		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "error retrieving id")
-		return
-	}
-
-	num, err := strconv.ParseInt(idStr, 10, 64)
+	rows, err := ReadImportFile(fileHeader.Filename, file, mapping, time.Now())
 	if err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error converted id tot int64")
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
 		return
 	}
 
-	err = contr.employeeService.DeleteById(num)
+	report, err := contr.employeeService.Import(web.Context(ctx), rows, dryRun)
 	if err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, err.Error())
-		return
-	}
-
-	if err = common.ResponseWithoutData(ctx); err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning result delete employee")
-		return
-	}
-}
-
-func (contr *Controller) DeleteEmployeeByIds(ctx *fiber.Ctx) {
-	var req struct {
-		IDs string `query:"ids"`
-	}
-
-	if err := ctx.QueryParser(&req); err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid query parameters")
-		return
-	}
-
-	if req.IDs == "" {
-		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "ids parameter is required")
-		return
-	}
+		switch {
 
-	idStrings := strings.Split(req.IDs, ",")
-	var ids []int64
+		// в ответ добавляем отчёт, чтобы было видно, какие строки файла некорректны
+		case errors.As(err, &common.RequestValidationError{}):
+			_ = common.ErrResponseWithData(ctx, fiber.StatusBadRequest, err.Error(), report)
 
-	for _, idStr := range idStrings {
-		id, err := strconv.ParseInt(idStr, 10, 64)
-		if err != nil {
-			_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id format: "+idStr)
-			return
+		default:
+			_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, err.Error())
 		}
-		ids = append(ids, id)
-	}
-
-	var err = contr.employeeService.DeleteByIds(ids)
-	if err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, err.Error())
 		return
 	}
 
-	if err = common.ResponseWithoutData(ctx); err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning result delete employees")
+	if err = common.OkResponse(ctx, report); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning import report")
 		return
 	}
 }
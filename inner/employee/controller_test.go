@@ -1,11 +1,15 @@
 package employee
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"idm/inner/common"
+	"idm/inner/tabular"
 	"idm/inner/web"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -23,33 +27,53 @@ type MockService struct {
 }
 
 // Реализуем функции мок-сервиса
-func (srv *MockService) FindById(id int64) (Response, error) {
+func (srv *MockService) FindById(ctx context.Context, id int64) (Response, error) {
 	args := srv.Called(id)
 	return args.Get(0).(Response), args.Error(1)
 }
 
-func (srv *MockService) SaveTx(req Request) (id int64, err error) {
+func (srv *MockService) SaveTx(ctx context.Context, req Request) (id int64, err error) {
 	args := srv.Called(req)
 	return args.Get(0).(int64), args.Error(1)
 }
 
-func (srv *MockService) FindByIds(ids []int64) ([]Response, error) {
+func (srv *MockService) SaveBatch(ctx context.Context, reqs []Request, atomic bool) ([]common.BatchItemResult, error) {
+	args := srv.Called(reqs, atomic)
+	return args.Get(0).([]common.BatchItemResult), args.Error(1)
+}
+
+func (srv *MockService) Import(ctx context.Context, rows []ImportRow, dryRun bool) (ImportReport, error) {
+	args := srv.Called(rows, dryRun)
+	return args.Get(0).(ImportReport), args.Error(1)
+}
+
+func (srv *MockService) FindByIds(ctx context.Context, ids []int64) ([]Response, error) {
 	args := srv.Called(ids)
 	return args.Get(0).([]Response), args.Error(1)
 }
 
-func (srv *MockService) GetAll() ([]Response, error) {
-	args := srv.Called()
+func (srv *MockService) GetAll(ctx context.Context, filter Filter) ([]Response, error) {
+	args := srv.Called(filter)
 	return args.Get(0).([]Response), args.Error(1)
 }
 
-func (srv *MockService) DeleteById(id int64) error {
-	args := srv.Called(id)
+func (srv *MockService) Export(ctx context.Context, filter Filter, format tabular.Format, writer io.Writer) error {
+	args := srv.Called(filter, format, writer)
 	return args.Error(0)
 }
 
-func (srv *MockService) DeleteByIds(ids []int64) error {
-	args := srv.Called(ids)
+func (srv *MockService) Update(ctx context.Context, id int64, version int64, req Request) (Response, error) {
+	args := srv.Called(id, version, req)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (srv *MockService) DeleteById(ctx context.Context, id int64, version int64) error {
+	args := srv.Called(id, version)
+	return args.Error(0)
+}
+
+func (srv *MockService) DeleteByIds(ctx context.Context, ids []int64, versions []int64) error {
+	args := srv.Called(ids, versions)
 	return args.Error(0)
 }
 
@@ -152,6 +176,205 @@ func TestCreateEmployee(t *testing.T) {
 	})
 }
 
+func TestCreateEmployees(t *testing.T) {
+	var a = assert.New(t)
+
+	t.Run("should return results of batch create in partial mode", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+
+		var body = strings.NewReader("[{\"name\": \"john doe\"}, {\"name\": \"x\"}]")
+		var req = httptest.NewRequest(fiber.MethodPost, "/api/v1/employees/batch?mode=partial", body)
+		req.Header.Set("Content-Type", "application/json")
+
+		var results = []common.BatchItemResult{{Index: 0, Id: 10}, {Index: 1, Error: "validation error"}}
+		svc.On("SaveBatch", mock.AnythingOfType("[]employee.Request"), false).Return(results, nil)
+
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.ResponseBody[[]common.BatchItemResult]
+		err = json.Unmarshal(bytesData, &responseBody)
+		a.Nil(err)
+		a.True(responseBody.Success)
+		a.Equal(results, responseBody.Data)
+	})
+
+	t.Run("should return item results when atomic batch is invalid", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+
+		var body = strings.NewReader("[{\"name\": \"x\"}]")
+		var req = httptest.NewRequest(fiber.MethodPost, "/api/v1/employees/batch", body)
+		req.Header.Set("Content-Type", "application/json")
+
+		var results = []common.BatchItemResult{{Index: 0, Error: "validation error"}}
+		svc.On("SaveBatch", mock.AnythingOfType("[]employee.Request"), true).
+			Return(results, common.RequestValidationError{Message: "batch contains invalid employees"})
+
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.ResponseBody[[]common.BatchItemResult]
+		err = json.Unmarshal(bytesData, &responseBody)
+		a.Nil(err)
+		a.False(responseBody.Success)
+		a.Equal(results, responseBody.Data)
+	})
+
+	t.Run("should return error for unknown mode", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+
+		var body = strings.NewReader("[{\"name\": \"john doe\"}]")
+		var req = httptest.NewRequest(fiber.MethodPost, "/api/v1/employees/batch?mode=any", body)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		svc.AssertNotCalled(t, "SaveBatch", mock.Anything, mock.Anything)
+	})
+}
+
+// формирование multipart-формы с файлом импорта
+func newImportRequest(t *testing.T, url string, fileName string, content string, mapping string) *http.Request {
+	var body bytes.Buffer
+	var writer = multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = part.Write([]byte(content))
+	if mapping != "" {
+		_ = writer.WriteField("mapping", mapping)
+	}
+	_ = writer.Close()
+
+	var req = httptest.NewRequest(fiber.MethodPost, url, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestImportEmployees(t *testing.T) {
+	var a = assert.New(t)
+
+	t.Run("should return preview report", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+
+		var req = newImportRequest(t, "/api/v1/employees/import", "employees.csv",
+			"ФИО;Дата\nPupkin;02.01.2025\n", `{"ФИО": "name", "Дата": "create_at"}`)
+
+		var report = ImportReport{DryRun: true, Total: 1, Created: 1}
+		svc.On("Import", mock.MatchedBy(func(rows []ImportRow) bool {
+			return len(rows) == 1 && rows[0].Request.Name == "Pupkin"
+		}), true).Return(report, nil)
+
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.ResponseBody[ImportReport]
+		err = json.Unmarshal(bytesData, &responseBody)
+		a.Nil(err)
+		a.True(responseBody.Success)
+		a.Equal(report.Created, responseBody.Data.Created)
+	})
+
+	t.Run("should return report when apply fails validation", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+
+		var req = newImportRequest(t, "/api/v1/employees/import?mode=apply", "employees.csv", "name\nx\n", "")
+
+		var report = ImportReport{Total: 1, Invalid: 1}
+		svc.On("Import", mock.AnythingOfType("[]employee.ImportRow"), false).
+			Return(report, common.RequestValidationError{Message: "import contains 1 invalid rows"})
+
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.ResponseBody[ImportReport]
+		err = json.Unmarshal(bytesData, &responseBody)
+		a.Nil(err)
+		a.False(responseBody.Success)
+		a.Equal(1, responseBody.Data.Invalid)
+	})
+
+	t.Run("should return error for unsupported file", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+
+		var req = newImportRequest(t, "/api/v1/employees/import", "employees.txt", "name\n", "")
+
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestExportEmployees(t *testing.T) {
+	var a = assert.New(t)
+
+	t.Run("should stream export in format from Accept header", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+
+		var req = httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/export?name=pup", nil)
+		req.Header.Set("Accept", "application/x-ndjson")
+
+		svc.On("Export", Filter{Name: "pup"}, tabular.NDJSON, mock.Anything).
+			Run(func(args mock.Arguments) {
+				_, _ = args.Get(2).(io.Writer).Write([]byte("{\"id\":1}\n"))
+			}).
+			Return(nil)
+
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.Equal("application/x-ndjson", resp.Header.Get("Content-Type"))
+		a.Contains(resp.Header.Get("Content-Disposition"), "employees.ndjson")
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		a.Equal("{\"id\":1}\n", string(bytesData))
+	})
+
+	t.Run("should return error for unknown format", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+
+		var req = httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/export?format=pdf", nil)
+
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
 func TestContrlFindById(t *testing.T) {
 	var a = assert.New(t)
 
@@ -219,6 +442,40 @@ func TestContrlFindById(t *testing.T) {
 		a.NotEmpty(responseBody.Message)
 		a.Equal(errMess2, responseBody.Message)
 	})
+
+	t.Run("should return ETag and 304 when If-None-Match matches", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+		svc.On("FindById", int64(123)).Return(Response{Id: 123, Name: "john doe", Version: 2}, nil)
+
+		var req = httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/id/123", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.Equal(`"2"`, resp.Header.Get(fiber.HeaderETag))
+
+		req = httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/id/123", nil)
+		req.Header.Set(fiber.HeaderIfNoneMatch, `W/"2"`)
+		resp, err = server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusNotModified, resp.StatusCode)
+	})
+
+	t.Run("should return 404 when employee is missing", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+		var req = httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/id/123", nil)
+		svc.On("FindById", int64(123)).Return(Response{}, common.NotFoundError{Message: "employee with id 123 not found"})
+
+		resp, err := server.App.Test(req)
+
+		a.Nil(err)
+		a.Equal(http.StatusNotFound, resp.StatusCode)
+	})
 }
 
 func TestContrlGetAll(t *testing.T) {
@@ -248,7 +505,7 @@ func TestContrlGetAll(t *testing.T) {
 			Create: time.Now(),
 			Update: time.Now(),
 		}
-		svc.On("GetAll").Return([]Response{entity1, entity2}, nil)
+		svc.On("GetAll", Filter{}).Return([]Response{entity1, entity2}, nil)
 
 		// Отправляем тестовый запрос на веб сервер
 		resp, err := server.App.Test(req)
@@ -276,7 +533,7 @@ func TestContrlGetAll(t *testing.T) {
 		var req = httptest.NewRequest(fiber.MethodGet, "/api/v1/employees", nil)
 		var errMess1 = fmt.Errorf("database error")
 		var errMess2 = fmt.Errorf("error finding employee by id: %s, %w", "123", errMess1).Error()
-		svc.On("GetAll").Return([]Response{}, common.DbOperationError{Message: errMess2})
+		svc.On("GetAll", Filter{}).Return([]Response{}, common.DbOperationError{Message: errMess2})
 
 		// Отправляем тестовый запрос на веб сервер
 		resp, err := server.App.Test(req)
@@ -308,8 +565,9 @@ func TestContrlDeleteById(t *testing.T) {
 		controller.RegisterRoutes()
 		// Готовим тестовое окружение
 		var req = httptest.NewRequest(fiber.MethodDelete, "/api/v1/employees/id/123", nil)
+		req.Header.Set(fiber.HeaderIfMatch, `"3"`)
 
-		svc.On("DeleteById", int64(123)).Return(nil)
+		svc.On("DeleteById", int64(123), int64(3)).Return(nil)
 
 		// Отправляем тестовый запрос на веб сервер
 		resp, err := server.App.Test(req)
@@ -335,10 +593,11 @@ func TestContrlDeleteById(t *testing.T) {
 		controller.RegisterRoutes()
 		// Готовим тестовое окружение
 		var req = httptest.NewRequest(fiber.MethodDelete, "/api/v1/employees/id/123", nil)
+		req.Header.Set(fiber.HeaderIfMatch, `"3"`)
 
 		var errMess1 = fmt.Errorf("database error")
 		var errMess2 = fmt.Errorf("error finding employee by id: %s, %w", "123", errMess1).Error()
-		svc.On("DeleteById", int64(123)).Return(common.DbOperationError{Message: errMess2})
+		svc.On("DeleteById", int64(123), int64(3)).Return(common.DbOperationError{Message: errMess2})
 
 		// Отправляем тестовый запрос на веб сервер
 		resp, err := server.App.Test(req)
@@ -356,6 +615,125 @@ func TestContrlDeleteById(t *testing.T) {
 		a.NotEmpty(responseBody.Message)
 		a.Equal(errMess2, responseBody.Message)
 	})
+
+	t.Run("should require If-Match on DeleteById", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+		var req = httptest.NewRequest(fiber.MethodDelete, "/api/v1/employees/id/123", nil)
+
+		resp, err := server.App.Test(req)
+
+		a.Nil(err)
+		a.Equal(http.StatusPreconditionRequired, resp.StatusCode)
+		svc.AssertNotCalled(t, "DeleteById", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should return 412 when version is stale", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+		var req = httptest.NewRequest(fiber.MethodDelete, "/api/v1/employees/id/123", nil)
+		req.Header.Set(fiber.HeaderIfMatch, `"3"`)
+		svc.On("DeleteById", int64(123), int64(3)).Return(common.PreconditionFailedError{Message: "version mismatch"})
+
+		resp, err := server.App.Test(req)
+
+		a.Nil(err)
+		a.Equal(http.StatusPreconditionFailed, resp.StatusCode)
+	})
+}
+
+func TestContrlUpdate(t *testing.T) {
+	var a = assert.New(t)
+
+	t.Run("should update employee and return new ETag", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+		var req = httptest.NewRequest(fiber.MethodPut, "/api/v1/employees/id/123", strings.NewReader(`{"name": "john doe"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		req.Header.Set(fiber.HeaderIfMatch, `"3"`)
+		svc.On("Update", int64(123), int64(3), Request{Name: "john doe"}).Return(Response{Id: 123, Name: "john doe", Version: 4}, nil)
+
+		resp, err := server.App.Test(req)
+
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.Equal(`"4"`, resp.Header.Get(fiber.HeaderETag))
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.ResponseBody[Response]
+		err = json.Unmarshal(bytesData, &responseBody)
+		a.Nil(err)
+		a.True(responseBody.Success)
+		a.Equal(int64(4), responseBody.Data.Version)
+	})
+
+	t.Run("should require If-Match", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+		var req = httptest.NewRequest(fiber.MethodPut, "/api/v1/employees/id/123", strings.NewReader(`{"name": "john doe"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		resp, err := server.App.Test(req)
+
+		a.Nil(err)
+		a.Equal(http.StatusPreconditionRequired, resp.StatusCode)
+		svc.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should reject weak If-Match", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+		var req = httptest.NewRequest(fiber.MethodPut, "/api/v1/employees/id/123", strings.NewReader(`{"name": "john doe"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		req.Header.Set(fiber.HeaderIfMatch, `W/"3"`)
+
+		resp, err := server.App.Test(req)
+
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("should return 412 when version is stale", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+		var req = httptest.NewRequest(fiber.MethodPut, "/api/v1/employees/id/123", strings.NewReader(`{"name": "john doe"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		req.Header.Set(fiber.HeaderIfMatch, `"3"`)
+		svc.On("Update", int64(123), int64(3), Request{Name: "john doe"}).Return(Response{}, common.PreconditionFailedError{Message: "version mismatch"})
+
+		resp, err := server.App.Test(req)
+
+		a.Nil(err)
+		a.Equal(http.StatusPreconditionFailed, resp.StatusCode)
+	})
+
+	t.Run("should return 404 when employee not found", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+		var req = httptest.NewRequest(fiber.MethodPut, "/api/v1/employees/id/123", strings.NewReader(`{"name": "john doe"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		req.Header.Set(fiber.HeaderIfMatch, "*")
+		svc.On("Update", int64(123), int64(0), Request{Name: "john doe"}).Return(Response{}, common.NotFoundError{Message: "not found"})
+
+		resp, err := server.App.Test(req)
+
+		a.Nil(err)
+		a.Equal(http.StatusNotFound, resp.StatusCode)
+	})
 }
 
 func TestContrlFindByIds(t *testing.T) {
@@ -436,7 +814,8 @@ func TestContrlDeleteByIds(t *testing.T) {
 		var controller = NewController(server, svc)
 		controller.RegisterRoutes()
 		var req = httptest.NewRequest(fiber.MethodDelete, "/api/v1/employees/ids?ids=1,2,3", nil)
-		svc.On("DeleteByIds", []int64{1, 2, 3}).Return(nil)
+		req.Header.Set(fiber.HeaderIfMatch, `"3", "1", "7"`)
+		svc.On("DeleteByIds", []int64{1, 2, 3}, []int64{3, 1, 7}).Return(nil)
 
 		resp, err := server.App.Test(req)
 
@@ -458,10 +837,11 @@ func TestContrlDeleteByIds(t *testing.T) {
 		var controller = NewController(server, svc)
 		controller.RegisterRoutes()
 		var req = httptest.NewRequest(fiber.MethodDelete, "/api/v1/employees/ids?ids=1,2,3", nil)
+		req.Header.Set(fiber.HeaderIfMatch, "*")
 
 		var errMess1 = fmt.Errorf("database error")
 		var errMess2 = fmt.Errorf("error finding employee by id: %s, %w", "123", errMess1).Error()
-		svc.On("DeleteByIds", []int64{1, 2, 3}).Return(common.DbOperationError{Message: errMess2})
+		svc.On("DeleteByIds", []int64{1, 2, 3}, []int64{0, 0, 0}).Return(common.DbOperationError{Message: errMess2})
 
 		resp, err := server.App.Test(req)
 
@@ -477,4 +857,49 @@ func TestContrlDeleteByIds(t *testing.T) {
 		a.NotEmpty(responseBody.Message)
 		a.Equal(errMess2, responseBody.Message)
 	})
+
+	t.Run("should require If-Match on DeleteByIds", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+		var req = httptest.NewRequest(fiber.MethodDelete, "/api/v1/employees/ids?ids=1,2,3", nil)
+
+		resp, err := server.App.Test(req)
+
+		a.Nil(err)
+		a.Equal(http.StatusPreconditionRequired, resp.StatusCode)
+		svc.AssertNotCalled(t, "DeleteByIds", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should reject If-Match without ETag for every id", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+		var req = httptest.NewRequest(fiber.MethodDelete, "/api/v1/employees/ids?ids=1,2,3", nil)
+		req.Header.Set(fiber.HeaderIfMatch, `"3", "1"`)
+
+		resp, err := server.App.Test(req)
+
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		svc.AssertNotCalled(t, "DeleteByIds", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should return 412 when one version is stale", func(t *testing.T) {
+		server := web.NewServer()
+		var svc = new(MockService)
+		var controller = NewController(server, svc)
+		controller.RegisterRoutes()
+		var req = httptest.NewRequest(fiber.MethodDelete, "/api/v1/employees/ids?ids=1,2", nil)
+		req.Header.Set(fiber.HeaderIfMatch, `"3", "1"`)
+		svc.On("DeleteByIds", []int64{1, 2}, []int64{3, 1}).
+			Return(common.PreconditionFailedError{Message: "employee with id 2 has version 2, expected 1"})
+
+		resp, err := server.App.Test(req)
+
+		a.Nil(err)
+		a.Equal(http.StatusPreconditionFailed, resp.StatusCode)
+	})
 }
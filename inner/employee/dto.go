@@ -1,6 +1,7 @@
 package employee
 
 import (
+	"idm/inner/tabular"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -11,13 +12,19 @@ type Entity struct {
 	Name   string    `db:"name"`
 	Create time.Time `db:"create_at"`
 	Update time.Time `db:"update_at"`
+	// работник деактивирован, если уволен по данным кадровой системы
+	Active bool `db:"active"`
+	// версия строки для оптимистичной блокировки, увеличивается при каждом изменении
+	Version int64 `db:"version"`
 }
 
 type Response struct {
-	Id     int64     `json:"id"`
-	Name   string    `json:"name"`
-	Create time.Time `json:"create_at"`
-	Update time.Time `json:"update_at"`
+	Id      int64     `json:"id"`
+	Name    string    `json:"name"`
+	Create  time.Time `json:"create_at"`
+	Update  time.Time `json:"update_at"`
+	Active  bool      `json:"active"`
+	Version int64     `json:"version"`
 }
 
 type Request struct {
@@ -26,6 +33,18 @@ type Request struct {
 	Update time.Time `json:"update_at" validate:"required"`
 }
 
+// Filter фильтры списка работников, общие для получения списка и выгрузки
+type Filter struct {
+	// часть имени без учёта регистра
+	Name string `query:"name"`
+}
+
+// RequestSetActive запрос на активацию или деактивацию работников
+type RequestSetActive struct {
+	Ids    []int64 `json:"ids" validate:"required,min=1"`
+	Active bool    `json:"active"`
+}
+
 type RequestById struct {
 	Id int64 `json:"id" validate:"required,gt=0"`
 }
@@ -34,12 +53,23 @@ type RequestByIds struct {
 	Ids []int64 `json:"ids" validate:"required"`
 }
 
+// колонки выгрузки в табличные форматы
+var exportColumns = []tabular.Column[Response]{
+	{Title: "id", Value: func(resp Response) any { return resp.Id }},
+	{Title: "name", Value: func(resp Response) any { return resp.Name }},
+	{Title: "active", Value: func(resp Response) any { return resp.Active }},
+	{Title: "create_at", Value: func(resp Response) any { return resp.Create.Format(time.RFC3339) }},
+	{Title: "update_at", Value: func(resp Response) any { return resp.Update.Format(time.RFC3339) }},
+}
+
 func (e *Entity) toResponse() Response {
 	return Response{
-		Id:     e.Id,
-		Name:   e.Name,
-		Create: e.Create,
-		Update: e.Update,
+		Id:      e.Id,
+		Name:    e.Name,
+		Create:  e.Create,
+		Update:  e.Update,
+		Active:  e.Active,
+		Version: e.Version,
 	}
 }
 
@@ -51,10 +81,11 @@ func toResponses(entities []Entity) (responses []Response) {
 	return responses
 }
 
-func (r *Request) toEntity() *Entity {
+func (r *Request) ToEntity() *Entity {
 	return &Entity{
 		Name:   r.Name,
 		Create: r.Create,
 		Update: r.Update,
+		Active: true,
 	}
 }
@@ -0,0 +1,174 @@
+package employee
+
+import (
+	"fmt"
+	"idm/inner/tabular"
+	"io"
+	"strings"
+	"time"
+)
+
+// ImportMapping соответствие заголовков колонок файла импорта полям employee.Request
+type ImportMapping map[string]string
+
+// поля employee.Request, в которые можно импортировать колонку файла
+const (
+	ImportFieldName   = "name"
+	ImportFieldCreate = "create_at"
+	ImportFieldUpdate = "update_at"
+)
+
+// действия над строкой импорта
+const (
+	ImportActionCreate = "create"
+	ImportActionUpdate = "update"
+	ImportActionError  = "error"
+)
+
+// DefaultImportMapping соответствие по умолчанию: заголовки совпадают с именами полей
+var DefaultImportMapping = ImportMapping{
+	ImportFieldName:   ImportFieldName,
+	ImportFieldCreate: ImportFieldCreate,
+	ImportFieldUpdate: ImportFieldUpdate,
+}
+
+// форматы дат, которые принимаются в файле импорта
+var importTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"02.01.2006",
+}
+
+// ImportRow строка файла импорта, преобразованная в запрос на создание работника
+type ImportRow struct {
+	// номер строки в файле (заголовок - первая строка)
+	Line    int
+	Request Request
+	// поля запроса, взятые из файла: при обновлении существующего работника меняются только они
+	Fields map[string]bool
+	Errors []string
+}
+
+// ImportRowResult результат импорта одной строки файла
+type ImportRowResult struct {
+	Line   int      `json:"line"`
+	Name   string   `json:"name"`
+	Action string   `json:"action"`
+	Id     int64    `json:"id,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// ImportReport отчёт об импорте: при предварительном просмотре (DryRun) изменения в базу данных не вносятся
+type ImportReport struct {
+	DryRun  bool              `json:"dry_run"`
+	Total   int               `json:"total"`
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Invalid int               `json:"invalid"`
+	Rows    []ImportRowResult `json:"rows"`
+}
+
+// ReadImportFile чтение файла импорта в формате, определённом по имени файла (CSV или XLSX),
+// и преобразование его строк в запросы
+func ReadImportFile(fileName string, file io.Reader, mapping ImportMapping, now time.Time) ([]ImportRow, error) {
+	format, err := tabular.FormatFromFileName(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	header, records, err := tabular.Read(file, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseImportRows(header, records, mapping, now)
+}
+
+// ParseImportRows преобразование строк файла в запросы по переданному соответствию колонок.
+// Если колонки дат не сопоставлены, используется время импорта now (при обновлении - только для update_at).
+func ParseImportRows(header []string, records [][]string, mapping ImportMapping, now time.Time) ([]ImportRow, error) {
+	// индекс колонки файла для каждого поля запроса
+	var columns = make(map[string]int)
+	for i, title := range header {
+		for column, field := range mapping {
+			if strings.EqualFold(strings.TrimSpace(title), strings.TrimSpace(column)) {
+				switch field {
+				case ImportFieldName, ImportFieldCreate, ImportFieldUpdate:
+					columns[field] = i
+				default:
+					return nil, fmt.Errorf("unknown employee field in mapping: %s", field)
+				}
+			}
+		}
+	}
+	if _, ok := columns[ImportFieldName]; !ok {
+		return nil, fmt.Errorf("file has no column mapped to field %s", ImportFieldName)
+	}
+
+	var rows = make([]ImportRow, 0, len(records))
+	for i, record := range records {
+		if isEmptyRecord(record) {
+			continue
+		}
+
+		var row = ImportRow{
+			Line:    i + 2,
+			Request: Request{Create: now, Update: now},
+			Fields:  make(map[string]bool, len(columns)),
+		}
+		for field := range columns {
+			row.Fields[field] = true
+		}
+		row.Request.Name = cell(record, columns[ImportFieldName])
+		for _, date := range []struct {
+			field  string
+			target *time.Time
+		}{
+			{ImportFieldCreate, &row.Request.Create},
+			{ImportFieldUpdate, &row.Request.Update},
+		} {
+			index, ok := columns[date.field]
+			if !ok {
+				continue
+			}
+			value, err := parseImportTime(cell(record, index))
+			if err != nil {
+				row.Errors = append(row.Errors, fmt.Sprintf("%s: %v", date.field, err))
+				continue
+			}
+			*date.target = value
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func cell(record []string, index int) string {
+	if index >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[index])
+}
+
+func isEmptyRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func parseImportTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("value is empty")
+	}
+	for _, layout := range importTimeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse date %q", value)
+}
@@ -0,0 +1,44 @@
+package employee
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseImportRows(t *testing.T) {
+	a := assert.New(t)
+	var now = time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("should map columns by custom mapping", func(t *testing.T) {
+		var header = []string{"ФИО", "Принят"}
+		var records = [][]string{
+			{"Pupkin", "02.01.2025"},
+			{"", ""},
+			{"Ivanov", "not a date"},
+		}
+		var mapping = ImportMapping{"фио": ImportFieldName, "Принят": ImportFieldCreate}
+
+		rows, err := ParseImportRows(header, records, mapping, now)
+		a.NoError(err)
+		a.Len(rows, 2)
+		a.Equal(2, rows[0].Line)
+		a.Equal("Pupkin", rows[0].Request.Name)
+		a.Equal(time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), rows[0].Request.Create)
+		a.Equal(now, rows[0].Request.Update)
+		a.Empty(rows[0].Errors)
+		a.Equal(4, rows[1].Line)
+		a.Len(rows[1].Errors, 1)
+	})
+
+	t.Run("should return error when name column is missing", func(t *testing.T) {
+		_, err := ParseImportRows([]string{"create_at"}, nil, DefaultImportMapping, now)
+		a.Error(err)
+	})
+
+	t.Run("should return error for unknown field in mapping", func(t *testing.T) {
+		_, err := ParseImportRows([]string{"name"}, nil, ImportMapping{"name": "salary"}, now)
+		a.Error(err)
+	})
+}
@@ -0,0 +1,64 @@
+package employee
+
+import (
+	"context"
+	"idm/inner/crud"
+	"idm/inner/memory"
+	"time"
+)
+
+// MemoryRepository хранение работников в памяти: те же методы, что и у Repository
+type MemoryRepository struct {
+	*crud.MemoryRepository[Entity, Filter]
+}
+
+// описание работника для обобщённого репозитория в памяти
+var memoryTable = crud.MemoryTable[Entity, Filter]{
+	Name:       "employee",
+	Id:         func(entity *Entity) int64 { return entity.Id },
+	SetId:      func(entity *Entity, id int64) { entity.Id = id },
+	Version:    func(entity *Entity) int64 { return entity.Version },
+	SetVersion: func(entity *Entity, version int64) { entity.Version = version },
+	Key:        func(entity *Entity) string { return entity.Name },
+	Match: func(filter Filter, entity *Entity) bool {
+		return crud.ContainsFold(entity.Name, filter.Name)
+	},
+	Apply: func(row *Entity, changes *Entity) {
+		row.Name, row.Create, row.Update = changes.Name, changes.Create, changes.Update
+	},
+	Defaults: func(entity *Entity) {
+		var now = time.Now()
+		if entity.Create.IsZero() {
+			entity.Create = now
+		}
+		if entity.Update.IsZero() {
+			entity.Update = now
+		}
+		entity.Active = true
+	},
+}
+
+func NewEmployeeMemoryRepository(db *memory.DB) *MemoryRepository {
+	return &MemoryRepository{MemoryRepository: crud.NewMemoryRepository(db, memoryTable)}
+}
+
+func (rep *MemoryRepository) FindByNameTx(ctx context.Context, name string) (isExists bool, err error) {
+	return rep.ExistsByName(ctx, name)
+}
+
+func (rep *MemoryRepository) SaveTx(ctx context.Context, entity *Entity) (id int64, err error) {
+	return rep.Insert(ctx, entity)
+}
+
+// SetActive активация или деактивация работников по списку id
+func (rep *MemoryRepository) SetActive(ctx context.Context, ids []int64, active bool) error {
+	return rep.Modify(ctx, ids, func(entity *Entity) {
+		entity.Active = active
+		entity.Update = time.Now()
+	})
+}
+
+func (rep *MemoryRepository) Save(ctx context.Context, entity *Entity) (id int64, err error) {
+	// как и в Repository, сохраняется только имя
+	return rep.Insert(ctx, &Entity{Name: entity.Name})
+}
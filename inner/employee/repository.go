@@ -1,79 +1,63 @@
 package employee
 
 import (
+	"context"
+	"idm/inner/crud"
+	"idm/inner/database"
+	"time"
+
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 )
 
+// Repository запросы к таблице employee: общие запросы выполняет встроенный crud.Repository
 type Repository struct {
-	db *sqlx.DB
+	*crud.Repository[Entity, Filter]
 }
 
-func NewEmployeeRepository(database *sqlx.DB) *Repository {
-	return &Repository{db: database}
+// описание таблицы employee для обобщённого репозитория
+var table = crud.Table[Entity, Filter]{
+	Name:    "employee",
+	Columns: []string{"name", "create_at", "update_at"},
+	Values: func(entity *Entity) []any {
+		return []any{entity.Name, entity.Create, entity.Update}
+	},
+	Id:        func(entity *Entity) int64 { return entity.Id },
+	KeyColumn: "name",
+	Key:       func(entity *Entity) string { return entity.Name },
+	Filter: func(filter Filter) (string, []any) {
+		return crud.NameFilter("name", filter.Name)
+	},
 }
 
-func (rep *Repository) BeginTransaction() (tx *sqlx.Tx, err error) {
-	return rep.db.Beginx()
+func NewEmployeeRepository(database *sqlx.DB) *Repository {
+	return &Repository{Repository: crud.NewRepository(database, table)}
 }
 
-func (rep *Repository) FindByNameTx(tx *sqlx.Tx, name string) (isExists bool, err error) {
-	err = tx.Get(&isExists, "SELECT EXISTS(SELECT 1 FROM employee WHERE name = $1)", name)
+func (rep *Repository) FindByNameTx(ctx context.Context, name string) (isExists bool, err error) {
+	err = rep.Conn(ctx).GetContext(ctx, &isExists, "SELECT EXISTS(SELECT 1 FROM employee WHERE LOWER(name) = LOWER($1))", name)
 	return isExists, err
 }
 
-func (rep *Repository) SaveTx(tx *sqlx.Tx, entity *Entity) (id int64, err error) {
+func (rep *Repository) SaveTx(ctx context.Context, entity *Entity) (id int64, err error) {
 	query := "INSERT INTO employee (name, create_at, update_at) VALUES ($1, $2, $3) RETURNING id"
-	err = tx.Get(&id, query, entity.Name, entity.Create, entity.Update)
-	return id, err
-}
-
-func (rep *Repository) Save(entity *Entity) (id int64, err error) {
-	query := "INSERT INTO employee (name) VALUES ($1) RETURNING id"
-	err = rep.db.Get(&id, query, entity.Name)
-	return id, err
+	err = rep.Conn(ctx).GetContext(ctx, &id, query, entity.Name, entity.Create, entity.Update)
+	return id, database.TranslateError(err)
 }
 
-func (rep *Repository) FindById(id int64) (entity Entity, err error) {
-	query := "SELECT * FROM employee WHERE id = $1"
-	err = rep.db.Get(&entity, query, id)
-	return entity, err
-}
-
-func (rep *Repository) GetAll() (entities []Entity, err error) {
-	query := "SELECT * FROM employee"
-	err = rep.db.Select(&entities, query)
-	return entities, err
-}
-
-func (rep *Repository) FindByIds(ids []int64) (entities []Entity, err error) {
-	query := "SELECT * FROM employee WHERE id IN (?)"
-	query, args, err := sqlx.In(query, ids)
-
+// SetActive активация или деактивация работников по списку id
+func (rep *Repository) SetActive(ctx context.Context, ids []int64, active bool) error {
+	query, args, err := sqlx.In("UPDATE employee SET active = ?, update_at = ?, version = version + 1 WHERE id IN (?)", active, time.Now(), ids)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	query = sqlx.Rebind(2, query)
-	err = rep.db.Select(&entities, query, args...)
-	return entities, err
-}
-
-func (rep *Repository) DeleteById(id int64) error {
-	query := "DELETE FROM employee WHERE id = $1"
-	_, err := rep.db.Exec(query, id)
+	_, err = rep.Conn(ctx).ExecContext(ctx, query, args...)
 	return err
 }
 
-func (rep *Repository) DeleteByIds(ids []int64) error {
-	query := "DELETE FROM employee WHERE id IN (?)"
-	query, args, err := sqlx.In(query, ids)
-
-	if err != nil {
-		return err
-	}
-
-	query = sqlx.Rebind(2, query)
-	_, err = rep.db.Exec(query, args...)
-	return err
+func (rep *Repository) Save(ctx context.Context, entity *Entity) (id int64, err error) {
+	query := "INSERT INTO employee (name) VALUES ($1) RETURNING id"
+	err = rep.Conn(ctx).GetContext(ctx, &id, query, entity.Name)
+	return id, database.TranslateError(err)
 }
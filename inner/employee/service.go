@@ -1,143 +1,236 @@
 package employee
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 
 	"idm/inner/common"
+	"idm/inner/crud"
+	"idm/inner/database"
+	"idm/inner/tracing"
 
-	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// Service операции с работниками: общие для ресурсов операции выполняет встроенный crud.Service
 type Service struct {
+	*crud.Service[Entity, Filter, Request, Response]
 	repo  Repo
 	valid Validator
 }
 
 type Repo interface {
-	FindByNameTx(tx *sqlx.Tx, name string) (isExists bool, err error)
-	BeginTransaction() (tx *sqlx.Tx, err error)
-	SaveTx(tx *sqlx.Tx, entity *Entity) (id int64, err error)
-	Save(entity *Entity) (id int64, err error)
-	FindById(id int64) (entity Entity, err error)
-	GetAll() (entities []Entity, err error)
-	FindByIds(ids []int64) (entities []Entity, err error)
-	DeleteById(id int64) error
-	DeleteByIds(ids []int64) error
+	crud.Store[Entity, Filter]
+	FindByNameTx(ctx context.Context, name string) (isExists bool, err error)
+	SaveTx(ctx context.Context, entity *Entity) (id int64, err error)
+	FindByNamesTx(ctx context.Context, names []string) (entities []Entity, err error)
+	UpdateTx(ctx context.Context, entity *Entity) error
+	SetActive(ctx context.Context, ids []int64, active bool) error
+	Save(ctx context.Context, entity *Entity) (id int64, err error)
 }
 
 type Validator interface {
 	Validate(request any) error
 }
 
+// описание работника для обобщённого сервиса
+var resource = crud.Resource[Entity, Request, Response]{
+	Name:          "employee",
+	Plural:        "employees",
+	Key:           func(req Request) string { return req.Name },
+	ToEntity:      func(req Request) *Entity { return req.ToEntity() },
+	SetId:         func(entity *Entity, id int64) { entity.Id = id },
+	Version:       func(entity Entity) int64 { return entity.Version },
+	ToResponse:    func(entity Entity) Response { return entity.toResponse() },
+	ExportColumns: exportColumns,
+}
+
 func NewService(repo Repo, validator Validator) *Service {
 	return &Service{
-		repo:  repo,
-		valid: validator,
+		Service: crud.NewService(repo, validator, resource),
+		repo:    repo,
+		valid:   validator,
 	}
 }
 
-func (serv *Service) SaveTx(req Request) (id int64, err error) {
+func (serv *Service) SaveTx(ctx context.Context, req Request) (id int64, err error) {
+	ctx, span := tracing.Start(ctx, "employee.SaveTx")
+	defer tracing.End(span, &err)
+
 	// валидируем запрос (про валидатор расскажу дальше)
-	err = serv.valid.Validate(req)
+	err = tracing.Validate(ctx, serv.valid, req)
 	if err != nil {
 		// возвращаем кастомную ошибку в случае, если запрос не прошёл валидацию (про кастомные ошибки - дальше)
 		return 0, common.RequestValidationError{Message: err.Error()}
 	}
 
-	tx, err := serv.repo.BeginTransaction()
-	if err != nil {
-		return 0, fmt.Errorf("error creating transaction: %w", err)
-	}
-
-	defer func() {
-		// проверяем, не было ли паники
-		if r := recover(); r != nil {
-			err = fmt.Errorf("creating employee panic: %v", r)
-			// если была паника, то откатываем транзакцию
-			errTx := tx.Rollback()
-			if errTx != nil {
-				err = fmt.Errorf("creating employee: rolling back transaction errors: %w, %w", err, errTx)
-			}
-		} else if err != nil {
-			// если произошла другая ошибка (не паника), то откатываем транзакцию
-			errTx := tx.Rollback()
-			if errTx != nil {
-				err = fmt.Errorf("creating employee: rolling back transaction errors: %w, %w", err, errTx)
-			}
-		} else {
-			// если ошибок нет, то коммитим транзакцию
-			errTx := tx.Commit()
-			if errTx != nil {
-				err = fmt.Errorf("creating employee: commiting transaction error: %w", errTx)
-			}
+	err = serv.repo.InTransaction(ctx, func(ctx context.Context) error {
+		isExists, err := serv.repo.FindByNameTx(ctx, req.Name)
+		if err != nil {
+			return fmt.Errorf("error finding employee by name: %s, %w", req.Name, err)
+		}
+		if isExists {
+			return common.AlreadyExistsError{Message: fmt.Errorf("employee with name %s already exists", req.Name).Error()}
 		}
-	}()
 
-	isExists, err := serv.repo.FindByNameTx(tx, req.Name)
+		// проверка выше не защищает от параллельных запросов, поэтому повтор имени может прийти
+		// и из уникального индекса - тогда ошибка уже переведена репозиторием в AlreadyExistsError
+		id, err = serv.repo.SaveTx(ctx, req.ToEntity())
+		if err != nil {
+			return fmt.Errorf("error creating employee with name: %s %w", req.Name, err)
+		}
+		return nil
+	})
 	if err != nil {
-		return 0, common.DbOperationError{Message: fmt.Errorf("error finding employee by name: %s, %w", req.Name, err).Error()}
-	}
-	if isExists {
-		return 0, common.AlreadyExistsError{Message: fmt.Errorf("employee with name %s already exists", req.Name).Error()}
+		return 0, crud.DbError(err)
 	}
+	return id, nil
+}
 
-	newId, err := serv.repo.SaveTx(tx, req.toEntity())
-	if err != nil {
-		err = fmt.Errorf("error creating employee with name: %s %v", req.Name, err)
+// Import импорт работников из строк файла с upsert-ом по имени работника.
+// При dryRun возвращается только отчёт о планируемых изменениях, а транзакция откатывается.
+// Иначе импорт применяется целиком в одной транзакции и только если все строки корректны.
+func (serv *Service) Import(ctx context.Context, rows []ImportRow, dryRun bool) (report ImportReport, err error) {
+	ctx, span := tracing.Start(ctx, "employee.Import", attribute.Int("import.rows", len(rows)), attribute.Bool("import.dry_run", dryRun))
+	defer tracing.End(span, &err)
+
+	report = ImportReport{
+		DryRun: dryRun,
+		Total:  len(rows),
+		Rows:   make([]ImportRowResult, len(rows)),
 	}
-	return newId, err
-}
 
-func (serv *Service) Save(req Request) (id int64, err error) {
-	id, err = serv.repo.Save(req.toEntity())
-	if err != nil {
-		return 0, fmt.Errorf("error save employee: %w", err)
+	// номер первой строки с таким именем (имена уникальны без учёта регистра)
+	var lineByName = make(map[string]int, len(rows))
+	// все строки проверяются в одном span, а не в span на каждую строку
+	_, validateSpan := tracing.Start(ctx, "validate", attribute.Int("import.rows", len(rows)))
+	for i, row := range rows {
+		var result = &report.Rows[i]
+		result.Line = row.Line
+		result.Name = row.Request.Name
+		result.Errors = row.Errors
+		if errVld := serv.valid.Validate(row.Request); errVld != nil {
+			result.Errors = append(result.Errors, errVld.Error())
+		}
+		if first, ok := lineByName[strings.ToLower(row.Request.Name)]; ok {
+			result.Errors = append(result.Errors, fmt.Sprintf("employee with name %s is duplicated in file (line %d)", row.Request.Name, first))
+		} else if len(result.Errors) == 0 {
+			lineByName[strings.ToLower(row.Request.Name)] = row.Line
+		}
 	}
+	validateSpan.End()
 
-	return id, nil
-}
+	// ошибки проверки строк до транзакции: при её повторе отчёт собирается заново
+	var validated = report.Rows
+	err = serv.repo.InTransaction(ctx, func(ctx context.Context) error {
+		report.Rows = append([]ImportRowResult(nil), validated...)
+		report.Created, report.Updated, report.Invalid = 0, 0, 0
 
-func (serv *Service) FindById(id int64) (Response, error) {
-	resp, err := serv.repo.FindById(id)
-	if err != nil {
-		return Response{}, common.DbOperationError{Message: fmt.Errorf("error finding employee with id %d: %w", id, err).Error()}
+		var names = make([]string, 0, len(lineByName))
+		for name := range lineByName {
+			names = append(names, name)
+		}
+		found, err := serv.repo.FindByNamesTx(ctx, names)
+		if err != nil {
+			return fmt.Errorf("error finding employees by names: %w", err)
+		}
+		var existing = make(map[string]Entity, len(found))
+		for _, entity := range found {
+			existing[strings.ToLower(entity.Name)] = entity
+		}
 
-	}
+		for i := range report.Rows {
+			var result = &report.Rows[i]
+			switch entity, ok := existing[strings.ToLower(result.Name)]; {
+			case len(result.Errors) > 0:
+				result.Action = ImportActionError
+				report.Invalid++
+			case ok:
+				result.Action = ImportActionUpdate
+				result.Id = entity.Id
+				report.Updated++
+			default:
+				result.Action = ImportActionCreate
+				report.Created++
+			}
+		}
 
-	return resp.toResponse(), nil
-}
+		if dryRun {
+			// при предварительном просмотре изменения никогда не сохраняются
+			return database.ErrRollback
+		}
+		if report.Invalid > 0 {
+			return common.RequestValidationError{Message: fmt.Sprintf("import contains %d invalid rows", report.Invalid)}
+		}
 
-func (serv *Service) GetAll() ([]Response, error) {
-	resps, err := serv.repo.GetAll()
-	if err != nil {
-		return []Response{}, common.DbOperationError{Message: fmt.Errorf("error get all employees: %w", err).Error()}
-	}
+		var created []int
+		var entities []*Entity
+		for i, row := range rows {
+			if report.Rows[i].Action == ImportActionUpdate {
+				// обновляются только поля из файла: без колонки create_at дата создания сохраняется
+				var entity = existing[strings.ToLower(row.Request.Name)]
+				entity.Name = row.Request.Name
+				entity.Update = row.Request.Update
+				if row.Fields[ImportFieldCreate] {
+					entity.Create = row.Request.Create
+				}
+				if err := serv.repo.UpdateTx(ctx, &entity); errors.As(err, &common.AlreadyExistsError{}) {
+					return err
+				} else if err != nil {
+					return fmt.Errorf("error updating employee with name: %s, %w", entity.Name, err)
+				}
+				continue
+			}
+			var entity = row.Request.ToEntity()
+			created = append(created, i)
+			entities = append(entities, entity)
+		}
 
-	return toResponses(resps), nil
+		if len(entities) > 0 {
+			ids, err := serv.repo.SaveBatchTx(ctx, entities)
+			if errors.As(err, &common.AlreadyExistsError{}) {
+				return err
+			}
+			if err != nil {
+				return fmt.Errorf("error creating employees: %w", err)
+			}
+			for k, i := range created {
+				report.Rows[i].Id = ids[k]
+			}
+		}
+
+		return nil
+	})
+	return report, crud.DbError(err)
 }
 
-func (serv *Service) FindByIds(ids []int64) ([]Response, error) {
-	resps, err := serv.repo.FindByIds(ids)
+func (serv *Service) Save(ctx context.Context, req Request) (id int64, err error) {
+	ctx, span := tracing.Start(ctx, "employee.Save")
+	defer tracing.End(span, &err)
+
+	id, err = serv.repo.Save(ctx, req.ToEntity())
 	if err != nil {
-		return []Response{}, common.DbOperationError{Message: fmt.Errorf("error finding employee with ids %d: %w", ids, err).Error()}
+		return 0, fmt.Errorf("error save employee: %w", err)
 	}
 
-	return toResponses(resps), nil
+	return id, nil
 }
 
-func (serv *Service) DeleteById(id int64) error {
-	err := serv.repo.DeleteById(id)
+// SetActive активация или деактивация работников без их удаления
+func (serv *Service) SetActive(ctx context.Context, req RequestSetActive) (err error) {
+	ctx, span := tracing.Start(ctx, "employee.SetActive", attribute.Int("ids.count", len(req.Ids)))
+	defer tracing.End(span, &err)
+
+	err = tracing.Validate(ctx, serv.valid, req)
 	if err != nil {
-		return common.DbOperationError{Message: fmt.Errorf("error delete employee by id %d: %w", id, err).Error()}
+		return common.RequestValidationError{Message: err.Error()}
 	}
 
-	return nil
-}
-
-func (serv *Service) DeleteByIds(ids []int64) error {
-	err := serv.repo.DeleteByIds(ids)
+	err = serv.repo.SetActive(ctx, req.Ids, req.Active)
 	if err != nil {
-		return common.DbOperationError{Message: fmt.Errorf("error delete employee by ids %d: %w", ids, err).Error()}
+		return common.DbOperationError{Message: fmt.Errorf("error set active=%t for employees with ids %d: %w", req.Active, req.Ids, err).Error()}
 	}
 
 	return nil
@@ -1,8 +1,13 @@
 package employee
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"idm/inner/common"
+	"idm/inner/memory"
+	"idm/inner/tabular"
 	"strings"
 	"testing"
 	"time"
@@ -38,7 +43,7 @@ func TestBeginTransactionError(t *testing.T) {
 		validator := NewStubRepo()
 		srv := NewService(repo, validator)
 
-		id, errIn := srv.SaveTx(Request{Name: "Pupkin"})
+		id, errIn := srv.SaveTx(context.Background(), Request{Name: "Pupkin"})
 		a.Equal(int64(0), id)
 		a.Error(errIn)
 		a.Equal(errIn.Error(), fmt.Errorf("error creating transaction: %w", err).Error())
@@ -65,7 +70,7 @@ func TestFindByName(t *testing.T) {
 		validator := NewStubRepo()
 		srv := NewService(repo, validator)
 
-		id, errIn := srv.SaveTx(Request{Name: "Pupkin"})
+		id, errIn := srv.SaveTx(context.Background(), Request{Name: "Pupkin"})
 		a.Equal(int64(0), id)
 		a.Error(errIn)
 		a.True(strings.Contains(errIn.Error(), fmt.Errorf("db error while searching by name").Error()))
@@ -93,7 +98,7 @@ func TestFindByName2(t *testing.T) {
 		validator := NewStubRepo()
 		srv := NewService(repo, validator)
 
-		id, errIn := srv.SaveTx(Request{Name: "Pupkin"})
+		id, errIn := srv.SaveTx(context.Background(), Request{Name: "Pupkin"})
 		a.Equal(int64(0), id)
 		a.Error(errIn)
 		a.True(strings.Contains(errIn.Error(), "already exists"))
@@ -128,7 +133,7 @@ func TestFindByNameAndSave(t *testing.T) {
 		validator := NewStubRepo()
 		srv := NewService(repo, validator)
 
-		id, errIn := srv.SaveTx(req)
+		id, errIn := srv.SaveTx(context.Background(), req)
 		a.Equal(int64(0), id)
 		a.Error(errIn)
 		a.True(strings.Contains(errIn.Error(), err.Error()))
@@ -164,59 +169,275 @@ func TestFindByNameAndSave2(t *testing.T) {
 		validator := NewStubRepo()
 		srv := NewService(repo, validator)
 
-		id, errIn := srv.SaveTx(req)
+		id, errIn := srv.SaveTx(context.Background(), req)
 		a.Equal(int64(777), id)
 		a.NoError(errIn)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 }
 
+// пакетное создание: работник с таким именем уже есть, в режиме atomic ничего не создаётся
+func TestSaveBatchAtomic(t *testing.T) {
+	a := assert.New(t)
+	db, mock, err := NewSqlmock()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	var reqs = []Request{
+		{Name: "Pupkin", Create: time.Now(), Update: time.Now()},
+		{Name: "Ivanov", Create: time.Now(), Update: time.Now()},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT name FROM employee").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Ivanov"))
+	mock.ExpectRollback()
+
+	t.Run("check batch is rolled back when one employee exists", func(t *testing.T) {
+		srv := NewService(NewEmployeeRepository(db), NewStubRepo())
+
+		results, errIn := srv.SaveBatch(context.Background(), reqs, true)
+		a.Error(errIn)
+		a.True(strings.Contains(errIn.Error(), "Ivanov"))
+		a.Len(results, 2)
+		a.Empty(results[0].Error)
+		a.Equal(int64(0), results[0].Id)
+		a.True(strings.Contains(results[1].Error, "already exists"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// пакетное создание в частичном режиме: дубликат внутри пакета пропускается, остальные создаются одним INSERT-ом
+func TestSaveBatchPartial(t *testing.T) {
+	a := assert.New(t)
+	db, mock, err := NewSqlmock()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	var reqs = []Request{
+		{Name: "Pupkin", Create: time.Now(), Update: time.Now()},
+		{Name: "Pupkin", Create: time.Now(), Update: time.Now()},
+		{Name: "Ivanov", Create: time.Now(), Update: time.Now()},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT name FROM employee").WillReturnRows(sqlmock.NewRows([]string{"name"}))
+	mock.ExpectQuery("INSERT INTO employee").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(int64(2), "Ivanov").AddRow(int64(1), "Pupkin"))
+	mock.ExpectCommit()
+
+	t.Run("check valid employees are created", func(t *testing.T) {
+		srv := NewService(NewEmployeeRepository(db), NewStubRepo())
+
+		results, errIn := srv.SaveBatch(context.Background(), reqs, false)
+		a.NoError(errIn)
+		a.Len(results, 3)
+		a.Equal(int64(1), results[0].Id)
+		a.True(strings.Contains(results[1].Error, "duplicated"))
+		a.Equal(int64(2), results[2].Id)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// предварительный просмотр импорта: изменения не сохраняются, транзакция откатывается
+func TestImportDryRun(t *testing.T) {
+	a := assert.New(t)
+	db, mock, err := NewSqlmock()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	var rows = []ImportRow{
+		{Line: 2, Request: Request{Name: "Pupkin", Create: time.Now(), Update: time.Now()}},
+		{Line: 3, Request: Request{Name: "Ivanov", Create: time.Now(), Update: time.Now()}},
+		{Line: 4, Request: Request{Name: "Ivanov", Create: time.Now(), Update: time.Now()}},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM employee WHERE LOWER\\(name\\) IN").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "create_at", "update_at"}).
+			AddRow(int64(7), "Ivanov", time.Now(), time.Now()))
+	mock.ExpectRollback()
+
+	t.Run("check import preview report", func(t *testing.T) {
+		srv := NewService(NewEmployeeRepository(db), NewStubRepo())
+
+		report, errIn := srv.Import(context.Background(), rows, true)
+		a.NoError(errIn)
+		a.True(report.DryRun)
+		a.Equal(3, report.Total)
+		a.Equal(1, report.Created)
+		a.Equal(1, report.Updated)
+		a.Equal(1, report.Invalid)
+		a.Equal(ImportActionCreate, report.Rows[0].Action)
+		a.Equal(ImportActionUpdate, report.Rows[1].Action)
+		a.Equal(int64(7), report.Rows[1].Id)
+		a.Equal(ImportActionError, report.Rows[2].Action)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// применение импорта с некорректными строками: ничего не сохраняется
+func TestImportApplyWithInvalidRows(t *testing.T) {
+	a := assert.New(t)
+	db, mock, err := NewSqlmock()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	var rows = []ImportRow{
+		{Line: 2, Request: Request{Name: "Pupkin", Create: time.Now(), Update: time.Now()}},
+		{Line: 3, Request: Request{Name: "Ivanov"}, Errors: []string{"create_at: value is empty"}},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM employee WHERE LOWER\\(name\\) IN").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "create_at", "update_at"}))
+	mock.ExpectRollback()
+
+	t.Run("check import is not applied", func(t *testing.T) {
+		srv := NewService(NewEmployeeRepository(db), NewStubRepo())
+
+		report, errIn := srv.Import(context.Background(), rows, false)
+		a.Error(errIn)
+		a.Equal(1, report.Invalid)
+		a.Equal([]string{"create_at: value is empty"}, report.Rows[1].Errors)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// обновление существующего работника при импорте меняет только поля из файла
+func TestImportUpdateKeepsCreateAt(t *testing.T) {
+	a := assert.New(t)
+	var ctx = context.Background()
+	var repo = NewEmployeeMemoryRepository(memory.NewDB())
+	var created = time.Date(2020, 3, 4, 0, 0, 0, 0, time.UTC)
+	id, err := repo.SaveTx(ctx, &Entity{Name: "Ivanov", Create: created, Update: created})
+	a.NoError(err)
+
+	var now = time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	rows, err := ParseImportRows([]string{"name"}, [][]string{{"ivanov"}}, DefaultImportMapping, now)
+	a.NoError(err)
+	srv := NewService(repo, NewStubRepo())
+
+	report, err := srv.Import(ctx, rows, false)
+	a.NoError(err)
+	a.Equal(1, report.Updated)
+	got, err := repo.FindById(ctx, id)
+	a.NoError(err)
+	a.Equal("ivanov", got.Name)
+	a.True(created.Equal(got.Create))
+	a.True(now.Equal(got.Update))
+}
+
+// выгрузка работников с фильтром по имени в CSV
+func TestExport(t *testing.T) {
+	a := assert.New(t)
+	db, mock, err := NewSqlmock()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	var created = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectQuery("SELECT \\* FROM employee WHERE LOWER\\(name\\) LIKE").
+		WithArgs("%pup\\_%").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "create_at", "update_at", "active"}).
+			AddRow(int64(1), "Pup_kin", created, created, true))
+
+	t.Run("check export streams filtered employees", func(t *testing.T) {
+		srv := NewService(NewEmployeeRepository(db), NewStubRepo())
+
+		var buffer strings.Builder
+		errIn := srv.Export(context.Background(), Filter{Name: "pup_"}, tabular.CSV, &buffer)
+		a.NoError(errIn)
+		a.Equal("id,name,active,create_at,update_at\n1,Pup_kin,true,2025-01-02T03:04:05Z,2025-01-02T03:04:05Z\n", buffer.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
 // объявляем структуру мок-репозитория
 type MockRepo struct {
 	mock.Mock
 }
 
-func (rep *MockRepo) FindByNameTx(tx *sqlx.Tx, name string) (isExists bool, err error) {
+func (rep *MockRepo) FindByNameTx(ctx context.Context, name string) (isExists bool, err error) {
 	return true, nil
 }
 
-func (rep *MockRepo) BeginTransaction() (tx *sqlx.Tx, err error) {
-	return nil, nil
+func (rep *MockRepo) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
 }
 
-func (s *MockRepo) SaveTx(tx *sqlx.Tx, entity *Entity) (id int64, err error) {
+func (s *MockRepo) SaveTx(ctx context.Context, entity *Entity) (id int64, err error) {
 	return 99, nil
 }
 
+func (s *MockRepo) FindExistingNamesTx(ctx context.Context, names []string) (existing []string, err error) {
+	return nil, nil
+}
+
+func (s *MockRepo) SaveBatchTx(ctx context.Context, entities []*Entity) (ids []int64, err error) {
+	return nil, nil
+}
+
+func (s *MockRepo) FindByNamesTx(ctx context.Context, names []string) (entities []Entity, err error) {
+	return nil, nil
+}
+
+func (s *MockRepo) UpdateTx(ctx context.Context, entity *Entity) error {
+	return nil
+}
+
 // реализуем интерфейс репозитория у мока
-func (m *MockRepo) Save(entity *Entity) (id int64, err error) {
+func (m *MockRepo) Save(ctx context.Context, entity *Entity) (id int64, err error) {
 
 	// Общая конфигурация поведения мок-объекта
 	args := m.Called(entity)
 	return args.Get(0).(int64), args.Error(1)
 }
 
-func (m *MockRepo) FindById(id int64) (employee Entity, err error) {
+func (m *MockRepo) FindById(ctx context.Context, id int64) (employee Entity, err error) {
 	args := m.Called(id)
 	return args.Get(0).(Entity), args.Error(1)
 }
 
-func (m *MockRepo) GetAll() (entities []Entity, err error) {
-	args := m.Called()
+func (m *MockRepo) GetAll(ctx context.Context, filter Filter) (entities []Entity, err error) {
+	args := m.Called(filter)
 	return args.Get(0).([]Entity), args.Error(1)
 }
 
-func (m *MockRepo) FindByIds(ids []int64) (entities []Entity, err error) {
+func (m *MockRepo) Stream(ctx context.Context, filter Filter, fn func(entity Entity) error) error {
+	args := m.Called(filter, fn)
+	return args.Error(0)
+}
+
+func (m *MockRepo) FindByIds(ctx context.Context, ids []int64) (entities []Entity, err error) {
 	args := m.Called(ids)
 	return args.Get(0).([]Entity), args.Error(1)
 }
 
-func (m *MockRepo) DeleteById(id int64) error {
-	args := m.Called(id)
+func (m *MockRepo) SetActive(ctx context.Context, ids []int64, active bool) error {
+	args := m.Called(ids, active)
 	return args.Error(0)
 }
 
-func (m *MockRepo) DeleteByIds(ids []int64) error {
+func (m *MockRepo) Update(ctx context.Context, entity *Entity, expectedVersion int64) (updated Entity, err error) {
+	args := m.Called(entity, expectedVersion)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) DeleteById(ctx context.Context, id int64, version int64) (deleted bool, err error) {
+	args := m.Called(id, version)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) DeleteByIds(ctx context.Context, ids []int64) error {
 	args := m.Called(ids)
 	return args.Error(0)
 }
@@ -239,19 +460,39 @@ func NewStubRepo() *StubRepo {
 	}
 }
 
-func (rep *StubRepo) FindByNameTx(tx *sqlx.Tx, name string) (isExists bool, err error) {
+func (rep *StubRepo) FindByNameTx(ctx context.Context, name string) (isExists bool, err error) {
 	return true, nil
 }
 
-func (rep *StubRepo) BeginTransaction() (tx *sqlx.Tx, err error) {
-	return nil, nil
+func (rep *StubRepo) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
 }
 
-func (s *StubRepo) SaveTx(tx *sqlx.Tx, entity *Entity) (id int64, err error) {
+func (s *StubRepo) SaveTx(ctx context.Context, entity *Entity) (id int64, err error) {
 	return 99, nil
 }
 
-func (s *StubRepo) Save(entity *Entity) (id int64, err error) {
+func (s *StubRepo) FindExistingNamesTx(ctx context.Context, names []string) (existing []string, err error) {
+	return nil, nil
+}
+
+func (s *StubRepo) SaveBatchTx(ctx context.Context, entities []*Entity) (ids []int64, err error) {
+	return nil, nil
+}
+
+func (s *StubRepo) FindByNamesTx(ctx context.Context, names []string) (entities []Entity, err error) {
+	return nil, nil
+}
+
+func (s *StubRepo) UpdateTx(ctx context.Context, entity *Entity) error {
+	return nil
+}
+
+func (s *StubRepo) SetActive(ctx context.Context, ids []int64, active bool) error {
+	return nil
+}
+
+func (s *StubRepo) Save(ctx context.Context, entity *Entity) (id int64, err error) {
 	if strings.EqualFold("Error Name", entity.Name) {
 		return 0, fmt.Errorf("cannot save an bad object")
 	}
@@ -261,30 +502,38 @@ func (s *StubRepo) Save(entity *Entity) (id int64, err error) {
 	return entity.Id, nil
 }
 
-func (s *StubRepo) FindById(id int64) (employee Entity, err error) {
+func (s *StubRepo) FindById(ctx context.Context, id int64) (employee Entity, err error) {
 	if id == 0 {
 		return Entity{}, fmt.Errorf("not found entity by %d", id)
 	}
 	return *s.entities[1], nil
 }
 
-func (m *StubRepo) FindByName(name string) (isExists bool, err error) {
+func (m *StubRepo) FindByName(ctx context.Context, name string) (isExists bool, err error) {
 	return false, nil
 }
 
-func (s *StubRepo) GetAll() (entities []Entity, err error) {
+func (s *StubRepo) GetAll(ctx context.Context, filter Filter) (entities []Entity, err error) {
 	return []Entity{}, nil
 }
 
-func (s *StubRepo) FindByIds(ids []int64) (entities []Entity, err error) {
+func (s *StubRepo) Stream(ctx context.Context, filter Filter, fn func(entity Entity) error) error {
+	return nil
+}
+
+func (s *StubRepo) FindByIds(ctx context.Context, ids []int64) (entities []Entity, err error) {
 	return []Entity{}, nil
 }
 
-func (s *StubRepo) DeleteById(id int64) error {
-	return nil
+func (s *StubRepo) Update(ctx context.Context, entity *Entity, expectedVersion int64) (updated Entity, err error) {
+	return *entity, nil
+}
+
+func (s *StubRepo) DeleteById(ctx context.Context, id int64, version int64) (deleted bool, err error) {
+	return true, nil
 }
 
-func (s *StubRepo) DeleteByIds(ids []int64) error {
+func (s *StubRepo) DeleteByIds(ctx context.Context, ids []int64) error {
 	return nil
 }
 
@@ -303,7 +552,7 @@ func TestSubSave(t *testing.T) {
 			Create: time.Now(),
 			Update: time.Now(),
 		}
-		newId, err := srv.Save(request)
+		newId, err := srv.Save(context.Background(), request)
 
 		a.Nil(err)
 		a.Equal(int64(3), newId)
@@ -316,7 +565,7 @@ func TestSubSave(t *testing.T) {
 			Create: time.Now(),
 			Update: time.Now(),
 		}
-		newId, err := srv.Save(request)
+		newId, err := srv.Save(context.Background(), request)
 
 		a.NotNil(err)
 		a.Equal(int64(0), newId)
@@ -330,7 +579,7 @@ func TestSubFindById(t *testing.T) {
 	t.Run("should return found employee", func(t *testing.T) {
 		repo := NewStubRepo()
 		srv := NewService(repo, repo)
-		response, err := srv.FindById(99)
+		response, err := srv.FindById(context.Background(), 99)
 
 		a.Nil(err)
 		a.Equal("Pupkin Vasia", response.Name)
@@ -349,9 +598,9 @@ func TestSave(t *testing.T) {
 		repo := new(MockRepo)
 		srv := NewService(repo, repo)
 		var id int64 = 5
-		entity := request.toEntity()
+		entity := request.ToEntity()
 		repo.On("Save", entity).Return(id, nil)
-		got, err := srv.Save(request)
+		got, err := srv.Save(context.Background(), request)
 
 		a.Nil(err)
 		a.Equal(id, got)
@@ -360,13 +609,13 @@ func TestSave(t *testing.T) {
 		repo := new(MockRepo)
 		srv := NewService(repo, repo)
 		var id int64 = 0
-		entity := request.toEntity()
+		entity := request.ToEntity()
 
 		var err = errors.New("database error")
 		var want = fmt.Errorf("error save employee: %w", err)
 
 		repo.On("Save", entity).Return(id, err)
-		newId, got := srv.Save(request)
+		newId, got := srv.Save(context.Background(), request)
 
 		a.NotNil(err)
 		a.Equal(id, newId)
@@ -401,7 +650,7 @@ func TestFindById(t *testing.T) {
 		repo.On("FindById", int64(1)).Return(entity, nil)
 
 		// вызываем сервис с аргументом id = 1
-		var got, err = svc.FindById(1)
+		var got, err = svc.FindById(context.Background(), 1)
 
 		// проверяем, что сервис не вернул ошибку
 		a.Nil(err)
@@ -434,7 +683,7 @@ func TestFindById(t *testing.T) {
 
 		repo.On("FindById", int64(1)).Return(entity, err)
 
-		var response, got = svc.FindById(1)
+		var response, got = svc.FindById(context.Background(), 1)
 
 		// проверяем результаты теста
 		a.Empty(response)
@@ -450,8 +699,8 @@ func TestGetAll(t *testing.T) {
 		repo := new(MockRepo)
 		srv := NewService(repo, repo)
 		listEntity := []Entity{{Name: "name1"}, {Name: "name2"}}
-		repo.On("GetAll").Return(listEntity, nil)
-		result, err := srv.GetAll()
+		repo.On("GetAll", Filter{}).Return(listEntity, nil)
+		result, err := srv.GetAll(context.Background(), Filter{})
 
 		a.Nil(err)
 		a.NotNil(result)
@@ -464,8 +713,8 @@ func TestGetAll(t *testing.T) {
 
 		err := errors.New("database error")
 
-		repo.On("GetAll").Return([]Entity{}, err)
-		result, err := srv.GetAll()
+		repo.On("GetAll", Filter{}).Return([]Entity{}, err)
+		result, err := srv.GetAll(context.Background(), Filter{})
 
 		a.Equal(result, []Response{})
 		a.NotNil(err)
@@ -497,7 +746,7 @@ func TestFindByIds(t *testing.T) {
 		var ids = []int64{1, 2}
 
 		repo.On("FindByIds", ids).Return(entities, nil)
-		result, err := svc.FindByIds(ids)
+		result, err := svc.FindByIds(context.Background(), ids)
 
 		a.Nil(err)
 		a.Equal(want, result)
@@ -516,7 +765,7 @@ func TestFindByIds(t *testing.T) {
 
 		repo.On("FindByIds", ids).Return(entities, err)
 
-		response, err := svc.FindByIds(ids)
+		response, err := svc.FindByIds(context.Background(), ids)
 
 		a.Equal(response, []Response{})
 		a.NotNil(err)
@@ -531,8 +780,8 @@ func TestDeleteById(t *testing.T) {
 		var repo = new(MockRepo)
 		var svc = NewService(repo, repo)
 		var id int64 = 7
-		repo.On("DeleteById", id).Return(nil).Once()
-		err := svc.DeleteById(id)
+		repo.On("DeleteById", id, int64(2)).Return(true, nil).Once()
+		err := svc.DeleteById(context.Background(), id, 2)
 
 		a.Nil(err)
 		a.True(repo.AssertNumberOfCalls(t, "DeleteById", 1))
@@ -545,44 +794,137 @@ func TestDeleteById(t *testing.T) {
 		var id int64 = 7
 
 		var err = errors.New("database error")
-		var want = fmt.Errorf("error delete employee by id %d:  %w", id, err)
+		var want = fmt.Errorf("error delete employee by id %d: %w", id, err)
 
-		repo.On("DeleteById", id).Return(want)
-		err = svc.DeleteById(id)
+		repo.On("DeleteById", id, int64(2)).Return(false, err)
+		err = svc.DeleteById(context.Background(), id, 2)
 
 		a.NotNil(err)
 		a.True(strings.Contains(err.Error(), want.Error()))
 		a.True(repo.AssertNumberOfCalls(t, "DeleteById", 1))
 	})
+
+	t.Run("return PreconditionFailedError when version is stale", func(t *testing.T) {
+		var repo = new(MockRepo)
+		var svc = NewService(repo, repo)
+		var id int64 = 7
+		repo.On("DeleteById", id, int64(2)).Return(false, nil)
+		repo.On("FindById", id).Return(Entity{Id: id, Version: 3}, nil)
+
+		err := svc.DeleteById(context.Background(), id, 2)
+
+		a.True(errors.As(err, &common.PreconditionFailedError{}))
+	})
+
+	t.Run("return NotFoundError when employee is missing", func(t *testing.T) {
+		var repo = new(MockRepo)
+		var svc = NewService(repo, repo)
+		var id int64 = 7
+		repo.On("DeleteById", id, int64(2)).Return(false, nil)
+		repo.On("FindById", id).Return(Entity{}, sql.ErrNoRows)
+
+		err := svc.DeleteById(context.Background(), id, 2)
+
+		a.True(errors.As(err, &common.NotFoundError{}))
+	})
 }
 
-func TestDeleteByIds(t *testing.T) {
+func TestUpdate(t *testing.T) {
 	var a = assert.New(t)
-	t.Run("return nil when called DeleteByIds", func(t *testing.T) {
+	var request = Request{Name: "John Doe", Create: time.Now(), Update: time.Now()}
+
+	t.Run("should return updated employee", func(t *testing.T) {
 		var repo = new(MockRepo)
 		var svc = NewService(repo, repo)
-		var ids = []int64{1, 2}
-		repo.On("DeleteByIds", ids).Return(nil).Once()
-		err := svc.DeleteByIds(ids)
+		repo.On("Validate", request).Return(nil)
+		repo.On("Update", mock.Anything, int64(2)).Return(Entity{Id: 7, Name: request.Name, Version: 3}, nil)
+
+		got, err := svc.Update(context.Background(), 7, 2, request)
 
 		a.Nil(err)
-		a.True(repo.AssertNumberOfCalls(t, "DeleteByIds", 1))
+		a.Equal(int64(3), got.Version)
 	})
 
-	t.Run("return error when called DeleteByIds", func(t *testing.T) {
+	t.Run("should return PreconditionFailedError when version is stale", func(t *testing.T) {
+		var repo = new(MockRepo)
+		var svc = NewService(repo, repo)
+		repo.On("Validate", request).Return(nil)
+		repo.On("Update", mock.Anything, int64(2)).Return(Entity{}, sql.ErrNoRows)
+		repo.On("FindById", int64(7)).Return(Entity{Id: 7, Version: 5}, nil)
+
+		_, err := svc.Update(context.Background(), 7, 2, request)
+
+		a.True(errors.As(err, &common.PreconditionFailedError{}))
+	})
+}
 
+func TestDeleteByIds(t *testing.T) {
+	var a = assert.New(t)
+	t.Run("delete every id with its version in one transaction", func(t *testing.T) {
 		var repo = new(MockRepo)
 		var svc = NewService(repo, repo)
-		var ids = []int64{1, 2}
+		repo.On("DeleteById", int64(1), int64(3)).Return(true, nil).Once()
+		repo.On("DeleteById", int64(2), int64(0)).Return(true, nil).Once()
+
+		err := svc.DeleteByIds(context.Background(), []int64{1, 2}, []int64{3, 0})
+
+		a.Nil(err)
+		a.True(repo.AssertNumberOfCalls(t, "DeleteById", 2))
+		repo.AssertNotCalled(t, "DeleteByIds", mock.Anything)
+	})
+
+	t.Run("return PreconditionFailedError and stop when version is stale", func(t *testing.T) {
+		var repo = new(MockRepo)
+		var svc = NewService(repo, repo)
+		repo.On("DeleteById", int64(1), int64(3)).Return(false, nil)
+		repo.On("FindById", int64(1)).Return(Entity{Id: 1, Version: 4}, nil)
 
+		err := svc.DeleteByIds(context.Background(), []int64{1, 2}, []int64{3, 0})
+
+		a.True(errors.As(err, &common.PreconditionFailedError{}))
+		repo.AssertNotCalled(t, "DeleteById", int64(2), mock.Anything)
+	})
+
+	t.Run("return error when called DeleteByIds", func(t *testing.T) {
+		var repo = new(MockRepo)
+		var svc = NewService(repo, repo)
 		var err = errors.New("database error")
-		var want = fmt.Errorf("error delete employee by ids %d: %w", ids, err)
+		var want = fmt.Errorf("error delete employee by id 1: %w", err)
+		repo.On("DeleteById", int64(1), int64(0)).Return(false, err)
 
-		repo.On("DeleteByIds", ids).Return(want)
-		err = svc.DeleteByIds(ids)
+		err = svc.DeleteByIds(context.Background(), []int64{1, 2}, []int64{0, 0})
 
-		a.NotNil(err)
+		a.True(errors.As(err, &common.DbOperationError{}))
 		a.True(strings.Contains(err.Error(), want.Error()))
-		a.True(repo.AssertNumberOfCalls(t, "DeleteByIds", 1))
+	})
+}
+
+func TestSetActive(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should deactivate employees", func(t *testing.T) {
+		repo := new(MockRepo)
+		srv := NewService(repo, repo)
+		var req = RequestSetActive{Ids: []int64{1, 2}, Active: false}
+		repo.On("Validate", req).Return(nil)
+		repo.On("SetActive", []int64{1, 2}, false).Return(nil)
+
+		err := srv.SetActive(context.Background(), req)
+
+		a.Nil(err)
+		repo.AssertNumberOfCalls(t, "SetActive", 1)
+	})
+
+	t.Run("should return validation error", func(t *testing.T) {
+		repo := new(MockRepo)
+		srv := NewService(repo, repo)
+		var req = RequestSetActive{}
+		repo.On("Validate", req).Return(errors.New("ids is required"))
+
+		err := srv.SetActive(context.Background(), req)
+
+		a.Error(err)
+		a.True(errors.As(err, &common.RequestValidationError{}))
+		repo.AssertNotCalled(t, "SetActive", mock.Anything, mock.Anything)
 	})
 }
@@ -0,0 +1,208 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Probe вид проверки, которую выполняет оркестратор (Kubernetes)
+type Probe string
+
+const (
+	// Live процесс жив: при ошибке процесс перезапускается, поэтому сюда не входят внешние зависимости
+	Live Probe = "live"
+	// Ready экземпляр готов принимать запросы: при ошибке запросы на него не направляются
+	Ready Probe = "ready"
+	// Startup приложение запустилось: пока проверка не пройдена, остальные не выполняются
+	Startup Probe = "startup"
+)
+
+// статусы проверок
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// CheckFunc проверка компонента: nil - компонент работает
+type CheckFunc func(ctx context.Context) error
+
+// ComponentStatus результат проверки компонента
+type ComponentStatus struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// Report результат проверки: up, только если все компоненты работают
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+	CheckedAt  time.Time                  `json:"checkedAt"`
+}
+
+// Up все компоненты работают
+func (report Report) Up() bool {
+	return report.Status == StatusUp
+}
+
+type check struct {
+	name   string
+	probes []Probe
+	fn     CheckFunc
+}
+
+// probeState последний результат проверки: частые запросы проверок получают его, а не нагружают зависимости
+type probeState struct {
+	mu     sync.Mutex
+	report Report
+}
+
+// Registry набор проверок компонентов для каждого вида проверки.
+// Компоненты проверяются параллельно, каждая проверка ограничена timeout,
+// а результат переиспользуется в течение cacheTTL.
+type Registry struct {
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	mu     sync.RWMutex
+	checks []check
+	states map[Probe]*probeState
+}
+
+// NewRegistry пустой набор проверок
+func NewRegistry(timeout time.Duration, cacheTTL time.Duration) *Registry {
+	return &Registry{
+		timeout:  timeout,
+		cacheTTL: cacheTTL,
+		states:   map[Probe]*probeState{Live: {}, Ready: {}, Startup: {}},
+	}
+}
+
+// Register добавление проверки компонента name в проверки probes
+func (registry *Registry) Register(name string, fn CheckFunc, probes ...Probe) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.checks = append(registry.checks, check{name: name, probes: probes, fn: fn})
+	// новая проверка должна попасть в следующий результат
+	for _, state := range registry.states {
+		state.mu.Lock()
+		state.report = Report{}
+		state.mu.Unlock()
+	}
+}
+
+// Check результат проверки probe: из кэша или после проверки всех её компонентов.
+// Одновременные запросы одной проверки ждут один общий результат.
+func (registry *Registry) Check(ctx context.Context, probe Probe) Report {
+	var state, ok = registry.states[probe]
+	if !ok {
+		return Report{Status: StatusDown, CheckedAt: time.Now()}
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if !state.report.CheckedAt.IsZero() && time.Since(state.report.CheckedAt) < registry.cacheTTL {
+		return state.report
+	}
+	state.report = registry.run(ctx, probe)
+	return state.report
+}
+
+func (registry *Registry) run(ctx context.Context, probe Probe) Report {
+	registry.mu.RLock()
+	var checks []check
+	for _, c := range registry.checks {
+		for _, p := range c.probes {
+			if p == probe {
+				checks = append(checks, c)
+				break
+			}
+		}
+	}
+	registry.mu.RUnlock()
+
+	var report = Report{Status: StatusUp, Components: make(map[string]ComponentStatus, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var status = registry.runCheck(ctx, c)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[c.name] = status
+			if status.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}()
+	}
+	wg.Wait()
+	report.CheckedAt = time.Now()
+	return report
+}
+
+func (registry *Registry) runCheck(ctx context.Context, c check) (status ComponentStatus) {
+	ctx, cancel := context.WithTimeout(ctx, registry.timeout)
+	defer cancel()
+
+	var started = time.Now()
+	var done = make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("check panicked: %v", r)
+			}
+		}()
+		done <- c.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// проверка, не учитывающая контекст, не должна задерживать ответ
+		err = ctx.Err()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("check timed out after %s", registry.timeout)
+	}
+
+	status = ComponentStatus{Status: StatusUp, DurationMs: time.Since(started).Milliseconds()}
+	if err != nil {
+		status.Status = StatusDown
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// Pinger подключение к базе данных (*sqlx.DB): проверка берёт подключение из пула, а не открывает новое
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Ping проверка доступности базы данных
+func Ping(db Pinger) CheckFunc {
+	return db.PingContext
+}
+
+// Workers проверка, что все фоновые задачи работают; running - состояние задач по имени (worker.Group.Running)
+func Workers(running func() map[string]bool) CheckFunc {
+	return func(ctx context.Context) error {
+		var stopped []string
+		for name, alive := range running() {
+			if !alive {
+				stopped = append(stopped, name)
+			}
+		}
+		if len(stopped) > 0 {
+			sort.Strings(stopped)
+			return fmt.Errorf("background workers stopped: %s", strings.Join(stopped, ", "))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,81 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should run only checks of the probe", func(t *testing.T) {
+		var registry = NewRegistry(time.Second, 0)
+		registry.Register("database", func(ctx context.Context) error { return errors.New("connection refused") }, Ready, Startup)
+		registry.Register("workers", func(ctx context.Context) error { return nil }, Live, Ready)
+
+		var live = registry.Check(context.Background(), Live)
+		var ready = registry.Check(context.Background(), Ready)
+
+		a.True(live.Up())
+		a.Equal([]string{"workers"}, keys(live.Components))
+		a.False(ready.Up())
+		a.Equal(StatusDown, ready.Components["database"].Status)
+		a.Equal("connection refused", ready.Components["database"].Error)
+		a.Equal(StatusUp, ready.Components["workers"].Status)
+	})
+
+	t.Run("should fail check that does not finish in time", func(t *testing.T) {
+		var registry = NewRegistry(20*time.Millisecond, 0)
+		var release = make(chan struct{})
+		defer close(release)
+		registry.Register("stuck", func(ctx context.Context) error { <-release; return nil }, Ready)
+
+		var report = registry.Check(context.Background(), Ready)
+
+		a.False(report.Up())
+		a.Contains(report.Components["stuck"].Error, "timed out")
+	})
+
+	t.Run("should reuse result for concurrent and repeated probes", func(t *testing.T) {
+		var registry = NewRegistry(time.Second, time.Minute)
+		var calls atomic.Int32
+		registry.Register("database", func(ctx context.Context) error {
+			calls.Add(1)
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		}, Ready)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				registry.Check(context.Background(), Ready)
+			}()
+		}
+		wg.Wait()
+
+		a.Equal(int32(1), calls.Load())
+	})
+}
+
+func TestWorkers(t *testing.T) {
+	a := assert.New(t)
+
+	a.Nil(Workers(func() map[string]bool { return map[string]bool{"cleanup": true} })(context.Background()))
+	a.EqualError(Workers(func() map[string]bool { return map[string]bool{"cleanup": false, "watch": true} })(context.Background()),
+		"background workers stopped: cleanup")
+}
+
+func keys(components map[string]ComponentStatus) (names []string) {
+	for name := range components {
+		names = append(names, name)
+	}
+	return names
+}
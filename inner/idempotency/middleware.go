@@ -0,0 +1,135 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"idm/inner/auth"
+	"idm/inner/common"
+	"idm/inner/web"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber"
+)
+
+// HeaderKey заголовок, в котором клиент передаёт ключ идемпотентности
+const HeaderKey = "Idempotency-Key"
+
+// HeaderReplayed заголовок ответа, повторно отданного из хранилища
+const HeaderReplayed = "Idempotent-Replayed"
+
+// максимальная длина ключа идемпотентности
+const maxKeyLength = 255
+
+// Middleware обработка POST-запросов с заголовком Idempotency-Key: ответ сохраняется на время ttl,
+// повторный запрос с тем же ключом и телом получает сохранённый ответ,
+// а повторное использование ключа с другим телом отклоняется с кодом 422
+func Middleware(store Store, ttl time.Duration) fiber.Handler {
+	return MiddlewareFrom(store, func() time.Duration { return ttl })
+}
+
+// MiddlewareFrom middleware Middleware с временем хранения, которое читается при каждом запросе (перезагружаемая конфигурация)
+func MiddlewareFrom(store Store, ttl func() time.Duration) fiber.Handler {
+	return func(ctx *fiber.Ctx) {
+		var key = ctx.Get(HeaderKey)
+		if ctx.Method() != fiber.MethodPost || key == "" {
+			ctx.Next()
+			return
+		}
+
+		if len(key) > maxKeyLength {
+			_ = common.ErrResponse(ctx, fiber.StatusBadRequest, fmt.Sprintf("%s must not be longer than %d characters", HeaderKey, maxKeyLength))
+			return
+		}
+
+		// ключ действует только в пределах одного маршрута и одного вызывающего: иначе клиенты с совпавшими ключами
+		// получили бы ответы друг друга (например, API-ключ созданного другим клиентом сервисного аккаунта)
+		var principal, _ = auth.PrincipalOf(ctx)
+		var scopedKey = ctx.Method() + " " + ctx.Path() + " " + principal.Subject + " " + key
+		var requestHash = hashRequest(ctx)
+
+		existing, err := store.Reserve(web.Context(ctx), scopedKey, requestHash, ttl())
+		if err != nil {
+			_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error reserving idempotency key: "+err.Error())
+			return
+		}
+
+		if existing != nil {
+			switch {
+			case existing.RequestHash != requestHash:
+				_ = common.ErrResponse(ctx, fiber.StatusUnprocessableEntity, HeaderKey+" was already used with a different request payload")
+			case existing.InProgress():
+				_ = common.ErrResponse(ctx, fiber.StatusConflict, "request with this "+HeaderKey+" is still in progress")
+			default:
+				ctx.Set(HeaderReplayed, "true")
+				ctx.Set(fiber.HeaderContentType, existing.ContentType)
+				ctx.Status(existing.Status).SendBytes(existing.Body)
+			}
+			return
+		}
+
+		// ответ нужно сохранить, даже если контекст запроса уже истёк, иначе ключ останется занятым до конца ttl
+		var saveCtx = context.WithoutCancel(web.Context(ctx))
+		// после ошибки сервера клиент должен иметь возможность повторить запрос с тем же ключом
+		var release = func() {
+			if err := store.Release(saveCtx, scopedKey); err != nil {
+				slog.ErrorContext(saveCtx, "error releasing idempotency key", "error", err)
+			}
+		}
+		// паника хендлера обрабатывается на уровне сервера (web.Recover) уже после выхода из middleware,
+		// поэтому ключ освобождается здесь, а паника передаётся дальше
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					release()
+					panic(r)
+				}
+			}()
+			ctx.Next()
+		}()
+
+		var response = &ctx.Fasthttp.Response
+		var status = response.StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			release()
+			return
+		}
+
+		var body = append([]byte(nil), response.Body()...)
+		if err = store.Complete(saveCtx, scopedKey, status, string(response.Header.ContentType()), body); err != nil {
+			slog.ErrorContext(saveCtx, "error saving idempotent response", "error", err)
+		}
+	}
+}
+
+// hashRequest хэш метода, пути с параметрами запроса и тела запроса для проверки, что ключ повторно
+// используется с тем же запросом: тот же ключ с другими параметрами (импорт ?mode=preview, затем ?mode=apply)
+// получает ошибку, а не сохранённый ответ
+func hashRequest(ctx *fiber.Ctx) string {
+	var hash = sha256.New()
+	hash.Write([]byte(ctx.Method()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(ctx.OriginalURL()))
+	hash.Write([]byte{0})
+	hash.Write(ctx.Fasthttp.Request.Body())
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// RunCleanup периодическое удаление истёкших ключей до отмены контекста
+func RunCleanup(ctx context.Context, store Store, interval time.Duration) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := store.DeleteExpired(ctx); err != nil {
+				slog.ErrorContext(ctx, "error deleting expired idempotency keys", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package idempotency
+
+import (
+	"context"
+	"idm/inner/auth"
+	"idm/inner/web"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// тестовый сервер: каждый успешный вызов создаёт новый объект с новым id
+func newTestApp(store Store, status int) (*fiber.App, *int) {
+	var calls = 0
+	var app = fiber.New()
+	app.Use(Middleware(store, time.Hour))
+	app.Post("/employees", func(ctx *fiber.Ctx) {
+		calls++
+		ctx.Status(status).SendString(strings.Repeat("x", calls))
+	})
+	return app, &calls
+}
+
+func post(app *fiber.App, key string, body string) *http.Response {
+	return postTo(app, "/employees", key, body)
+}
+
+func postTo(app *fiber.App, target string, key string, body string) *http.Response {
+	var req = httptest.NewRequest(fiber.MethodPost, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(HeaderKey, key)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		panic(err)
+	}
+	return resp
+}
+
+func TestMiddleware(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should replay stored response for retry", func(t *testing.T) {
+		app, calls := newTestApp(NewMemoryStore(), fiber.StatusOK)
+
+		first := post(app, "key-1", `{"name": "john doe"}`)
+		second := post(app, "key-1", `{"name": "john doe"}`)
+
+		a.Equal(1, *calls)
+		a.Equal(http.StatusOK, second.StatusCode)
+		a.Equal("true", second.Header.Get(HeaderReplayed))
+		a.Empty(first.Header.Get(HeaderReplayed))
+		body, _ := io.ReadAll(second.Body)
+		a.Equal("x", string(body))
+	})
+
+	t.Run("should reject key reuse with different payload", func(t *testing.T) {
+		app, calls := newTestApp(NewMemoryStore(), fiber.StatusOK)
+
+		post(app, "key-1", `{"name": "john doe"}`)
+		resp := post(app, "key-1", `{"name": "jane doe"}`)
+
+		a.Equal(1, *calls)
+		a.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+	})
+
+	t.Run("should reject key reuse with different query", func(t *testing.T) {
+		app, calls := newTestApp(NewMemoryStore(), fiber.StatusOK)
+
+		postTo(app, "/employees?mode=preview", "key-1", `{}`)
+		resp := postTo(app, "/employees?mode=apply", "key-1", `{}`)
+
+		a.Equal(1, *calls)
+		a.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+	})
+
+	t.Run("should return conflict while original request is in progress", func(t *testing.T) {
+		var store = NewMemoryStore()
+		app, calls := newTestApp(store, fiber.StatusOK)
+		var req = httptest.NewRequest(fiber.MethodPost, "/employees", strings.NewReader(`{}`))
+		_, _ = store.Reserve(context.Background(), "POST /employees  key-1", hashRequestBody(t, req), time.Hour)
+
+		resp := post(app, "key-1", `{}`)
+
+		a.Equal(0, *calls)
+		a.Equal(http.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("should allow retry after server error", func(t *testing.T) {
+		app, calls := newTestApp(NewMemoryStore(), fiber.StatusInternalServerError)
+
+		post(app, "key-1", `{}`)
+		post(app, "key-1", `{}`)
+
+		a.Equal(2, *calls)
+	})
+
+	t.Run("should release key after handler panic", func(t *testing.T) {
+		var calls = 0
+		var app = fiber.New()
+		app.Use(web.Recover())
+		app.Use(Middleware(NewMemoryStore(), time.Hour))
+		app.Post("/employees", func(ctx *fiber.Ctx) {
+			calls++
+			if calls == 1 {
+				panic("unexpected state")
+			}
+			ctx.SendString("created")
+		})
+
+		first := post(app, "key-1", `{}`)
+		second := post(app, "key-1", `{}`)
+
+		a.Equal(2, calls)
+		a.Equal(http.StatusInternalServerError, first.StatusCode)
+		a.Equal(http.StatusOK, second.StatusCode)
+	})
+
+	t.Run("should not replay response to another caller", func(t *testing.T) {
+		var calls = 0
+		var app = fiber.New()
+		// вызывающий из заголовка вместо аутентификации
+		app.Use(func(ctx *fiber.Ctx) {
+			auth.SetPrincipal(ctx, auth.Principal{Subject: ctx.Get("X-Caller")})
+			ctx.Next()
+		})
+		app.Use(Middleware(NewMemoryStore(), time.Hour))
+		app.Post("/employees", func(ctx *fiber.Ctx) {
+			calls++
+			ctx.SendString(ctx.Get("X-Caller"))
+		})
+		var postAs = func(caller string) string {
+			var req = httptest.NewRequest(fiber.MethodPost, "/employees", strings.NewReader(`{}`))
+			req.Header.Set(HeaderKey, "key-1")
+			req.Header.Set("X-Caller", caller)
+			resp, err := app.Test(req)
+			a.Nil(err)
+			body, _ := io.ReadAll(resp.Body)
+			return string(body)
+		}
+
+		a.Equal("service-account:first", postAs("service-account:first"))
+		a.Equal("service-account:second", postAs("service-account:second"))
+		a.Equal("service-account:first", postAs("service-account:first"))
+		a.Equal(2, calls)
+	})
+
+	t.Run("should not store requests without key", func(t *testing.T) {
+		app, calls := newTestApp(NewMemoryStore(), fiber.StatusOK)
+
+		post(app, "", `{}`)
+		post(app, "", `{}`)
+
+		a.Equal(2, *calls)
+	})
+}
+
+// хэш запроса, вычисленный тем же способом, что и в middleware
+func hashRequestBody(t *testing.T, req *http.Request) string {
+	var hash string
+	var probe = fiber.New()
+	probe.Post("/employees", func(ctx *fiber.Ctx) {
+		hash = hashRequest(ctx)
+	})
+	if _, err := probe.Test(req); err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestDbStoreReserve(t *testing.T) {
+	a := assert.New(t)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+	var store = NewDbStore(sqlx.NewDb(db, "sqlmock"))
+
+	t.Run("should reserve new key", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO idempotency_key").WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("key-1"))
+
+		existing, err := store.Reserve(context.Background(), "key-1", "hash", time.Hour)
+		a.NoError(err)
+		a.Nil(existing)
+	})
+
+	t.Run("should return existing record for taken key", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO idempotency_key").WillReturnRows(sqlmock.NewRows([]string{"key"}))
+		mock.ExpectQuery("SELECT key, request_hash").WillReturnRows(
+			sqlmock.NewRows([]string{"key", "request_hash", "status", "content_type", "body", "expires_at"}).
+				AddRow("key-1", "hash", 200, "application/json", []byte("{}"), time.Now().Add(time.Hour)))
+
+		existing, err := store.Reserve(context.Background(), "key-1", "hash", time.Hour)
+		a.NoError(err)
+		a.NotNil(existing)
+		a.Equal(200, existing.Status)
+		a.False(existing.InProgress())
+	})
+
+	a.NoError(mock.ExpectationsWereMet())
+}
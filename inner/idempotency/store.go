@@ -0,0 +1,151 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"idm/inner/database"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Record сохранённый результат запроса с ключом идемпотентности
+type Record struct {
+	Key         string `db:"key"`
+	RequestHash string `db:"request_hash"`
+	// 0, пока исходный запрос ещё выполняется
+	Status      int       `db:"status"`
+	ContentType string    `db:"content_type"`
+	Body        []byte    `db:"body"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
+
+// InProgress исходный запрос с этим ключом ещё не завершился
+func (rec *Record) InProgress() bool {
+	return rec.Status == 0
+}
+
+// Store хранилище ключей идемпотентности
+type Store interface {
+	// Reserve атомарно занимает ключ. Если ключ уже занят и не истёк, возвращается существующая запись.
+	Reserve(ctx context.Context, key string, requestHash string, ttl time.Duration) (existing *Record, err error)
+	// Complete сохраняет ответ на запрос для повторной отдачи
+	Complete(ctx context.Context, key string, status int, contentType string, body []byte) error
+	// Release освобождает ключ, чтобы запрос можно было повторить (например, после ошибки сервера)
+	Release(ctx context.Context, key string) error
+	// DeleteExpired удаляет истёкшие ключи
+	DeleteExpired(ctx context.Context) (deleted int64, err error)
+}
+
+// DbStore хранение ключей в таблице idempotency_key
+type DbStore struct {
+	db *sqlx.DB
+}
+
+func NewDbStore(database *sqlx.DB) *DbStore {
+	return &DbStore{db: database}
+}
+
+// conn транзакция из контекста или подключение к базе данных
+func (store *DbStore) conn(ctx context.Context) database.Executor {
+	return database.ConnFor(ctx, store.db, "idempotency")
+}
+
+func (store *DbStore) Reserve(ctx context.Context, key string, requestHash string, ttl time.Duration) (*Record, error) {
+	// истёкший ключ перезаписывается тем же запросом, поэтому конкурентные вставки не создадут дубликатов
+	query := `INSERT INTO idempotency_key (key, request_hash, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, status = 0, content_type = '', body = NULL, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_key.expires_at < $4
+		RETURNING key`
+	var now = time.Now().UTC()
+	var reserved string
+	err := store.conn(ctx).GetContext(ctx, &reserved, query, key, requestHash, now.Add(ttl), now)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	var existing Record
+	err = store.conn(ctx).GetContext(ctx, &existing, "SELECT key, request_hash, status, content_type, body, expires_at FROM idempotency_key WHERE key = $1", key)
+	return &existing, err
+}
+
+func (store *DbStore) Complete(ctx context.Context, key string, status int, contentType string, body []byte) error {
+	query := "UPDATE idempotency_key SET status = $1, content_type = $2, body = $3 WHERE key = $4"
+	_, err := store.conn(ctx).ExecContext(ctx, query, status, contentType, body, key)
+	return err
+}
+
+func (store *DbStore) Release(ctx context.Context, key string) error {
+	_, err := store.conn(ctx).ExecContext(ctx, "DELETE FROM idempotency_key WHERE key = $1", key)
+	return err
+}
+
+func (store *DbStore) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := store.conn(ctx).ExecContext(ctx, "DELETE FROM idempotency_key WHERE expires_at < $1", time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// MemoryStore хранение ключей в памяти процесса, подходит для одного экземпляра сервиса и тестов
+type MemoryStore struct {
+	mutex   sync.Mutex
+	records map[string]*Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]*Record)}
+}
+
+func (store *MemoryStore) Reserve(ctx context.Context, key string, requestHash string, ttl time.Duration) (*Record, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if existing, ok := store.records[key]; ok && existing.ExpiresAt.After(time.Now()) {
+		var copied = *existing
+		return &copied, nil
+	}
+	store.records[key] = &Record{Key: key, RequestHash: requestHash, ExpiresAt: time.Now().Add(ttl)}
+	return nil, nil
+}
+
+func (store *MemoryStore) Complete(ctx context.Context, key string, status int, contentType string, body []byte) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if record, ok := store.records[key]; ok {
+		record.Status = status
+		record.ContentType = contentType
+		record.Body = body
+	}
+	return nil
+}
+
+func (store *MemoryStore) Release(ctx context.Context, key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.records, key)
+	return nil
+}
+
+func (store *MemoryStore) DeleteExpired(ctx context.Context) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var deleted int64
+	for key, record := range store.records {
+		if record.ExpiresAt.Before(time.Now()) {
+			delete(store.records, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -2,32 +2,33 @@ package info
 
 import (
 	"idm/inner/common"
+	"idm/inner/health"
 	"idm/inner/web"
+	"time"
 
 	"github.com/gofiber/fiber"
 )
 
 type Controller struct {
-	server            *web.Server
-	cfg               common.Config
-	connectionService Srv
+	server *web.Server
+	cfg    *common.LiveConfig
+	checks *health.Registry
 }
 
-type Srv interface {
-	CheckDbConnection(cfg common.Config) bool
-}
-
-func NewController(server *web.Server, cfg common.Config, connectionService Srv) *Controller {
+func NewController(server *web.Server, cfg *common.LiveConfig, checks *health.Registry) *Controller {
 	return &Controller{
-		server:            server,
-		cfg:               cfg,
-		connectionService: connectionService,
+		server: server,
+		cfg:    cfg,
+		checks: checks,
 	}
 }
 
 type InfoResponse struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
+	// версия действующей конфигурации: увеличивается при перезагрузке конфигурации без перезапуска
+	ConfigVersion  int64     `json:"configVersion"`
+	ConfigLoadedAt time.Time `json:"configLoadedAt"`
 }
 
 func (c *Controller) RegisterRoutes() {
@@ -35,13 +36,21 @@ func (c *Controller) RegisterRoutes() {
 	c.server.GroupInternal.Get("/info", c.GetInfo)
 	// полный путь будет "/internal/health"
 	c.server.GroupInternal.Get("/health", c.GetHealth)
+	// пробы Kubernetes: "/internal/health/live", "/internal/health/ready", "/internal/health/startup"
+	c.server.GroupInternal.Get("/health/live", c.GetProbe(health.Live))
+	c.server.GroupInternal.Get("/health/ready", c.GetProbe(health.Ready))
+	c.server.GroupInternal.Get("/health/startup", c.GetProbe(health.Startup))
 }
 
 // GetInfo получение информации о приложении
 func (c *Controller) GetInfo(ctx *fiber.Ctx) {
+	var cfg = c.cfg.Get()
+	configVersion, loadedAt := c.cfg.Version()
 	var err = ctx.Status(fiber.StatusOK).JSON(&InfoResponse{
-		Name:    c.cfg.AppName,
-		Version: c.cfg.AppVersion,
+		Name:           cfg.AppName,
+		Version:        cfg.AppVersion,
+		ConfigVersion:  configVersion,
+		ConfigLoadedAt: loadedAt,
 	})
 	if err != nil {
 		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning info")
@@ -49,12 +58,37 @@ func (c *Controller) GetInfo(ctx *fiber.Ctx) {
 	}
 }
 
-// GetHealth проверка работоспособности приложения
+// GetHealth проверка работоспособности приложения в текстовом виде, результат совпадает с проверкой готовности
 func (c *Controller) GetHealth(ctx *fiber.Ctx) {
-	result := c.connectionService.CheckDbConnection(c.cfg)
-	if result {
+	if c.report(ctx, health.Ready).Up() {
 		ctx.Status(fiber.StatusOK).SendString("Healthy")
 	} else {
-		ctx.Status(fiber.StatusInternalServerError).SendString("Unhealthy")
+		ctx.Status(fiber.StatusServiceUnavailable).SendString("Unhealthy")
+	}
+}
+
+// GetProbe проверка probe с результатом по каждому компоненту: 200 - все компоненты работают, иначе 503
+func (c *Controller) GetProbe(probe health.Probe) fiber.Handler {
+	return func(ctx *fiber.Ctx) {
+		var report = c.report(ctx, probe)
+		var status = fiber.StatusOK
+		if !report.Up() {
+			status = fiber.StatusServiceUnavailable
+		}
+		if err := ctx.Status(status).JSON(report); err != nil {
+			_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning health")
+		}
+	}
+}
+
+// report результат проверки; при остановке сервера экземпляр больше не готов принимать запросы
+func (c *Controller) report(ctx *fiber.Ctx, probe health.Probe) health.Report {
+	if probe == health.Ready && c.server.ShuttingDown() {
+		return health.Report{
+			Status:     health.StatusDown,
+			Components: map[string]health.ComponentStatus{"server": {Status: health.StatusDown, Error: "shutting down"}},
+			CheckedAt:  time.Now(),
+		}
 	}
+	return c.checks.Check(web.Context(ctx), probe)
 }
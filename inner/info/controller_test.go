@@ -1,26 +1,28 @@
 package info
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"idm/inner/common"
+	"idm/inner/health"
 	"idm/inner/web"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
 )
 
-type MockService struct {
-	mock.Mock
-}
-
-func (srv *MockService) CheckDbConnection(cfg common.Config) bool {
-	args := srv.Called(cfg)
-	return args.Get(0).(bool)
+// newChecks проверки с одним компонентом database, который отвечает ошибкой dbErr
+func newChecks(dbErr error) *health.Registry {
+	var checks = health.NewRegistry(time.Second, 0)
+	checks.Register("database", func(ctx context.Context) error { return dbErr }, health.Ready, health.Startup)
+	checks.Register("workers", func(ctx context.Context) error { return nil }, health.Live)
+	return checks
 }
 
 func TestInternalApiHealth(t *testing.T) {
@@ -29,12 +31,10 @@ func TestInternalApiHealth(t *testing.T) {
 	t.Run("Check response /internal/health - Healthy", func(t *testing.T) {
 		server := web.NewServer()
 		conf, _ := common.GetConfig(".env_info")
-		srv := new(MockService)
-		controller := NewController(server, conf, srv)
+		controller := NewController(server, common.NewLiveConfig(common.Loader{}, conf), newChecks(nil))
 		controller.RegisterRoutes()
 
 		req := httptest.NewRequest(fiber.MethodGet, "/internal/health", nil)
-		srv.On("CheckDbConnection", mock.AnythingOfType("common.Config")).Return(true)
 
 		resp, err := server.App.Test(req)
 
@@ -51,18 +51,16 @@ func TestInternalApiHealth(t *testing.T) {
 	t.Run("Check response /internal/health - Unhealthy", func(t *testing.T) {
 		server := web.NewServer()
 		conf, _ := common.GetConfig(".env_info")
-		srv := new(MockService)
-		controller := NewController(server, conf, srv)
+		controller := NewController(server, common.NewLiveConfig(common.Loader{}, conf), newChecks(errors.New("connection refused")))
 		controller.RegisterRoutes()
 
 		req := httptest.NewRequest(fiber.MethodGet, "/internal/health", nil)
-		srv.On("CheckDbConnection", mock.AnythingOfType("common.Config")).Return(false)
 
 		resp, err := server.App.Test(req)
 
 		a.Nil(err)
 		a.NotEmpty(resp)
-		a.Equal(http.StatusInternalServerError, resp.StatusCode)
+		a.Equal(http.StatusServiceUnavailable, resp.StatusCode)
 		bytesData, err := io.ReadAll(resp.Body)
 		a.Nil(err)
 		body := string(bytesData)
@@ -71,14 +69,58 @@ func TestInternalApiHealth(t *testing.T) {
 	})
 }
 
+func TestInternalApiProbes(t *testing.T) {
+	a := assert.New(t)
+
+	var probe = func(server *web.Server, path string) (int, health.Report) {
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, path, nil))
+		a.Nil(err)
+		var report health.Report
+		a.Nil(json.NewDecoder(resp.Body).Decode(&report))
+		return resp.StatusCode, report
+	}
+
+	t.Run("should report components of each probe", func(t *testing.T) {
+		server := web.NewServer()
+		conf, _ := common.GetConfig(".env_info")
+		NewController(server, common.NewLiveConfig(common.Loader{}, conf), newChecks(errors.New("connection refused"))).RegisterRoutes()
+
+		status, report := probe(server, "/internal/health/live")
+		a.Equal(http.StatusOK, status)
+		a.Equal(health.StatusUp, report.Status)
+		a.Equal(health.StatusUp, report.Components["workers"].Status)
+
+		status, report = probe(server, "/internal/health/ready")
+		a.Equal(http.StatusServiceUnavailable, status)
+		a.Equal(health.StatusDown, report.Status)
+		a.Equal("connection refused", report.Components["database"].Error)
+
+		status, _ = probe(server, "/internal/health/startup")
+		a.Equal(http.StatusServiceUnavailable, status)
+	})
+
+	t.Run("should fail readiness while shutting down", func(t *testing.T) {
+		server := web.NewServer()
+		conf, _ := common.GetConfig(".env_info")
+		NewController(server, common.NewLiveConfig(common.Loader{}, conf), newChecks(nil)).RegisterRoutes()
+		server.BeginShutdown()
+
+		status, report := probe(server, "/internal/health/ready")
+		a.Equal(http.StatusServiceUnavailable, status)
+		a.Equal("shutting down", report.Components["server"].Error)
+
+		status, _ = probe(server, "/internal/health/live")
+		a.Equal(http.StatusOK, status)
+	})
+}
+
 func TestInternalApiInfo(t *testing.T) {
 	a := assert.New(t)
 
 	t.Run("Check response /internal/info", func(t *testing.T) {
 		server := web.NewServer()
 		conf, _ := common.GetConfig(".env_info")
-		srv := new(MockService)
-		conntroller := NewController(server, conf, srv)
+		conntroller := NewController(server, common.NewLiveConfig(common.Loader{}, conf), newChecks(nil))
 		conntroller.RegisterRoutes()
 
 		req := httptest.NewRequest(fiber.MethodGet, "/internal/info", nil)
@@ -94,5 +136,6 @@ func TestInternalApiInfo(t *testing.T) {
 		a.Nil(err)
 		a.Equal("idm", responseBody.Name)
 		a.Equal("1.0.1", responseBody.Version)
+		a.Equal(int64(1), responseBody.ConfigVersion)
 	})
 }
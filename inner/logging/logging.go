@@ -0,0 +1,104 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// форматы логов (LOG_FORMAT)
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// значение, которым заменяются секреты в логах
+const redactedValue = "******"
+
+// части имён атрибутов, значения которых не должны попадать в логи
+var sensitiveKeys = []string{"password", "secret", "token", "authorization", "cookie", "api_key", "apikey", "signing_key", "dsn"}
+
+// New логгер в формате format (text или json) с уровнем level.
+// В каждую запись добавляются идентификаторы запроса и трассировки из контекста (см. WithRequestID),
+// а значения атрибутов с именами вроде password или token заменяются звёздочками.
+func New(writer io.Writer, format string, level slog.Leveler) *slog.Logger {
+	var options = &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	var handler slog.Handler
+	if format == FormatJSON {
+		handler = slog.NewJSONHandler(writer, options)
+	} else {
+		handler = slog.NewTextHandler(writer, options)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+// ParseLevel уровень логов по имени (debug, info, warn, error), неизвестное имя - info
+func ParseLevel(name string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// LevelFrom уровень логов, который читается при каждой записи (перезагружаемая конфигурация LOG_LEVEL)
+func LevelFrom(name func() string) slog.Leveler {
+	return levelFunc(name)
+}
+
+type levelFunc func() string
+
+func (name levelFunc) Level() slog.Level {
+	return ParseLevel(name())
+}
+
+// ключ идентификатора запроса в контексте
+type requestIdKey struct{}
+
+// WithRequestID контекст с идентификатором запроса: он попадает во все записи логов с этим контекстом
+func WithRequestID(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, requestId)
+}
+
+// RequestID идентификатор запроса из контекста, если он есть
+func RequestID(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdKey{}).(string)
+	return requestId
+}
+
+// redact замена значений секретных атрибутов, в том числе во вложенных группах
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	var key = strings.ToLower(attr.Key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(attr.Key, redactedValue)
+		}
+	}
+	return attr
+}
+
+// contextHandler добавление в запись идентификаторов запроса и трассировки из контекста
+type contextHandler struct {
+	slog.Handler
+}
+
+func (handler contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestId := RequestID(ctx); requestId != "" {
+		record.AddAttrs(slog.String("request_id", requestId))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.HasTraceID() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()))
+	}
+	return handler.Handler.Handle(ctx, record)
+}
+
+func (handler contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{handler.Handler.WithAttrs(attrs)}
+}
+
+func (handler contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{handler.Handler.WithGroup(name)}
+}
@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"idm/inner/database"
+)
+
+// Table таблица в памяти, участвующая в транзакциях DB
+type Table interface {
+	// Snapshot копия данных таблицы: restore возвращает таблицу к этой копии
+	Snapshot() (restore func())
+}
+
+// DB хранилище в памяти процесса для демонстрации сервиса и тестов без базы данных.
+// Транзакции выполняются строго по очереди (как при уровне изоляции SERIALIZABLE),
+// при ошибке все таблицы возвращаются к состоянию на начало транзакции.
+type DB struct {
+	// семафор вместо sync.Mutex, чтобы ожидание можно было прервать через контекст
+	lock   chan struct{}
+	tables []Table
+}
+
+func NewDB() *DB {
+	return &DB{lock: make(chan struct{}, 1)}
+}
+
+// Register подключение таблицы к транзакциям, вызывается при создании репозитория
+func (db *DB) Register(table Table) {
+	db.tables = append(db.tables, table)
+}
+
+// ключ транзакции в контексте
+type txKey struct{}
+
+// Lock монопольный доступ к таблицам для запроса вне транзакции.
+// Внутри транзакции из контекста блокировка уже захвачена, и unlock ничего не делает.
+func (db *DB) Lock(ctx context.Context) (unlock func(), err error) {
+	if owner, ok := ctx.Value(txKey{}).(*DB); ok && owner == db {
+		return func() {}, nil
+	}
+
+	select {
+	case db.lock <- struct{}{}:
+		return func() { <-db.lock }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Do выполнение fn в транзакции: если fn вернула ошибку или запаниковала, изменения всех таблиц откатываются.
+// Вложенный вызов работает как точка сохранения: при ошибке откатываются только его изменения.
+// database.ErrRollback откатывает транзакцию без ошибки.
+func (db *DB) Do(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	unlock, err := db.Lock(ctx)
+	if err != nil {
+		return fmt.Errorf("error creating transaction: %w", err)
+	}
+	defer unlock()
+
+	var restores = make([]func(), len(db.tables))
+	for i, table := range db.tables {
+		restores[i] = table.Snapshot()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("transaction panic: %v", r)
+		}
+		if err != nil {
+			for _, restore := range restores {
+				restore()
+			}
+		}
+		if errors.Is(err, database.ErrRollback) {
+			err = nil
+		}
+	}()
+
+	return fn(context.WithValue(ctx, txKey{}, db))
+}
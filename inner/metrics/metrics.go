@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"idm/inner/common"
+	"idm/inner/web"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// префикс имён метрик приложения
+const namespace = "idm"
+
+// маршрут запросов, не попавших ни в один маршрут: путь не используется как метка, чтобы не плодить ряды
+const unmatchedRoute = "unmatched"
+
+// Metrics метрики приложения в формате Prometheus.
+// Используется собственный реестр, а не глобальный, чтобы тесты и несколько серверов в процессе не мешали друг другу.
+type Metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	queryDuration   *prometheus.HistogramVec
+}
+
+// New реестр с метриками HTTP, запросов к базе данных, среды выполнения Go и версией приложения
+func New(cfg common.Config) *Metrics {
+	var metrics = &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Количество HTTP-запросов по маршруту и коду ответа.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Время обработки HTTP-запросов по маршруту и коду ответа.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Время выполнения запросов репозиториев к базе данных.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"repository", "operation", "outcome"}),
+	}
+
+	var buildInfo = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Name:        "build_info",
+		Help:        "Версия приложения, значение всегда 1.",
+		ConstLabels: prometheus.Labels{"name": cfg.AppName, "version": cfg.AppVersion},
+	})
+	buildInfo.Set(1)
+
+	metrics.registry.MustRegister(
+		metrics.requests,
+		metrics.requestDuration,
+		metrics.queryDuration,
+		buildInfo,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return metrics
+}
+
+// Middleware учёт количества и времени обработки запросов.
+// Меткой служит шаблон маршрута (/api/v1/employees/:id), а не путь запроса, чтобы число рядов не зависело от id.
+func (metrics *Metrics) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) {
+		var started = time.Now()
+		var own = c.Route()
+		c.Next()
+
+		route, ok := web.MatchedRoute(c, own)
+		if !ok {
+			route = unmatchedRoute
+		}
+		var labels = prometheus.Labels{
+			// строки fiber ссылаются на буферы fasthttp, которые переиспользуются следующими запросами
+			"method": strings.Clone(c.Method()),
+			"route":  route,
+			"status": strconv.Itoa(c.Fasthttp.Response.StatusCode()),
+		}
+		metrics.requests.With(labels).Inc()
+		metrics.requestDuration.With(labels).Observe(time.Since(started).Seconds())
+	}
+}
+
+// Handler выдача метрик в текстовом формате Prometheus
+func (metrics *Metrics) Handler() fiber.Handler {
+	var handler = fasthttpadaptor.NewFastHTTPHandler(promhttp.HandlerFor(metrics.registry, promhttp.HandlerOpts{}))
+	return func(c *fiber.Ctx) {
+		handler(c.Fasthttp)
+	}
+}
+
+// ObserveQuery учёт запроса репозитория, подходит для database.ObserveQueries
+func (metrics *Metrics) ObserveQuery(repository string, operation string, duration time.Duration, err error) {
+	var outcome = "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	metrics.queryDuration.WithLabelValues(repository, operation, outcome).Observe(duration.Seconds())
+}
+
+// RegisterDB метрики пула подключений к базе данных (sql.DBStats)
+func (metrics *Metrics) RegisterDB(db *sql.DB) {
+	metrics.registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// Counter функция подсчёта для бизнес-метрики, например количество работников
+type Counter func(ctx context.Context) (int64, error)
+
+// RegisterCounts бизнес-метрики: значения считаются при каждом сборе метрик, но не дольше timeout
+func (metrics *Metrics) RegisterCounts(timeout time.Duration, counts map[string]Counter) {
+	var collector = &countCollector{timeout: timeout, counts: make([]countMetric, 0, len(counts))}
+	for name, count := range counts {
+		var desc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), fmt.Sprintf("Текущее значение %s.", name), nil, nil)
+		collector.counts = append(collector.counts, countMetric{name: name, desc: desc, count: count})
+	}
+	metrics.registry.MustRegister(collector)
+}
+
+// countCollector сборщик бизнес-метрик, значения которых берутся из хранилища в момент сбора
+type countCollector struct {
+	timeout time.Duration
+	counts  []countMetric
+}
+
+type countMetric struct {
+	name  string
+	desc  *prometheus.Desc
+	count Counter
+}
+
+func (collector *countCollector) Describe(descs chan<- *prometheus.Desc) {
+	for _, metric := range collector.counts {
+		descs <- metric.desc
+	}
+}
+
+func (collector *countCollector) Collect(values chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collector.timeout)
+	defer cancel()
+	for _, metric := range collector.counts {
+		value, err := metric.count(ctx)
+		if err != nil {
+			// ошибка одного подсчёта (например, таймаут базы данных) не должна ломать весь сбор метрик:
+			// метрики пула подключений и HTTP нужнее всего как раз тогда, когда база данных недоступна
+			slog.Warn("error collecting metric", "metric", metric.name, "error", err)
+			continue
+		}
+		values <- prometheus.MustNewConstMetric(metric.desc, prometheus.GaugeValue, float64(value))
+	}
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"idm/inner/common"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	a := assert.New(t)
+
+	var metrics = New(common.Config{AppName: "idm", AppVersion: "1.0.1"})
+	var app = fiber.New()
+	app.Use(metrics.Middleware())
+	app.Use("/api/v1", func(c *fiber.Ctx) { c.Next() })
+	app.Get("/internal/metrics", metrics.Handler())
+	app.Get("/api/v1/employees/:id", func(c *fiber.Ctx) { c.SendString("ok") })
+	metrics.ObserveQuery("employee", "get", 5*time.Millisecond, nil)
+	metrics.ObserveQuery("employee", "exec", time.Millisecond, errors.New("connection refused"))
+	metrics.RegisterCounts(time.Second, map[string]Counter{
+		"employees": func(ctx context.Context) (int64, error) { return 42, nil },
+		"roles":     func(ctx context.Context) (int64, error) { return 0, context.DeadlineExceeded },
+	})
+
+	for _, path := range []string{"/api/v1/employees/1", "/api/v1/employees/2", "/unknown", "/api/v1/unknown"} {
+		_, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil))
+		a.Nil(err)
+	}
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/internal/metrics", nil))
+	a.Nil(err)
+	a.Equal(http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	a.Nil(err)
+	var text = string(body)
+
+	a.Contains(text, `idm_http_requests_total{method="GET",route="/api/v1/employees/:id",status="200"} 2`)
+	a.Contains(text, `idm_http_requests_total{method="GET",route="unmatched",status="404"} 2`)
+	a.Contains(text, `idm_http_request_duration_seconds_bucket{method="GET",route="/api/v1/employees/:id",status="200",le="+Inf"} 2`)
+	a.Contains(text, `idm_db_query_duration_seconds_count{operation="get",outcome="ok",repository="employee"} 1`)
+	a.Contains(text, `idm_db_query_duration_seconds_count{operation="exec",outcome="error",repository="employee"} 1`)
+	a.Contains(text, `idm_employees 42`)
+	a.NotContains(text, `idm_roles`)
+	a.Contains(text, `idm_build_info{name="idm",version="1.0.1"} 1`)
+	a.Contains(text, `go_goroutines`)
+}
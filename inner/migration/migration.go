@@ -0,0 +1,106 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/migrations"
+	"io/fs"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+)
+
+// ErrPending в базе данных применены не все миграции
+var ErrPending = errors.New("database has pending migrations")
+
+// Migrator применение встроенных миграций (см. migrations.FS) к базе данных
+type Migrator struct {
+	provider *goose.Provider
+}
+
+// NewMigrator миграции для диалекта подключения: PostgreSQL или SQLite
+func NewMigrator(db *sqlx.DB) (*Migrator, error) {
+	var dialect = goose.DialectPostgres
+	var fsys fs.FS = migrations.FS
+	if database.DialectOf(db) == database.DialectSQLite {
+		dialect = goose.DialectSQLite3
+		sub, err := fs.Sub(migrations.FS, "sqlite")
+		if err != nil {
+			return nil, err
+		}
+		fsys = sub
+	}
+
+	provider, err := goose.NewProvider(dialect, db.DB, fsys, goose.WithDisableGlobalRegistry(true))
+	if err != nil {
+		return nil, fmt.Errorf("error loading migrations: %w", err)
+	}
+	return &Migrator{provider: provider}, nil
+}
+
+// Up применение всех недостающих миграций
+func (migrator *Migrator) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return migrator.provider.Up(ctx)
+}
+
+// Down откат последней применённой миграции
+func (migrator *Migrator) Down(ctx context.Context) (*goose.MigrationResult, error) {
+	return migrator.provider.Down(ctx)
+}
+
+// Redo откат и повторное применение последней миграции
+func (migrator *Migrator) Redo(ctx context.Context) ([]*goose.MigrationResult, error) {
+	down, err := migrator.provider.Down(ctx)
+	if err != nil {
+		return nil, err
+	}
+	up, err := migrator.provider.UpByOne(ctx)
+	if err != nil {
+		return []*goose.MigrationResult{down}, err
+	}
+	return []*goose.MigrationResult{down, up}, nil
+}
+
+// Status состояние всех миграций: применена или ожидает применения
+func (migrator *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return migrator.provider.Status(ctx)
+}
+
+// Verify проверка без изменения схемы: если применены не все миграции, возвращается ErrPending
+func (migrator *Migrator) Verify(ctx context.Context) error {
+	current, target, err := migrator.provider.GetVersions(ctx)
+	if err != nil {
+		return err
+	}
+	pending, err := migrator.provider.HasPending(ctx)
+	if err != nil {
+		return err
+	}
+	if pending {
+		return fmt.Errorf("%w: current version %d, latest version %d", ErrPending, current, target)
+	}
+	return nil
+}
+
+// OnStartup миграции при запуске приложения в режиме из конфигурации (common.Config.Migrations):
+// auto - применить недостающие, verify - только проверить, off - ничего не делать
+func OnStartup(ctx context.Context, db *sqlx.DB, mode string) error {
+	if mode == common.MigrationsOff {
+		return nil
+	}
+
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	if mode == common.MigrationsVerify {
+		return migrator.Verify(ctx)
+	}
+	if _, err = migrator.Up(ctx); err != nil {
+		return fmt.Errorf("error applying migrations: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package migration
+
+import (
+	"context"
+	"idm/inner/common"
+	"idm/inner/database"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSQLite(t *testing.T) *sqlx.DB {
+	db, err := database.ConnectDbWithCfg(common.Config{DbDriverName: database.DriverSQLite, Dsn: filepath.Join(t.TempDir(), "idm.db")})
+	if err != nil {
+		t.Fatalf("error connecting to db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestMigrator(t *testing.T) {
+	a := assert.New(t)
+	var ctx = context.Background()
+
+	t.Run("should apply, verify and roll back embedded migrations", func(t *testing.T) {
+		var db = newSQLite(t)
+		migrator, err := NewMigrator(db)
+		a.NoError(err)
+		a.ErrorIs(migrator.Verify(ctx), ErrPending)
+
+		results, err := migrator.Up(ctx)
+		a.NoError(err)
+		a.NotEmpty(results)
+		a.NoError(migrator.Verify(ctx))
+		_, err = db.Exec("INSERT INTO employee_role (employee_id, role_id) VALUES (1, 1)")
+		a.Error(err, "foreign keys should be enforced")
+
+		results, err = migrator.Redo(ctx)
+		a.NoError(err)
+		a.Len(results, 2)
+
+		_, err = migrator.Down(ctx)
+		a.NoError(err)
+		statuses, err := migrator.Status(ctx)
+		a.NoError(err)
+		a.Equal(goose.StatePending, statuses[len(statuses)-1].State)
+	})
+
+	t.Run("should verify or skip migrations on startup", func(t *testing.T) {
+		var db = newSQLite(t)
+		a.NoError(OnStartup(ctx, db, common.MigrationsOff))
+		a.ErrorIs(OnStartup(ctx, db, common.MigrationsVerify), ErrPending)
+		a.NoError(OnStartup(ctx, db, common.MigrationsAuto))
+		a.NoError(OnStartup(ctx, db, common.MigrationsVerify))
+	})
+}
@@ -1,9 +1,9 @@
 package mypackage_test
 
 import (
-    "testing"
+	"testing"
 )
 
 func TestExample(t *testing.T) {
-    t.Log("Это пустой тест-заглушка")
-}
\ No newline at end of file
+	t.Log("Это пустой тест-заглушка")
+}
@@ -0,0 +1,244 @@
+package oidc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"idm/inner/auth"
+	"idm/inner/common"
+	"idm/inner/crud"
+	"idm/inner/web"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber"
+)
+
+// пути точек провайдера относительно издателя
+const (
+	PathDiscovery = "/.well-known/openid-configuration"
+	PathJwks      = "/oauth2/jwks"
+	PathAuthorize = "/oauth2/authorize"
+	PathToken     = "/oauth2/token"
+	PathUserInfo  = "/oauth2/userinfo"
+)
+
+type Controller struct {
+	server   *web.Server
+	provider Srv
+	keys     *Keys
+}
+
+// интерфейс провайдера oidc.Provider
+type Srv interface {
+	CreateClient(ctx context.Context, req ClientRequest) (CreatedClient, error)
+	GetClients(ctx context.Context) ([]ClientResponse, error)
+	DeleteClient(ctx context.Context, id int64) error
+	Discovery() Discovery
+	Authorize(ctx context.Context, req AuthorizeRequest, session string) (string, error)
+	Token(ctx context.Context, req TokenRequest) (TokenResponse, error)
+	UserInfo(ctx context.Context, accessToken string) (UserInfo, error)
+}
+
+func NewController(server *web.Server, provider Srv, keys *Keys) *Controller {
+	return &Controller{server: server, provider: provider, keys: keys}
+}
+
+// функция для регистрации маршрутов: точки протокола - вне "/api/v1" и не требуют аутентификации API,
+// регистрация клиентов - "/api/v1/oidc-clients" с разрешениями oidc-clients:read и oidc-clients:write
+func (contr *Controller) RegisterRoutes() {
+	contr.server.App.Get(PathDiscovery, contr.Discovery)
+	contr.server.App.Get(PathJwks, contr.Jwks)
+	contr.server.App.Get(PathAuthorize, contr.Authorize)
+	contr.server.App.Post(PathToken, contr.Token)
+	contr.server.App.Get(PathUserInfo, contr.UserInfo)
+
+	contr.server.GroupApiV1.Post("/oidc-clients", contr.CreateClient)
+	contr.server.GroupApiV1.Get("/oidc-clients", contr.GetClients)
+	contr.server.GroupApiV1.Delete("/oidc-clients/id/:id", contr.DeleteClient)
+}
+
+// Discovery хендлер GET-запроса "/.well-known/openid-configuration"
+func (contr *Controller) Discovery(ctx *fiber.Ctx) {
+	writeJson(ctx, fiber.StatusOK, contr.provider.Discovery())
+}
+
+// Jwks хендлер GET-запроса "/oauth2/jwks": открытые ключи проверки подписи токенов
+func (contr *Controller) Jwks(ctx *fiber.Ctx) {
+	jwks, err := contr.keys.Jwks(web.Context(ctx))
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	writeJson(ctx, fiber.StatusOK, jwks)
+}
+
+// Authorize хендлер GET-запроса "/oauth2/authorize": перенаправление на redirect_uri клиента с кодом или ошибкой
+func (contr *Controller) Authorize(ctx *fiber.Ctx) {
+	var req AuthorizeRequest
+	if err := ctx.QueryParser(&req); err != nil {
+		writeError(ctx, Error{Code: ErrInvalidRequest, Description: err.Error()})
+		return
+	}
+
+	var session = ctx.Cookies(auth.SessionCookie)
+	if scheme, token, ok := strings.Cut(ctx.Get(fiber.HeaderAuthorization), " "); ok && strings.EqualFold(scheme, auth.SchemeBearer) {
+		session = token
+	}
+
+	location, err := contr.provider.Authorize(web.Context(ctx), req, session)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	ctx.Redirect(location, fiber.StatusFound)
+}
+
+// Token хендлер POST-запроса "/oauth2/token" (application/x-www-form-urlencoded)
+func (contr *Controller) Token(ctx *fiber.Ctx) {
+	var req TokenRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		writeError(ctx, Error{Code: ErrInvalidRequest, Description: err.Error()})
+		return
+	}
+	// client_secret_basic: учётные данные из заголовка важнее параметров тела
+	if clientId, secret, ok := basicAuth(ctx); ok {
+		req.ClientId, req.ClientSecret = clientId, secret
+	}
+
+	response, err := contr.provider.Token(web.Context(ctx), req)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	writeJson(ctx, fiber.StatusOK, response)
+}
+
+// UserInfo хендлер GET-запроса "/oauth2/userinfo" с токеном доступа "Authorization: Bearer ..."
+func (contr *Controller) UserInfo(ctx *fiber.Ctx) {
+	scheme, token, _ := strings.Cut(ctx.Get(fiber.HeaderAuthorization), " ")
+	if !strings.EqualFold(scheme, auth.SchemeBearer) || token == "" {
+		ctx.Set(fiber.HeaderWWWAuthenticate, auth.SchemeBearer)
+		writeJson(ctx, fiber.StatusUnauthorized, Error{Code: "invalid_token", Description: "bearer access token required"})
+		return
+	}
+
+	info, err := contr.provider.UserInfo(web.Context(ctx), token)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		ctx.Set(fiber.HeaderWWWAuthenticate, auth.SchemeBearer+` error="invalid_token"`)
+		writeJson(ctx, fiber.StatusUnauthorized, Error{Code: "invalid_token", Description: err.Error()})
+		return
+	}
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	writeJson(ctx, fiber.StatusOK, info)
+}
+
+// CreateClient хендлер POST-запроса "/api/v1/oidc-clients": в ответе секрет, который больше нигде не показывается
+func (contr *Controller) CreateClient(ctx *fiber.Ctx) {
+	var req ClientRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+		return
+	}
+
+	created, err := contr.provider.CreateClient(web.Context(ctx), req)
+	if err != nil {
+		crud.WriteError(ctx, err)
+		return
+	}
+
+	if err = common.OkResponse(ctx, created); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning created oidc client")
+		return
+	}
+}
+
+// GetClients хендлер GET-запроса "/api/v1/oidc-clients"
+func (contr *Controller) GetClients(ctx *fiber.Ctx) {
+	responses, err := contr.provider.GetClients(web.Context(ctx))
+	if err != nil {
+		crud.WriteError(ctx, err)
+		return
+	}
+
+	if err = common.OkResponse(ctx, responses); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning all oidc clients")
+		return
+	}
+}
+
+// DeleteClient хендлер DELETE-запроса "/api/v1/oidc-clients/id/:id": выданные клиенту токены действуют до истечения срока
+func (contr *Controller) DeleteClient(ctx *fiber.Ctx) {
+	id, ok := crud.ParamId(ctx)
+	if !ok {
+		return
+	}
+
+	if err := contr.provider.DeleteClient(web.Context(ctx), id); err != nil {
+		crud.WriteError(ctx, err)
+		return
+	}
+
+	if err := common.ResponseWithoutData(ctx); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning result delete oidc client")
+		return
+	}
+}
+
+// basicAuth учётные данные клиента из "Authorization: Basic ...", закодированные по RFC 6749 (раздел 2.3.1)
+func basicAuth(ctx *fiber.Ctx) (clientId string, secret string, ok bool) {
+	scheme, encoded, found := strings.Cut(ctx.Get(fiber.HeaderAuthorization), " ")
+	if !found || !strings.EqualFold(scheme, "Basic") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", false
+	}
+	escapedId, escapedSecret, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return "", "", false
+	}
+	if clientId, err = url.QueryUnescape(escapedId); err != nil {
+		return "", "", false
+	}
+	if secret, err = url.QueryUnescape(escapedSecret); err != nil {
+		return "", "", false
+	}
+	return clientId, secret, true
+}
+
+// writeError ответ с ошибкой протокола: invalid_client - 401, прочие ошибки протокола - 400,
+// остальные ошибки - 500 server_error без подробностей
+func writeError(ctx *fiber.Ctx, err error) {
+	var protocolErr Error
+	if !errors.As(err, &protocolErr) {
+		slog.ErrorContext(web.Context(ctx), "oidc request failed", "error", err)
+		writeJson(ctx, fiber.StatusInternalServerError, Error{Code: "server_error"})
+		return
+	}
+	var status = fiber.StatusBadRequest
+	if protocolErr.Code == ErrInvalidClient {
+		status = fiber.StatusUnauthorized
+		ctx.Set(fiber.HeaderWWWAuthenticate, "Basic")
+	}
+	writeJson(ctx, status, protocolErr)
+}
+
+// writeJson ответ в формате протокола: без обёртки common.Response и без кеширования (RFC 6749, раздел 5.1)
+func writeJson(ctx *fiber.Ctx, status int, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error encoding response")
+		return
+	}
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	ctx.Status(status).SendBytes(data)
+}
@@ -0,0 +1,194 @@
+package oidc
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// типы грантов OAuth 2.0
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantClientCredentials = "client_credentials"
+)
+
+// scope OIDC: openid обязателен для ID-токена, profile добавляет имя работника, roles - его роли
+const (
+	ScopeOpenId  = "openid"
+	ScopeProfile = "profile"
+	ScopeRoles   = "roles"
+)
+
+var supportedScopes = []string{ScopeOpenId, ScopeProfile, ScopeRoles}
+
+// ClientEntity клиент провайдера: приложение, которое получает токены
+type ClientEntity struct {
+	Id       int64  `db:"id"`
+	ClientId string `db:"client_id"`
+	Name     string `db:"name"`
+	// SHA-256 секрета, пустая строка у публичного клиента
+	SecretHash string `db:"secret_hash"`
+	// списки через пробел
+	RedirectUris string `db:"redirect_uris"`
+	GrantTypes   string `db:"grant_types"`
+	// разрешения API (см. auth.RequiredScope), которые клиент может получить по client_credentials
+	Scopes string    `db:"scopes"`
+	Create time.Time `db:"create_at"`
+}
+
+// Public клиент без секрета: код обменивается на токены только с PKCE
+func (client *ClientEntity) Public() bool {
+	return client.SecretHash == ""
+}
+
+// Allows разрешён ли клиенту грант
+func (client *ClientEntity) Allows(grantType string) bool {
+	return contains(client.GrantTypes, grantType)
+}
+
+// SigningKeyEntity ключ подписи токенов
+type SigningKeyEntity struct {
+	Kid string `db:"kid"`
+	// закрытый ключ RSA в PEM (PKCS#8)
+	PrivateKey string    `db:"private_key"`
+	Create     time.Time `db:"create_at"`
+}
+
+// CodeEntity выданный код авторизации: хранится SHA-256 кода, код одноразовый
+type CodeEntity struct {
+	Hash        string `db:"hash"`
+	ClientId    string `db:"client_id"`
+	EmployeeId  int64  `db:"employee_id"`
+	RedirectUri string `db:"redirect_uri"`
+	Scope       string `db:"scope"`
+	Nonce       string `db:"nonce"`
+	// BASE64URL(SHA-256(code_verifier)), PKCE с методом S256
+	CodeChallenge string    `db:"code_challenge"`
+	AuthTime      time.Time `db:"auth_time"`
+	ExpiresAt     time.Time `db:"expires_at"`
+}
+
+type ClientRequest struct {
+	Name         string   `json:"name" validate:"required,min=2,max=155"`
+	RedirectUris []string `json:"redirect_uris" validate:"dive,url"`
+	GrantTypes   []string `json:"grant_types" validate:"required,min=1,dive,oneof=authorization_code client_credentials"`
+	// публичный клиент (SPA, CLI) не получает секрета и не может использовать client_credentials
+	Public bool     `json:"public"`
+	Scopes []string `json:"scopes"`
+}
+
+type ClientResponse struct {
+	Id           int64     `json:"id"`
+	ClientId     string    `json:"client_id"`
+	Name         string    `json:"name"`
+	Public       bool      `json:"public"`
+	RedirectUris []string  `json:"redirect_uris"`
+	GrantTypes   []string  `json:"grant_types"`
+	Scopes       []string  `json:"scopes"`
+	Create       time.Time `json:"create_at"`
+}
+
+// CreatedClient зарегистрированный клиент: секрет возвращается только в ответе на регистрацию
+type CreatedClient struct {
+	ClientResponse
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+func (client *ClientEntity) toResponse() ClientResponse {
+	return ClientResponse{
+		Id:           client.Id,
+		ClientId:     client.ClientId,
+		Name:         client.Name,
+		Public:       client.Public(),
+		RedirectUris: strings.Fields(client.RedirectUris),
+		GrantTypes:   strings.Fields(client.GrantTypes),
+		Scopes:       strings.Fields(client.Scopes),
+		Create:       client.Create,
+	}
+}
+
+// AuthorizeRequest параметры запроса авторизации (OpenID Connect Core, раздел 3.1.2.1)
+type AuthorizeRequest struct {
+	ResponseType        string `query:"response_type"`
+	ClientId            string `query:"client_id"`
+	RedirectUri         string `query:"redirect_uri"`
+	Scope               string `query:"scope"`
+	State               string `query:"state"`
+	Nonce               string `query:"nonce"`
+	CodeChallenge       string `query:"code_challenge"`
+	CodeChallengeMethod string `query:"code_challenge_method"`
+}
+
+// TokenRequest параметры запроса токена (RFC 6749, разделы 4.1.3 и 4.4.2)
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectUri  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	Scope        string `form:"scope"`
+	// учётные данные клиента: из тела запроса или из заголовка Authorization: Basic
+	ClientId     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	IdToken     string `json:"id_token,omitempty"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+// IdTokenClaims утверждения ID-токена: sub - работник ("employee:42"), roles - имена его ролей
+type IdTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce    string           `json:"nonce,omitempty"`
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	Name     string           `json:"name,omitempty"`
+	Roles    []string         `json:"roles,omitempty"`
+}
+
+// UserInfo ответ userinfo: те же сведения о работнике, что и в ID-токене
+type UserInfo struct {
+	Subject string   `json:"sub"`
+	Name    string   `json:"name,omitempty"`
+	Roles   []string `json:"roles,omitempty"`
+}
+
+// Discovery документ обнаружения провайдера (OpenID Connect Discovery 1.0)
+type Discovery struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JwksUri                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+}
+
+// Jwk открытый ключ RSA в формате JWK (RFC 7517)
+type Jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type Jwks struct {
+	Keys []Jwk `json:"keys"`
+}
+
+// contains есть ли value в списке через пробел
+func contains(list string, value string) bool {
+	return slices.Contains(strings.Fields(list), value)
+}
@@ -0,0 +1,219 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// размер ключей RSA
+const keyBits = 2048
+
+// как долго ключи из хранилища используются без перечитывания: за это время ключ,
+// выпущенный другим экземпляром сервиса, появляется в JWKS и начинает использоваться для подписи
+const keyCacheTTL = time.Minute
+
+// signingKey ключ подписи, готовый к использованию
+type signingKey struct {
+	kid     string
+	private *rsa.PrivateKey
+	create  time.Time
+}
+
+// Keys ключи подписи токенов с ротацией: токены подписываются самым новым ключом,
+// а в JWKS публикуются все ключи, которыми могли быть подписаны ещё действующие токены.
+// Ключ подписывает токены rotation, затем ещё rotation остаётся в JWKS и после этого удаляется,
+// поэтому rotation должен быть не меньше времени жизни токенов.
+type Keys struct {
+	store    Store
+	rotation time.Duration
+	// текущее время, подменяется в тестах
+	now func() time.Time
+
+	mutex    sync.Mutex
+	cached   []signingKey
+	loadedAt time.Time
+}
+
+func NewKeys(store Store, rotation time.Duration) *Keys {
+	return &Keys{store: store, rotation: rotation, now: time.Now}
+}
+
+// Rotate выпуск нового ключа, если самому новому ключу больше rotation (или ключей ещё нет),
+// и удаление ключей, которыми уже не подписан ни один действующий токен
+func (keys *Keys) Rotate(ctx context.Context) error {
+	var now = keys.now().UTC()
+	current, err := keys.load(ctx)
+	if err != nil {
+		return err
+	}
+	if len(current) == 0 || now.Sub(current[0].create) >= keys.rotation {
+		if err = keys.generate(ctx, now); err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "oidc signing key rotated")
+	}
+	if _, err = keys.store.DeleteKeysBefore(ctx, now.Add(-2*keys.rotation)); err != nil {
+		return fmt.Errorf("error deleting old signing keys: %w", err)
+	}
+	// следующий запрос перечитает ключи
+	keys.mutex.Lock()
+	keys.loadedAt = time.Time{}
+	keys.mutex.Unlock()
+	return nil
+}
+
+func (keys *Keys) generate(ctx context.Context, now time.Time) error {
+	private, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return fmt.Errorf("error generating signing key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return fmt.Errorf("error encoding signing key: %w", err)
+	}
+	var entity = SigningKeyEntity{
+		Kid:        thumbprint(&private.PublicKey),
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		Create:     now,
+	}
+	if err = keys.store.SaveKey(ctx, &entity); err != nil {
+		return fmt.Errorf("error saving signing key: %w", err)
+	}
+	return nil
+}
+
+// load ключи из кеша или хранилища, самый новый - первый
+func (keys *Keys) load(ctx context.Context) ([]signingKey, error) {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+
+	if !keys.loadedAt.IsZero() && keys.now().Sub(keys.loadedAt) < keyCacheTTL {
+		return keys.cached, nil
+	}
+	entities, err := keys.store.FindKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error finding signing keys: %w", err)
+	}
+	var loaded = make([]signingKey, 0, len(entities))
+	for _, entity := range entities {
+		private, err := parsePrivateKey(entity.PrivateKey)
+		if err != nil {
+			slog.ErrorContext(ctx, "invalid oidc signing key skipped", "kid", entity.Kid, "error", err)
+			continue
+		}
+		loaded = append(loaded, signingKey{kid: entity.Kid, private: private, create: entity.Create})
+	}
+	slices.SortFunc(loaded, func(a, b signingKey) int { return b.create.Compare(a.create) })
+	keys.cached, keys.loadedAt = loaded, keys.now()
+	return loaded, nil
+}
+
+// Sign подпись утверждений самым новым ключом
+func (keys *Keys) Sign(ctx context.Context, claims jwt.Claims) (string, error) {
+	loaded, err := keys.load(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(loaded) == 0 {
+		return "", errors.New("no oidc signing keys, rotation has not run yet")
+	}
+	var token = jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = loaded[0].kid
+	return token.SignedString(loaded[0].private)
+}
+
+// PublicKey открытый ключ для проверки токена (auth.KeySource)
+func (keys *Keys) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	loaded, err := keys.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range loaded {
+		if key.kid == kid {
+			return &key.private.PublicKey, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// Jwks опубликованные открытые ключи
+func (keys *Keys) Jwks(ctx context.Context) (Jwks, error) {
+	loaded, err := keys.load(ctx)
+	if err != nil {
+		return Jwks{}, err
+	}
+	var jwks = Jwks{Keys: make([]Jwk, len(loaded))}
+	for i, key := range loaded {
+		jwks.Keys[i] = toJwk(key.kid, &key.private.PublicKey)
+	}
+	return jwks, nil
+}
+
+// RunRotation периодическая ротация ключей и удаление истёкших кодов авторизации до отмены контекста
+func RunRotation(ctx context.Context, keys *Keys, interval time.Duration) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := keys.Rotate(ctx); err != nil {
+				slog.ErrorContext(ctx, "error rotating oidc signing keys", "error", err)
+			}
+			if _, err := keys.store.DeleteExpiredCodes(ctx, keys.now().UTC()); err != nil {
+				slog.ErrorContext(ctx, "error deleting expired authorization codes", "error", err)
+			}
+		}
+	}
+}
+
+func parsePrivateKey(value string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unexpected key type %T", parsed)
+	}
+	return private, nil
+}
+
+func toJwk(kid string, public *rsa.PublicKey) Jwk {
+	return Jwk{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: jwt.SigningMethodRS256.Alg(),
+		Kid: kid,
+		N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+	}
+}
+
+// thumbprint kid ключа - отпечаток JWK по RFC 7638
+func thumbprint(public *rsa.PublicKey) string {
+	var jwk = toJwk("", public)
+	var sum = sha256.Sum256([]byte(`{"e":"` + jwk.E + `","kty":"RSA","n":"` + jwk.N + `"}`))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
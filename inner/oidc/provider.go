@@ -0,0 +1,519 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"idm/inner/auth"
+	"idm/inner/common"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"idm/inner/tracing"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// время жизни кода авторизации: клиент обменивает его на токены сразу после перенаправления
+const codeTTL = time.Minute
+
+// ClientSubjectPrefix префикс вызывающего-клиента в токенах client_credentials, например "client:reports"
+const ClientSubjectPrefix = "client:"
+
+// коды ошибок OAuth 2.0 (RFC 6749, разделы 4.1.2.1 и 5.2) и OpenID Connect
+const (
+	ErrInvalidRequest          = "invalid_request"
+	ErrInvalidClient           = "invalid_client"
+	ErrInvalidGrant            = "invalid_grant"
+	ErrInvalidScope            = "invalid_scope"
+	ErrUnauthorizedClient      = "unauthorized_client"
+	ErrUnsupportedGrantType    = "unsupported_grant_type"
+	ErrUnsupportedResponseType = "unsupported_response_type"
+	ErrAccessDenied            = "access_denied"
+	ErrLoginRequired           = "login_required"
+)
+
+// Error ошибка протокола: возвращается клиенту в виде {"error": ..., "error_description": ...}
+// или в параметрах перенаправления на redirect_uri
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (err Error) Error() string {
+	return err.Code + ": " + err.Description
+}
+
+// Employees работники, которым провайдер выдаёт токены
+type Employees interface {
+	FindById(ctx context.Context, id int64) (employee.Entity, error)
+}
+
+// Roles роли работников для утверждения roles
+type Roles interface {
+	FindByEmployeeId(ctx context.Context, employeeId int64) ([]role.Entity, error)
+}
+
+// Sessions проверка сессии работника, от имени которого клиент запрашивает авторизацию
+type Sessions interface {
+	Verify(ctx context.Context, token string) (auth.TokenClaims, error)
+}
+
+type Validator interface {
+	Validate(request any) error
+}
+
+// Provider встроенный OIDC-провайдер: регистрация клиентов, выдача кодов авторизации и токенов
+type Provider struct {
+	store     Store
+	keys      *Keys
+	employees Employees
+	roles     Roles
+	sessions  Sessions
+	valid     Validator
+	// адрес издателя, например https://idm.example.com: от него строятся адреса всех точек провайдера
+	issuer   string
+	tokenTTL time.Duration
+	// текущее время, подменяется в тестах
+	now func() time.Time
+}
+
+func NewProvider(store Store, keys *Keys, employees Employees, roles Roles, sessions Sessions, validator Validator,
+	issuer string, tokenTTL time.Duration) *Provider {
+	return &Provider{
+		store:     store,
+		keys:      keys,
+		employees: employees,
+		roles:     roles,
+		sessions:  sessions,
+		valid:     validator,
+		issuer:    strings.TrimSuffix(issuer, "/"),
+		tokenTTL:  tokenTTL,
+		now:       time.Now,
+	}
+}
+
+// Discovery документ обнаружения провайдера
+func (provider *Provider) Discovery() Discovery {
+	return Discovery{
+		Issuer:                            provider.issuer,
+		AuthorizationEndpoint:             provider.issuer + PathAuthorize,
+		TokenEndpoint:                     provider.issuer + PathToken,
+		UserinfoEndpoint:                  provider.issuer + PathUserInfo,
+		JwksUri:                           provider.issuer + PathJwks,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{GrantAuthorizationCode, GrantClientCredentials},
+		SubjectTypesSupported:             []string{"public"},
+		IdTokenSigningAlgValuesSupported:  []string{jwt.SigningMethodRS256.Alg()},
+		ScopesSupported:                   supportedScopes,
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "name", "roles"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+	}
+}
+
+// CreateClient регистрация клиента: секрет конфиденциального клиента возвращается только в ответе
+func (provider *Provider) CreateClient(ctx context.Context, req ClientRequest) (created CreatedClient, err error) {
+	ctx, span := tracing.Start(ctx, "oidc.CreateClient")
+	defer tracing.End(span, &err)
+
+	if err = tracing.Validate(ctx, provider.valid, req); err != nil {
+		return CreatedClient{}, common.RequestValidationError{Message: err.Error()}
+	}
+	if err = validateClient(req); err != nil {
+		return CreatedClient{}, err
+	}
+	// токены client_credentials принимаются API с разрешениями клиента
+	if err = auth.CheckGrant(ctx, req.Scopes); err != nil {
+		return CreatedClient{}, err
+	}
+
+	var client = ClientEntity{
+		Name:         req.Name,
+		RedirectUris: strings.Join(req.RedirectUris, " "),
+		GrantTypes:   strings.Join(req.GrantTypes, " "),
+		Scopes:       strings.Join(req.Scopes, " "),
+		Create:       provider.now().UTC(),
+	}
+	if client.ClientId, err = randomString(12); err != nil {
+		return CreatedClient{}, err
+	}
+	if !req.Public {
+		if created.ClientSecret, err = randomString(32); err != nil {
+			return CreatedClient{}, err
+		}
+		client.SecretHash = hash(created.ClientSecret)
+	}
+	client.Id, err = provider.store.CreateClient(ctx, &client)
+	if err != nil {
+		return CreatedClient{}, common.DbOperationError{Message: fmt.Errorf("error creating oidc client: %w", err).Error()}
+	}
+	created.ClientResponse = client.toResponse()
+	return created, nil
+}
+
+func validateClient(req ClientRequest) error {
+	if slices.Contains(req.GrantTypes, GrantAuthorizationCode) && len(req.RedirectUris) == 0 {
+		return common.RequestValidationError{Message: "redirect_uris are required for authorization_code grant"}
+	}
+	if slices.Contains(req.GrantTypes, GrantClientCredentials) && req.Public {
+		return common.RequestValidationError{Message: "public client cannot use client_credentials grant"}
+	}
+	if invalid := slices.IndexFunc(req.Scopes, func(scope string) bool { return !auth.ValidScope(scope) }); invalid >= 0 {
+		return common.RequestValidationError{Message: fmt.Sprintf("invalid scope %q: expected resource:read, resource:write, resource:* or *", req.Scopes[invalid])}
+	}
+	return nil
+}
+
+func (provider *Provider) GetClients(ctx context.Context) (responses []ClientResponse, err error) {
+	ctx, span := tracing.Start(ctx, "oidc.GetClients")
+	defer tracing.End(span, &err)
+
+	clients, err := provider.store.FindClients(ctx)
+	if err != nil {
+		return nil, common.DbOperationError{Message: fmt.Errorf("error finding oidc clients: %w", err).Error()}
+	}
+	responses = make([]ClientResponse, len(clients))
+	for i := range clients {
+		responses[i] = clients[i].toResponse()
+	}
+	return responses, nil
+}
+
+func (provider *Provider) DeleteClient(ctx context.Context, id int64) (err error) {
+	ctx, span := tracing.Start(ctx, "oidc.DeleteClient")
+	defer tracing.End(span, &err)
+
+	deleted, err := provider.store.DeleteClient(ctx, id)
+	if err != nil {
+		return common.DbOperationError{Message: fmt.Errorf("error deleting oidc client: %w", err).Error()}
+	}
+	if !deleted {
+		return common.NotFoundError{Message: fmt.Sprintf("oidc client with id %d not found", id)}
+	}
+	return nil
+}
+
+// Authorize запрос авторизации от имени работника с сессией session: адрес перенаправления
+// на redirect_uri клиента с кодом или с ошибкой. Если клиент или redirect_uri неизвестны,
+// перенаправлять некуда и возвращается Error.
+func (provider *Provider) Authorize(ctx context.Context, req AuthorizeRequest, session string) (location string, err error) {
+	ctx, span := tracing.Start(ctx, "oidc.Authorize")
+	defer tracing.End(span, &err)
+
+	client, err := provider.findClient(ctx, req.ClientId)
+	if err != nil {
+		return "", err
+	}
+	if !contains(client.RedirectUris, req.RedirectUri) {
+		return "", Error{Code: ErrInvalidRequest, Description: "redirect_uri is not registered for the client"}
+	}
+
+	var redirect = func(params url.Values) string {
+		params.Set("state", req.State)
+		params.Set("iss", provider.issuer)
+		var separator = "?"
+		if strings.Contains(req.RedirectUri, "?") {
+			separator = "&"
+		}
+		return req.RedirectUri + separator + params.Encode()
+	}
+	var fail = func(code string, description string) (string, error) {
+		return redirect(url.Values{"error": {code}, "error_description": {description}}), nil
+	}
+
+	switch {
+	case req.ResponseType != "code":
+		return fail(ErrUnsupportedResponseType, "only response_type=code is supported")
+	case !client.Allows(GrantAuthorizationCode):
+		return fail(ErrUnauthorizedClient, "client is not allowed to use authorization_code grant")
+	case req.CodeChallenge == "" || req.CodeChallengeMethod != "S256":
+		return fail(ErrInvalidRequest, "PKCE with code_challenge_method=S256 is required")
+	}
+	var scopes = strings.Fields(req.Scope)
+	if !slices.Contains(scopes, ScopeOpenId) {
+		return fail(ErrInvalidScope, "scope must contain openid")
+	}
+	if unknown := slices.IndexFunc(scopes, func(scope string) bool { return !slices.Contains(supportedScopes, scope) }); unknown >= 0 {
+		return fail(ErrInvalidScope, fmt.Sprintf("unsupported scope %s", scopes[unknown]))
+	}
+
+	// вход работника выполняется не здесь: запрос должен прийти с действующей сессией
+	claims, err := provider.sessions.Verify(ctx, session)
+	if err != nil {
+		return fail(ErrLoginRequired, "employee session is missing or expired")
+	}
+	employeeId, ok := auth.EmployeeId(claims.Subject)
+	if !ok {
+		return fail(ErrAccessDenied, "session does not belong to an employee")
+	}
+	if _, err = provider.activeEmployee(ctx, employeeId); err != nil {
+		var protocolErr Error
+		if errors.As(err, &protocolErr) {
+			return fail(ErrAccessDenied, protocolErr.Description)
+		}
+		return "", err
+	}
+
+	code, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+	var now = provider.now().UTC()
+	var authTime = now
+	if claims.IssuedAt != nil {
+		authTime = claims.IssuedAt.UTC()
+	}
+	err = provider.store.SaveCode(ctx, &CodeEntity{
+		Hash:          hash(code),
+		ClientId:      client.ClientId,
+		EmployeeId:    employeeId,
+		RedirectUri:   req.RedirectUri,
+		Scope:         strings.Join(scopes, " "),
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      authTime,
+		ExpiresAt:     now.Add(codeTTL),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error saving authorization code: %w", err)
+	}
+	return redirect(url.Values{"code": {code}}), nil
+}
+
+// Token выдача токенов по коду авторизации или по учётным данным клиента
+func (provider *Provider) Token(ctx context.Context, req TokenRequest) (response TokenResponse, err error) {
+	ctx, span := tracing.Start(ctx, "oidc.Token")
+	defer tracing.End(span, &err)
+
+	client, err := provider.authenticateClient(ctx, req.ClientId, req.ClientSecret)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	switch req.GrantType {
+	case GrantAuthorizationCode:
+		return provider.exchangeCode(ctx, client, req)
+	case GrantClientCredentials:
+		return provider.clientCredentials(ctx, client, req)
+	default:
+		return TokenResponse{}, Error{Code: ErrUnsupportedGrantType, Description: fmt.Sprintf("unsupported grant_type %s", req.GrantType)}
+	}
+}
+
+func (provider *Provider) exchangeCode(ctx context.Context, client ClientEntity, req TokenRequest) (TokenResponse, error) {
+	if !client.Allows(GrantAuthorizationCode) {
+		return TokenResponse{}, Error{Code: ErrUnauthorizedClient, Description: "client is not allowed to use authorization_code grant"}
+	}
+	code, err := provider.store.ConsumeCode(ctx, hash(req.Code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return TokenResponse{}, Error{Code: ErrInvalidGrant, Description: "authorization code is invalid or already used"}
+	}
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("error finding authorization code: %w", err)
+	}
+
+	var now = provider.now().UTC()
+	switch {
+	case code.ClientId != client.ClientId:
+		return TokenResponse{}, Error{Code: ErrInvalidGrant, Description: "authorization code was issued to another client"}
+	case code.RedirectUri != req.RedirectUri:
+		return TokenResponse{}, Error{Code: ErrInvalidGrant, Description: "redirect_uri does not match authorization request"}
+	case !now.Before(code.ExpiresAt):
+		return TokenResponse{}, Error{Code: ErrInvalidGrant, Description: "authorization code expired"}
+	case !verifyChallenge(req.CodeVerifier, code.CodeChallenge):
+		return TokenResponse{}, Error{Code: ErrInvalidGrant, Description: "code_verifier does not match code_challenge"}
+	}
+
+	entity, err := provider.activeEmployee(ctx, code.EmployeeId)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	var subject = auth.EmployeeSubject(entity.Id)
+	accessToken, err := provider.keys.Sign(ctx, provider.accessClaims(subject, client.ClientId, code.Scope, now))
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	var idClaims = IdTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    provider.issuer,
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{client.ClientId},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(provider.tokenTTL)),
+		},
+		Nonce:    code.Nonce,
+		AuthTime: jwt.NewNumericDate(code.AuthTime),
+	}
+	info, err := provider.userInfo(ctx, entity, code.Scope)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	idClaims.Name, idClaims.Roles = info.Name, info.Roles
+	idToken, err := provider.keys.Sign(ctx, idClaims)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	return TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(provider.tokenTTL.Seconds()),
+		IdToken:     idToken,
+		Scope:       code.Scope,
+	}, nil
+}
+
+func (provider *Provider) clientCredentials(ctx context.Context, client ClientEntity, req TokenRequest) (TokenResponse, error) {
+	if client.Public() || !client.Allows(GrantClientCredentials) {
+		return TokenResponse{}, Error{Code: ErrUnauthorizedClient, Description: "client is not allowed to use client_credentials grant"}
+	}
+	// без scope выдаются все разрешения клиента
+	var scopes = strings.Fields(req.Scope)
+	if len(scopes) == 0 {
+		scopes = strings.Fields(client.Scopes)
+	}
+	if denied := slices.IndexFunc(scopes, func(scope string) bool { return !contains(client.Scopes, scope) }); denied >= 0 {
+		return TokenResponse{}, Error{Code: ErrInvalidScope, Description: fmt.Sprintf("scope %s is not allowed for the client", scopes[denied])}
+	}
+
+	var scope = strings.Join(scopes, " ")
+	accessToken, err := provider.keys.Sign(ctx, provider.accessClaims(ClientSubjectPrefix+client.ClientId, client.ClientId, scope, provider.now().UTC()))
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	return TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(provider.tokenTTL.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// accessClaims утверждения токена доступа: его же принимает API (см. auth.TokenVerifier.WithKeys)
+func (provider *Provider) accessClaims(subject string, clientId string, scope string, now time.Time) auth.TokenClaims {
+	return auth.TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    provider.issuer,
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{clientId},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(provider.tokenTTL)),
+		},
+		Scope: scope,
+	}
+}
+
+// UserInfo сведения о работнике по токену доступа, выданному по коду авторизации
+func (provider *Provider) UserInfo(ctx context.Context, accessToken string) (info UserInfo, err error) {
+	ctx, span := tracing.Start(ctx, "oidc.UserInfo")
+	defer tracing.End(span, &err)
+
+	var claims auth.TokenClaims
+	var parser = jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithExpirationRequired(), jwt.WithIssuer(provider.issuer))
+	_, err = parser.ParseWithClaims(accessToken, &claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return provider.keys.PublicKey(ctx, kid)
+	})
+	if err != nil {
+		return UserInfo{}, fmt.Errorf("%w: %w", auth.ErrInvalidCredentials, err)
+	}
+	employeeId, ok := auth.EmployeeId(claims.Subject)
+	if !ok || !contains(claims.Scope, ScopeOpenId) {
+		return UserInfo{}, fmt.Errorf("%w: token was not issued to an employee", auth.ErrInvalidCredentials)
+	}
+	entity, err := provider.activeEmployee(ctx, employeeId)
+	if err != nil {
+		return UserInfo{}, err
+	}
+	return provider.userInfo(ctx, entity, claims.Scope)
+}
+
+// userInfo сведения о работнике, разрешённые scope
+func (provider *Provider) userInfo(ctx context.Context, entity employee.Entity, scope string) (UserInfo, error) {
+	var info = UserInfo{Subject: auth.EmployeeSubject(entity.Id)}
+	if contains(scope, ScopeProfile) {
+		info.Name = entity.Name
+	}
+	if contains(scope, ScopeRoles) {
+		roles, err := provider.roles.FindByEmployeeId(ctx, entity.Id)
+		if err != nil {
+			return UserInfo{}, fmt.Errorf("error finding employee roles: %w", err)
+		}
+		info.Roles = make([]string, len(roles))
+		for i, found := range roles {
+			info.Roles[i] = found.Name
+		}
+	}
+	return info, nil
+}
+
+// activeEmployee работник, которому можно выдать токены: уволенные работники токенов не получают
+func (provider *Provider) activeEmployee(ctx context.Context, id int64) (employee.Entity, error) {
+	entity, err := provider.employees.FindById(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return employee.Entity{}, Error{Code: ErrInvalidGrant, Description: fmt.Sprintf("employee %d not found", id)}
+	}
+	if err != nil {
+		return employee.Entity{}, fmt.Errorf("error finding employee: %w", err)
+	}
+	if !entity.Active {
+		return employee.Entity{}, Error{Code: ErrInvalidGrant, Description: fmt.Sprintf("employee %d is not active", id)}
+	}
+	return entity, nil
+}
+
+func (provider *Provider) findClient(ctx context.Context, clientId string) (ClientEntity, error) {
+	client, err := provider.store.FindClient(ctx, clientId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ClientEntity{}, Error{Code: ErrInvalidClient, Description: "unknown client"}
+	}
+	if err != nil {
+		return ClientEntity{}, fmt.Errorf("error finding oidc client: %w", err)
+	}
+	return client, nil
+}
+
+// authenticateClient проверка секрета конфиденциального клиента; публичный клиент передаёт только client_id
+func (provider *Provider) authenticateClient(ctx context.Context, clientId string, secret string) (ClientEntity, error) {
+	client, err := provider.findClient(ctx, clientId)
+	if err != nil {
+		return ClientEntity{}, err
+	}
+	if !client.Public() && subtle.ConstantTimeCompare([]byte(hash(secret)), []byte(client.SecretHash)) != 1 {
+		return ClientEntity{}, Error{Code: ErrInvalidClient, Description: "invalid client credentials"}
+	}
+	return client, nil
+}
+
+// verifyChallenge проверка PKCE (RFC 7636): BASE64URL(SHA-256(code_verifier)) == code_challenge
+func verifyChallenge(verifier string, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	var sum = sha256.Sum256([]byte(verifier))
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(challenge)) == 1
+}
+
+func randomString(size int) (string, error) {
+	var random = make([]byte, size)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("error generating random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+func hash(value string) string {
+	var sum = sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
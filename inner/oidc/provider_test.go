@@ -0,0 +1,427 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"idm/inner/auth"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/memory"
+	"idm/inner/migration"
+	"idm/inner/role"
+	"idm/inner/validator"
+	"idm/inner/web"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testIssuer     = "https://idm.example.com"
+	testSigningKey = "session-signing-key"
+	testRedirect   = "https://app.example.com/callback"
+	testVerifier   = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+type employeeRepo interface {
+	Employees
+	Save(ctx context.Context, entity *employee.Entity) (int64, error)
+	SetActive(ctx context.Context, ids []int64, active bool) error
+}
+
+type roleRepo interface {
+	Roles
+	Save(ctx context.Context, entity *role.Entity) (int64, error)
+	AssignTx(ctx context.Context, employeeId int64, roleIds []int64) error
+}
+
+// fixture хранилище провайдера вместе с работниками и ролями: в базе данных коды ссылаются на работников
+type fixture struct {
+	store     Store
+	employees employeeRepo
+	roles     roleRepo
+}
+
+func newMemoryFixture(t *testing.T) fixture {
+	var db = memory.NewDB()
+	return fixture{store: NewMemoryStore(), employees: employee.NewEmployeeMemoryRepository(db), roles: role.NewRoleMemoryRepository(db)}
+}
+
+func newDbFixture(t *testing.T) fixture {
+	db, err := database.ConnectDbWithCfg(common.Config{DbDriverName: database.DriverSQLite, Dsn: filepath.Join(t.TempDir(), "idm.db")})
+	if err != nil {
+		t.Fatalf("error connecting to db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err = migration.OnStartup(context.Background(), db, common.MigrationsAuto); err != nil {
+		t.Fatalf("error applying migrations: %v", err)
+	}
+	return fixture{store: NewDbStore(db), employees: employee.NewEmployeeRepository(db), roles: role.NewRoleRepository(db)}
+}
+
+// testProvider провайдер с маршрутами на сервере; маршрут "/api/v1/employees" закрыт аутентификацией
+type testProvider struct {
+	fixture
+	server   *web.Server
+	provider *Provider
+	keys     *Keys
+	now      time.Time
+}
+
+func newTestProvider(t *testing.T, fix fixture) *testProvider {
+	var tp = &testProvider{fixture: fix, server: web.NewServer(), now: time.Now().UTC()}
+	tp.keys = NewKeys(fix.store, 24*time.Hour)
+	tp.keys.now = func() time.Time { return tp.now }
+	if err := tp.keys.Rotate(context.Background()); err != nil {
+		t.Fatalf("error rotating keys: %v", err)
+	}
+	tp.provider = NewProvider(fix.store, tp.keys, fix.employees, fix.roles, auth.NewTokenVerifier(testSigningKey),
+		validator.NewRequestValidator(), testIssuer, time.Hour)
+	tp.provider.now = func() time.Time { return tp.now }
+
+	tp.server.GroupApiV1.Use(auth.Middleware(map[string]auth.Authenticator{
+		auth.SchemeBearer: auth.NewTokenVerifier(testSigningKey).WithKeys(testIssuer, tp.keys),
+	}))
+	NewController(tp.server, tp.provider, tp.keys).RegisterRoutes()
+	tp.server.GroupApiV1.Get("/employees", func(c *fiber.Ctx) { c.SendStatus(fiber.StatusOK) })
+	tp.server.GroupApiV1.Post("/employees", func(c *fiber.Ctx) { c.SendStatus(fiber.StatusOK) })
+	return tp
+}
+
+func (tp *testProvider) employee(t *testing.T, name string, roles ...string) int64 {
+	var ctx = context.Background()
+	id, err := tp.employees.Save(ctx, &employee.Entity{Name: name})
+	if err != nil {
+		t.Fatalf("error saving employee: %v", err)
+	}
+	var roleIds []int64
+	for _, roleName := range roles {
+		roleId, err := tp.roles.Save(ctx, &role.Entity{Name: roleName})
+		if err != nil {
+			t.Fatalf("error saving role: %v", err)
+		}
+		roleIds = append(roleIds, roleId)
+	}
+	if err = tp.roles.AssignTx(ctx, id, roleIds); err != nil {
+		t.Fatalf("error assigning roles: %v", err)
+	}
+	return id
+}
+
+// session сессия работника, подписанная ключом AUTH_SIGNING_KEY
+func (tp *testProvider) session(t *testing.T, employeeId int64) string {
+	var claims = auth.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   auth.EmployeeSubject(employeeId),
+		IssuedAt:  jwt.NewNumericDate(tp.now),
+		ExpiresAt: jwt.NewNumericDate(tp.now.Add(time.Hour)),
+	}}
+	token, err := auth.SignToken(testSigningKey, claims)
+	if err != nil {
+		t.Fatalf("error signing session: %v", err)
+	}
+	return token
+}
+
+func (tp *testProvider) client(t *testing.T, req ClientRequest) CreatedClient {
+	created, err := tp.provider.CreateClient(context.Background(), req)
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	return created
+}
+
+func (tp *testProvider) do(t *testing.T, req *http.Request) (*http.Response, []byte) {
+	resp, err := tp.server.App.Test(req)
+	if err != nil {
+		t.Fatalf("error sending request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return resp, body
+}
+
+// authorize запрос авторизации с сессией session; в ответе - параметры перенаправления
+func (tp *testProvider) authorize(t *testing.T, clientId string, redirectUri string, session string) (*http.Response, url.Values) {
+	var sum = sha256.Sum256([]byte(testVerifier))
+	var query = url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientId},
+		"redirect_uri":          {redirectUri},
+		"scope":                 {"openid profile roles"},
+		"state":                 {"xyz"},
+		"nonce":                 {"n-0S6"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+	var req = httptest.NewRequest(fiber.MethodGet, PathAuthorize+"?"+query.Encode(), nil)
+	if session != "" {
+		req.AddCookie(&http.Cookie{Name: auth.SessionCookie, Value: session})
+	}
+	resp, _ := tp.do(t, req)
+	location, err := url.Parse(resp.Header.Get(fiber.HeaderLocation))
+	if err != nil {
+		t.Fatalf("error parsing location: %v", err)
+	}
+	return resp, location.Query()
+}
+
+func (tp *testProvider) token(t *testing.T, form url.Values, clientId string, secret string) (*http.Response, []byte) {
+	var req = httptest.NewRequest(fiber.MethodPost, PathToken, strings.NewReader(form.Encode()))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+	if secret != "" {
+		req.SetBasicAuth(url.QueryEscape(clientId), url.QueryEscape(secret))
+	}
+	return tp.do(t, req)
+}
+
+func (tp *testProvider) bearer(t *testing.T, method string, path string, token string) *http.Response {
+	var req = httptest.NewRequest(method, path, nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	resp, _ := tp.do(t, req)
+	return resp
+}
+
+// jwks открытые ключи, опубликованные провайдером
+func (tp *testProvider) jwks(t *testing.T) map[string]*rsa.PublicKey {
+	_, body := tp.do(t, httptest.NewRequest(fiber.MethodGet, PathJwks, nil))
+	var jwks Jwks
+	if err := json.Unmarshal(body, &jwks); err != nil {
+		t.Fatalf("error decoding jwks: %v", err)
+	}
+	var keys = make(map[string]*rsa.PublicKey)
+	for _, jwk := range jwks.Keys {
+		n, _ := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, _ := base64.RawURLEncoding.DecodeString(jwk.E)
+		keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys
+}
+
+func TestProvider(t *testing.T) {
+	var fixtures = map[string]func(t *testing.T) fixture{
+		"memory":   newMemoryFixture,
+		"database": newDbFixture,
+	}
+	for name, newFixture := range fixtures {
+		t.Run(name, func(t *testing.T) {
+			var spa = ClientRequest{Name: "portal", Public: true, RedirectUris: []string{testRedirect},
+				GrantTypes: []string{GrantAuthorizationCode}}
+
+			t.Run("should issue tokens by authorization code with PKCE", func(t *testing.T) {
+				a := assert.New(t)
+				var tp = newTestProvider(t, newFixture(t))
+				var employeeId = tp.employee(t, "Ivan Petrov", "admin")
+				var client = tp.client(t, spa)
+				a.Empty(client.ClientSecret)
+
+				resp, params := tp.authorize(t, client.ClientId, testRedirect, tp.session(t, employeeId))
+				a.Equal(fiber.StatusFound, resp.StatusCode)
+				a.Equal("xyz", params.Get("state"))
+				a.Equal(testIssuer, params.Get("iss"))
+				a.NotEmpty(params.Get("code"))
+
+				var form = url.Values{
+					"grant_type":    {GrantAuthorizationCode},
+					"code":          {params.Get("code")},
+					"redirect_uri":  {testRedirect},
+					"code_verifier": {testVerifier},
+					"client_id":     {client.ClientId},
+				}
+				resp, body := tp.token(t, form, "", "")
+				a.Equal(fiber.StatusOK, resp.StatusCode, string(body))
+				a.Equal("no-store", resp.Header.Get(fiber.HeaderCacheControl))
+				var tokens TokenResponse
+				a.Nil(json.Unmarshal(body, &tokens))
+				a.Equal("Bearer", tokens.TokenType)
+				a.Equal(int64(3600), tokens.ExpiresIn)
+
+				// ID-токен проверяется ключом из JWKS, как это делает клиент
+				var keys = tp.jwks(t)
+				var claims IdTokenClaims
+				_, err := jwt.ParseWithClaims(tokens.IdToken, &claims, func(token *jwt.Token) (any, error) {
+					return keys[token.Header["kid"].(string)], nil
+				}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithIssuer(testIssuer), jwt.WithAudience(client.ClientId))
+				a.Nil(err)
+				a.Equal(auth.EmployeeSubject(employeeId), claims.Subject)
+				a.Equal("n-0S6", claims.Nonce)
+				a.Equal("Ivan Petrov", claims.Name)
+				a.Equal([]string{"admin"}, claims.Roles)
+				a.NotNil(claims.AuthTime)
+
+				var req = httptest.NewRequest(fiber.MethodGet, PathUserInfo, nil)
+				req.Header.Set(fiber.HeaderAuthorization, "Bearer "+tokens.AccessToken)
+				resp, body = tp.do(t, req)
+				a.Equal(fiber.StatusOK, resp.StatusCode)
+				var info UserInfo
+				a.Nil(json.Unmarshal(body, &info))
+				a.Equal(UserInfo{Subject: auth.EmployeeSubject(employeeId), Name: "Ivan Petrov", Roles: []string{"admin"}}, info)
+
+				// код одноразовый
+				resp, body = tp.token(t, form, "", "")
+				a.Equal(fiber.StatusBadRequest, resp.StatusCode)
+				a.Contains(string(body), ErrInvalidGrant)
+			})
+
+			t.Run("should reject invalid authorization requests", func(t *testing.T) {
+				a := assert.New(t)
+				var tp = newTestProvider(t, newFixture(t))
+				var employeeId = tp.employee(t, "Ivan Petrov")
+				var client = tp.client(t, spa)
+
+				// на незарегистрированный адрес не перенаправляем
+				resp, _ := tp.authorize(t, client.ClientId, "https://evil.example.com/callback", tp.session(t, employeeId))
+				a.Equal(fiber.StatusBadRequest, resp.StatusCode)
+				resp, _ = tp.authorize(t, "unknown", testRedirect, tp.session(t, employeeId))
+				a.Equal(fiber.StatusUnauthorized, resp.StatusCode)
+
+				_, params := tp.authorize(t, client.ClientId, testRedirect, "")
+				a.Equal(ErrLoginRequired, params.Get("error"))
+				a.Equal("xyz", params.Get("state"))
+
+				a.Nil(tp.employees.SetActive(context.Background(), []int64{employeeId}, false))
+				_, params = tp.authorize(t, client.ClientId, testRedirect, tp.session(t, employeeId))
+				a.Equal(ErrAccessDenied, params.Get("error"))
+			})
+
+			t.Run("should reject code with wrong verifier or after employee deactivation", func(t *testing.T) {
+				a := assert.New(t)
+				var tp = newTestProvider(t, newFixture(t))
+				var employeeId = tp.employee(t, "Ivan Petrov")
+				var client = tp.client(t, spa)
+				var exchange = func(verifier string) (*http.Response, []byte) {
+					_, params := tp.authorize(t, client.ClientId, testRedirect, tp.session(t, employeeId))
+					return tp.token(t, url.Values{
+						"grant_type":    {GrantAuthorizationCode},
+						"code":          {params.Get("code")},
+						"redirect_uri":  {testRedirect},
+						"code_verifier": {verifier},
+						"client_id":     {client.ClientId},
+					}, "", "")
+				}
+
+				resp, body := exchange(strings.Repeat("a", 43))
+				a.Equal(fiber.StatusBadRequest, resp.StatusCode)
+				a.Contains(string(body), "code_verifier")
+
+				_, params := tp.authorize(t, client.ClientId, testRedirect, tp.session(t, employeeId))
+				a.Nil(tp.employees.SetActive(context.Background(), []int64{employeeId}, false))
+				resp, body = tp.token(t, url.Values{
+					"grant_type":    {GrantAuthorizationCode},
+					"code":          {params.Get("code")},
+					"redirect_uri":  {testRedirect},
+					"code_verifier": {testVerifier},
+					"client_id":     {client.ClientId},
+				}, "", "")
+				a.Equal(fiber.StatusBadRequest, resp.StatusCode)
+				a.Contains(string(body), "is not active")
+			})
+
+			t.Run("should issue client credentials token accepted by api", func(t *testing.T) {
+				a := assert.New(t)
+				var tp = newTestProvider(t, newFixture(t))
+				var client = tp.client(t, ClientRequest{Name: "reports", GrantTypes: []string{GrantClientCredentials},
+					Scopes: []string{"employees:read"}})
+				a.NotEmpty(client.ClientSecret)
+
+				var form = url.Values{"grant_type": {GrantClientCredentials}}
+				resp, body := tp.token(t, form, client.ClientId, client.ClientSecret)
+				a.Equal(fiber.StatusOK, resp.StatusCode, string(body))
+				var tokens TokenResponse
+				a.Nil(json.Unmarshal(body, &tokens))
+				a.Equal("employees:read", tokens.Scope)
+				a.Empty(tokens.IdToken)
+
+				a.Equal(fiber.StatusOK, tp.bearer(t, fiber.MethodGet, "/api/v1/employees", tokens.AccessToken).StatusCode)
+				a.Equal(fiber.StatusForbidden, tp.bearer(t, fiber.MethodPost, "/api/v1/employees", tokens.AccessToken).StatusCode)
+				// токен клиента не даёт сведений о работнике
+				a.Equal(fiber.StatusUnauthorized, tp.bearer(t, fiber.MethodGet, PathUserInfo, tokens.AccessToken).StatusCode)
+
+				resp, _ = tp.token(t, form, client.ClientId, "wrong")
+				a.Equal(fiber.StatusUnauthorized, resp.StatusCode)
+				resp, body = tp.token(t, url.Values{"grant_type": {GrantClientCredentials}, "scope": {"roles:read"}},
+					client.ClientId, client.ClientSecret)
+				a.Equal(fiber.StatusBadRequest, resp.StatusCode)
+				a.Contains(string(body), ErrInvalidScope)
+			})
+
+			t.Run("should validate client registration", func(t *testing.T) {
+				a := assert.New(t)
+				var tp = newTestProvider(t, newFixture(t))
+				var ctx = context.Background()
+
+				_, err := tp.provider.CreateClient(ctx, ClientRequest{Name: "portal", GrantTypes: []string{GrantAuthorizationCode}})
+				a.ErrorAs(err, &common.RequestValidationError{})
+				_, err = tp.provider.CreateClient(ctx, ClientRequest{Name: "cli", Public: true, GrantTypes: []string{GrantClientCredentials}})
+				a.ErrorAs(err, &common.RequestValidationError{})
+				_, err = tp.provider.CreateClient(ctx, ClientRequest{Name: "reports", GrantTypes: []string{GrantClientCredentials},
+					Scopes: []string{"employees"}})
+				a.ErrorAs(err, &common.RequestValidationError{})
+				_, err = tp.provider.CreateClient(ctx, ClientRequest{Name: "reports", GrantTypes: []string{"password"}})
+				a.ErrorAs(err, &common.RequestValidationError{})
+				// клиент получает разрешения API только из разрешений регистрирующего его вызывающего
+				var narrowCtx = auth.WithPrincipal(ctx, auth.Principal{Subject: "service-account:ci", Scopes: []string{"oidc-clients:write", "employees:read"}})
+				_, err = tp.provider.CreateClient(narrowCtx, ClientRequest{Name: "reports", GrantTypes: []string{GrantClientCredentials},
+					Scopes: []string{auth.AllScopes}})
+				a.ErrorAs(err, &common.ForbiddenError{})
+				reports, err := tp.provider.CreateClient(narrowCtx, ClientRequest{Name: "reports", GrantTypes: []string{GrantClientCredentials},
+					Scopes: []string{"employees:read"}})
+				a.Nil(err)
+				a.Nil(tp.provider.DeleteClient(ctx, reports.Id))
+
+				var client = tp.client(t, spa)
+				clients, err := tp.provider.GetClients(ctx)
+				a.Nil(err)
+				a.Equal([]ClientResponse{client.ClientResponse}, clients)
+				a.Nil(tp.provider.DeleteClient(ctx, client.Id))
+				a.ErrorAs(tp.provider.DeleteClient(ctx, client.Id), &common.NotFoundError{})
+			})
+
+			t.Run("should keep previous key in jwks after rotation", func(t *testing.T) {
+				a := assert.New(t)
+				var tp = newTestProvider(t, newFixture(t))
+				var client = tp.client(t, ClientRequest{Name: "reports", GrantTypes: []string{GrantClientCredentials},
+					Scopes: []string{"employees:read"}})
+				var issue = func() string {
+					_, body := tp.token(t, url.Values{"grant_type": {GrantClientCredentials}}, client.ClientId, client.ClientSecret)
+					var tokens TokenResponse
+					a.Nil(json.Unmarshal(body, &tokens))
+					return tokens.AccessToken
+				}
+				var oldToken = issue()
+				a.Len(tp.jwks(t), 1)
+
+				// ротация в пределах периода ничего не меняет
+				a.Nil(tp.keys.Rotate(context.Background()))
+				a.Len(tp.jwks(t), 1)
+
+				tp.now = tp.now.Add(24 * time.Hour)
+				a.Nil(tp.keys.Rotate(context.Background()))
+				a.Len(tp.jwks(t), 2)
+				var newToken = issue()
+				oldParsed, _, _ := jwt.NewParser().ParseUnverified(oldToken, &auth.TokenClaims{})
+				newParsed, _, _ := jwt.NewParser().ParseUnverified(newToken, &auth.TokenClaims{})
+				a.NotEqual(oldParsed.Header["kid"], newParsed.Header["kid"])
+
+				// через период после ротации прежний ключ удаляется
+				tp.now = tp.now.Add(24*time.Hour + time.Second)
+				a.Nil(tp.keys.Rotate(context.Background()))
+				var keys = tp.jwks(t)
+				a.Len(keys, 2)
+				a.NotContains(keys, oldParsed.Header["kid"])
+			})
+		})
+	}
+}
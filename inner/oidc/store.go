@@ -0,0 +1,245 @@
+package oidc
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"idm/inner/common"
+	"idm/inner/database"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Store хранилище клиентов, ключей подписи и кодов авторизации провайдера.
+// Методы поиска одной записи возвращают sql.ErrNoRows, если запись не найдена.
+type Store interface {
+	CreateClient(ctx context.Context, client *ClientEntity) (id int64, err error)
+	FindClients(ctx context.Context) ([]ClientEntity, error)
+	FindClient(ctx context.Context, clientId string) (ClientEntity, error)
+	DeleteClient(ctx context.Context, id int64) (deleted bool, err error)
+
+	SaveKey(ctx context.Context, key *SigningKeyEntity) error
+	FindKeys(ctx context.Context) ([]SigningKeyEntity, error)
+	// DeleteKeysBefore удаляет ключи, созданные раньше before
+	DeleteKeysBefore(ctx context.Context, before time.Time) (deleted int64, err error)
+
+	SaveCode(ctx context.Context, code *CodeEntity) error
+	// ConsumeCode удаляет код и возвращает его: повторный обмен того же кода получит sql.ErrNoRows
+	ConsumeCode(ctx context.Context, hash string) (CodeEntity, error)
+	DeleteExpiredCodes(ctx context.Context, now time.Time) (deleted int64, err error)
+}
+
+// DbStore хранение в таблицах oidc_client, oidc_signing_key и oidc_authorization_code
+type DbStore struct {
+	db *sqlx.DB
+}
+
+func NewDbStore(db *sqlx.DB) *DbStore {
+	return &DbStore{db: db}
+}
+
+// conn транзакция из контекста или подключение к базе данных
+func (store *DbStore) conn(ctx context.Context) database.Executor {
+	return database.ConnFor(ctx, store.db, "oidc")
+}
+
+func (store *DbStore) CreateClient(ctx context.Context, client *ClientEntity) (id int64, err error) {
+	query := `INSERT INTO oidc_client (client_id, name, secret_hash, redirect_uris, grant_types, scopes, create_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	err = store.conn(ctx).GetContext(ctx, &id, query,
+		client.ClientId, client.Name, client.SecretHash, client.RedirectUris, client.GrantTypes, client.Scopes, client.Create)
+	return id, database.TranslateError(err)
+}
+
+func (store *DbStore) FindClients(ctx context.Context) (clients []ClientEntity, err error) {
+	err = store.conn(ctx).SelectContext(ctx, &clients, "SELECT * FROM oidc_client ORDER BY id")
+	return clients, err
+}
+
+func (store *DbStore) FindClient(ctx context.Context, clientId string) (client ClientEntity, err error) {
+	err = store.conn(ctx).GetContext(ctx, &client, "SELECT * FROM oidc_client WHERE client_id = $1", clientId)
+	return client, err
+}
+
+func (store *DbStore) DeleteClient(ctx context.Context, id int64) (bool, error) {
+	result, err := store.conn(ctx).ExecContext(ctx, "DELETE FROM oidc_client WHERE id = $1", id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+func (store *DbStore) SaveKey(ctx context.Context, key *SigningKeyEntity) error {
+	query := "INSERT INTO oidc_signing_key (kid, private_key, create_at) VALUES ($1, $2, $3)"
+	_, err := store.conn(ctx).ExecContext(ctx, query, key.Kid, key.PrivateKey, key.Create)
+	return err
+}
+
+func (store *DbStore) FindKeys(ctx context.Context) (keys []SigningKeyEntity, err error) {
+	err = store.conn(ctx).SelectContext(ctx, &keys, "SELECT * FROM oidc_signing_key")
+	return keys, err
+}
+
+func (store *DbStore) DeleteKeysBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := store.conn(ctx).ExecContext(ctx, "DELETE FROM oidc_signing_key WHERE create_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (store *DbStore) SaveCode(ctx context.Context, code *CodeEntity) error {
+	query := `INSERT INTO oidc_authorization_code
+		(hash, client_id, employee_id, redirect_uri, scope, nonce, code_challenge, auth_time, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err := store.conn(ctx).ExecContext(ctx, query, code.Hash, code.ClientId, code.EmployeeId, code.RedirectUri,
+		code.Scope, code.Nonce, code.CodeChallenge, code.AuthTime, code.ExpiresAt)
+	return err
+}
+
+func (store *DbStore) ConsumeCode(ctx context.Context, hash string) (code CodeEntity, err error) {
+	// удаление с RETURNING: из двух одновременных обменов одного кода успешен только один
+	err = store.conn(ctx).GetContext(ctx, &code, "DELETE FROM oidc_authorization_code WHERE hash = $1 RETURNING *", hash)
+	return code, err
+}
+
+func (store *DbStore) DeleteExpiredCodes(ctx context.Context, now time.Time) (int64, error) {
+	result, err := store.conn(ctx).ExecContext(ctx, "DELETE FROM oidc_authorization_code WHERE expires_at < $1", now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// MemoryStore хранение в памяти процесса (STORAGE=memory) и для тестов
+type MemoryStore struct {
+	mutex   sync.Mutex
+	clients map[int64]ClientEntity
+	lastId  int64
+	keys    map[string]SigningKeyEntity
+	codes   map[string]CodeEntity
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		clients: make(map[int64]ClientEntity),
+		keys:    make(map[string]SigningKeyEntity),
+		codes:   make(map[string]CodeEntity),
+	}
+}
+
+func (store *MemoryStore) CreateClient(ctx context.Context, client *ClientEntity) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for _, existing := range store.clients {
+		if existing.ClientId == client.ClientId {
+			return 0, common.AlreadyExistsError{Message: fmt.Sprintf("oidc_client already exists: client_id %s", client.ClientId)}
+		}
+	}
+	store.lastId++
+	var row = *client
+	row.Id = store.lastId
+	store.clients[row.Id] = row
+	return row.Id, nil
+}
+
+func (store *MemoryStore) FindClients(ctx context.Context) ([]ClientEntity, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var clients []ClientEntity
+	for _, id := range slices.Sorted(maps.Keys(store.clients)) {
+		clients = append(clients, store.clients[id])
+	}
+	return clients, nil
+}
+
+func (store *MemoryStore) FindClient(ctx context.Context, clientId string) (ClientEntity, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for _, client := range store.clients {
+		if client.ClientId == clientId {
+			return client, nil
+		}
+	}
+	return ClientEntity{}, sql.ErrNoRows
+}
+
+func (store *MemoryStore) DeleteClient(ctx context.Context, id int64) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	_, ok := store.clients[id]
+	delete(store.clients, id)
+	return ok, nil
+}
+
+func (store *MemoryStore) SaveKey(ctx context.Context, key *SigningKeyEntity) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.keys[key.Kid] = *key
+	return nil
+}
+
+func (store *MemoryStore) FindKeys(ctx context.Context) ([]SigningKeyEntity, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return slices.Collect(maps.Values(store.keys)), nil
+}
+
+func (store *MemoryStore) DeleteKeysBefore(ctx context.Context, before time.Time) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var deleted int64
+	for kid, key := range store.keys {
+		if key.Create.Before(before) {
+			delete(store.keys, kid)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (store *MemoryStore) SaveCode(ctx context.Context, code *CodeEntity) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.codes[code.Hash] = *code
+	return nil
+}
+
+func (store *MemoryStore) ConsumeCode(ctx context.Context, hash string) (CodeEntity, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	code, ok := store.codes[hash]
+	if !ok {
+		return CodeEntity{}, sql.ErrNoRows
+	}
+	delete(store.codes, hash)
+	return code, nil
+}
+
+func (store *MemoryStore) DeleteExpiredCodes(ctx context.Context, now time.Time) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var deleted int64
+	for hash, code := range store.codes {
+		if code.ExpiresAt.Before(now) {
+			delete(store.codes, hash)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,163 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"idm/inner/common"
+	"idm/inner/web"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber"
+)
+
+// заголовки ответа с состоянием ограничения (IETF draft "RateLimit header fields for HTTP")
+const (
+	HeaderLimit     = "RateLimit-Limit"
+	HeaderRemaining = "RateLimit-Remaining"
+	HeaderReset     = "RateLimit-Reset"
+	HeaderPolicy    = "RateLimit-Policy"
+)
+
+// имя правила для запросов, к которым не подошло ни одно ограничение маршрута
+const defaultRule = "default"
+
+// Limits ограничения частоты запросов: значение по умолчанию и переопределения для маршрутов.
+// Ключ переопределения - метод (или * для любого метода) и префикс пути, например "DELETE /api/v1/employees/ids".
+type Limits struct {
+	Default common.RateLimit
+	Routes  map[string]common.RateLimit
+}
+
+// For ограничение для запроса и имя правила, по которому считаются запросы: берётся переопределение
+// с самым длинным подходящим префиксом пути, а при одинаковых префиксах точный метод важнее "*"
+func (limits Limits) For(method string, path string) (rule string, limit common.RateLimit) {
+	rule, limit = defaultRule, limits.Default
+	var matched = -1
+	for route, routeLimit := range limits.Routes {
+		routeMethod, prefix, ok := strings.Cut(route, " ")
+		if !ok || !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		var exact = strings.EqualFold(routeMethod, method)
+		if !exact && routeMethod != "*" {
+			continue
+		}
+		// точное совпадение метода добавляет к длине префикса половину символа
+		var weight = 2 * len(prefix)
+		if exact {
+			weight++
+		}
+		if weight > matched {
+			matched = weight
+			rule, limit = route, routeLimit
+		}
+	}
+	return rule, limit
+}
+
+// Middleware ограничение частоты запросов каждого клиента
+func Middleware(store Store, limits Limits) fiber.Handler {
+	return MiddlewareFrom(store, func() Limits { return limits })
+}
+
+// MiddlewareFrom middleware Middleware с ограничениями, которые читаются при каждом запросе (перезагружаемая конфигурация).
+// Клиент - аутентифицированный вызывающий (см. web.SetCaller), а без аутентификации - IP-адрес.
+// Состояние ограничения возвращается в заголовках RateLimit-*, а превышение - ответом 429 с заголовком Retry-After.
+// Если хранилище счётчиков недоступно, запрос пропускается: ограничение не должно останавливать весь API.
+func MiddlewareFrom(store Store, limits func() Limits) fiber.Handler {
+	return func(c *fiber.Ctx) {
+		rule, limit := limits().For(c.Method(), c.Path())
+		if limit.Unlimited() {
+			c.Next()
+			return
+		}
+
+		var ctx = web.Context(c)
+		res, err := store.Take(ctx, rule+" "+client(c), limit, time.Now())
+		if err != nil {
+			slog.WarnContext(ctx, "rate limit store error, request allowed", "error", err)
+			c.Next()
+			return
+		}
+
+		c.Set(HeaderLimit, strconv.Itoa(limit.Requests))
+		c.Set(HeaderRemaining, strconv.Itoa(res.Remaining))
+		c.Set(HeaderReset, seconds(res.Reset))
+		c.Set(HeaderPolicy, fmt.Sprintf("%d;w=%s", limit.Requests, seconds(limit.Period)))
+		if !res.Allowed {
+			c.Set(fiber.HeaderRetryAfter, seconds(res.RetryAfter))
+			_ = common.ErrResponse(c, fiber.StatusTooManyRequests,
+				fmt.Sprintf("rate limit of %d requests per %s exceeded, retry after %s seconds", limit.Requests, limit.Period, seconds(res.RetryAfter)))
+			return
+		}
+		c.Next()
+	}
+}
+
+// UnauthorizedFrom ограничение ответов 401 для каждого IP-адреса: регистрируется до аутентификации,
+// чтобы перебор неверных API-ключей и токенов получал 429, не доходя до проверки учётных данных.
+// Маркер забирается только за ответ 401, поэтому запросы с верными учётными данными лимит не расходуют.
+// Ограничение читается при каждом запросе, пустое значение - без ограничения.
+func UnauthorizedFrom(store Store, limit func() common.RateLimit) fiber.Handler {
+	return func(c *fiber.Ctx) {
+		var current = limit()
+		if current.Unlimited() {
+			c.Next()
+			return
+		}
+
+		var ctx = web.Context(c)
+		var key = "unauthorized ip:" + c.IP()
+		res, err := store.Peek(ctx, key, current, time.Now())
+		if err != nil {
+			slog.WarnContext(ctx, "rate limit store error, request allowed", "error", err)
+		} else if !res.Allowed {
+			c.Set(fiber.HeaderRetryAfter, seconds(res.RetryAfter))
+			_ = common.ErrResponse(c, fiber.StatusTooManyRequests,
+				fmt.Sprintf("too many unauthorized requests, retry after %s seconds", seconds(res.RetryAfter)))
+			return
+		}
+
+		c.Next()
+		if c.Fasthttp.Response.StatusCode() != fiber.StatusUnauthorized {
+			return
+		}
+		if _, err = store.Take(ctx, key, current, time.Now()); err != nil {
+			slog.WarnContext(ctx, "rate limit store error, unauthorized request not counted", "error", err)
+		}
+	}
+}
+
+// client ключ клиента для счётчиков
+func client(c *fiber.Ctx) string {
+	if caller := web.Caller(c); caller != "" {
+		return "caller:" + caller
+	}
+	return "ip:" + c.IP()
+}
+
+// seconds длительность в целых секундах с округлением вверх, как в заголовках Retry-After и RateLimit-*
+func seconds(duration time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(duration.Seconds())))
+}
+
+// RunCleanup периодическое удаление полных корзин до отмены контекста
+func RunCleanup(ctx context.Context, store Store, interval time.Duration) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := store.DeleteExpired(ctx, time.Now()); err != nil {
+				slog.ErrorContext(ctx, "error deleting expired rate limit buckets", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"idm/inner/common"
+	"idm/inner/web"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingStore хранилище счётчиков, которое всегда недоступно
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit common.RateLimit, now time.Time) (Result, error) {
+	return Result{}, errors.New("connection refused")
+}
+
+func (failingStore) Peek(ctx context.Context, key string, limit common.RateLimit, now time.Time) (Result, error) {
+	return Result{}, errors.New("connection refused")
+}
+
+func (failingStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	return 0, errors.New("connection refused")
+}
+
+func TestLimitsFor(t *testing.T) {
+	a := assert.New(t)
+	var perMinute = func(requests int) common.RateLimit { return common.RateLimit{Requests: requests, Period: time.Minute} }
+	var limits = Limits{
+		Default: perMinute(600),
+		Routes: map[string]common.RateLimit{
+			"DELETE /api/v1/employees/ids": perMinute(10),
+			"* /api/v1/employees/ids":      perMinute(100),
+			"* /api/v1/employees/import":   perMinute(5),
+		},
+	}
+
+	var tests = []struct {
+		method, path, rule string
+		limit              common.RateLimit
+	}{
+		{fiber.MethodDelete, "/api/v1/employees/ids", "DELETE /api/v1/employees/ids", perMinute(10)},
+		{fiber.MethodGet, "/api/v1/employees/ids", "* /api/v1/employees/ids", perMinute(100)},
+		{fiber.MethodPost, "/api/v1/employees/import", "* /api/v1/employees/import", perMinute(5)},
+		{fiber.MethodGet, "/api/v1/roles", defaultRule, perMinute(600)},
+	}
+	for _, test := range tests {
+		rule, limit := limits.For(test.method, test.path)
+		a.Equal(test.rule, rule, test.method+" "+test.path)
+		a.Equal(test.limit, limit, test.method+" "+test.path)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	a := assert.New(t)
+
+	var newApp = func(store Store) *fiber.App {
+		var app = fiber.New()
+		app.Use(func(c *fiber.Ctx) {
+			if caller := c.Get("X-Test-Caller"); caller != "" {
+				web.SetCaller(c, caller)
+			}
+			c.Next()
+		})
+		app.Use(Middleware(store, Limits{Routes: map[string]common.RateLimit{
+			"DELETE /api/v1/employees/ids": {Requests: 1, Period: time.Minute},
+		}}))
+		app.Delete("/api/v1/employees/ids", func(c *fiber.Ctx) { c.SendString("deleted") })
+		app.Get("/api/v1/employees", func(c *fiber.Ctx) { c.SendString("list") })
+		return app
+	}
+	var request = func(app *fiber.App, method string, path string, caller string) *http.Response {
+		var req = httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-Test-Caller", caller)
+		resp, err := app.Test(req)
+		a.Nil(err)
+		return resp
+	}
+
+	t.Run("should reject requests over limit with rate limit headers", func(t *testing.T) {
+		var app = newApp(NewMemoryStore())
+
+		var first = request(app, fiber.MethodDelete, "/api/v1/employees/ids", "reports")
+		a.Equal(fiber.StatusOK, first.StatusCode)
+		a.Equal("1", first.Header.Get(HeaderLimit))
+		a.Equal("0", first.Header.Get(HeaderRemaining))
+		a.Equal("60", first.Header.Get(HeaderReset))
+		a.Equal("1;w=60", first.Header.Get(HeaderPolicy))
+
+		var second = request(app, fiber.MethodDelete, "/api/v1/employees/ids", "reports")
+		a.Equal(fiber.StatusTooManyRequests, second.StatusCode)
+		a.Equal("60", second.Header.Get(fiber.HeaderRetryAfter))
+
+		var otherCaller = request(app, fiber.MethodDelete, "/api/v1/employees/ids", "billing")
+		a.Equal(fiber.StatusOK, otherCaller.StatusCode)
+
+		var unlimited = request(app, fiber.MethodGet, "/api/v1/employees", "reports")
+		a.Equal(fiber.StatusOK, unlimited.StatusCode)
+		a.Empty(unlimited.Header.Get(HeaderLimit))
+	})
+
+	t.Run("should allow requests when store is unavailable", func(t *testing.T) {
+		var app = newApp(failingStore{})
+
+		for i := 0; i < 3; i++ {
+			a.Equal(fiber.StatusOK, request(app, fiber.MethodDelete, "/api/v1/employees/ids", "reports").StatusCode)
+		}
+	})
+}
+
+func TestUnauthorized(t *testing.T) {
+	a := assert.New(t)
+
+	var newApp = func(store Store) *fiber.App {
+		var app = fiber.New()
+		app.Use(UnauthorizedFrom(store, func() common.RateLimit { return common.RateLimit{Requests: 2, Period: time.Minute} }))
+		app.Get("/api/v1/employees", func(c *fiber.Ctx) {
+			if c.Get(fiber.HeaderAuthorization) != "ApiKey valid" {
+				c.Status(fiber.StatusUnauthorized)
+				return
+			}
+			c.SendString("list")
+		})
+		return app
+	}
+	var request = func(app *fiber.App, key string) *http.Response {
+		var req = httptest.NewRequest(fiber.MethodGet, "/api/v1/employees", nil)
+		req.Header.Set(fiber.HeaderAuthorization, "ApiKey "+key)
+		resp, err := app.Test(req)
+		a.Nil(err)
+		return resp
+	}
+
+	t.Run("should reject requests from address after too many unauthorized responses", func(t *testing.T) {
+		var app = newApp(NewMemoryStore())
+
+		for i := 0; i < 3; i++ {
+			a.Equal(fiber.StatusOK, request(app, "valid").StatusCode, "authorized requests should not be counted")
+		}
+		a.Equal(fiber.StatusUnauthorized, request(app, "bad").StatusCode)
+		a.Equal(fiber.StatusUnauthorized, request(app, "bad").StatusCode)
+
+		var denied = request(app, "bad")
+		a.Equal(fiber.StatusTooManyRequests, denied.StatusCode)
+		a.Equal("30", denied.Header.Get(fiber.HeaderRetryAfter))
+		a.Equal(fiber.StatusTooManyRequests, request(app, "valid").StatusCode)
+	})
+
+	t.Run("should allow requests when store is unavailable", func(t *testing.T) {
+		var app = newApp(failingStore{})
+
+		for i := 0; i < 3; i++ {
+			a.Equal(fiber.StatusUnauthorized, request(app, "bad").StatusCode)
+		}
+	})
+}
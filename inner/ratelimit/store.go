@@ -0,0 +1,195 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"idm/inner/common"
+	"idm/inner/database"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Result результат попытки выполнить запрос
+type Result struct {
+	Allowed bool
+	// сколько запросов ещё можно выполнить подряд
+	Remaining int
+	// через сколько можно повторить отклонённый запрос
+	RetryAfter time.Duration
+	// через сколько ограничение восстановится полностью
+	Reset time.Duration
+}
+
+// Store счётчики запросов клиентов: корзина маркеров (token bucket) на каждый ключ.
+// Корзина вмещает limit.Requests маркеров и наполняется равномерно за limit.Period,
+// каждый разрешённый запрос забирает один маркер.
+type Store interface {
+	// Take забирает маркер из корзины key, если он есть
+	Take(ctx context.Context, key string, limit common.RateLimit, now time.Time) (Result, error)
+	// Peek состояние корзины key без взятия маркера: Allowed - в корзине есть маркер
+	Peek(ctx context.Context, key string, limit common.RateLimit, now time.Time) (Result, error)
+	// DeleteExpired удаляет полные корзины: они ничем не отличаются от отсутствующих
+	DeleteExpired(ctx context.Context, now time.Time) (deleted int64, err error)
+}
+
+// bucket состояние корзины: маркеры на момент updatedAt
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	// к этому моменту корзина наполнится полностью, даже если была пуста
+	expiresAt time.Time
+}
+
+// refill количество маркеров в момент now
+func refill(tokens float64, elapsed time.Duration, limit common.RateLimit) float64 {
+	return math.Min(float64(limit.Requests), tokens+elapsed.Seconds()*rate(limit))
+}
+
+// rate скорость наполнения корзины, маркеров в секунду
+func rate(limit common.RateLimit) float64 {
+	return float64(limit.Requests) / limit.Period.Seconds()
+}
+
+// result результат по количеству маркеров, оставшихся после попытки
+func result(allowed bool, tokens float64, limit common.RateLimit) Result {
+	var perToken = time.Duration(float64(time.Second) / rate(limit))
+	var res = Result{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(limit.Requests) - tokens) * float64(perToken)),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) * float64(perToken))
+	}
+	return res
+}
+
+// MemoryStore счётчики в памяти процесса: ограничение действует для каждого экземпляра сервиса отдельно
+type MemoryStore struct {
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+func (store *MemoryStore) Take(ctx context.Context, key string, limit common.RateLimit, now time.Time) (Result, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var current, ok = store.buckets[key]
+	if !ok {
+		current = &bucket{tokens: float64(limit.Requests), updatedAt: now}
+		store.buckets[key] = current
+	}
+	current.tokens = refill(current.tokens, now.Sub(current.updatedAt), limit)
+	current.updatedAt = now
+	current.expiresAt = now.Add(limit.Period)
+
+	var allowed = current.tokens >= 1
+	if allowed {
+		current.tokens--
+	}
+	return result(allowed, current.tokens, limit), nil
+}
+
+func (store *MemoryStore) Peek(ctx context.Context, key string, limit common.RateLimit, now time.Time) (Result, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var current, ok = store.buckets[key]
+	if !ok {
+		return result(true, float64(limit.Requests), limit), nil
+	}
+	var tokens = refill(current.tokens, now.Sub(current.updatedAt), limit)
+	return result(tokens >= 1, tokens, limit), nil
+}
+
+func (store *MemoryStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var deleted int64
+	for key, current := range store.buckets {
+		if current.expiresAt.Before(now) {
+			delete(store.buckets, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// DbStore счётчики в таблице rate_limit_bucket, общие для всех экземпляров сервиса
+type DbStore struct {
+	db *sqlx.DB
+}
+
+func NewDbStore(db *sqlx.DB) *DbStore {
+	return &DbStore{db: db}
+}
+
+// Take наполнение корзины и взятие маркера одним запросом: параллельные запросы разных экземпляров
+// к одной корзине выполняются по очереди благодаря блокировке строки.
+// Время хранится в секундах Unix, чтобы запрос одинаково работал в PostgreSQL и SQLite.
+func (store *DbStore) Take(ctx context.Context, key string, limit common.RateLimit, now time.Time) (Result, error) {
+	// маркеры в корзине на момент запроса
+	const refilled = `CASE WHEN rate_limit_bucket.tokens + ($3 - rate_limit_bucket.updated_at) * $4 > $2
+		THEN CAST($2 AS double precision)
+		ELSE rate_limit_bucket.tokens + ($3 - rate_limit_bucket.updated_at) * $4 END`
+	var query = `INSERT INTO rate_limit_bucket (key, tokens, allowed, updated_at, expires_at)
+		VALUES ($1, CAST($2 AS double precision) - 1, true, $3, $5)
+		ON CONFLICT (key) DO UPDATE
+		SET tokens = CASE WHEN ` + refilled + ` >= 1 THEN ` + refilled + ` - 1 ELSE ` + refilled + ` END,
+			allowed = ` + refilled + ` >= 1,
+			updated_at = EXCLUDED.updated_at,
+			expires_at = EXCLUDED.expires_at
+		RETURNING tokens, allowed`
+
+	var seconds = float64(now.UnixNano()) / float64(time.Second)
+	var row struct {
+		Tokens  float64 `db:"tokens"`
+		Allowed bool    `db:"allowed"`
+	}
+	// к этому моменту корзина наполнится полностью, даже если была пуста
+	var expiresAt = seconds + limit.Period.Seconds()
+	err := database.ConnFor(ctx, store.db, "rate_limit").
+		GetContext(ctx, &row, query, key, limit.Requests, seconds, rate(limit), expiresAt)
+	if err != nil {
+		return Result{}, err
+	}
+	return result(row.Allowed, row.Tokens, limit), nil
+}
+
+func (store *DbStore) Peek(ctx context.Context, key string, limit common.RateLimit, now time.Time) (Result, error) {
+	var row struct {
+		Tokens    float64 `db:"tokens"`
+		UpdatedAt float64 `db:"updated_at"`
+	}
+	err := database.ConnFor(ctx, store.db, "rate_limit").
+		GetContext(ctx, &row, "SELECT tokens, updated_at FROM rate_limit_bucket WHERE key = $1", key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return result(true, float64(limit.Requests), limit), nil
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	var seconds = float64(now.UnixNano()) / float64(time.Second)
+	var elapsed = time.Duration((seconds - row.UpdatedAt) * float64(time.Second))
+	var tokens = refill(row.Tokens, elapsed, limit)
+	return result(tokens >= 1, tokens, limit), nil
+}
+
+func (store *DbStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	var seconds = float64(now.UnixNano()) / float64(time.Second)
+	res, err := database.ConnFor(ctx, store.db, "rate_limit").
+		ExecContext(ctx, "DELETE FROM rate_limit_bucket WHERE expires_at < $1", seconds)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/migration"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newDbStore(t *testing.T) *DbStore {
+	db, err := database.ConnectDbWithCfg(common.Config{DbDriverName: database.DriverSQLite, Dsn: filepath.Join(t.TempDir(), "idm.db")})
+	if err != nil {
+		t.Fatalf("error connecting to db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err = migration.OnStartup(context.Background(), db, common.MigrationsAuto); err != nil {
+		t.Fatalf("error applying migrations: %v", err)
+	}
+	return NewDbStore(db)
+}
+
+func TestStores(t *testing.T) {
+	var stores = map[string]func(t *testing.T) Store{
+		"memory":   func(t *testing.T) Store { return NewMemoryStore() },
+		"database": func(t *testing.T) Store { return newDbStore(t) },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+			var ctx = context.Background()
+			var limit = common.RateLimit{Requests: 2, Period: 10 * time.Second}
+			var now = time.Unix(1_700_000_000, 0)
+
+			t.Run("should allow burst and refill one token per period/requests", func(t *testing.T) {
+				var store = newStore(t)
+
+				first, err := store.Take(ctx, "client", limit, now)
+				a.Nil(err)
+				a.Equal(Result{Allowed: true, Remaining: 1, Reset: 5 * time.Second}, first)
+				second, err := store.Take(ctx, "client", limit, now)
+				a.Nil(err)
+				a.True(second.Allowed)
+				a.Equal(0, second.Remaining)
+
+				denied, err := store.Take(ctx, "client", limit, now.Add(time.Second))
+				a.Nil(err)
+				a.False(denied.Allowed)
+				a.Equal(4*time.Second, denied.RetryAfter.Round(time.Millisecond))
+
+				other, err := store.Take(ctx, "other", limit, now.Add(time.Second))
+				a.Nil(err)
+				a.True(other.Allowed, "clients should have separate buckets")
+
+				refilled, err := store.Take(ctx, "client", limit, now.Add(5*time.Second))
+				a.Nil(err)
+				a.True(refilled.Allowed)
+				a.Equal(0, refilled.Remaining)
+			})
+
+			t.Run("should peek without taking token", func(t *testing.T) {
+				var store = newStore(t)
+
+				empty, err := store.Peek(ctx, "client", limit, now)
+				a.Nil(err)
+				a.Equal(Result{Allowed: true, Remaining: 2}, empty)
+
+				_, err = store.Take(ctx, "client", limit, now)
+				a.Nil(err)
+				_, err = store.Take(ctx, "client", limit, now)
+				a.Nil(err)
+				denied, err := store.Peek(ctx, "client", limit, now.Add(time.Second))
+				a.Nil(err)
+				a.False(denied.Allowed)
+				a.Equal(4*time.Second, denied.RetryAfter.Round(time.Millisecond))
+
+				refilled, err := store.Peek(ctx, "client", limit, now.Add(5*time.Second))
+				a.Nil(err)
+				a.True(refilled.Allowed)
+				again, err := store.Peek(ctx, "client", limit, now.Add(5*time.Second))
+				a.Nil(err)
+				a.Equal(refilled, again, "peek should not take tokens")
+			})
+
+			t.Run("should delete full buckets", func(t *testing.T) {
+				var store = newStore(t)
+				_, err := store.Take(ctx, "client", limit, now)
+				a.Nil(err)
+
+				deleted, err := store.DeleteExpired(ctx, now.Add(time.Second))
+				a.Nil(err)
+				a.Equal(int64(0), deleted)
+				deleted, err = store.DeleteExpired(ctx, now.Add(limit.Period+time.Second))
+				a.Nil(err)
+				a.Equal(int64(1), deleted)
+			})
+		})
+	}
+}
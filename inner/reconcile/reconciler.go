@@ -0,0 +1,204 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"idm/inner/common"
+	"idm/inner/employee"
+	"strings"
+	"time"
+)
+
+// Transactor выполнение функции в транзакции, переданной через контекст (database.TxManager)
+type Transactor interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// EmployeeService операции employee.Service, через которые применяются изменения
+type EmployeeService interface {
+	GetAll(ctx context.Context, filter employee.Filter) ([]employee.Response, error)
+	SaveBatch(ctx context.Context, reqs []employee.Request, atomic bool) ([]common.BatchItemResult, error)
+	SetActive(ctx context.Context, req employee.RequestSetActive) error
+}
+
+// типы действий сверки
+const (
+	ActionCreate     = "create"
+	ActionUpdate     = "update"
+	ActionDeactivate = "deactivate"
+)
+
+// ErrTooManyDeactivations сверка прервана: деактивировать нужно больше работников, чем разрешено порогом
+var ErrTooManyDeactivations = errors.New("too many deactivations")
+
+// Action действие над работником, вычисленное при сверке
+type Action struct {
+	Type       string `json:"type"`
+	Name       string `json:"name"`
+	EmployeeId int64  `json:"employee_id,omitempty"`
+}
+
+// Report отчёт о сверке: при DryRun действия только вычисляются, но не применяются
+type Report struct {
+	DryRun     bool     `json:"dry_run"`
+	Applied    bool     `json:"applied"`
+	Total      int      `json:"total"`
+	Unchanged  int      `json:"unchanged"`
+	Create     []Action `json:"create"`
+	Update     []Action `json:"update"`
+	Deactivate []Action `json:"deactivate"`
+}
+
+// Reconciler сверка таблицы employee с выгрузкой кадровой системы, которая считается источником истины
+type Reconciler struct {
+	tx        Transactor
+	employees EmployeeService
+	// максимальная доля действующих работников (в процентах), которую можно деактивировать за один запуск
+	maxDeactivatePercent float64
+	now                  func() time.Time
+}
+
+func NewReconciler(tx Transactor, employees EmployeeService, maxDeactivatePercent float64) *Reconciler {
+	return &Reconciler{
+		tx:                   tx,
+		employees:            employees,
+		maxDeactivatePercent: maxDeactivatePercent,
+		now:                  time.Now,
+	}
+}
+
+// Plan вычисление действий без изменения данных.
+// Новые действующие работники создаются, деактивированные ранее, но вернувшиеся в выгрузку - активируются,
+// а отсутствующие в выгрузке или уволенные по ней - деактивируются.
+// Имена сравниваются без учёта регистра, как в ограничении уникальности имени работника.
+func (rec *Reconciler) Plan(ctx context.Context, records []Record) (report Report, err error) {
+	report = Report{
+		DryRun:     true,
+		Total:      len(records),
+		Create:     []Action{},
+		Update:     []Action{},
+		Deactivate: []Action{},
+	}
+
+	var feed = make(map[string]Record, len(records))
+	for i, record := range records {
+		if record.Name == "" {
+			return report, fmt.Errorf("snapshot record %d has empty name", i+1)
+		}
+		if _, ok := feed[strings.ToLower(record.Name)]; ok {
+			return report, fmt.Errorf("snapshot has duplicate employee %s", record.Name)
+		}
+		feed[strings.ToLower(record.Name)] = record
+	}
+
+	current, err := rec.employees.GetAll(ctx, employee.Filter{})
+	if err != nil {
+		return report, err
+	}
+
+	var activeCount int
+	var known = make(map[string]bool, len(current))
+	for _, emp := range current {
+		known[strings.ToLower(emp.Name)] = true
+		if emp.Active {
+			activeCount++
+		}
+
+		record, inFeed := feed[strings.ToLower(emp.Name)]
+		switch {
+		case inFeed && record.IsActive() && !emp.Active:
+			report.Update = append(report.Update, Action{Type: ActionUpdate, Name: emp.Name, EmployeeId: emp.Id})
+		case (!inFeed || !record.IsActive()) && emp.Active:
+			report.Deactivate = append(report.Deactivate, Action{Type: ActionDeactivate, Name: emp.Name, EmployeeId: emp.Id})
+		default:
+			report.Unchanged++
+		}
+	}
+
+	for _, record := range records {
+		if known[strings.ToLower(record.Name)] {
+			continue
+		}
+		if record.IsActive() {
+			report.Create = append(report.Create, Action{Type: ActionCreate, Name: record.Name})
+		} else {
+			// уволенного работника, которого у нас никогда не было, создавать не нужно
+			report.Unchanged++
+		}
+	}
+
+	if activeCount > 0 {
+		var percent = float64(len(report.Deactivate)) * 100 / float64(activeCount)
+		if percent > rec.maxDeactivatePercent {
+			return report, fmt.Errorf("%w: %d of %d active employees (%.1f%%), threshold %.1f%%",
+				ErrTooManyDeactivations, len(report.Deactivate), activeCount, percent, rec.maxDeactivatePercent)
+		}
+	}
+
+	return report, nil
+}
+
+// Run сверка с применением действий через employee.Service, если dryRun == false.
+// Действия вычисляются и применяются в одной транзакции: порог деактивации проверяется по тем же данным,
+// которые изменяются, а ошибка на любом шаге откатывает всю сверку.
+func (rec *Reconciler) Run(ctx context.Context, records []Record, dryRun bool) (report Report, err error) {
+	if dryRun {
+		return rec.Plan(ctx, records)
+	}
+
+	err = rec.tx.Do(ctx, func(ctx context.Context) (err error) {
+		report, err = rec.Plan(ctx, records)
+		if err != nil {
+			return err
+		}
+		report.DryRun = false
+		return rec.apply(ctx, &report)
+	})
+	if err != nil {
+		// созданные работники откачены вместе с остальными изменениями
+		for i := range report.Create {
+			report.Create[i].EmployeeId = 0
+		}
+		return report, err
+	}
+	report.Applied = true
+	return report, nil
+}
+
+// apply применение вычисленных действий; id созданных работников записываются в report
+func (rec *Reconciler) apply(ctx context.Context, report *Report) (err error) {
+	if len(report.Create) > 0 {
+		var now = rec.now()
+		var reqs = make([]employee.Request, 0, len(report.Create))
+		for _, action := range report.Create {
+			reqs = append(reqs, employee.Request{Name: action.Name, Create: now, Update: now})
+		}
+		results, err := rec.employees.SaveBatch(ctx, reqs, true)
+		if err != nil {
+			return fmt.Errorf("error creating employees: %w", err)
+		}
+		for i, result := range results {
+			report.Create[i].EmployeeId = result.Id
+		}
+	}
+
+	if err = rec.setActive(ctx, report.Update, true); err != nil {
+		return fmt.Errorf("error activating employees: %w", err)
+	}
+	if err = rec.setActive(ctx, report.Deactivate, false); err != nil {
+		return fmt.Errorf("error deactivating employees: %w", err)
+	}
+	return nil
+}
+
+func (rec *Reconciler) setActive(ctx context.Context, actions []Action, active bool) error {
+	if len(actions) == 0 {
+		return nil
+	}
+	var ids = make([]int64, 0, len(actions))
+	for _, action := range actions {
+		ids = append(ids, action.EmployeeId)
+	}
+	return rec.employees.SetActive(ctx, employee.RequestSetActive{Ids: ids, Active: active})
+}
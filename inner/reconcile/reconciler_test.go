@@ -0,0 +1,205 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"idm/inner/common"
+	"idm/inner/employee"
+	"idm/inner/memory"
+	"idm/inner/validator"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockEmployeeService struct {
+	mock.Mock
+}
+
+func (srv *MockEmployeeService) GetAll(ctx context.Context, filter employee.Filter) ([]employee.Response, error) {
+	args := srv.Called(filter)
+	return args.Get(0).([]employee.Response), args.Error(1)
+}
+
+func (srv *MockEmployeeService) SaveBatch(ctx context.Context, reqs []employee.Request, atomic bool) ([]common.BatchItemResult, error) {
+	args := srv.Called(reqs, atomic)
+	return args.Get(0).([]common.BatchItemResult), args.Error(1)
+}
+
+func (srv *MockEmployeeService) SetActive(ctx context.Context, req employee.RequestSetActive) error {
+	args := srv.Called(req)
+	return args.Error(0)
+}
+
+// noTx выполнение функции без транзакции: изменения применяет мок
+type noTx struct{}
+
+func (noTx) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// failingDeactivation employee.Service, у которого деактивация завершается ошибкой
+type failingDeactivation struct {
+	*employee.Service
+}
+
+func (srv failingDeactivation) SetActive(ctx context.Context, req employee.RequestSetActive) error {
+	if !req.Active {
+		return errors.New("connection reset")
+	}
+	return srv.Service.SetActive(ctx, req)
+}
+
+func active(value bool) *bool {
+	return &value
+}
+
+var current = []employee.Response{
+	{Id: 1, Name: "Pupkin", Active: true},
+	{Id: 2, Name: "Ivanov", Active: true},
+	{Id: 3, Name: "Petrov", Active: false},
+	{Id: 4, Name: "Sidorov", Active: true},
+}
+
+func TestPlan(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should compute create, update and deactivate actions", func(t *testing.T) {
+		var srv = new(MockEmployeeService)
+		srv.On("GetAll", employee.Filter{}).Return(current, nil)
+		var records = []Record{
+			{Name: "Pupkin"},
+			{Name: "Petrov"},
+			{Name: "Sidorov", Active: active(false)},
+			{Name: "Smirnov"},
+			{Name: "Kuznetsov", Active: active(false)},
+		}
+
+		report, err := NewReconciler(noTx{}, srv, 100).Plan(context.Background(), records)
+
+		a.NoError(err)
+		a.True(report.DryRun)
+		a.Equal([]Action{{Type: ActionCreate, Name: "Smirnov"}}, report.Create)
+		a.Equal([]Action{{Type: ActionUpdate, Name: "Petrov", EmployeeId: 3}}, report.Update)
+		a.Equal([]Action{
+			{Type: ActionDeactivate, Name: "Ivanov", EmployeeId: 2},
+			{Type: ActionDeactivate, Name: "Sidorov", EmployeeId: 4},
+		}, report.Deactivate)
+		a.Equal(2, report.Unchanged)
+	})
+
+	t.Run("should match names regardless of case", func(t *testing.T) {
+		var srv = new(MockEmployeeService)
+		srv.On("GetAll", employee.Filter{}).Return(current, nil)
+		var records = []Record{{Name: "PUPKIN"}, {Name: "ivanov"}, {Name: "petrov"}, {Name: "sidorov"}}
+
+		report, err := NewReconciler(noTx{}, srv, 100).Plan(context.Background(), records)
+
+		a.NoError(err)
+		a.Empty(report.Create)
+		a.Empty(report.Deactivate)
+		a.Equal([]Action{{Type: ActionUpdate, Name: "Petrov", EmployeeId: 3}}, report.Update)
+		a.Equal(3, report.Unchanged)
+	})
+
+	t.Run("should abort when too many deactivations", func(t *testing.T) {
+		var srv = new(MockEmployeeService)
+		srv.On("GetAll", employee.Filter{}).Return(current, nil)
+
+		report, err := NewReconciler(noTx{}, srv, 50).Plan(context.Background(), []Record{{Name: "Pupkin"}})
+
+		a.True(errors.Is(err, ErrTooManyDeactivations))
+		a.Len(report.Deactivate, 2)
+	})
+
+	t.Run("should return error for duplicate record", func(t *testing.T) {
+		var srv = new(MockEmployeeService)
+
+		_, err := NewReconciler(noTx{}, srv, 100).Plan(context.Background(), []Record{{Name: "Pupkin"}, {Name: "Pupkin"}})
+		a.Error(err)
+		_, err = NewReconciler(noTx{}, srv, 100).Plan(context.Background(), []Record{{Name: "Pupkin"}, {Name: "pupkin"}})
+		a.Error(err)
+		srv.AssertNotCalled(t, "GetAll", mock.Anything)
+	})
+}
+
+func TestRun(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should apply actions through employee service", func(t *testing.T) {
+		var srv = new(MockEmployeeService)
+		srv.On("GetAll", employee.Filter{}).Return(current, nil)
+		srv.On("SaveBatch", mock.MatchedBy(func(reqs []employee.Request) bool {
+			return len(reqs) == 1 && reqs[0].Name == "Smirnov"
+		}), true).Return([]common.BatchItemResult{{Index: 0, Id: 10}}, nil)
+		srv.On("SetActive", employee.RequestSetActive{Ids: []int64{3}, Active: true}).Return(nil)
+		srv.On("SetActive", employee.RequestSetActive{Ids: []int64{2, 4}, Active: false}).Return(nil)
+		var records = []Record{{Name: "Pupkin"}, {Name: "Petrov"}, {Name: "Smirnov"}}
+
+		report, err := NewReconciler(noTx{}, srv, 100).Run(context.Background(), records, false)
+
+		a.NoError(err)
+		a.True(report.Applied)
+		a.False(report.DryRun)
+		a.Equal(int64(10), report.Create[0].EmployeeId)
+		srv.AssertExpectations(t)
+	})
+
+	t.Run("should roll back all actions when a step fails", func(t *testing.T) {
+		var db = memory.NewDB()
+		var repo = employee.NewEmployeeMemoryRepository(db)
+		var srv = employee.NewService(repo, validator.NewRequestValidator())
+		var ctx = context.Background()
+		for _, name := range []string{"Pupkin", "Ivanov", "Petrov"} {
+			_, err := repo.Save(ctx, &employee.Entity{Name: name})
+			a.NoError(err)
+		}
+		petrov, err := repo.FindByNamesTx(ctx, []string{"Petrov"})
+		a.NoError(err)
+		a.NoError(repo.SetActive(ctx, []int64{petrov[0].Id}, false))
+
+		// создание и активация проходят, деактивация Ivanov - нет
+		var records = []Record{{Name: "Pupkin"}, {Name: "Petrov"}, {Name: "Smirnov"}}
+		report, err := NewReconciler(db, failingDeactivation{srv}, 100).Run(ctx, records, false)
+
+		a.ErrorContains(err, "error deactivating employees")
+		a.False(report.Applied)
+		a.Zero(report.Create[0].EmployeeId)
+		all, err := srv.GetAll(ctx, employee.Filter{})
+		a.NoError(err)
+		var state = make(map[string]bool, len(all))
+		for _, emp := range all {
+			state[emp.Name] = emp.Active
+		}
+		a.Equal(map[string]bool{"Pupkin": true, "Ivanov": true, "Petrov": false}, state)
+	})
+
+	t.Run("should not apply in dry run", func(t *testing.T) {
+		var srv = new(MockEmployeeService)
+		srv.On("GetAll", employee.Filter{}).Return(current, nil)
+
+		report, err := NewReconciler(noTx{}, srv, 100).Run(context.Background(), []Record{{Name: "Smirnov"}}, true)
+
+		a.NoError(err)
+		a.False(report.Applied)
+		srv.AssertNotCalled(t, "SaveBatch", mock.Anything, mock.Anything)
+		srv.AssertNotCalled(t, "SetActive", mock.Anything)
+	})
+}
+
+func TestReadSnapshotFile(t *testing.T) {
+	a := assert.New(t)
+
+	records, err := ReadSnapshotFile("hr.csv", strings.NewReader("name,active\nPupkin,true\nIvanov,false\nPetrov,\n"))
+	a.NoError(err)
+	a.Equal([]Record{{Name: "Pupkin", Active: active(true)}, {Name: "Ivanov", Active: active(false)}, {Name: "Petrov"}}, records)
+
+	records, err = ReadSnapshotFile("hr.json", strings.NewReader(`[{"name": " Pupkin "}, {"name": "Ivanov", "active": false}]`))
+	a.NoError(err)
+	a.Equal([]Record{{Name: "Pupkin"}, {Name: "Ivanov", Active: active(false)}}, records)
+
+	_, err = ReadSnapshotFile("hr.txt", strings.NewReader(""))
+	a.Error(err)
+}
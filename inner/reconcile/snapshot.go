@@ -0,0 +1,84 @@
+package reconcile
+
+import (
+	"encoding/json"
+	"fmt"
+	"idm/inner/tabular"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Record запись выгрузки кадровой системы о работнике
+type Record struct {
+	Name string `json:"name"`
+	// если признак не указан, работник считается действующим
+	Active *bool `json:"active,omitempty"`
+}
+
+// IsActive работник действующий по данным кадровой системы
+func (rec Record) IsActive() bool {
+	return rec.Active == nil || *rec.Active
+}
+
+// ReadSnapshotFile чтение выгрузки кадровой системы, формат определяется по расширению файла (.csv или .json)
+func ReadSnapshotFile(fileName string, reader io.Reader) ([]Record, error) {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json":
+		return readJSON(reader)
+	case ".csv":
+		return readCSV(reader)
+	default:
+		return nil, fmt.Errorf("unsupported snapshot format: %s", fileName)
+	}
+}
+
+// JSON-выгрузка: массив объектов {"name": "...", "active": true}
+func readJSON(reader io.Reader) (records []Record, err error) {
+	if err = json.NewDecoder(reader).Decode(&records); err != nil {
+		return nil, fmt.Errorf("error decoding json snapshot: %w", err)
+	}
+	for i := range records {
+		records[i].Name = strings.TrimSpace(records[i].Name)
+	}
+	return records, nil
+}
+
+// CSV-выгрузка: обязательная колонка name и необязательная колонка active
+func readCSV(reader io.Reader) ([]Record, error) {
+	header, rows, err := tabular.Read(reader, tabular.CSV)
+	if err != nil {
+		return nil, err
+	}
+
+	var nameIndex, activeIndex = -1, -1
+	for i, title := range header {
+		switch strings.ToLower(strings.TrimSpace(title)) {
+		case "name":
+			nameIndex = i
+		case "active":
+			activeIndex = i
+		}
+	}
+	if nameIndex < 0 {
+		return nil, fmt.Errorf("snapshot has no name column")
+	}
+
+	var records = make([]Record, 0, len(rows))
+	for i, row := range rows {
+		var record Record
+		if nameIndex < len(row) {
+			record.Name = strings.TrimSpace(row[nameIndex])
+		}
+		if activeIndex >= 0 && activeIndex < len(row) && strings.TrimSpace(row[activeIndex]) != "" {
+			active, err := strconv.ParseBool(strings.TrimSpace(row[activeIndex]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid active value %q", i+2, row[activeIndex])
+			}
+			record.Active = &active
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
@@ -1,11 +1,11 @@
 package role
 
 import (
+	"context"
 	"errors"
 	"idm/inner/common"
+	"idm/inner/crud"
 	"idm/inner/web"
-	"strconv"
-	"strings"
 
 	"github.com/gofiber/fiber"
 )
@@ -13,22 +13,21 @@ import (
 type Controller struct {
 	server     *web.Server
 	roleervice Srv
+	// общие для ресурсов маршруты: пакетное создание, списки, выгрузка, изменение и удаление
+	handlers *crud.Handlers[Filter, Request, Response]
 }
 
-// интерфейс сервиса employee.Service
+// интерфейс сервиса role.Service
 type Srv interface {
-	FindById(id int64) (Response, error)
-	Save(req Request) (id int64, err error)
-	FindByIds(ids []int64) ([]Response, error)
-	GetAll() ([]Response, error)
-	DeleteById(id int64) error
-	DeleteByIds(ids []int64) error
+	crud.Srv[Filter, Request, Response]
+	Save(ctx context.Context, req Request) (id int64, err error)
 }
 
 func NewController(server *web.Server, roleervice Srv) *Controller {
 	return &Controller{
 		server:     server,
 		roleervice: roleervice,
+		handlers:   crud.NewHandlers(roleervice, "role", "roles", func(resp Response) int64 { return resp.Version }),
 	}
 }
 
@@ -37,11 +36,7 @@ func (contr *Controller) RegisterRoutes() {
 
 	// полный маршрут получится "/api/v1/roles"
 	contr.server.GroupApiV1.Post("/roles", contr.CreateRole)
-	contr.server.GroupApiV1.Get("/roles", contr.GetAllRole)
-	contr.server.GroupApiV1.Get("/roles/id/:id", contr.FindRoleById)
-	contr.server.GroupApiV1.Get("/roles/ids", contr.FindRoleByIds)
-	contr.server.GroupApiV1.Delete("/roles/id/:id", contr.DeleteRoleById)
-	contr.server.GroupApiV1.Delete("/roles/ids", contr.DeleteRoleByIds)
+	contr.handlers.RegisterRoutes(contr.server.GroupApiV1, "/roles")
 }
 
 // функция-хендлер, которая будет вызываться при POST запросе по маршруту "/api/v1/roles"
@@ -55,7 +50,7 @@ func (contr *Controller) CreateRole(ctx *fiber.Ctx) {
 	}
 
 	// вызываем метод Save сервиса role.Service
-	var newId, err = contr.roleervice.Save(req)
+	var newId, err = contr.roleervice.Save(web.Context(ctx), req)
 	if err != nil {
 		switch {
 
@@ -79,144 +74,3 @@ func (contr *Controller) CreateRole(ctx *fiber.Ctx) {
 		return
 	}
 }
-
-func (contr *Controller) FindRoleById(ctx *fiber.Ctx) {
-	var idStr string
-	if idStr = ctx.Params("id"); idStr == "" {
-		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "error retrieving id")
-		return
-	}
-
-	num, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error converted id tot int64")
-		return
-	}
-
-	foundResponse, err := contr.roleervice.FindById(num)
-	if err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, err.Error())
-		return
-	}
-
-	if err = common.OkResponse(ctx, foundResponse); err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning found role")
-		return
-	}
-}
-
-func (contr *Controller) FindRoleByIds(ctx *fiber.Ctx) {
-	var req struct {
-		IDs string `query:"ids"`
-	}
-
-	if err := ctx.QueryParser(&req); err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid query parameters")
-		return
-	}
-
-	if req.IDs == "" {
-		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "ids parameter is required")
-		return
-	}
-
-	idStrings := strings.Split(req.IDs, ",")
-	var ids []int64
-
-	for _, idStr := range idStrings {
-		id, err := strconv.ParseInt(idStr, 10, 64)
-		if err != nil {
-			_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id format: "+idStr)
-			return
-		}
-		ids = append(ids, id)
-	}
-
-	var foundResponses, err = contr.roleervice.FindByIds(ids)
-	if err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, err.Error())
-		return
-	}
-
-	if err = common.OkResponse(ctx, foundResponses); err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning found roles")
-		return
-	}
-}
-
-func (contr *Controller) GetAllRole(ctx *fiber.Ctx) {
-	var foundResponses, err = contr.roleervice.GetAll()
-	if err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, err.Error())
-		return
-	}
-
-	if err = common.OkResponse(ctx, foundResponses); err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning all roles")
-		return
-	}
-}
-
-func (contr *Controller) DeleteRoleById(ctx *fiber.Ctx) {
-	var idStr string
-	if idStr = ctx.Params("id"); idStr == "" {
-		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "error retrieving id")
-		return
-	}
-
-	num, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error converted id tot int64")
-		return
-	}
-
-	err = contr.roleervice.DeleteById(num)
-	if err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, err.Error())
-		return
-	}
-
-	if err = common.ResponseWithoutData(ctx); err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning result delete role")
-		return
-	}
-}
-
-func (contr *Controller) DeleteRoleByIds(ctx *fiber.Ctx) {
-	var req struct {
-		IDs string `query:"ids"`
-	}
-
-	if err := ctx.QueryParser(&req); err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid query parameters")
-		return
-	}
-
-	if req.IDs == "" {
-		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "ids parameter is required")
-		return
-	}
-
-	idStrings := strings.Split(req.IDs, ",")
-	var ids []int64
-
-	for _, idStr := range idStrings {
-		id, err := strconv.ParseInt(idStr, 10, 64)
-		if err != nil {
-			_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id format: "+idStr)
-			return
-		}
-		ids = append(ids, id)
-	}
-
-	var err = contr.roleervice.DeleteByIds(ids)
-	if err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, err.Error())
-		return
-	}
-
-	if err = common.ResponseWithoutData(ctx); err != nil {
-		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning result delete roles")
-		return
-	}
-}
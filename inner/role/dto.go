@@ -1,6 +1,7 @@
 package role
 
 import (
+	"idm/inner/tabular"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -11,13 +12,16 @@ type Entity struct {
 	Name   string    `db:"name"`
 	Create time.Time `db:"create_at"`
 	Update time.Time `db:"update_at"`
+	// версия строки для оптимистичной блокировки, увеличивается при каждом изменении
+	Version int64 `db:"version"`
 }
 
 type Response struct {
-	Id     int64     `json:"id"`
-	Name   string    `json:"name"`
-	Create time.Time `json:"create_at"`
-	Update time.Time `json:"update_at"`
+	Id      int64     `json:"id"`
+	Name    string    `json:"name"`
+	Create  time.Time `json:"create_at"`
+	Update  time.Time `json:"update_at"`
+	Version int64     `json:"version"`
 }
 
 type Request struct {
@@ -26,6 +30,12 @@ type Request struct {
 	Update time.Time `json:"update_at" validate:"required"`
 }
 
+// Filter фильтры списка ролей, общие для получения списка и выгрузки
+type Filter struct {
+	// часть имени без учёта регистра
+	Name string `query:"name"`
+}
+
 type RequestById struct {
 	Id int64 `json:"id" validate:"required,gt=0"`
 }
@@ -34,18 +44,27 @@ type RequestByIds struct {
 	Ids []int64 `json:"ids" validate:"required"`
 }
 
-func (e *Entity) toResponse() Response {
+// колонки выгрузки в табличные форматы
+var exportColumns = []tabular.Column[Response]{
+	{Title: "id", Value: func(resp Response) any { return resp.Id }},
+	{Title: "name", Value: func(resp Response) any { return resp.Name }},
+	{Title: "create_at", Value: func(resp Response) any { return resp.Create.Format(time.RFC3339) }},
+	{Title: "update_at", Value: func(resp Response) any { return resp.Update.Format(time.RFC3339) }},
+}
+
+func (e *Entity) ToResponse() Response {
 	return Response{
-		Id:     e.Id,
-		Name:   e.Name,
-		Create: e.Create,
-		Update: e.Update,
+		Id:      e.Id,
+		Name:    e.Name,
+		Create:  e.Create,
+		Update:  e.Update,
+		Version: e.Version,
 	}
 }
 
 func toResponses(entities []Entity) (responses []Response) {
 	for _, e := range entities {
-		responses = append(responses, e.toResponse())
+		responses = append(responses, e.ToResponse())
 	}
 
 	return responses
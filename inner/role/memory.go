@@ -0,0 +1,127 @@
+package role
+
+import (
+	"context"
+	"idm/inner/crud"
+	"idm/inner/memory"
+	"maps"
+	"slices"
+	"time"
+)
+
+// MemoryRepository хранение ролей и их назначений работникам в памяти: те же методы, что и у Repository
+type MemoryRepository struct {
+	*crud.MemoryRepository[Entity, Filter]
+	assignments *assignmentTable
+}
+
+// описание роли для обобщённого репозитория в памяти
+var memoryTable = crud.MemoryTable[Entity, Filter]{
+	Name:       "role",
+	Id:         func(entity *Entity) int64 { return entity.Id },
+	SetId:      func(entity *Entity, id int64) { entity.Id = id },
+	Version:    func(entity *Entity) int64 { return entity.Version },
+	SetVersion: func(entity *Entity, version int64) { entity.Version = version },
+	Key:        func(entity *Entity) string { return entity.Name },
+	Match: func(filter Filter, entity *Entity) bool {
+		return crud.ContainsFold(entity.Name, filter.Name)
+	},
+	Apply: func(row *Entity, changes *Entity) {
+		row.Name, row.Create, row.Update = changes.Name, changes.Create, changes.Update
+	},
+	Defaults: func(entity *Entity) {
+		var now = time.Now()
+		if entity.Create.IsZero() {
+			entity.Create = now
+		}
+		if entity.Update.IsZero() {
+			entity.Update = now
+		}
+	},
+}
+
+// assignmentTable таблица employee_role в памяти: id работника -> множество id ролей
+type assignmentTable struct {
+	links map[int64]map[int64]bool
+}
+
+func (table *assignmentTable) Snapshot() (restore func()) {
+	var links = make(map[int64]map[int64]bool, len(table.links))
+	for employeeId, roleIds := range table.links {
+		links[employeeId] = maps.Clone(roleIds)
+	}
+	return func() {
+		table.links = links
+	}
+}
+
+func NewRoleMemoryRepository(db *memory.DB) *MemoryRepository {
+	var assignments = &assignmentTable{links: make(map[int64]map[int64]bool)}
+	db.Register(assignments)
+	return &MemoryRepository{
+		MemoryRepository: crud.NewMemoryRepository(db, memoryTable),
+		assignments:      assignments,
+	}
+}
+
+func (rep *MemoryRepository) FindByName(ctx context.Context, name string) (isExists bool, err error) {
+	return rep.ExistsByName(ctx, name)
+}
+
+func (rep *MemoryRepository) Save(ctx context.Context, entity *Entity) (id int64, err error) {
+	// как и в Repository, сохраняется только имя
+	return rep.Insert(ctx, &Entity{Name: entity.Name})
+}
+
+// AssignTx назначение ролей работнику, уже назначенные роли пропускаются
+func (rep *MemoryRepository) AssignTx(ctx context.Context, employeeId int64, roleIds []int64) error {
+	unlock, err := rep.Lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if rep.assignments.links[employeeId] == nil {
+		rep.assignments.links[employeeId] = make(map[int64]bool)
+	}
+	for _, roleId := range roleIds {
+		rep.assignments.links[employeeId][roleId] = true
+	}
+	return nil
+}
+
+// FindByEmployeeId роли, назначенные работнику (удалённые роли пропускаются, как при JOIN)
+func (rep *MemoryRepository) FindByEmployeeId(ctx context.Context, employeeId int64) (entities []Entity, err error) {
+	unlock, err := rep.Lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var roleIds = slices.Sorted(maps.Keys(rep.assignments.links[employeeId]))
+	unlock()
+
+	return rep.FindByIds(ctx, roleIds)
+}
+
+// CountAssignments количество назначений ролей работникам (назначения удалённых ролей не учитываются, как при JOIN)
+func (rep *MemoryRepository) CountAssignments(ctx context.Context) (count int64, err error) {
+	unlock, err := rep.Lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var links = make(map[int64]int64)
+	for _, roleIds := range rep.assignments.links {
+		for roleId := range roleIds {
+			links[roleId]++
+		}
+	}
+	unlock()
+
+	existing, err := rep.FindByIds(ctx, slices.Collect(maps.Keys(links)))
+	if err != nil {
+		return 0, err
+	}
+	for _, role := range existing {
+		count += links[role.Id]
+	}
+	return count, nil
+}
@@ -1,69 +1,77 @@
 package role
 
 import (
+	"context"
+	"fmt"
+	"idm/inner/crud"
+	"idm/inner/database"
+	"strings"
+
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 )
 
+// Repository запросы к таблице role: общие запросы выполняет встроенный crud.Repository
 type Repository struct {
-	db *sqlx.DB
+	*crud.Repository[Entity, Filter]
+}
+
+// описание таблицы role для обобщённого репозитория
+var table = crud.Table[Entity, Filter]{
+	Name:    "role",
+	Columns: []string{"name", "create_at", "update_at"},
+	Values: func(entity *Entity) []any {
+		return []any{entity.Name, entity.Create, entity.Update}
+	},
+	Id:        func(entity *Entity) int64 { return entity.Id },
+	KeyColumn: "name",
+	Key:       func(entity *Entity) string { return entity.Name },
+	Filter: func(filter Filter) (string, []any) {
+		return crud.NameFilter("name", filter.Name)
+	},
 }
 
 func NewRoleRepository(database *sqlx.DB) *Repository {
-	return &Repository{db: database}
+	return &Repository{Repository: crud.NewRepository(database, table)}
 }
 
-func (rep *Repository) FindByName(name string) (isExists bool, err error) {
-	err = rep.db.Get(&isExists, "SELECT EXISTS(SELECT 1 FROM employee WHERE name = $1)", name)
+func (rep *Repository) FindByName(ctx context.Context, name string) (isExists bool, err error) {
+	err = rep.Conn(ctx).GetContext(ctx, &isExists, "SELECT EXISTS(SELECT 1 FROM role WHERE LOWER(name) = LOWER($1))", name)
 	return isExists, err
 }
 
-func (rep *Repository) Save(entity *Entity) (id int64, err error) {
+func (rep *Repository) Save(ctx context.Context, entity *Entity) (id int64, err error) {
 	query := "INSERT INTO role (name) VALUES ($1) RETURNING id"
-	err = rep.db.Get(&id, query, entity.Name)
-	return id, err
-}
-
-func (rep *Repository) FindById(id int64) (entity Entity, err error) {
-	query := "SELECT * FROM role WHERE id = $1"
-	err = rep.db.Get(&entity, query, id)
-	return entity, err
+	err = rep.Conn(ctx).GetContext(ctx, &id, query, entity.Name)
+	return id, database.TranslateError(err)
 }
 
-func (rep *Repository) GetAll() (entities []Entity, err error) {
-	query := "SELECT * FROM role"
-	err = rep.db.Select(&entities, query)
-	return entities, err
-}
-
-func (rep *Repository) FindByIds(ids []int64) (entities []Entity, err error) {
-	query := "SELECT * FROM ROLE WHERE id IN (?)"
-	query, args, err := sqlx.In(query, ids)
-
-	if err != nil {
-		return nil, err
+// AssignTx назначение ролей работнику, уже назначенные роли пропускаются
+func (rep *Repository) AssignTx(ctx context.Context, employeeId int64, roleIds []int64) error {
+	if len(roleIds) == 0 {
+		return nil
 	}
 
-	query = sqlx.Rebind(2, query)
-	err = rep.db.Select(&entities, query, args...)
-	return entities, err
-}
-
-func (rep *Repository) DeleteById(id int64) error {
-	query := "DELETE FROM role WHERE id = $1"
-	_, err := rep.db.Exec(query, id)
+	var values = make([]string, len(roleIds))
+	var args = []any{employeeId}
+	for i, roleId := range roleIds {
+		values[i] = fmt.Sprintf("($1, $%d)", i+2)
+		args = append(args, roleId)
+	}
+	query := "INSERT INTO employee_role (employee_id, role_id) VALUES " + strings.Join(values, ", ") + " ON CONFLICT DO NOTHING"
+	_, err := rep.Conn(ctx).ExecContext(ctx, query, args...)
 	return err
 }
 
-func (rep *Repository) DeleteByIds(ids []int64) error {
-	query := "DELETE FROM role WHERE id IN (?)"
-	query, args, err := sqlx.In(query, ids)
-
-	if err != nil {
-		return err
-	}
+// FindByEmployeeId роли, назначенные работнику
+func (rep *Repository) FindByEmployeeId(ctx context.Context, employeeId int64) (entities []Entity, err error) {
+	query := "SELECT role.* FROM role JOIN employee_role ON employee_role.role_id = role.id WHERE employee_role.employee_id = $1 ORDER BY role.id"
+	err = rep.Conn(ctx).SelectContext(ctx, &entities, query, employeeId)
+	return entities, err
+}
 
-	query = sqlx.Rebind(2, query)
-	_, err = rep.db.Exec(query, args...)
-	return err
+// CountAssignments количество назначений ролей работникам
+func (rep *Repository) CountAssignments(ctx context.Context) (count int64, err error) {
+	err = rep.Conn(ctx).GetContext(ctx, &count, "SELECT COUNT(*) FROM employee_role")
+	return count, err
 }
@@ -1,94 +1,71 @@
 package role
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"idm/inner/common"
+	"idm/inner/crud"
+	"idm/inner/tracing"
 )
 
+// Service операции с ролями: общие для ресурсов операции выполняет встроенный crud.Service
 type Service struct {
+	*crud.Service[Entity, Filter, Request, Response]
 	repo  Repo
 	valid Validator
 }
 
 type Repo interface {
-	Save(entity *Entity) (id int64, err error)
-	FindById(id int64) (entity Entity, err error)
-	GetAll() (entities []Entity, err error)
-	FindByIds(ids []int64) (entities []Entity, err error)
-	DeleteById(id int64) error
-	DeleteByIds(ids []int64) error
-	FindByName(name string) (isExists bool, err error)
+	crud.Store[Entity, Filter]
+	Save(ctx context.Context, entity *Entity) (id int64, err error)
+	FindByName(ctx context.Context, name string) (isExists bool, err error)
 }
 
 type Validator interface {
 	Validate(request any) error
 }
 
+// описание роли для обобщённого сервиса
+var resource = crud.Resource[Entity, Request, Response]{
+	Name:          "role",
+	Plural:        "roles",
+	Key:           func(req Request) string { return req.Name },
+	ToEntity:      func(req Request) *Entity { return req.toEntity() },
+	SetId:         func(entity *Entity, id int64) { entity.Id = id },
+	Version:       func(entity Entity) int64 { return entity.Version },
+	ToResponse:    func(entity Entity) Response { return entity.ToResponse() },
+	ExportColumns: exportColumns,
+}
+
 func NewService(repo Repo, validator Validator) *Service {
 	return &Service{
-		repo:  repo,
-		valid: validator,
+		Service: crud.NewService(repo, validator, resource),
+		repo:    repo,
+		valid:   validator,
 	}
 }
 
-func (serv *Service) Save(req Request) (id int64, err error) {
-	isExists, err := serv.repo.FindByName(req.Name)
+func (serv *Service) Save(ctx context.Context, req Request) (id int64, err error) {
+	ctx, span := tracing.Start(ctx, "role.Save")
+	defer tracing.End(span, &err)
+
+	isExists, err := serv.repo.FindByName(ctx, req.Name)
 	if err != nil {
-		return 0, common.DbOperationError{Message: fmt.Errorf("error finding employee by name: %s, %w", req.Name, err).Error()}
+		return 0, common.DbOperationError{Message: fmt.Errorf("error finding role by name: %s, %w", req.Name, err).Error()}
 	}
 	if isExists {
-		return 0, common.AlreadyExistsError{Message: fmt.Errorf("employee with name %s already exists", req.Name).Error()}
+		return 0, common.AlreadyExistsError{Message: fmt.Errorf("role with name %s already exists", req.Name).Error()}
 	}
 
-	id, err = serv.repo.Save(req.toEntity())
+	// проверка выше не защищает от параллельных запросов, повтор имени отлавливает уникальный индекс
+	id, err = serv.repo.Save(ctx, req.toEntity())
+	if errors.As(err, &common.AlreadyExistsError{}) {
+		return 0, err
+	}
 	if err != nil {
 		return 0, common.DbOperationError{Message: fmt.Errorf("error save role: %w", err).Error()}
 	}
 
 	return id, nil
 }
-
-func (serv *Service) FindById(id int64) (Response, error) {
-	resp, err := serv.repo.FindById(id)
-	if err != nil {
-		return Response{}, common.DbOperationError{Message: fmt.Errorf("error finding role with id %d: %w", id, err).Error()}
-	}
-
-	return resp.toResponse(), nil
-}
-
-func (serv *Service) GetAll() ([]Response, error) {
-	resps, err := serv.repo.GetAll()
-	if err != nil {
-		return []Response{}, common.DbOperationError{Message: fmt.Errorf("error GetAll roles: %w", err).Error()}
-	}
-
-	return toResponses(resps), nil
-}
-
-func (serv *Service) FindByIds(ids []int64) ([]Response, error) {
-	resps, err := serv.repo.FindByIds(ids)
-	if err != nil {
-		return []Response{}, common.DbOperationError{Message: fmt.Errorf("error finding role with ids %d: %w", ids, err).Error()}
-	}
-
-	return toResponses(resps), nil
-}
-
-func (serv *Service) DeleteById(id int64) error {
-	err := serv.repo.DeleteById(id)
-	if err != nil {
-		return common.DbOperationError{Message: fmt.Errorf("error delete role by id %d: %w", id, err).Error()}
-	}
-
-	return nil
-}
-
-func (serv *Service) DeleteByIds(ids []int64) error {
-	err := serv.repo.DeleteByIds(ids)
-	if err != nil {
-		return common.DbOperationError{Message: fmt.Errorf("error delete role by ids %d: %w", ids, err).Error()}
-	}
-
-	return nil
-}
@@ -1,8 +1,11 @@
 package role
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"idm/inner/common"
 	"strings"
 	"testing"
 	"time"
@@ -22,43 +25,71 @@ type MockValidator struct {
 }
 
 // реализуем интерфейс репозитория у мока
-func (m *MockRepo) Save(entity *Entity) (id int64, err error) {
+func (m *MockRepo) Save(ctx context.Context, entity *Entity) (id int64, err error) {
 
 	// Общая конфигурация поведения мок-объекта
 	args := m.Called(entity)
 	return args.Get(0).(int64), args.Error(1)
 }
 
-func (m *MockRepo) FindById(id int64) (role Entity, err error) {
+func (m *MockRepo) FindById(ctx context.Context, id int64) (role Entity, err error) {
 	args := m.Called(id)
 	return args.Get(0).(Entity), args.Error(1)
 }
 
-func (m *MockRepo) FindByName(name string) (isExists bool, err error) {
+func (m *MockRepo) FindByName(ctx context.Context, name string) (isExists bool, err error) {
 	args := m.Called(name)
 	return args.Get(0).(bool), args.Error(1)
 }
 
-func (m *MockRepo) GetAll() (entities []Entity, err error) {
-	args := m.Called()
+func (m *MockRepo) GetAll(ctx context.Context, filter Filter) (entities []Entity, err error) {
+	args := m.Called(filter)
 	return args.Get(0).([]Entity), args.Error(1)
 }
 
-func (m *MockRepo) FindByIds(ids []int64) (entities []Entity, err error) {
+func (m *MockRepo) Stream(ctx context.Context, filter Filter, fn func(entity Entity) error) error {
+	args := m.Called(filter, fn)
+	return args.Error(0)
+}
+
+func (m *MockRepo) FindByIds(ctx context.Context, ids []int64) (entities []Entity, err error) {
 	args := m.Called(ids)
 	return args.Get(0).([]Entity), args.Error(1)
 }
 
-func (m *MockRepo) DeleteById(id int64) error {
-	args := m.Called(id)
-	return args.Error(0)
+func (m *MockRepo) Update(ctx context.Context, entity *Entity, expectedVersion int64) (updated Entity, err error) {
+	args := m.Called(entity, expectedVersion)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) DeleteById(ctx context.Context, id int64, version int64) (deleted bool, err error) {
+	args := m.Called(id, version)
+	return args.Bool(0), args.Error(1)
 }
 
-func (m *MockRepo) DeleteByIds(ids []int64) error {
+func (m *MockRepo) DeleteByIds(ctx context.Context, ids []int64) error {
 	args := m.Called(ids)
 	return args.Error(0)
 }
 
+func (m *MockRepo) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	args := m.Called(fn)
+	if err := args.Error(0); err != nil {
+		return err
+	}
+	return fn(ctx)
+}
+
+func (m *MockRepo) FindExistingNamesTx(ctx context.Context, names []string) (existing []string, err error) {
+	args := m.Called(names)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockRepo) SaveBatchTx(ctx context.Context, entities []*Entity) (ids []int64, err error) {
+	args := m.Called(entities)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
 func (m *MockRepo) Validate(request any) (err error) {
 	args := m.Called(request)
 	return args.Error(0)
@@ -79,7 +110,7 @@ func TestSave(t *testing.T) {
 		entity := request.toEntity()
 		repo.On("FindByName", entity.Name).Return(false, nil)
 		repo.On("Save", entity).Return(id, nil)
-		got, err := srv.Save(request)
+		got, err := srv.Save(context.Background(), request)
 
 		a.Nil(err)
 		a.Equal(id, got)
@@ -93,12 +124,23 @@ func TestSave(t *testing.T) {
 		var want = fmt.Errorf("error save role: %w", err)
 		repo.On("FindByName", entity.Name).Return(false, nil)
 		repo.On("Save", entity).Return(id, err)
-		newId, got := srv.Save(request)
+		newId, got := srv.Save(context.Background(), request)
 
 		a.NotNil(err)
 		a.Equal(id, newId)
 		a.True(strings.Contains(got.Error(), want.Error()))
 	})
+	t.Run("should keep AlreadyExistsError from unique index", func(t *testing.T) {
+		repo := new(MockRepo)
+		srv := NewService(repo, repo)
+		entity := request.toEntity()
+		var want = common.AlreadyExistsError{Message: "role already exists"}
+		repo.On("FindByName", entity.Name).Return(false, nil)
+		repo.On("Save", entity).Return(int64(0), want)
+		_, got := srv.Save(context.Background(), request)
+
+		a.Equal(want, got)
+	})
 }
 
 func TestFindById(t *testing.T) {
@@ -122,13 +164,13 @@ func TestFindById(t *testing.T) {
 		}
 
 		// создаём Response, который ожидаем получить от сервиса
-		var want = entity.toResponse()
+		var want = entity.ToResponse()
 
 		// конфигурируем поведение мок-репозитория (при вызове метода FindById с аргументом 1 вернуть Entity, созданную нами выше)
 		repo.On("FindById", int64(1)).Return(entity, nil)
 
 		// вызываем сервис с аргументом id = 1
-		var got, err = svc.FindById(1)
+		var got, err = svc.FindById(context.Background(), 1)
 
 		// проверяем, что сервис не вернул ошибку
 		a.Nil(err)
@@ -161,7 +203,7 @@ func TestFindById(t *testing.T) {
 
 		repo.On("FindById", int64(1)).Return(entity, err)
 
-		var response, got = svc.FindById(1)
+		var response, got = svc.FindById(context.Background(), 1)
 
 		// проверяем результаты теста
 		a.Empty(response)
@@ -177,8 +219,8 @@ func TestGetAll(t *testing.T) {
 		repo := new(MockRepo)
 		srv := NewService(repo, repo)
 		listEntity := []Entity{{Name: "name1"}, {Name: "name2"}}
-		repo.On("GetAll").Return(listEntity, nil)
-		result, err := srv.GetAll()
+		repo.On("GetAll", Filter{}).Return(listEntity, nil)
+		result, err := srv.GetAll(context.Background(), Filter{})
 
 		a.Nil(err)
 		a.NotNil(result)
@@ -190,10 +232,10 @@ func TestGetAll(t *testing.T) {
 		srv := NewService(repo, repo)
 
 		err := errors.New("database error")
-		want := fmt.Errorf("error GetAll roles: %w", err)
+		want := fmt.Errorf("error get all roles: %w", err)
 
-		repo.On("GetAll").Return([]Entity{}, err)
-		result, err := srv.GetAll()
+		repo.On("GetAll", Filter{}).Return([]Entity{}, err)
+		result, err := srv.GetAll(context.Background(), Filter{})
 
 		a.Equal(result, []Response{})
 		a.NotNil(err)
@@ -225,7 +267,7 @@ func TestFindByIds(t *testing.T) {
 		var ids = []int64{1, 2}
 
 		repo.On("FindByIds", ids).Return(entities, nil)
-		result, err := svc.FindByIds(ids)
+		result, err := svc.FindByIds(context.Background(), ids)
 
 		a.Nil(err)
 		a.Equal(want, result)
@@ -245,7 +287,7 @@ func TestFindByIds(t *testing.T) {
 
 		repo.On("FindByIds", ids).Return(entities, err)
 
-		response, err := svc.FindByIds(ids)
+		response, err := svc.FindByIds(context.Background(), ids)
 
 		a.Equal(response, []Response{})
 		a.NotNil(err)
@@ -260,8 +302,8 @@ func TestDeleteById(t *testing.T) {
 		var repo = new(MockRepo)
 		var svc = NewService(repo, repo)
 		var id int64 = 7
-		repo.On("DeleteById", id).Return(nil).Once()
-		err := svc.DeleteById(id)
+		repo.On("DeleteById", id, int64(2)).Return(true, nil).Once()
+		err := svc.DeleteById(context.Background(), id, 2)
 
 		a.Nil(err)
 		a.True(repo.AssertNumberOfCalls(t, "DeleteById", 1))
@@ -274,44 +316,132 @@ func TestDeleteById(t *testing.T) {
 		var id int64 = 7
 
 		var err = errors.New("database error")
-		var want = fmt.Errorf("error delete role by id %d:  %w", id, err)
+		var want = fmt.Errorf("error delete role by id %d: %w", id, err)
 
-		repo.On("DeleteById", id).Return(want)
-		err = svc.DeleteById(id)
+		repo.On("DeleteById", id, int64(2)).Return(false, err)
+		err = svc.DeleteById(context.Background(), id, 2)
 
 		a.NotNil(err)
 		a.True(strings.Contains(err.Error(), want.Error()))
 		a.True(repo.AssertNumberOfCalls(t, "DeleteById", 1))
 	})
+
+	t.Run("return PreconditionFailedError when version is stale", func(t *testing.T) {
+		var repo = new(MockRepo)
+		var svc = NewService(repo, repo)
+		var id int64 = 7
+		repo.On("DeleteById", id, int64(2)).Return(false, nil)
+		repo.On("FindById", id).Return(Entity{Id: id, Version: 3}, nil)
+
+		err := svc.DeleteById(context.Background(), id, 2)
+
+		a.True(errors.As(err, &common.PreconditionFailedError{}))
+	})
+
+	t.Run("return NotFoundError when role is missing", func(t *testing.T) {
+		var repo = new(MockRepo)
+		var svc = NewService(repo, repo)
+		var id int64 = 7
+		repo.On("DeleteById", id, int64(2)).Return(false, nil)
+		repo.On("FindById", id).Return(Entity{}, sql.ErrNoRows)
+
+		err := svc.DeleteById(context.Background(), id, 2)
+
+		a.True(errors.As(err, &common.NotFoundError{}))
+	})
 }
 
-func TestDeleteByIds(t *testing.T) {
+func TestUpdate(t *testing.T) {
 	var a = assert.New(t)
-	t.Run("return nil when called DeleteByIds", func(t *testing.T) {
+	var request = Request{Name: "John Doe", Create: time.Now(), Update: time.Now()}
+
+	t.Run("should return updated role", func(t *testing.T) {
 		var repo = new(MockRepo)
 		var svc = NewService(repo, repo)
-		var ids = []int64{1, 2}
-		repo.On("DeleteByIds", ids).Return(nil).Once()
-		err := svc.DeleteByIds(ids)
+		repo.On("Validate", request).Return(nil)
+		repo.On("Update", mock.Anything, int64(2)).Return(Entity{Id: 7, Name: request.Name, Version: 3}, nil)
+
+		got, err := svc.Update(context.Background(), 7, 2, request)
 
 		a.Nil(err)
-		a.True(repo.AssertNumberOfCalls(t, "DeleteByIds", 1))
+		a.Equal(int64(3), got.Version)
 	})
 
-	t.Run("return error when called DeleteByIds", func(t *testing.T) {
+	t.Run("should return PreconditionFailedError when version is stale", func(t *testing.T) {
+		var repo = new(MockRepo)
+		var svc = NewService(repo, repo)
+		repo.On("Validate", request).Return(nil)
+		repo.On("Update", mock.Anything, int64(2)).Return(Entity{}, sql.ErrNoRows)
+		repo.On("FindById", int64(7)).Return(Entity{Id: 7, Version: 5}, nil)
+
+		_, err := svc.Update(context.Background(), 7, 2, request)
+
+		a.True(errors.As(err, &common.PreconditionFailedError{}))
+	})
+}
 
+func TestDeleteByIds(t *testing.T) {
+	var a = assert.New(t)
+	t.Run("delete every id with its version in one transaction", func(t *testing.T) {
 		var repo = new(MockRepo)
 		var svc = NewService(repo, repo)
-		var ids = []int64{1, 2}
+		repo.On("InTransaction", mock.Anything).Return(nil)
+		repo.On("DeleteById", int64(1), int64(3)).Return(true, nil).Once()
+		repo.On("DeleteById", int64(2), int64(0)).Return(true, nil).Once()
+
+		err := svc.DeleteByIds(context.Background(), []int64{1, 2}, []int64{3, 0})
+
+		a.Nil(err)
+		a.True(repo.AssertNumberOfCalls(t, "DeleteById", 2))
+		repo.AssertNotCalled(t, "DeleteByIds", mock.Anything)
+	})
 
+	t.Run("return PreconditionFailedError and stop when version is stale", func(t *testing.T) {
+		var repo = new(MockRepo)
+		var svc = NewService(repo, repo)
+		repo.On("InTransaction", mock.Anything).Return(nil)
+		repo.On("DeleteById", int64(1), int64(3)).Return(false, nil)
+		repo.On("FindById", int64(1)).Return(Entity{Id: 1, Version: 4}, nil)
+
+		err := svc.DeleteByIds(context.Background(), []int64{1, 2}, []int64{3, 0})
+
+		a.True(errors.As(err, &common.PreconditionFailedError{}))
+		repo.AssertNotCalled(t, "DeleteById", int64(2), mock.Anything)
+	})
+
+	t.Run("return error when called DeleteByIds", func(t *testing.T) {
+		var repo = new(MockRepo)
+		var svc = NewService(repo, repo)
+		repo.On("InTransaction", mock.Anything).Return(nil)
 		var err = errors.New("database error")
-		var want = fmt.Errorf("error delete role by ids %d: %w", ids, err)
+		var want = fmt.Errorf("error delete role by id 1: %w", err)
+		repo.On("DeleteById", int64(1), int64(0)).Return(false, err)
 
-		repo.On("DeleteByIds", ids).Return(want)
-		err = svc.DeleteByIds(ids)
+		err = svc.DeleteByIds(context.Background(), []int64{1, 2}, []int64{0, 0})
 
-		a.NotNil(err)
+		a.True(errors.As(err, &common.DbOperationError{}))
 		a.True(strings.Contains(err.Error(), want.Error()))
-		a.True(repo.AssertNumberOfCalls(t, "DeleteByIds", 1))
+	})
+}
+
+func TestSaveBatch(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should not start transaction when atomic batch is invalid", func(t *testing.T) {
+		repo := new(MockRepo)
+		srv := NewService(repo, repo)
+		var valid = Request{Name: "admin", Create: time.Now(), Update: time.Now()}
+		var invalid = Request{Name: "a", Create: time.Now(), Update: time.Now()}
+		repo.On("Validate", valid).Return(nil)
+		repo.On("Validate", invalid).Return(errors.New("name is too short"))
+
+		results, err := srv.SaveBatch(context.Background(), []Request{valid, invalid}, true)
+
+		a.Error(err)
+		a.True(errors.As(err, &common.RequestValidationError{}))
+		a.Len(results, 2)
+		a.Empty(results[0].Error)
+		a.Equal("name is too short", results[1].Error)
+		repo.AssertNotCalled(t, "InTransaction")
 	})
 }
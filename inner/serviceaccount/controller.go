@@ -0,0 +1,163 @@
+package serviceaccount
+
+import (
+	"context"
+	"idm/inner/common"
+	"idm/inner/crud"
+	"idm/inner/web"
+	"strconv"
+
+	"github.com/gofiber/fiber"
+)
+
+type Controller struct {
+	server  *web.Server
+	service Srv
+}
+
+// интерфейс сервиса serviceaccount.Service
+type Srv interface {
+	Create(ctx context.Context, req Request) (CreatedKey, error)
+	GetAll(ctx context.Context) ([]Response, error)
+	FindById(ctx context.Context, id int64) (Response, error)
+	RotateKey(ctx context.Context, id int64, req RotateRequest) (CreatedKey, error)
+	RevokeKey(ctx context.Context, id int64, keyId int64) error
+	Delete(ctx context.Context, id int64) error
+}
+
+func NewController(server *web.Server, service Srv) *Controller {
+	return &Controller{server: server, service: service}
+}
+
+// функция для регистрации маршрутов: полный путь "/api/v1/service-accounts",
+// доступ к ним дают разрешения service-accounts:read и service-accounts:write
+func (contr *Controller) RegisterRoutes() {
+	contr.server.GroupApiV1.Post("/service-accounts", contr.Create)
+	contr.server.GroupApiV1.Get("/service-accounts", contr.GetAll)
+	contr.server.GroupApiV1.Get("/service-accounts/id/:id", contr.FindById)
+	contr.server.GroupApiV1.Delete("/service-accounts/id/:id", contr.Delete)
+	contr.server.GroupApiV1.Post("/service-accounts/id/:id/keys", contr.RotateKey)
+	contr.server.GroupApiV1.Delete("/service-accounts/id/:id/keys/:keyId", contr.RevokeKey)
+}
+
+// Create хендлер POST-запроса "/api/v1/service-accounts": в ответе ключ, который больше нигде не показывается
+func (contr *Controller) Create(ctx *fiber.Ctx) {
+	var req Request
+	if err := ctx.BodyParser(&req); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+		return
+	}
+
+	created, err := contr.service.Create(web.Context(ctx), req)
+	if err != nil {
+		crud.WriteError(ctx, err)
+		return
+	}
+
+	if err = common.OkResponse(ctx, created); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning created service account")
+		return
+	}
+}
+
+// GetAll хендлер GET-запроса "/api/v1/service-accounts"
+func (contr *Controller) GetAll(ctx *fiber.Ctx) {
+	responses, err := contr.service.GetAll(web.Context(ctx))
+	if err != nil {
+		crud.WriteError(ctx, err)
+		return
+	}
+
+	if err = common.OkResponse(ctx, responses); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning all service accounts")
+		return
+	}
+}
+
+// FindById хендлер GET-запроса "/api/v1/service-accounts/id/:id"
+func (contr *Controller) FindById(ctx *fiber.Ctx) {
+	id, ok := crud.ParamId(ctx)
+	if !ok {
+		return
+	}
+
+	response, err := contr.service.FindById(web.Context(ctx), id)
+	if err != nil {
+		crud.WriteError(ctx, err)
+		return
+	}
+
+	if err = common.OkResponse(ctx, response); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning found service account")
+		return
+	}
+}
+
+// Delete хендлер DELETE-запроса "/api/v1/service-accounts/id/:id"
+func (contr *Controller) Delete(ctx *fiber.Ctx) {
+	id, ok := crud.ParamId(ctx)
+	if !ok {
+		return
+	}
+
+	if err := contr.service.Delete(web.Context(ctx), id); err != nil {
+		crud.WriteError(ctx, err)
+		return
+	}
+
+	if err := common.ResponseWithoutData(ctx); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning result delete service account")
+		return
+	}
+}
+
+// RotateKey хендлер POST-запроса "/api/v1/service-accounts/id/:id/keys": выпуск нового ключа
+func (contr *Controller) RotateKey(ctx *fiber.Ctx) {
+	id, ok := crud.ParamId(ctx)
+	if !ok {
+		return
+	}
+
+	// тело необязательно: без него прежние ключи перестают действовать сразу, а новый ключ бессрочный
+	var req RotateRequest
+	if len(ctx.Fasthttp.Request.Body()) > 0 {
+		if err := ctx.BodyParser(&req); err != nil {
+			_ = common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	created, err := contr.service.RotateKey(web.Context(ctx), id, req)
+	if err != nil {
+		crud.WriteError(ctx, err)
+		return
+	}
+
+	if err = common.OkResponse(ctx, created); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning created api key")
+		return
+	}
+}
+
+// RevokeKey хендлер DELETE-запроса "/api/v1/service-accounts/id/:id/keys/:keyId"
+func (contr *Controller) RevokeKey(ctx *fiber.Ctx) {
+	id, ok := crud.ParamId(ctx)
+	if !ok {
+		return
+	}
+	keyId, err := strconv.ParseInt(ctx.Params("keyId"), 10, 64)
+	if err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusBadRequest, "error converted key id to int64")
+		return
+	}
+
+	if err = contr.service.RevokeKey(web.Context(ctx), id, keyId); err != nil {
+		crud.WriteError(ctx, err)
+		return
+	}
+
+	if err = common.ResponseWithoutData(ctx); err != nil {
+		_ = common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning result revoke api key")
+		return
+	}
+}
@@ -0,0 +1,103 @@
+package serviceaccount
+
+import (
+	"strings"
+	"time"
+)
+
+// Entity сервисный аккаунт: вызывающий без интерактивного входа (интеграции, боты)
+type Entity struct {
+	Id          int64  `db:"id"`
+	Name        string `db:"name"`
+	Description string `db:"description"`
+	// разрешения через пробел, см. auth.RequiredScope
+	Scopes string    `db:"scopes"`
+	Create time.Time `db:"create_at"`
+	Update time.Time `db:"update_at"`
+}
+
+// KeyEntity API-ключ сервисного аккаунта: сам ключ не хранится, только его SHA-256
+type KeyEntity struct {
+	Id        int64 `db:"id"`
+	AccountId int64 `db:"service_account_id"`
+	// открытая часть ключа, по которой ключ ищется при аутентификации
+	Prefix string `db:"prefix"`
+	Hash   string `db:"hash"`
+	// nil - бессрочный ключ
+	ExpiresAt  *time.Time `db:"expires_at"`
+	LastUsedAt *time.Time `db:"last_used_at"`
+	Create     time.Time  `db:"create_at"`
+}
+
+// Expired ключ больше не действует в момент now
+func (key *KeyEntity) Expired(now time.Time) bool {
+	return key.ExpiresAt != nil && !now.Before(*key.ExpiresAt)
+}
+
+type Request struct {
+	Name        string   `json:"name" validate:"required,min=2,max=155"`
+	Description string   `json:"description" validate:"max=500"`
+	Scopes      []string `json:"scopes" validate:"required,min=1"`
+	// срок действия первого ключа, например "2160h"; пустая строка - бессрочный ключ
+	ExpiresIn string `json:"expires_in"`
+}
+
+// RotateRequest выпуск нового ключа взамен действующих
+type RotateRequest struct {
+	// сколько ещё действуют прежние ключи, например "24h", чтобы интеграция успела перейти на новый ключ;
+	// пустая строка или "0s" - прежние ключи перестают действовать сразу
+	Overlap string `json:"overlap"`
+	// срок действия нового ключа, пустая строка - бессрочный ключ
+	ExpiresIn string `json:"expires_in"`
+}
+
+type Response struct {
+	Id          int64         `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Scopes      []string      `json:"scopes"`
+	Create      time.Time     `json:"create_at"`
+	Update      time.Time     `json:"update_at"`
+	Keys        []KeyResponse `json:"keys"`
+}
+
+type KeyResponse struct {
+	Id         int64      `json:"id"`
+	Prefix     string     `json:"prefix"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Create     time.Time  `json:"create_at"`
+}
+
+// CreatedKey выпущенный ключ: Key возвращается только в ответе на создание, повторно получить его нельзя
+type CreatedKey struct {
+	KeyResponse
+	AccountId int64  `json:"service_account_id"`
+	Key       string `json:"key"`
+}
+
+func (e *Entity) toResponse(keys []KeyEntity) Response {
+	var keyResponses = make([]KeyResponse, len(keys))
+	for i := range keys {
+		keyResponses[i] = keys[i].toResponse()
+	}
+	return Response{
+		Id:          e.Id,
+		Name:        e.Name,
+		Description: e.Description,
+		Scopes:      strings.Fields(e.Scopes),
+		Create:      e.Create,
+		Update:      e.Update,
+		Keys:        keyResponses,
+	}
+}
+
+func (key *KeyEntity) toResponse() KeyResponse {
+	return KeyResponse{
+		Id:         key.Id,
+		Prefix:     key.Prefix,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		Create:     key.Create,
+	}
+}
@@ -0,0 +1,278 @@
+package serviceaccount
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"idm/inner/auth"
+	"idm/inner/common"
+	"idm/inner/tracing"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// префикс API-ключа, по нему ключ легко найти в конфигурации интеграции и в утечках
+const keyPrefix = "idm_"
+
+// как часто обновляется время последнего использования ключа
+const touchInterval = time.Minute
+
+// SubjectPrefix префикс имени вызывающего для сервисных аккаунтов, например "service-account:ticket-bot"
+const SubjectPrefix = "service-account:"
+
+// Service операции с сервисными аккаунтами и аутентификация их API-ключами (auth.Authenticator схемы ApiKey)
+type Service struct {
+	store Store
+	valid Validator
+	// текущее время, подменяется в тестах
+	now func() time.Time
+}
+
+type Validator interface {
+	Validate(request any) error
+}
+
+func NewService(store Store, validator Validator) *Service {
+	return &Service{store: store, valid: validator, now: time.Now}
+}
+
+// Create создание аккаунта и его первого ключа: ключ возвращается только в ответе
+func (serv *Service) Create(ctx context.Context, req Request) (created CreatedKey, err error) {
+	ctx, span := tracing.Start(ctx, "serviceaccount.Create")
+	defer tracing.End(span, &err)
+
+	if err = tracing.Validate(ctx, serv.valid, req); err != nil {
+		return CreatedKey{}, common.RequestValidationError{Message: err.Error()}
+	}
+	if invalid := slices.IndexFunc(req.Scopes, func(scope string) bool { return !auth.ValidScope(scope) }); invalid >= 0 {
+		return CreatedKey{}, common.RequestValidationError{Message: fmt.Sprintf("invalid scope %q: expected resource:read, resource:write, resource:* or *", req.Scopes[invalid])}
+	}
+	if err = auth.CheckGrant(ctx, req.Scopes); err != nil {
+		return CreatedKey{}, err
+	}
+	expiresIn, err := parseDuration("expires_in", req.ExpiresIn)
+	if err != nil {
+		return CreatedKey{}, err
+	}
+
+	var now = serv.now().UTC()
+	var account = Entity{
+		Name:        req.Name,
+		Description: req.Description,
+		Scopes:      strings.Join(req.Scopes, " "),
+		Create:      now,
+		Update:      now,
+	}
+	key, plaintext, err := newKey(now, expiresIn)
+	if err != nil {
+		return CreatedKey{}, err
+	}
+	_, key.Id, err = serv.store.Create(ctx, &account, &key)
+	if errors.As(err, &common.AlreadyExistsError{}) {
+		return CreatedKey{}, common.AlreadyExistsError{Message: fmt.Sprintf("service account with name %s already exists", req.Name)}
+	}
+	if err != nil {
+		return CreatedKey{}, common.DbOperationError{Message: fmt.Errorf("error creating service account: %w", err).Error()}
+	}
+	return CreatedKey{KeyResponse: key.toResponse(), AccountId: key.AccountId, Key: plaintext}, nil
+}
+
+// GetAll список аккаунтов с их ключами (без самих ключей)
+func (serv *Service) GetAll(ctx context.Context) (responses []Response, err error) {
+	ctx, span := tracing.Start(ctx, "serviceaccount.GetAll")
+	defer tracing.End(span, &err)
+
+	accounts, err := serv.store.FindAll(ctx)
+	if err != nil {
+		return nil, common.DbOperationError{Message: fmt.Errorf("error finding service accounts: %w", err).Error()}
+	}
+	var ids = make([]int64, len(accounts))
+	for i, account := range accounts {
+		ids[i] = account.Id
+	}
+	keys, err := serv.store.FindKeys(ctx, ids)
+	if err != nil {
+		return nil, common.DbOperationError{Message: fmt.Errorf("error finding api keys: %w", err).Error()}
+	}
+
+	responses = make([]Response, len(accounts))
+	for i, account := range accounts {
+		responses[i] = account.toResponse(slices.DeleteFunc(slices.Clone(keys), func(key KeyEntity) bool { return key.AccountId != account.Id }))
+	}
+	return responses, nil
+}
+
+func (serv *Service) FindById(ctx context.Context, id int64) (response Response, err error) {
+	ctx, span := tracing.Start(ctx, "serviceaccount.FindById")
+	defer tracing.End(span, &err)
+
+	account, err := serv.findAccount(ctx, id)
+	if err != nil {
+		return Response{}, err
+	}
+	keys, err := serv.store.FindKeys(ctx, []int64{id})
+	if err != nil {
+		return Response{}, common.DbOperationError{Message: fmt.Errorf("error finding api keys: %w", err).Error()}
+	}
+	return account.toResponse(keys), nil
+}
+
+// RotateKey выпуск нового ключа: прежние ключи действуют ещё req.Overlap, чтобы интеграция успела перейти на новый
+func (serv *Service) RotateKey(ctx context.Context, id int64, req RotateRequest) (created CreatedKey, err error) {
+	ctx, span := tracing.Start(ctx, "serviceaccount.RotateKey")
+	defer tracing.End(span, &err)
+
+	overlap, err := parseDuration("overlap", req.Overlap)
+	if err != nil {
+		return CreatedKey{}, err
+	}
+	expiresIn, err := parseDuration("expires_in", req.ExpiresIn)
+	if err != nil {
+		return CreatedKey{}, err
+	}
+	account, err := serv.findAccount(ctx, id)
+	if err != nil {
+		return CreatedKey{}, err
+	}
+	// новый ключ даёт все разрешения аккаунта
+	if err = auth.CheckGrant(ctx, strings.Fields(account.Scopes)); err != nil {
+		return CreatedKey{}, err
+	}
+
+	var now = serv.now().UTC()
+	key, plaintext, err := newKey(now, expiresIn)
+	if err != nil {
+		return CreatedKey{}, err
+	}
+	key.AccountId = id
+	key.Id, err = serv.store.AddKey(ctx, &key, now.Add(overlap))
+	if err != nil {
+		return CreatedKey{}, common.DbOperationError{Message: fmt.Errorf("error rotating api key: %w", err).Error()}
+	}
+	return CreatedKey{KeyResponse: key.toResponse(), AccountId: id, Key: plaintext}, nil
+}
+
+// RevokeKey отзыв ключа: запросы с ним сразу перестают проходить аутентификацию
+func (serv *Service) RevokeKey(ctx context.Context, id int64, keyId int64) (err error) {
+	ctx, span := tracing.Start(ctx, "serviceaccount.RevokeKey")
+	defer tracing.End(span, &err)
+
+	deleted, err := serv.store.DeleteKey(ctx, id, keyId)
+	if err != nil {
+		return common.DbOperationError{Message: fmt.Errorf("error revoking api key: %w", err).Error()}
+	}
+	if !deleted {
+		return common.NotFoundError{Message: fmt.Sprintf("api key with id %d not found in service account %d", keyId, id)}
+	}
+	return nil
+}
+
+// Delete удаление аккаунта вместе с ключами
+func (serv *Service) Delete(ctx context.Context, id int64) (err error) {
+	ctx, span := tracing.Start(ctx, "serviceaccount.Delete")
+	defer tracing.End(span, &err)
+
+	deleted, err := serv.store.Delete(ctx, id)
+	if err != nil {
+		return common.DbOperationError{Message: fmt.Errorf("error deleting service account: %w", err).Error()}
+	}
+	if !deleted {
+		return common.NotFoundError{Message: fmt.Sprintf("service account with id %d not found", id)}
+	}
+	return nil
+}
+
+// Authenticate проверка API-ключа из заголовка "Authorization: ApiKey <ключ>"
+func (serv *Service) Authenticate(ctx context.Context, credentials string) (principal auth.Principal, err error) {
+	ctx, span := tracing.Start(ctx, "serviceaccount.Authenticate")
+	defer tracing.End(span, &err)
+
+	prefix, ok := parseKey(credentials)
+	if !ok {
+		return auth.Principal{}, fmt.Errorf("%w: malformed api key", auth.ErrInvalidCredentials)
+	}
+	key, account, err := serv.store.FindKey(ctx, prefix)
+	if errors.Is(err, sql.ErrNoRows) {
+		return auth.Principal{}, fmt.Errorf("%w: unknown api key", auth.ErrInvalidCredentials)
+	}
+	if err != nil {
+		return auth.Principal{}, fmt.Errorf("error finding api key: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(hash(credentials)), []byte(key.Hash)) != 1 {
+		return auth.Principal{}, fmt.Errorf("%w: unknown api key", auth.ErrInvalidCredentials)
+	}
+	var now = serv.now().UTC()
+	if key.Expired(now) {
+		return auth.Principal{}, fmt.Errorf("%w: api key expired", auth.ErrInvalidCredentials)
+	}
+
+	// время использования не должно мешать запросу
+	if err := serv.store.TouchKey(ctx, key.Id, now, touchInterval); err != nil {
+		slog.WarnContext(ctx, "error updating api key last used time", "key", key.Prefix, "error", err)
+	}
+	return auth.Principal{Subject: SubjectPrefix + account.Name, Scopes: strings.Fields(account.Scopes)}, nil
+}
+
+func (serv *Service) findAccount(ctx context.Context, id int64) (Entity, error) {
+	account, err := serv.store.FindById(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Entity{}, common.NotFoundError{Message: fmt.Sprintf("service account with id %d not found", id)}
+	}
+	if err != nil {
+		return Entity{}, common.DbOperationError{Message: fmt.Errorf("error finding service account: %w", err).Error()}
+	}
+	return account, nil
+}
+
+// newKey новый ключ вида "idm_<prefix>_<secret>": prefix хранится открыто для поиска ключа,
+// в базе данных остаётся только SHA-256 всего ключа. Ключ случайный и длинный, поэтому медленный хеш не нужен.
+func newKey(now time.Time, expiresIn time.Duration) (key KeyEntity, plaintext string, err error) {
+	var random = make([]byte, 6+32)
+	if _, err = rand.Read(random); err != nil {
+		return KeyEntity{}, "", fmt.Errorf("error generating api key: %w", err)
+	}
+	var prefix = hex.EncodeToString(random[:6])
+	plaintext = keyPrefix + prefix + "_" + base64.RawURLEncoding.EncodeToString(random[6:])
+
+	key = KeyEntity{Prefix: prefix, Hash: hash(plaintext), Create: now}
+	if expiresIn > 0 {
+		var expiresAt = now.Add(expiresIn)
+		key.ExpiresAt = &expiresAt
+	}
+	return key, plaintext, nil
+}
+
+// parseKey открытая часть ключа
+func parseKey(plaintext string) (prefix string, ok bool) {
+	rest, ok := strings.CutPrefix(plaintext, keyPrefix)
+	if !ok {
+		return "", false
+	}
+	prefix, secret, ok := strings.Cut(rest, "_")
+	return prefix, ok && prefix != "" && secret != ""
+}
+
+func hash(plaintext string) string {
+	var sum = sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseDuration разбор длительности из запроса, пустая строка - 0
+func parseDuration(field string, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, common.RequestValidationError{Message: fmt.Sprintf("invalid %s %q: expected duration like 24h", field, value)}
+	}
+	return duration, nil
+}
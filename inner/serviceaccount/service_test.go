@@ -0,0 +1,149 @@
+package serviceaccount
+
+import (
+	"context"
+	"idm/inner/auth"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/migration"
+	"idm/inner/validator"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newDbStore(t *testing.T) *DbStore {
+	db, err := database.ConnectDbWithCfg(common.Config{DbDriverName: database.DriverSQLite, Dsn: filepath.Join(t.TempDir(), "idm.db")})
+	if err != nil {
+		t.Fatalf("error connecting to db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err = migration.OnStartup(context.Background(), db, common.MigrationsAuto); err != nil {
+		t.Fatalf("error applying migrations: %v", err)
+	}
+	return NewDbStore(db)
+}
+
+func TestService(t *testing.T) {
+	var stores = map[string]func(t *testing.T) Store{
+		"memory":   func(t *testing.T) Store { return NewMemoryStore() },
+		"database": func(t *testing.T) Store { return newDbStore(t) },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+			var ctx = context.Background()
+			var now = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+			var newService = func(t *testing.T) *Service {
+				var serv = NewService(newStore(t), validator.NewRequestValidator())
+				serv.now = func() time.Time { return now }
+				return serv
+			}
+			var request = Request{Name: "ticket-bot", Scopes: []string{"employees:read", "roles:*"}}
+
+			t.Run("should authenticate created key and track last use", func(t *testing.T) {
+				var serv = newService(t)
+				created, err := serv.Create(ctx, request)
+				a.Nil(err)
+				a.NotEmpty(created.Key)
+				a.Contains(created.Key, created.Prefix)
+
+				principal, err := serv.Authenticate(ctx, created.Key)
+				a.Nil(err)
+				a.Equal(auth.Principal{Subject: "service-account:ticket-bot", Scopes: []string{"employees:read", "roles:*"}}, principal)
+
+				found, err := serv.FindById(ctx, created.AccountId)
+				a.Nil(err)
+				a.Len(found.Keys, 1)
+				a.NotNil(found.Keys[0].LastUsedAt)
+				a.True(now.Equal(*found.Keys[0].LastUsedAt))
+
+				_, err = serv.Authenticate(ctx, created.Key+"x")
+				a.ErrorIs(err, auth.ErrInvalidCredentials)
+				_, err = serv.Authenticate(ctx, "not-a-key")
+				a.ErrorIs(err, auth.ErrInvalidCredentials)
+			})
+
+			t.Run("should validate request", func(t *testing.T) {
+				var serv = newService(t)
+				_, err := serv.Create(ctx, Request{Name: "bot", Scopes: []string{"employees:delete"}})
+				a.ErrorAs(err, &common.RequestValidationError{})
+				_, err = serv.Create(ctx, Request{Name: "bot", Scopes: []string{"*"}, ExpiresIn: "soon"})
+				a.ErrorAs(err, &common.RequestValidationError{})
+
+				_, err = serv.Create(ctx, request)
+				a.Nil(err)
+				_, err = serv.Create(ctx, Request{Name: "Ticket-Bot", Scopes: []string{"*"}})
+				a.ErrorAs(err, &common.AlreadyExistsError{})
+			})
+
+			t.Run("should not grant scopes the caller does not have", func(t *testing.T) {
+				var serv = newService(t)
+				var adminCtx = auth.WithPrincipal(ctx, auth.Principal{Subject: "service-account:admin", Scopes: []string{auth.AllScopes}})
+				var narrowCtx = auth.WithPrincipal(ctx, auth.Principal{Subject: "service-account:ci", Scopes: []string{"service-accounts:write", "employees:read"}})
+
+				_, err := serv.Create(narrowCtx, Request{Name: "escalation", Scopes: []string{auth.AllScopes}})
+				a.ErrorAs(err, &common.ForbiddenError{})
+				_, err = serv.Create(narrowCtx, Request{Name: "escalation", Scopes: []string{"employees:read", "roles:write"}})
+				a.ErrorAs(err, &common.ForbiddenError{})
+				_, err = serv.Create(narrowCtx, Request{Name: "reader", Scopes: []string{"employees:read"}})
+				a.Nil(err)
+
+				// ключ аккаунта с разрешениями шире, чем у вызывающего, выпустить нельзя
+				created, err := serv.Create(adminCtx, Request{Name: "admin-bot", Scopes: []string{auth.AllScopes}})
+				a.Nil(err)
+				_, err = serv.RotateKey(narrowCtx, created.AccountId, RotateRequest{})
+				a.ErrorAs(err, &common.ForbiddenError{})
+				_, err = serv.RotateKey(adminCtx, created.AccountId, RotateRequest{})
+				a.Nil(err)
+			})
+
+			t.Run("should keep old key valid during rotation overlap", func(t *testing.T) {
+				var serv = newService(t)
+				created, err := serv.Create(ctx, request)
+				a.Nil(err)
+
+				rotated, err := serv.RotateKey(ctx, created.AccountId, RotateRequest{Overlap: "1h", ExpiresIn: "720h"})
+				a.Nil(err)
+				a.NotEqual(created.Key, rotated.Key)
+				a.True(now.Add(720 * time.Hour).Equal(*rotated.ExpiresAt))
+
+				now = now.Add(30 * time.Minute)
+				_, err = serv.Authenticate(ctx, created.Key)
+				a.Nil(err, "old key should be valid during overlap")
+
+				now = now.Add(time.Hour)
+				_, err = serv.Authenticate(ctx, created.Key)
+				a.ErrorIs(err, auth.ErrInvalidCredentials)
+				_, err = serv.Authenticate(ctx, rotated.Key)
+				a.Nil(err)
+
+				_, err = serv.RotateKey(ctx, created.AccountId+100, RotateRequest{})
+				a.ErrorAs(err, &common.NotFoundError{})
+			})
+
+			t.Run("should reject revoked key and keys of deleted account", func(t *testing.T) {
+				var serv = newService(t)
+				created, err := serv.Create(ctx, request)
+				a.Nil(err)
+
+				a.Nil(serv.RevokeKey(ctx, created.AccountId, created.Id))
+				_, err = serv.Authenticate(ctx, created.Key)
+				a.ErrorIs(err, auth.ErrInvalidCredentials)
+				a.ErrorAs(serv.RevokeKey(ctx, created.AccountId, created.Id), &common.NotFoundError{})
+
+				rotated, err := serv.RotateKey(ctx, created.AccountId, RotateRequest{})
+				a.Nil(err)
+				a.Nil(serv.Delete(ctx, created.AccountId))
+				_, err = serv.Authenticate(ctx, rotated.Key)
+				a.ErrorIs(err, auth.ErrInvalidCredentials)
+
+				all, err := serv.GetAll(ctx)
+				a.Nil(err)
+				a.Empty(all)
+			})
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package serviceaccount
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"idm/inner/common"
+	"idm/inner/database"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Store хранилище сервисных аккаунтов и их ключей.
+// Методы поиска одной записи возвращают sql.ErrNoRows, если запись не найдена.
+type Store interface {
+	// Create создание аккаунта вместе с первым ключом
+	Create(ctx context.Context, account *Entity, key *KeyEntity) (id int64, keyId int64, err error)
+	FindAll(ctx context.Context) ([]Entity, error)
+	FindById(ctx context.Context, id int64) (Entity, error)
+	// FindKeys ключи аккаунтов в порядке создания
+	FindKeys(ctx context.Context, accountIds []int64) ([]KeyEntity, error)
+	// FindKey ключ с открытой частью prefix и его аккаунт
+	FindKey(ctx context.Context, prefix string) (KeyEntity, Entity, error)
+	// AddKey выпуск ключа: срок действия прежних ключей аккаунта сокращается до overlapEnd
+	AddKey(ctx context.Context, key *KeyEntity, overlapEnd time.Time) (id int64, err error)
+	// TouchKey время последнего использования ключа; обновляется не чаще раза в interval,
+	// чтобы частые запросы интеграции не превращались в такие же частые записи
+	TouchKey(ctx context.Context, id int64, now time.Time, interval time.Duration) error
+	DeleteKey(ctx context.Context, accountId int64, id int64) (deleted bool, err error)
+	// Delete удаление аккаунта вместе с ключами
+	Delete(ctx context.Context, id int64) (deleted bool, err error)
+}
+
+// DbStore хранение аккаунтов в таблице service_account, ключей - в таблице api_key
+type DbStore struct {
+	db *sqlx.DB
+	tx *database.TxManager
+}
+
+func NewDbStore(db *sqlx.DB) *DbStore {
+	return &DbStore{db: db, tx: database.NewTxManager(db, database.DefaultTxOptions)}
+}
+
+// conn транзакция из контекста или подключение к базе данных
+func (store *DbStore) conn(ctx context.Context) database.Executor {
+	return database.ConnFor(ctx, store.db, "service_account")
+}
+
+func (store *DbStore) Create(ctx context.Context, account *Entity, key *KeyEntity) (id int64, keyId int64, err error) {
+	err = store.tx.Do(ctx, func(ctx context.Context) error {
+		query := `INSERT INTO service_account (name, description, scopes, create_at, update_at)
+			VALUES ($1, $2, $3, $4, $5) RETURNING id`
+		err := store.conn(ctx).GetContext(ctx, &id, query, account.Name, account.Description, account.Scopes, account.Create, account.Update)
+		if err != nil {
+			return database.TranslateError(err)
+		}
+		key.AccountId = id
+		keyId, err = store.insertKey(ctx, key)
+		return err
+	})
+	return id, keyId, err
+}
+
+func (store *DbStore) insertKey(ctx context.Context, key *KeyEntity) (id int64, err error) {
+	query := `INSERT INTO api_key (service_account_id, prefix, hash, expires_at, create_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	err = store.conn(ctx).GetContext(ctx, &id, query, key.AccountId, key.Prefix, key.Hash, key.ExpiresAt, key.Create)
+	return id, database.TranslateError(err)
+}
+
+func (store *DbStore) FindAll(ctx context.Context) (accounts []Entity, err error) {
+	err = store.conn(ctx).SelectContext(ctx, &accounts, "SELECT * FROM service_account ORDER BY id")
+	return accounts, err
+}
+
+func (store *DbStore) FindById(ctx context.Context, id int64) (account Entity, err error) {
+	err = store.conn(ctx).GetContext(ctx, &account, "SELECT * FROM service_account WHERE id = $1", id)
+	return account, err
+}
+
+func (store *DbStore) FindKeys(ctx context.Context, accountIds []int64) (keys []KeyEntity, err error) {
+	if len(accountIds) == 0 {
+		return keys, nil
+	}
+	query, args, err := sqlx.In("SELECT * FROM api_key WHERE service_account_id IN (?) ORDER BY id", accountIds)
+	if err != nil {
+		return nil, err
+	}
+	err = store.conn(ctx).SelectContext(ctx, &keys, query, args...)
+	return keys, err
+}
+
+func (store *DbStore) FindKey(ctx context.Context, prefix string) (key KeyEntity, account Entity, err error) {
+	err = store.conn(ctx).GetContext(ctx, &key, "SELECT * FROM api_key WHERE prefix = $1", prefix)
+	if err != nil {
+		return key, account, err
+	}
+	account, err = store.FindById(ctx, key.AccountId)
+	return key, account, err
+}
+
+func (store *DbStore) AddKey(ctx context.Context, key *KeyEntity, overlapEnd time.Time) (id int64, err error) {
+	err = store.tx.Do(ctx, func(ctx context.Context) error {
+		query := `UPDATE api_key SET expires_at = $1
+			WHERE service_account_id = $2 AND (expires_at IS NULL OR expires_at > $1)`
+		if _, err := store.conn(ctx).ExecContext(ctx, query, overlapEnd, key.AccountId); err != nil {
+			return err
+		}
+		id, err = store.insertKey(ctx, key)
+		return err
+	})
+	return id, err
+}
+
+func (store *DbStore) TouchKey(ctx context.Context, id int64, now time.Time, interval time.Duration) error {
+	query := "UPDATE api_key SET last_used_at = $1 WHERE id = $2 AND (last_used_at IS NULL OR last_used_at < $3)"
+	_, err := store.conn(ctx).ExecContext(ctx, query, now, id, now.Add(-interval))
+	return err
+}
+
+func (store *DbStore) DeleteKey(ctx context.Context, accountId int64, id int64) (bool, error) {
+	result, err := store.conn(ctx).ExecContext(ctx, "DELETE FROM api_key WHERE id = $1 AND service_account_id = $2", id, accountId)
+	return deleted(result, err)
+}
+
+func (store *DbStore) Delete(ctx context.Context, id int64) (bool, error) {
+	// ключи удаляются каскадно
+	result, err := store.conn(ctx).ExecContext(ctx, "DELETE FROM service_account WHERE id = $1", id)
+	return deleted(result, err)
+}
+
+func deleted(result sql.Result, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// MemoryStore хранение аккаунтов в памяти процесса (STORAGE=memory) и для тестов
+type MemoryStore struct {
+	mutex     sync.Mutex
+	accounts  map[int64]Entity
+	keys      map[int64]KeyEntity
+	lastId    int64
+	lastKeyId int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{accounts: make(map[int64]Entity), keys: make(map[int64]KeyEntity)}
+}
+
+func (store *MemoryStore) Create(ctx context.Context, account *Entity, key *KeyEntity) (int64, int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// как уникальный индекс по LOWER(name)
+	for _, existing := range store.accounts {
+		if strings.EqualFold(existing.Name, account.Name) {
+			return 0, 0, common.AlreadyExistsError{Message: fmt.Sprintf("service_account already exists: name %s", account.Name)}
+		}
+	}
+	store.lastId++
+	var row = *account
+	row.Id = store.lastId
+	store.accounts[row.Id] = row
+
+	key.AccountId = row.Id
+	return row.Id, store.insertKey(key), nil
+}
+
+func (store *MemoryStore) insertKey(key *KeyEntity) int64 {
+	store.lastKeyId++
+	var row = *key
+	row.Id = store.lastKeyId
+	store.keys[row.Id] = row
+	return row.Id
+}
+
+func (store *MemoryStore) FindAll(ctx context.Context) ([]Entity, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var accounts []Entity
+	for _, id := range slices.Sorted(maps.Keys(store.accounts)) {
+		accounts = append(accounts, store.accounts[id])
+	}
+	return accounts, nil
+}
+
+func (store *MemoryStore) FindById(ctx context.Context, id int64) (Entity, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	account, ok := store.accounts[id]
+	if !ok {
+		return Entity{}, sql.ErrNoRows
+	}
+	return account, nil
+}
+
+func (store *MemoryStore) FindKeys(ctx context.Context, accountIds []int64) ([]KeyEntity, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var keys []KeyEntity
+	for _, id := range slices.Sorted(maps.Keys(store.keys)) {
+		if slices.Contains(accountIds, store.keys[id].AccountId) {
+			keys = append(keys, store.keys[id])
+		}
+	}
+	return keys, nil
+}
+
+func (store *MemoryStore) FindKey(ctx context.Context, prefix string) (KeyEntity, Entity, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for _, key := range store.keys {
+		if key.Prefix == prefix {
+			return key, store.accounts[key.AccountId], nil
+		}
+	}
+	return KeyEntity{}, Entity{}, sql.ErrNoRows
+}
+
+func (store *MemoryStore) AddKey(ctx context.Context, key *KeyEntity, overlapEnd time.Time) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for id, existing := range store.keys {
+		if existing.AccountId == key.AccountId && (existing.ExpiresAt == nil || existing.ExpiresAt.After(overlapEnd)) {
+			existing.ExpiresAt = &overlapEnd
+			store.keys[id] = existing
+		}
+	}
+	return store.insertKey(key), nil
+}
+
+func (store *MemoryStore) TouchKey(ctx context.Context, id int64, now time.Time, interval time.Duration) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	key, ok := store.keys[id]
+	if ok && (key.LastUsedAt == nil || key.LastUsedAt.Before(now.Add(-interval))) {
+		key.LastUsedAt = &now
+		store.keys[id] = key
+	}
+	return nil
+}
+
+func (store *MemoryStore) DeleteKey(ctx context.Context, accountId int64, id int64) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	key, ok := store.keys[id]
+	if !ok || key.AccountId != accountId {
+		return false, nil
+	}
+	delete(store.keys, id)
+	return true, nil
+}
+
+func (store *MemoryStore) Delete(ctx context.Context, id int64) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if _, ok := store.accounts[id]; !ok {
+		return false, nil
+	}
+	delete(store.accounts, id)
+	for keyId, key := range store.keys {
+		if key.AccountId == id {
+			delete(store.keys, keyId)
+		}
+	}
+	return true, nil
+}
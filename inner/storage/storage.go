@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"idm/inner/account"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/idempotency"
+	"idm/inner/memory"
+	"idm/inner/migration"
+	"idm/inner/oidc"
+	"idm/inner/ratelimit"
+	"idm/inner/role"
+	"idm/inner/serviceaccount"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Employees хранилище работников
+type Employees interface {
+	employee.Repo
+	Count(ctx context.Context) (count int64, err error)
+}
+
+// Roles хранилище ролей и их назначений работникам
+type Roles interface {
+	role.Repo
+	AssignTx(ctx context.Context, employeeId int64, roleIds []int64) error
+	FindByEmployeeId(ctx context.Context, employeeId int64) (entities []role.Entity, err error)
+	Count(ctx context.Context) (count int64, err error)
+	CountAssignments(ctx context.Context) (count int64, err error)
+}
+
+// Transactor транзакции, объединяющие запросы нескольких хранилищ
+type Transactor interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Storage хранилища данных приложения, выбранные конфигурацией (common.Config.Storage)
+type Storage struct {
+	Employees   Employees
+	Roles       Roles
+	Tx          Transactor
+	Idempotency idempotency.Store
+	// счётчики ограничения частоты запросов: в памяти экземпляра или в базе данных (common.Config.RateLimitStore)
+	RateLimits ratelimit.Store
+	// сервисные аккаунты и их API-ключи
+	ServiceAccounts serviceaccount.Store
+	// клиенты, ключи подписи и коды авторизации встроенного OIDC-провайдера
+	Oidc oidc.Store
+	// учётные записи работников для входа по паролю
+	Accounts account.Store
+	// DB подключение к базе данных для проверок работоспособности и миграций, nil для хранилища в памяти
+	DB *sqlx.DB
+	// Close освобождение ресурсов хранилища (закрытие подключения к базе данных)
+	Close func() error
+}
+
+// Open создание хранилищ: в базе данных (PostgreSQL или SQLite) или в памяти процесса
+func Open(cfg common.Config) (*Storage, error) {
+	switch cfg.Storage {
+	case common.StorageMemory:
+		if cfg.RateLimitStore == common.RateLimitStoreDatabase {
+			return nil, fmt.Errorf("rate limit store %s requires storage %s", cfg.RateLimitStore, common.StorageDatabase)
+		}
+		return NewMemory(), nil
+	case common.StorageDatabase, "":
+		db, err := database.ConnectDbWithCfg(cfg)
+		if err != nil {
+			return nil, err
+		}
+		// схема базы данных должна соответствовать коду до того, как к ней обратятся репозитории
+		if err = migration.OnStartup(context.Background(), db, cfg.Migrations); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+		var rateLimits ratelimit.Store = ratelimit.NewMemoryStore()
+		if cfg.RateLimitStore == common.RateLimitStoreDatabase {
+			rateLimits = ratelimit.NewDbStore(db)
+		}
+		return &Storage{
+			Employees:       employee.NewEmployeeRepository(db),
+			Roles:           role.NewRoleRepository(db),
+			Tx:              database.NewTxManager(db, database.DefaultTxOptions),
+			Idempotency:     idempotency.NewDbStore(db),
+			RateLimits:      rateLimits,
+			ServiceAccounts: serviceaccount.NewDbStore(db),
+			Oidc:            oidc.NewDbStore(db),
+			Accounts:        account.NewDbStore(db),
+			DB:              db,
+			Close:           db.Close,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage: %s", cfg.Storage)
+	}
+}
+
+// NewMemory хранилища в памяти процесса: данные не сохраняются между запусками
+func NewMemory() *Storage {
+	var db = memory.NewDB()
+	return &Storage{
+		Employees:       employee.NewEmployeeMemoryRepository(db),
+		Roles:           role.NewRoleMemoryRepository(db),
+		Tx:              db,
+		Idempotency:     idempotency.NewMemoryStore(),
+		RateLimits:      ratelimit.NewMemoryStore(),
+		ServiceAccounts: serviceaccount.NewMemoryStore(),
+		Oidc:            oidc.NewMemoryStore(),
+		Accounts:        account.NewMemoryStore(),
+		Close:           func() error { return nil },
+	}
+}
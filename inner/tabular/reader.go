@@ -0,0 +1,95 @@
+package tabular
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Format формат табличного файла
+type Format string
+
+const (
+	CSV  Format = "csv"
+	XLSX Format = "xlsx"
+)
+
+// FormatFromFileName определение формата по расширению имени файла
+func FormatFromFileName(name string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return CSV, nil
+	case ".xlsx":
+		return XLSX, nil
+	default:
+		return "", fmt.Errorf("unsupported file format: %s", name)
+	}
+}
+
+// Read чтение таблицы целиком: первая строка файла считается заголовком
+func Read(reader io.Reader, format Format) (header []string, rows [][]string, err error) {
+	var records [][]string
+	switch format {
+	case CSV:
+		records, err = readCSV(reader)
+	case XLSX:
+		records, err = readXLSX(reader)
+	default:
+		return nil, nil, fmt.Errorf("unsupported file format: %s", format)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil, errors.New("file is empty")
+	}
+	return records[0], records[1:], nil
+}
+
+// UTF-8 BOM, который добавляет Excel при сохранении в CSV
+var bom = []byte{0xEF, 0xBB, 0xBF}
+
+func readCSV(reader io.Reader) ([][]string, error) {
+	var buffered = bufio.NewReader(reader)
+	if prefix, err := buffered.Peek(len(bom)); err == nil && bytes.Equal(prefix, bom) {
+		_, _ = buffered.Discard(len(bom))
+	}
+
+	// русскоязычный Excel сохраняет CSV с разделителем ";", поэтому определяем его по заголовку
+	var comma = ','
+	// ошибку Peek не проверяем: для коротких файлов будет io.EOF, но прочитанные данные всё равно вернутся
+	head, _ := buffered.Peek(buffered.Size())
+	line, _, _ := bytes.Cut(head, []byte("\n"))
+	if bytes.Count(line, []byte(";")) > bytes.Count(line, []byte(",")) {
+		comma = ';'
+	}
+
+	var csvReader = csv.NewReader(buffered)
+	csvReader.Comma = comma
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+	return csvReader.ReadAll()
+}
+
+func readXLSX(reader io.Reader) ([][]string, error) {
+	file, err := excelize.OpenReader(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error opening xlsx file: %w", err)
+	}
+	defer file.Close()
+
+	var sheets = file.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, errors.New("xlsx file has no sheets")
+	}
+	// импортируем только первый лист книги
+	return file.GetRows(sheets[0])
+}
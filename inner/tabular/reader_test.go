@@ -0,0 +1,66 @@
+package tabular
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestFormatFromFileName(t *testing.T) {
+	a := assert.New(t)
+
+	format, err := FormatFromFileName("employees.CSV")
+	a.NoError(err)
+	a.Equal(CSV, format)
+
+	format, err = FormatFromFileName("employees.xlsx")
+	a.NoError(err)
+	a.Equal(XLSX, format)
+
+	_, err = FormatFromFileName("employees.txt")
+	a.Error(err)
+}
+
+func TestReadCSV(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should read csv with comma delimiter", func(t *testing.T) {
+		header, rows, err := Read(strings.NewReader("name,create_at\nPupkin,2025-01-02\n"), CSV)
+		a.NoError(err)
+		a.Equal([]string{"name", "create_at"}, header)
+		a.Equal([][]string{{"Pupkin", "2025-01-02"}}, rows)
+	})
+
+	t.Run("should read excel csv with BOM and semicolon delimiter", func(t *testing.T) {
+		var content = "\xEF\xBB\xBFФИО;Дата\nПупкин, Василий;02.01.2025\n"
+		header, rows, err := Read(strings.NewReader(content), CSV)
+		a.NoError(err)
+		a.Equal([]string{"ФИО", "Дата"}, header)
+		a.Equal([][]string{{"Пупкин, Василий", "02.01.2025"}}, rows)
+	})
+
+	t.Run("should return error for empty file", func(t *testing.T) {
+		_, _, err := Read(strings.NewReader(""), CSV)
+		a.Error(err)
+	})
+}
+
+func TestReadXLSX(t *testing.T) {
+	a := assert.New(t)
+
+	var file = excelize.NewFile()
+	var sheet = file.GetSheetName(0)
+	a.NoError(file.SetSheetRow(sheet, "A1", &[]any{"name", "create_at"}))
+	a.NoError(file.SetSheetRow(sheet, "A2", &[]any{"Pupkin", "2025-01-02"}))
+	var buffer bytes.Buffer
+	_, err := file.WriteTo(&buffer)
+	a.NoError(err)
+
+	header, rows, err := Read(&buffer, XLSX)
+	a.NoError(err)
+	a.Equal([]string{"name", "create_at"}, header)
+	a.Equal([][]string{{"Pupkin", "2025-01-02"}}, rows)
+}
@@ -0,0 +1,200 @@
+package tabular
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// NDJSON формат JSON Lines: один JSON-объект в строке
+const NDJSON Format = "ndjson"
+
+// Column описание колонки выгрузки: заголовок и способ получения значения из элемента
+type Column[T any] struct {
+	Title string
+	Value func(item T) any
+}
+
+// Encoder построчная запись элементов в выходной поток
+type Encoder[T any] interface {
+	Encode(item T) error
+	// Close дописывает буферизованные данные, сам выходной поток не закрывается
+	Close() error
+	// Discard освобождение ресурсов без записи оставшихся данных, если выгрузка прервана ошибкой
+	Discard()
+}
+
+// ParseFormat получение формата выгрузки по его имени или MIME-типу
+func ParseFormat(value string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "csv", "text/csv":
+		return CSV, nil
+	case "ndjson", "jsonl", "application/x-ndjson", "application/jsonl":
+		return NDJSON, nil
+	case "xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":
+		return XLSX, nil
+	default:
+		return "", fmt.Errorf("unsupported export format: %s", value)
+	}
+}
+
+// NegotiateFormat выбор формата выгрузки: параметр запроса format важнее заголовка Accept, по умолчанию CSV
+func NegotiateFormat(query string, accept string) (Format, error) {
+	if query != "" {
+		return ParseFormat(query)
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		if format, err := ParseFormat(mediaType); err == nil {
+			return format, nil
+		}
+	}
+	return CSV, nil
+}
+
+// ContentType MIME-тип для формата
+func ContentType(format Format) string {
+	switch format {
+	case NDJSON:
+		return "application/x-ndjson"
+	case XLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// NewEncoder создание кодировщика для формата: для табличных форматов первой строкой пишется заголовок
+func NewEncoder[T any](writer io.Writer, format Format, columns []Column[T]) (Encoder[T], error) {
+	switch format {
+	case CSV:
+		return newCsvEncoder(writer, columns)
+	case NDJSON:
+		return &ndjsonEncoder[T]{encoder: json.NewEncoder(writer)}, nil
+	case XLSX:
+		return newXlsxEncoder(writer, columns)
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+type csvEncoder[T any] struct {
+	writer  *csv.Writer
+	columns []Column[T]
+	record  []string
+}
+
+func newCsvEncoder[T any](writer io.Writer, columns []Column[T]) (*csvEncoder[T], error) {
+	var encoder = &csvEncoder[T]{
+		writer:  csv.NewWriter(writer),
+		columns: columns,
+		record:  make([]string, len(columns)),
+	}
+	for i, column := range columns {
+		encoder.record[i] = column.Title
+	}
+	return encoder, encoder.writer.Write(encoder.record)
+}
+
+func (enc *csvEncoder[T]) Encode(item T) error {
+	for i, column := range enc.columns {
+		enc.record[i] = fmt.Sprint(escapeFormula(column.Value(item)))
+	}
+	return enc.writer.Write(enc.record)
+}
+
+func (enc *csvEncoder[T]) Close() error {
+	enc.writer.Flush()
+	return enc.writer.Error()
+}
+
+func (enc *csvEncoder[T]) Discard() {}
+
+type ndjsonEncoder[T any] struct {
+	encoder *json.Encoder
+}
+
+func (enc *ndjsonEncoder[T]) Encode(item T) error {
+	return enc.encoder.Encode(item)
+}
+
+func (enc *ndjsonEncoder[T]) Close() error {
+	return nil
+}
+
+func (enc *ndjsonEncoder[T]) Discard() {}
+
+// xlsxEncoder использует потоковую запись excelize: строки сбрасываются во временный файл,
+// а не копятся в памяти, сама книга пишется в выходной поток при закрытии
+type xlsxEncoder[T any] struct {
+	writer  io.Writer
+	file    *excelize.File
+	stream  *excelize.StreamWriter
+	columns []Column[T]
+	row     int
+}
+
+func newXlsxEncoder[T any](writer io.Writer, columns []Column[T]) (*xlsxEncoder[T], error) {
+	var file = excelize.NewFile()
+	stream, err := file.NewStreamWriter(file.GetSheetName(0))
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	var encoder = &xlsxEncoder[T]{writer: writer, file: file, stream: stream, columns: columns, row: 1}
+	var header = make([]any, len(columns))
+	for i, column := range columns {
+		header[i] = column.Title
+	}
+	if err = encoder.writeRow(header); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return encoder, nil
+}
+
+func (enc *xlsxEncoder[T]) Encode(item T) error {
+	var values = make([]any, len(enc.columns))
+	for i, column := range enc.columns {
+		values[i] = escapeFormula(column.Value(item))
+	}
+	return enc.writeRow(values)
+}
+
+func (enc *xlsxEncoder[T]) writeRow(values []any) error {
+	cellName, err := excelize.CoordinatesToCellName(1, enc.row)
+	if err != nil {
+		return err
+	}
+	enc.row++
+	return enc.stream.SetRow(cellName, values)
+}
+
+func (enc *xlsxEncoder[T]) Close() error {
+	defer enc.file.Close()
+	if err := enc.stream.Flush(); err != nil {
+		return err
+	}
+	_, err := enc.file.WriteTo(enc.writer)
+	return err
+}
+
+// Discard удаляет временные файлы потоковой записи
+func (enc *xlsxEncoder[T]) Discard() {
+	_ = enc.file.Close()
+}
+
+// escapeFormula строка, которую табличный редактор примет за формулу (=, +, -, @, табуляция, перевод каретки
+// в начале), выгружается с префиксом ', чтобы значение из базы данных не выполнилось у получателя выгрузки
+func escapeFormula(value any) any {
+	text, ok := value.(string)
+	if ok && text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return value
+}
@@ -0,0 +1,87 @@
+package tabular
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type item struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+var itemColumns = []Column[item]{
+	{Title: "id", Value: func(it item) any { return it.Id }},
+	{Title: "name", Value: func(it item) any { return it.Name }},
+}
+
+func encodeAll(t *testing.T, format Format, items ...item) []byte {
+	var buffer bytes.Buffer
+	encoder, err := NewEncoder(&buffer, format, itemColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, it := range items {
+		if err = encoder.Encode(it); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = encoder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestEncoder(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should write csv with header", func(t *testing.T) {
+		var data = encodeAll(t, CSV, item{1, "Pupkin"}, item{2, "Doe, John"})
+		a.Equal("id,name\n1,Pupkin\n2,\"Doe, John\"\n", string(data))
+	})
+
+	t.Run("should write json lines", func(t *testing.T) {
+		var data = encodeAll(t, NDJSON, item{1, "Pupkin"}, item{2, "Doe"})
+		a.Equal("{\"id\":1,\"name\":\"Pupkin\"}\n{\"id\":2,\"name\":\"Doe\"}\n", string(data))
+	})
+
+	t.Run("should write xlsx readable by Read", func(t *testing.T) {
+		var data = encodeAll(t, XLSX, item{1, "Pupkin"})
+		header, rows, err := Read(bytes.NewReader(data), XLSX)
+		a.NoError(err)
+		a.Equal([]string{"id", "name"}, header)
+		a.Equal([][]string{{"1", "Pupkin"}}, rows)
+	})
+
+	t.Run("should escape cells that start a formula", func(t *testing.T) {
+		var items = []item{{1, "=HYPERLINK(\"http://evil\")"}, {-2, "+7 900"}, {3, "-x"}, {4, "@SUM(A1)"}}
+		var data = encodeAll(t, CSV, items...)
+		a.Equal("id,name\n1,\"'=HYPERLINK(\"\"http://evil\"\")\"\n-2,'+7 900\n3,'-x\n4,'@SUM(A1)\n", string(data))
+
+		data = encodeAll(t, XLSX, items[0])
+		_, rows, err := Read(bytes.NewReader(data), XLSX)
+		a.NoError(err)
+		a.Equal([][]string{{"1", "'=HYPERLINK(\"http://evil\")"}}, rows)
+	})
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	a := assert.New(t)
+
+	format, err := NegotiateFormat("", "")
+	a.NoError(err)
+	a.Equal(CSV, format)
+
+	format, err = NegotiateFormat("", "text/html, application/x-ndjson;q=0.9")
+	a.NoError(err)
+	a.Equal(NDJSON, format)
+
+	format, err = NegotiateFormat("xlsx", "text/csv")
+	a.NoError(err)
+	a.Equal(XLSX, format)
+
+	_, err = NegotiateFormat("pdf", "")
+	a.Error(err)
+}
@@ -0,0 +1,134 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"idm/inner/common"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// экспортёры трассировки (TRACING_EXPORTER)
+const (
+	// трассировка выключена: span не записываются, но traceparent входящих запросов передаётся дальше
+	ExporterNone = "none"
+	// OTLP по HTTP: адрес коллектора из TRACING_ENDPOINT или стандартных переменных OTEL_EXPORTER_OTLP_*
+	ExporterOTLP = "otlp"
+	// span в виде JSON в стандартный вывод
+	ExporterStdout = "stdout"
+	// span в виде JSON в файл TRACING_FILE, подходит для окружений без коллектора
+	ExporterFile = "file"
+)
+
+// имя инструментирования, под которым создаются все span приложения
+const instrumentation = "idm"
+
+// tracer трассировщик глобального провайдера: до вызова Setup (и без него) span не записываются.
+// Берётся при каждом вызове, а не один раз, чтобы следовать замене провайдера (например, в тестах).
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Setup глобальный провайдер трассировки с экспортёром из конфигурации и распространение контекста в формате W3C
+// (заголовки traceparent, tracestate и baggage). Возвращаемая функция отправляет накопленные span и закрывает экспортёр.
+func Setup(ctx context.Context, cfg common.Config) (shutdown func(ctx context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	exporter, closer, err := newExporter(ctx, cfg)
+	if err != nil || exporter == nil {
+		return func(ctx context.Context) error { return nil }, err
+	}
+
+	var provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(cfg.AppName),
+			semconv.ServiceVersion(cfg.AppVersion),
+		)),
+		// решение о записи принимает вызывающий сервис, если он передал traceparent
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		var err = provider.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer.Close())
+		}
+		return err
+	}, nil
+}
+
+// newExporter экспортёр TRACING_EXPORTER и файл, который нужно закрыть после него (только для file)
+func newExporter(ctx context.Context, cfg common.Config) (sdktrace.SpanExporter, io.Closer, error) {
+	switch cfg.TracingExporter {
+	case "", ExporterNone:
+		return nil, nil, nil
+	case ExporterOTLP:
+		var options []otlptracehttp.Option
+		if cfg.TracingEndpoint != "" {
+			options = append(options, otlptracehttp.WithEndpointURL(cfg.TracingEndpoint))
+		}
+		exporter, err := otlptracehttp.New(ctx, options...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating OTLP trace exporter: %w", err)
+		}
+		return exporter, nil, nil
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		return exporter, nil, err
+	case ExporterFile:
+		file, err := os.OpenFile(cfg.TracingFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error opening trace file: %w", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			_ = file.Close()
+			return nil, nil, err
+		}
+		return exporter, file, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown trace exporter: %s", cfg.TracingExporter)
+	}
+}
+
+// Start начало span - потомка span из ctx. Завершается через defer End(span, &err).
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// StartServer начало span входящего запроса: родитель берётся из заголовков запроса (traceparent), если он есть
+func StartServer(ctx context.Context, headers propagation.TextMapCarrier, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, headers)
+	return tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attributes...))
+}
+
+// End завершение span; ошибка, если она есть, записывается в span.
+// Ошибка передаётся указателем, чтобы через defer прочитать именованный результат после выхода из функции.
+func End(span trace.Span, errPtr *error) {
+	if err := *errPtr; err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Validate проверка запроса в отдельном span: время валидации видно отдельно от запросов к базе данных
+func Validate(ctx context.Context, validator interface{ Validate(request any) error }, request any) (err error) {
+	_, span := Start(ctx, "validate", attribute.String("request.type", fmt.Sprintf("%T", request)))
+	defer End(span, &err)
+	return validator.Validate(request)
+}
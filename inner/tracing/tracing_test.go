@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"idm/inner/common"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type validatorFunc func(request any) error
+
+func (fn validatorFunc) Validate(request any) error {
+	return fn(request)
+}
+
+func TestSpans(t *testing.T) {
+	a := assert.New(t)
+
+	var recorder = tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(trace.NewNoopTracerProvider()) })
+
+	var invalid = validatorFunc(func(request any) error { return errors.New("name is required") })
+	var save = func(ctx context.Context) (err error) {
+		ctx, span := Start(ctx, "employee.Save")
+		defer End(span, &err)
+		return Validate(ctx, invalid, struct{}{})
+	}
+
+	a.EqualError(save(context.Background()), "name is required")
+
+	var spans = recorder.Ended()
+	a.Len(spans, 2)
+	var validate, service = spans[0], spans[1]
+	a.Equal("validate", validate.Name())
+	a.Equal(service.SpanContext().SpanID(), validate.Parent().SpanID())
+	a.Equal("employee.Save", service.Name())
+	a.Equal(codes.Error, service.Status().Code)
+	a.Equal("name is required", service.Status().Description)
+}
+
+func TestSetup(t *testing.T) {
+	a := assert.New(t)
+	t.Cleanup(func() { otel.SetTracerProvider(trace.NewNoopTracerProvider()) })
+
+	t.Run("should write spans to file", func(t *testing.T) {
+		var path = filepath.Join(t.TempDir(), "traces.json")
+		shutdown, err := Setup(context.Background(), common.Config{AppName: "idm", AppVersion: "1.0.1", TracingExporter: ExporterFile, TracingFile: path})
+		a.Nil(err)
+
+		_, span := Start(context.Background(), "employee.FindById")
+		span.End()
+		a.Nil(shutdown(context.Background()))
+
+		content, err := os.ReadFile(path)
+		a.Nil(err)
+		a.Contains(string(content), `"Name":"employee.FindById"`)
+		a.Contains(string(content), `"Value":"idm"`)
+	})
+
+	t.Run("should not export spans when disabled", func(t *testing.T) {
+		shutdown, err := Setup(context.Background(), common.Config{TracingExporter: ExporterNone})
+		a.Nil(err)
+		a.Nil(shutdown(context.Background()))
+	})
+
+	t.Run("should fail with unknown exporter", func(t *testing.T) {
+		_, err := Setup(context.Background(), common.Config{TracingExporter: "jaeger"})
+		a.EqualError(err, "unknown trace exporter: jaeger")
+	})
+}
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"idm/inner/common"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber"
+)
+
+// StatusClientClosedRequest нестандартный код 499 для журнала запросов: клиент закрыл соединение,
+// не дождавшись ответа
+const StatusClientClosedRequest = 499
+
+// ErrClientClosed причина отмены контекста запроса, если клиент закрыл соединение
+var ErrClientClosed = errors.New("client closed connection")
+
+// ключи в ctx.Locals, под которыми middleware Timeout хранит контекст и таймаут запроса,
+// а сервер - базовый контекст запросов
+const (
+	localsContext     = "requestContext"
+	localsTimeout     = "requestTimeout"
+	localsBaseContext = "baseContext"
+)
+
+// Timeouts таймауты запросов: значение по умолчанию и переопределения для маршрутов.
+// Ключ переопределения - метод и префикс пути, например "GET /api/v1/employees/export".
+type Timeouts struct {
+	Default time.Duration
+	Routes  map[string]time.Duration
+}
+
+// For таймаут для запроса: берётся переопределение с самым длинным подходящим префиксом пути
+func (timeouts Timeouts) For(method string, path string) time.Duration {
+	var timeout = timeouts.Default
+	var matched = -1
+	for route, routeTimeout := range timeouts.Routes {
+		routeMethod, prefix, ok := strings.Cut(route, " ")
+		if !ok || !strings.EqualFold(routeMethod, method) || !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		if len(prefix) > matched {
+			matched = len(prefix)
+			timeout = routeTimeout
+		}
+	}
+	return timeout
+}
+
+// Timeout middleware, создающий для запроса контекст с таймаутом.
+// Хендлеры получают его через Context и передают в сервисы и репозитории, поэтому медленный запрос
+// к базе данных прерывается по истечении таймаута или при закрытии соединения клиентом, и подключение
+// из пула освобождается. Если после этого хендлер ответил ошибкой сервера, ответ заменяется на 504 Gateway Timeout,
+// 499 (клиент закрыл соединение, код виден только в журнале) или 503 Service Unavailable (остановка сервера).
+func Timeout(timeouts Timeouts) fiber.Handler {
+	return TimeoutFrom(func() Timeouts { return timeouts })
+}
+
+// TimeoutFrom middleware Timeout с таймаутами, которые читаются при каждом запросе (перезагружаемая конфигурация)
+func TimeoutFrom(timeouts func() Timeouts) fiber.Handler {
+	return func(c *fiber.Ctx) {
+		var timeout = timeouts().For(c.Method(), c.Path())
+		timeoutCtx, cancel := context.WithTimeout(baseContext(c), timeout)
+		defer cancel()
+		requestCtx, cancelCause := context.WithCancelCause(timeoutCtx)
+		defer cancelCause(nil)
+		var stopWatching = watchDisconnect(c.Fasthttp.Conn(), cancelCause)
+		defer stopWatching()
+
+		c.Locals(localsContext, requestCtx)
+		c.Locals(localsTimeout, timeout)
+		c.Next()
+
+		if c.Fasthttp.Response.StatusCode() < fiber.StatusInternalServerError {
+			return
+		}
+		switch err := requestCtx.Err(); {
+		case errors.Is(context.Cause(requestCtx), ErrClientClosed):
+			_ = common.ErrResponse(c, StatusClientClosedRequest, "client closed request")
+		case errors.Is(err, context.DeadlineExceeded):
+			_ = common.ErrResponse(c, fiber.StatusGatewayTimeout, "request timed out after "+timeout.String())
+		case errors.Is(err, context.Canceled):
+			_ = common.ErrResponse(c, fiber.StatusServiceUnavailable, "request canceled: server is shutting down")
+		}
+	}
+}
+
+// baseContext базовый контекст сервера (см. NewServer), без него - контекст fasthttp
+func baseContext(c *fiber.Ctx) context.Context {
+	if base, ok := c.Locals(localsBaseContext).(context.Context); ok {
+		return base
+	}
+	return c.Context()
+}
+
+// Context контекст запроса, созданный middleware Timeout. Без него - базовый контекст запроса из NewServer
+// (идентификатор запроса, span, отмена при остановке сервера), вне сервера - context.Background()
+func Context(c *fiber.Ctx) context.Context {
+	if requestCtx, ok := c.Locals(localsContext).(context.Context); ok {
+		return requestCtx
+	}
+	if base, ok := c.Locals(localsBaseContext).(context.Context); ok {
+		return base
+	}
+	return context.Background()
+}
+
+// SetContext замена контекста запроса производным от Context, например со сведениями о вызывающем
+func SetContext(c *fiber.Ctx, ctx context.Context) {
+	c.Locals(localsContext, ctx)
+}
+
+// RequestTimeout таймаут запроса, назначенный middleware Timeout (без него - 0).
+// Нужен хендлерам, которые продолжают работу после выхода из хендлера, как потоковая выгрузка.
+func RequestTimeout(c *fiber.Ctx) time.Duration {
+	timeout, _ := c.Locals(localsTimeout).(time.Duration)
+	return timeout
+}
@@ -0,0 +1,87 @@
+package web
+
+import (
+	"errors"
+	"idm/inner/common"
+	"idm/inner/logging"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeoutsFor(t *testing.T) {
+	var a = assert.New(t)
+	var timeouts = Timeouts{
+		Default: time.Second,
+		Routes: map[string]time.Duration{
+			"GET /api/v1/employees":        2 * time.Second,
+			"GET /api/v1/employees/export": time.Minute,
+		},
+	}
+
+	a.Equal(time.Minute, timeouts.For(fiber.MethodGet, "/api/v1/employees/export"))
+	a.Equal(2*time.Second, timeouts.For(fiber.MethodGet, "/api/v1/employees/id/1"))
+	a.Equal(time.Second, timeouts.For(fiber.MethodPost, "/api/v1/employees"))
+	a.Equal(time.Second, timeouts.For(fiber.MethodGet, "/api/v1/roles"))
+}
+
+func TestTimeout(t *testing.T) {
+	var a = assert.New(t)
+
+	// хендлер ждёт отмены контекста так же, как ждал бы медленный запрос к базе данных
+	var slowHandler = func(c *fiber.Ctx) {
+		<-Context(c).Done()
+		_ = common.ErrResponse(c, fiber.StatusInternalServerError, Context(c).Err().Error())
+	}
+
+	t.Run("should respond 504 when request context times out", func(t *testing.T) {
+		var server = NewServer()
+		server.GroupApiV1.Use(Timeout(Timeouts{Default: 20 * time.Millisecond}))
+		server.GroupApiV1.Get("/slow", slowHandler)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/slow", nil))
+
+		a.Nil(err)
+		a.Equal(http.StatusGatewayTimeout, resp.StatusCode)
+	})
+
+	t.Run("should keep response of fast handler", func(t *testing.T) {
+		var server = NewServer()
+		server.GroupApiV1.Use(Timeout(Timeouts{Default: time.Second}))
+		server.GroupApiV1.Get("/fast", func(c *fiber.Ctx) {
+			var deadline, ok = Context(c).Deadline()
+			a.True(ok)
+			a.True(time.Until(deadline) <= time.Second)
+			a.Equal(time.Second, RequestTimeout(c))
+			_ = common.ErrResponse(c, fiber.StatusInternalServerError, errors.New("database error").Error())
+		})
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/fast", nil))
+
+		a.Nil(err)
+		a.Equal(http.StatusInternalServerError, resp.StatusCode)
+	})
+
+	t.Run("should use base request context without middleware", func(t *testing.T) {
+		var server = NewServer()
+		server.GroupApiV1.Get("/plain", func(c *fiber.Ctx) {
+			var _, hasDeadline = Context(c).Deadline()
+			a.False(hasDeadline)
+			a.NotNil(Context(c).Done())
+			a.Equal("request-1", logging.RequestID(Context(c)))
+			a.Zero(RequestTimeout(c))
+			c.SendStatus(fiber.StatusOK)
+		})
+
+		var req = httptest.NewRequest(fiber.MethodGet, "/api/v1/plain", nil)
+		req.Header.Set(fiber.HeaderXRequestID, "request-1")
+		resp, err := server.App.Test(req)
+
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+}
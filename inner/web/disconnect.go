@@ -0,0 +1,54 @@
+//go:build unix
+
+package web
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// watchDisconnect наблюдение за соединением клиента, пока обрабатывается запрос: если клиент закрыл
+// соединение, cancel вызывается с ErrClientClosed. Данные из соединения не читаются (MSG_PEEK),
+// поэтому следующий запрос keep-alive соединения остаётся fasthttp. Если клиент уже прислал следующий запрос,
+// закрытие больше не отслеживается. Для соединений без доступа к сокету (TLS, тестовые) наблюдение не ведётся.
+// stop прекращает наблюдение и должен быть вызван до выхода из хендлера.
+func watchDisconnect(conn net.Conn, cancel context.CancelCauseFunc) (stop func()) {
+	sysConn, ok := conn.(syscall.Conn)
+	if !ok {
+		return func() {}
+	}
+	rawConn, err := sysConn.SyscallConn()
+	if err != nil {
+		return func() {}
+	}
+
+	var done = make(chan struct{})
+	go func() {
+		defer close(done)
+		var buf [1]byte
+		var closed bool
+		// ошибка Read - истёкший срок чтения, которым stop прерывает ожидание
+		_ = rawConn.Read(func(fd uintptr) bool {
+			n, _, err := syscall.Recvfrom(int(fd), buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+			if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) {
+				return false
+			}
+			// n == 0 без ошибки - клиент закрыл соединение, ошибка - соединение разорвано
+			closed = n == 0 || err != nil
+			return true
+		})
+		if closed {
+			cancel(ErrClientClosed)
+		}
+	}()
+
+	return func() {
+		// срок чтения в прошлом будит ожидание; fasthttp назначает свой срок перед чтением следующего запроса
+		_ = conn.SetReadDeadline(time.Unix(1, 0))
+		<-done
+		_ = conn.SetReadDeadline(time.Time{})
+	}
+}
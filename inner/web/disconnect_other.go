@@ -0,0 +1,13 @@
+//go:build !unix
+
+package web
+
+import (
+	"context"
+	"net"
+)
+
+// watchDisconnect на платформах без MSG_PEEK закрытие соединения клиентом не отслеживается
+func watchDisconnect(conn net.Conn, cancel context.CancelCauseFunc) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,117 @@
+package web
+
+import (
+	"fmt"
+	"idm/inner/common"
+	"idm/inner/logging"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber"
+	"github.com/google/uuid"
+)
+
+// максимальная длина X-Request-ID, принимаемого от клиента: более длинный заменяется новым
+const maxRequestIdLength = 128
+
+// ключ в ctx.Locals, под которым проверка аутентификации сохраняет вызывающего (см. SetCaller)
+const localsCaller = "caller"
+
+// RequestID middleware, назначающий запросу идентификатор: берётся из заголовка X-Request-ID
+// (например, от балансировщика или вызывающего сервиса) или создаётся новый. Идентификатор возвращается
+// в заголовке ответа и в теле ответов с ошибкой, а через базовый контекст запроса попадает во все записи логов.
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) {
+		var requestId = c.Get(fiber.HeaderXRequestID)
+		if !validRequestId(requestId) {
+			requestId = uuid.NewString()
+		} else {
+			// строки fiber ссылаются на буферы fasthttp, которые переиспользуются следующими запросами
+			requestId = strings.Clone(requestId)
+		}
+		c.Set(fiber.HeaderXRequestID, requestId)
+		c.Locals(localsBaseContext, logging.WithRequestID(baseContext(c), requestId))
+		c.Next()
+	}
+}
+
+// validRequestId идентификатор от клиента попадает в логи и ответ, поэтому допускаются только печатные ASCII-символы
+func validRequestId(requestId string) bool {
+	if requestId == "" || len(requestId) > maxRequestIdLength {
+		return false
+	}
+	for _, char := range requestId {
+		if char < '!' || char > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// Recover middleware, перехватывающий панику хендлера: она записывается в лог со стеком вызовов,
+// а клиент получает ответ 500 с идентификатором запроса вместо разорванного соединения
+func Recover() fiber.Handler {
+	return func(c *fiber.Ctx) {
+		defer func() {
+			if r := recover(); r != nil {
+				slog.ErrorContext(baseContext(c), "panic in request handler",
+					"panic", fmt.Sprint(r),
+					"stack", string(debug.Stack()),
+				)
+				_ = common.ErrResponse(c, fiber.StatusInternalServerError, "internal server error")
+			}
+		}()
+		c.Next()
+	}
+}
+
+// AccessLog middleware, записывающий в лог каждый запрос: метод, шаблон маршрута, код ответа, время обработки
+// и вызывающего. Ответы 5xx записываются с уровнем error, 4xx - warn, остальные - info.
+func AccessLog() fiber.Handler {
+	return func(c *fiber.Ctx) {
+		var started = time.Now()
+		var own = c.Route()
+		c.Next()
+
+		var status = c.Fasthttp.Response.StatusCode()
+		var level = slog.LevelInfo
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= fiber.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		var ctx = baseContext(c)
+		if !slog.Default().Enabled(ctx, level) {
+			return
+		}
+
+		var attrs = []slog.Attr{
+			slog.String("method", c.Method()),
+			slog.String("path", c.Path()),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(started)),
+			slog.String("ip", c.IP()),
+		}
+		if route, ok := MatchedRoute(c, own); ok {
+			attrs = append(attrs, slog.String("route", route))
+		}
+		if caller := Caller(c); caller != "" {
+			attrs = append(attrs, slog.String("caller", caller))
+		}
+		slog.LogAttrs(ctx, level, "request", attrs...)
+	}
+}
+
+// SetCaller вызывающий запроса (пользователь или сервисный аккаунт) для журнала запросов
+func SetCaller(c *fiber.Ctx, caller string) {
+	c.Locals(localsCaller, caller)
+}
+
+// Caller вызывающий запроса, сохранённый SetCaller (без аутентификации - пустая строка)
+func Caller(c *fiber.Ctx) string {
+	caller, _ := c.Locals(localsCaller).(string)
+	return caller
+}
@@ -0,0 +1,103 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"idm/inner/common"
+	"idm/inner/logging"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber"
+	"github.com/stretchr/testify/assert"
+)
+
+// captureLogs записи логов в формате JSON на время теста
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var output bytes.Buffer
+	var previous = slog.Default()
+	slog.SetDefault(logging.New(&output, logging.FormatJSON, slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &output
+}
+
+// logRecords записи логов с сообщением message
+func logRecords(output *bytes.Buffer, message string) (records []map[string]any) {
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		var record map[string]any
+		if json.Unmarshal([]byte(line), &record) == nil && record["msg"] == message {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func TestRequestLogging(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should propagate request id to response, error body and access log", func(t *testing.T) {
+		var output = captureLogs(t)
+		var server = NewServer()
+		server.GroupApiV1.Get("/employees/id/:id", func(c *fiber.Ctx) {
+			SetCaller(c, "service-account:reports")
+			_ = common.ErrResponse(c, fiber.StatusNotFound, "employee with id 1 not found")
+		})
+
+		var req = httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/id/1", nil)
+		req.Header.Set(fiber.HeaderXRequestID, "req-42")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(fiber.StatusNotFound, resp.StatusCode)
+		a.Equal("req-42", resp.Header.Get(fiber.HeaderXRequestID))
+		var body common.ResponseBody[any]
+		a.Nil(json.NewDecoder(resp.Body).Decode(&body))
+		a.Equal("req-42", body.RequestId)
+
+		var records = logRecords(output, "request")
+		a.Len(records, 1)
+		a.Equal("WARN", records[0]["level"])
+		a.Equal("req-42", records[0]["request_id"])
+		a.Equal("/api/v1/employees/id/:id", records[0]["route"])
+		a.Equal(float64(fiber.StatusNotFound), records[0]["status"])
+		a.Equal("service-account:reports", records[0]["caller"])
+	})
+
+	t.Run("should generate request id when header is missing or invalid", func(t *testing.T) {
+		captureLogs(t)
+		var server = NewServer()
+		server.GroupApiV1.Get("/ping", func(c *fiber.Ctx) { c.SendString("pong") })
+
+		for _, header := range []string{"", "bad id\twith spaces", strings.Repeat("x", maxRequestIdLength+1)} {
+			var req = httptest.NewRequest(fiber.MethodGet, "/api/v1/ping", nil)
+			req.Header.Set(fiber.HeaderXRequestID, header)
+			resp, err := server.App.Test(req)
+			a.Nil(err)
+			var requestId = resp.Header.Get(fiber.HeaderXRequestID)
+			a.Len(requestId, 36)
+			a.NotEqual(header, requestId)
+		}
+	})
+
+	t.Run("should answer 500 and log panic of handler", func(t *testing.T) {
+		var output = captureLogs(t)
+		var server = NewServer()
+		server.GroupApiV1.Get("/panic", func(c *fiber.Ctx) { panic("nil map") })
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/panic", nil))
+		a.Nil(err)
+		a.Equal(fiber.StatusInternalServerError, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		a.Contains(string(body), `"requestId":"`+resp.Header.Get(fiber.HeaderXRequestID)+`"`)
+
+		var panics = logRecords(output, "panic in request handler")
+		a.Len(panics, 1)
+		a.Equal("nil map", panics[0]["panic"])
+		a.Contains(panics[0]["stack"], "runtime/debug.Stack")
+		var requests = logRecords(output, "request")
+		a.Len(requests, 1)
+		a.Equal("ERROR", requests[0]["level"])
+	})
+}
@@ -1,6 +1,12 @@
 package web
 
-import "github.com/gofiber/fiber"
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber"
+)
 
 // структуа веб-сервера
 type Server struct {
@@ -9,6 +15,11 @@ type Server struct {
 	GroupApiV1 fiber.Router
 	// группа непубличного API
 	GroupInternal fiber.Router
+
+	// базовый контекст запросов: отменяется, только если запросы не успели завершиться при остановке сервера
+	requestsCtx    context.Context
+	cancelRequests context.CancelFunc
+	shuttingDown   atomic.Bool
 }
 
 // функция-конструктор
@@ -16,6 +27,21 @@ func NewServer() *Server {
 	// создаём новый веб-вервер
 	app := fiber.New()
 
+	requestsCtx, cancelRequests := context.WithCancel(context.Background())
+	// контекст fasthttp отменяется сразу при остановке сервера, поэтому middleware Timeout
+	// создаёт контекст запроса от базового контекста сервера, чтобы запросы успели завершиться
+	app.Use(func(c *fiber.Ctx) {
+		c.Locals(localsBaseContext, requestsCtx)
+		c.Next()
+	})
+	// идентификатор запроса нужен всем следующим middleware для записей в логах
+	app.Use(RequestID())
+	app.Use(AccessLog())
+	// span запроса создаётся до остальных middleware, чтобы учесть и их время
+	app.Use(Trace())
+	// паника хендлера превращается в ответ 500, который видят журнал запросов и трассировка
+	app.Use(Recover())
+
 	// создаём группу "/api"
 	groupApi := app.Group("/api")
 	// создаём подгруппу "api/v1"
@@ -24,8 +50,40 @@ func NewServer() *Server {
 	groupInternal := app.Group("/internal")
 
 	return &Server{
-		App:           app,
-		GroupApiV1:    groupApiV1,
-		GroupInternal: groupInternal,
+		App:            app,
+		GroupApiV1:     groupApiV1,
+		GroupInternal:  groupInternal,
+		requestsCtx:    requestsCtx,
+		cancelRequests: cancelRequests,
+	}
+}
+
+// BeginShutdown начало остановки: проверка готовности (readiness) начинает отвечать ошибкой,
+// чтобы балансировщик перестал направлять запросы, а сервер пока продолжает их обрабатывать
+func (s *Server) BeginShutdown() {
+	s.shuttingDown.Store(true)
+}
+
+// ShuttingDown сервер останавливается
+func (s *Server) ShuttingDown() bool {
+	return s.shuttingDown.Load()
+}
+
+// Shutdown остановка сервера: новые подключения не принимаются, обрабатываемые запросы завершаются.
+// Если запросы не успели завершиться до отмены ctx, их контексты отменяются:
+// запросы к базе данных прерываются, а незавершённые транзакции откатываются.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.BeginShutdown()
+
+	var done = make(chan error, 1)
+	go func() {
+		done <- s.App.Shutdown()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		s.cancelRequests()
+		return fmt.Errorf("in-flight requests were canceled: %w", ctx.Err())
 	}
 }
@@ -0,0 +1,150 @@
+package web
+
+import (
+	"context"
+	"idm/inner/common"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber"
+	"github.com/stretchr/testify/assert"
+)
+
+// startServer запуск сервера на свободном порту, возвращает адрес для запросов
+func startServer(t *testing.T, server *Server) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.App.Settings.DisableStartupMessage = true
+	go func() {
+		_ = server.App.Listener(listener)
+	}()
+	return "http://" + listener.Addr().String()
+}
+
+func TestServerShutdown(t *testing.T) {
+	var a = assert.New(t)
+
+	t.Run("should finish in-flight request before stopping", func(t *testing.T) {
+		var server = NewServer()
+		var started = make(chan struct{})
+		server.GroupApiV1.Use(Timeout(Timeouts{Default: time.Minute}))
+		server.GroupApiV1.Get("/slow", func(c *fiber.Ctx) {
+			close(started)
+			select {
+			case <-time.After(200 * time.Millisecond):
+				c.SendString("done")
+			case <-Context(c).Done():
+				_ = common.ErrResponse(c, fiber.StatusInternalServerError, Context(c).Err().Error())
+			}
+		})
+		var url = startServer(t, server)
+
+		var response = make(chan int, 1)
+		go func() {
+			resp, err := http.Get(url + "/api/v1/slow")
+			if err != nil {
+				response <- 0
+				return
+			}
+			_ = resp.Body.Close()
+			response <- resp.StatusCode
+		}()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		a.Nil(server.Shutdown(ctx))
+		a.True(server.ShuttingDown())
+		a.Equal(http.StatusOK, <-response)
+	})
+
+	t.Run("should cancel in-flight request after deadline", func(t *testing.T) {
+		var server = NewServer()
+		var started = make(chan struct{})
+		server.GroupApiV1.Use(Timeout(Timeouts{Default: time.Minute}))
+		server.GroupApiV1.Get("/slow", func(c *fiber.Ctx) {
+			close(started)
+			<-Context(c).Done()
+			_ = common.ErrResponse(c, fiber.StatusInternalServerError, Context(c).Err().Error())
+		})
+		var url = startServer(t, server)
+
+		var response = make(chan int, 1)
+		go func() {
+			resp, err := http.Get(url + "/api/v1/slow")
+			if err != nil {
+				response <- 0
+				return
+			}
+			_ = resp.Body.Close()
+			response <- resp.StatusCode
+		}()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		a.ErrorIs(server.Shutdown(ctx), context.DeadlineExceeded)
+		a.Equal(http.StatusServiceUnavailable, <-response)
+	})
+}
+
+func TestClientDisconnect(t *testing.T) {
+	var a = assert.New(t)
+
+	t.Run("should cancel request context when client closes connection", func(t *testing.T) {
+		var server = NewServer()
+		var started = make(chan struct{})
+		var cause = make(chan error, 1)
+		server.GroupApiV1.Use(Timeout(Timeouts{Default: time.Minute}))
+		server.GroupApiV1.Get("/slow", func(c *fiber.Ctx) {
+			close(started)
+			select {
+			case <-Context(c).Done():
+				cause <- context.Cause(Context(c))
+			case <-time.After(5 * time.Second):
+				cause <- nil
+			}
+		})
+		var url = startServer(t, server)
+
+		conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = conn.Write([]byte("GET /api/v1/slow HTTP/1.1\r\nHost: idm\r\n\r\n"))
+		a.NoError(err)
+		<-started
+		a.NoError(conn.Close())
+
+		a.ErrorIs(<-cause, ErrClientClosed)
+	})
+
+	t.Run("should keep connection usable for next request", func(t *testing.T) {
+		var server = NewServer()
+		server.GroupApiV1.Use(Timeout(Timeouts{Default: time.Minute}))
+		server.GroupApiV1.Get("/fast", func(c *fiber.Ctx) {
+			a.NoError(Context(c).Err())
+			c.SendString("ok")
+		})
+		var url = startServer(t, server)
+
+		// оба запроса идут по одному keep-alive соединению
+		var client = &http.Client{Transport: &http.Transport{MaxConnsPerHost: 1}}
+		for range 2 {
+			resp, err := client.Get(url + "/api/v1/fast")
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(resp.Body)
+			a.NoError(err)
+			_ = resp.Body.Close()
+			a.Equal("ok", string(body))
+		}
+	})
+}
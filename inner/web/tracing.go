@@ -0,0 +1,80 @@
+package web
+
+import (
+	"idm/inner/tracing"
+	"strings"
+
+	"github.com/gofiber/fiber"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+)
+
+// Trace middleware, создающий span запроса с родителем из заголовка traceparent.
+// Контекст со span становится базовым контекстом запроса (см. NewServer и Timeout),
+// поэтому span сервисов и запросов к базе данных оказываются его потомками.
+func Trace() fiber.Handler {
+	return func(c *fiber.Ctx) {
+		var own = c.Route()
+		// строки fiber ссылаются на буферы fasthttp, которые переиспользуются следующими запросами
+		var method = strings.Clone(c.Method())
+		ctx, span := tracing.StartServer(baseContext(c), requestHeaders{&c.Fasthttp.Request.Header}, method,
+			semconv.HTTPRequestMethodKey.String(method),
+			semconv.URLPath(strings.Clone(c.Path())),
+			semconv.ClientAddress(c.IP()),
+			semconv.UserAgentOriginal(strings.Clone(c.Get(fiber.HeaderUserAgent))),
+		)
+		defer span.End()
+
+		c.Locals(localsBaseContext, ctx)
+		c.Next()
+
+		// имя span - шаблон маршрута, а не путь запроса, чтобы запросы группировались по хендлерам
+		if route, ok := MatchedRoute(c, own); ok {
+			span.SetName(method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		var status = c.Fasthttp.Response.StatusCode()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		// ошибки клиента (4xx) - нормальный ответ сервера, ошибкой span считаются только ответы 5xx
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, "")
+		}
+	}
+}
+
+// MatchedRoute шаблон маршрута хендлера, обработавшего запрос (/api/v1/employees/:id).
+// После c.Next в контексте остаётся последний выполненный маршрут, own - маршрут вызывающего middleware.
+// Если хендлер не найден, это middleware (сам вызывающий или Use группы), путь которого - префикс пути запроса,
+// тогда как путь маршрута хендлера совпадает с путём запроса целиком или содержит параметры.
+func MatchedRoute(c *fiber.Ctx, own *fiber.Route) (string, bool) {
+	var matched = c.Route()
+	if matched == own {
+		return "", false
+	}
+	if len(matched.Params) > 0 || strings.EqualFold(strings.TrimRight(matched.Path, "/"), strings.TrimRight(c.Path(), "/")) {
+		return matched.Path, true
+	}
+	return "", false
+}
+
+// requestHeaders заголовки запроса fasthttp для извлечения контекста трассировки
+type requestHeaders struct {
+	header *fasthttp.RequestHeader
+}
+
+func (headers requestHeaders) Get(key string) string {
+	return string(headers.header.Peek(key))
+}
+
+func (headers requestHeaders) Set(key string, value string) {
+	headers.header.Set(key, value)
+}
+
+func (headers requestHeaders) Keys() []string {
+	var keys []string
+	headers.header.VisitAll(func(key, value []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"idm/inner/tracing"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTrace(t *testing.T) {
+	a := assert.New(t)
+
+	var recorder = tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(trace.NewNoopTracerProvider()) })
+
+	var server = NewServer()
+	server.GroupApiV1.Use(Timeout(Timeouts{Default: time.Minute}))
+	server.GroupApiV1.Get("/employees/:id", func(c *fiber.Ctx) {
+		_, span := tracing.Start(Context(c), "employee.FindById")
+		span.End()
+		c.SendString("ok")
+	})
+
+	var req = httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := server.App.Test(req)
+	a.Nil(err)
+	a.Equal(fiber.StatusOK, resp.StatusCode)
+
+	var spans = recorder.Ended()
+	a.Len(spans, 2)
+	var service, request = spans[0], spans[1]
+	a.Equal("GET /api/v1/employees/:id", request.Name())
+	a.Equal(trace.SpanKindServer, request.SpanKind())
+	// родитель span запроса - span вызывающего сервиса из traceparent
+	a.Equal("4bf92f3577b34da6a3ce929d0e0e4736", request.SpanContext().TraceID().String())
+	a.Equal("00f067aa0ba902b7", request.Parent().SpanID().String())
+	a.Equal(request.SpanContext().SpanID(), service.Parent().SpanID())
+	a.Equal(request.SpanContext().TraceID(), service.SpanContext().TraceID())
+}
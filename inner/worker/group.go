@@ -0,0 +1,93 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sort"
+	"sync"
+)
+
+// Group фоновые задачи процесса (очистка ключей идемпотентности, перезагрузка конфигурации и т.п.).
+// Все задачи получают общий контекст, который отменяется при остановке группы.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+// NewGroup пустая группа фоновых задач
+func NewGroup() *Group {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Group{ctx: ctx, cancel: cancel, running: make(map[string]bool)}
+}
+
+// Go запуск задачи name: fn должна вернуться после отмены ctx.
+// Паника задачи не завершает процесс: она записывается в лог, а задача считается остановленной,
+// и проверка работоспособности (health.Workers) сообщает об этом.
+func (group *Group) Go(name string, fn func(ctx context.Context)) {
+	group.setRunning(name, true)
+	group.wg.Add(1)
+	go func() {
+		defer group.wg.Done()
+		defer group.setRunning(name, false)
+		defer func() {
+			if r := recover(); r != nil {
+				slog.Error("panic in background worker",
+					"worker", name,
+					"panic", fmt.Sprint(r),
+					"stack", string(debug.Stack()),
+				)
+			}
+		}()
+		fn(group.ctx)
+	}()
+}
+
+// Running состояние задач: false - задача завершилась (штатно или после паники)
+func (group *Group) Running() map[string]bool {
+	group.mu.Lock()
+	defer group.mu.Unlock()
+	var running = make(map[string]bool, len(group.running))
+	for name, alive := range group.running {
+		running[name] = alive
+	}
+	return running
+}
+
+// Stop отмена контекста задач и ожидание их завершения, но не дольше, чем до отмены ctx
+func (group *Group) Stop(ctx context.Context) error {
+	group.cancel()
+
+	var done = make(chan struct{})
+	go func() {
+		group.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("background workers did not stop: %v: %w", group.stillRunning(), ctx.Err())
+	}
+}
+
+func (group *Group) setRunning(name string, alive bool) {
+	group.mu.Lock()
+	defer group.mu.Unlock()
+	group.running[name] = alive
+}
+
+func (group *Group) stillRunning() (names []string) {
+	for name, alive := range group.Running() {
+		if alive {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup(t *testing.T) {
+	var a = assert.New(t)
+
+	t.Run("should stop workers and report their state", func(t *testing.T) {
+		var group = NewGroup()
+		group.Go("ticker", func(ctx context.Context) { <-ctx.Done() })
+		group.Go("once", func(ctx context.Context) {})
+
+		a.Eventually(func() bool { return !group.Running()["once"] }, time.Second, 10*time.Millisecond)
+		a.True(group.Running()["ticker"])
+
+		a.Nil(group.Stop(context.Background()))
+		a.Equal(map[string]bool{"ticker": false, "once": false}, group.Running())
+	})
+
+	t.Run("should mark worker stopped after panic", func(t *testing.T) {
+		var group = NewGroup()
+		group.Go("broken", func(ctx context.Context) { panic("unexpected state") })
+		group.Go("ticker", func(ctx context.Context) { <-ctx.Done() })
+
+		a.Eventually(func() bool { return !group.Running()["broken"] }, time.Second, 10*time.Millisecond)
+		a.True(group.Running()["ticker"])
+		a.Nil(group.Stop(context.Background()))
+	})
+
+	t.Run("should return error when worker ignores cancellation", func(t *testing.T) {
+		var group = NewGroup()
+		var release = make(chan struct{})
+		defer close(release)
+		group.Go("stuck", func(ctx context.Context) { <-release })
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		var err = group.Stop(ctx)
+
+		a.ErrorIs(err, context.DeadlineExceeded)
+		a.Contains(err.Error(), "stuck")
+	})
+}
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "employee" ADD COLUMN IF NOT EXISTS "active" boolean NOT NULL DEFAULT true;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "employee" DROP COLUMN "active";
+-- +goose StatementEnd
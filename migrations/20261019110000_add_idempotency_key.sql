@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "idempotency_key"
+(
+    "key" text primary key,
+    "request_hash" text not null,
+    "status" int not null DEFAULT 0,
+    "content_type" text not null DEFAULT '',
+    "body" bytea,
+    "expires_at" timestamptz not null
+);
+
+CREATE INDEX IF NOT EXISTS "idempotency_key_expires_at_idx" ON "idempotency_key" ("expires_at");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "idempotency_key";
+-- +goose StatementEnd
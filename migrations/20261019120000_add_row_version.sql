@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "employee" ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 1;
+ALTER TABLE "role" ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 1;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "employee" DROP COLUMN "version";
+ALTER TABLE "role" DROP COLUMN "version";
+-- +goose StatementEnd
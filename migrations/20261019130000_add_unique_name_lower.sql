@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- имена работников и ролей уникальны без учёта регистра;
+-- до применения миграции дубликаты, различающиеся только регистром, нужно устранить вручную
+CREATE UNIQUE INDEX IF NOT EXISTS "employee_name_lower_key" ON "employee" (LOWER("name"));
+CREATE UNIQUE INDEX IF NOT EXISTS "role_name_lower_key" ON "role" (LOWER("name"));
+ALTER TABLE "role" DROP CONSTRAINT IF EXISTS "role_name_key";
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "role" ADD CONSTRAINT "role_name_key" UNIQUE ("name");
+DROP INDEX IF EXISTS "role_name_lower_key";
+DROP INDEX IF EXISTS "employee_name_lower_key";
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "employee_role"
+(
+    "employee_id" bigint NOT NULL REFERENCES "employee" ("id") ON DELETE CASCADE,
+    "role_id" bigint NOT NULL REFERENCES "role" ("id") ON DELETE CASCADE,
+    "create_at" timestamptz DEFAULT now(),
+
+    primary key ("employee_id", "role_id")
+);
+CREATE INDEX IF NOT EXISTS "employee_role_role_id_idx" ON "employee_role" ("role_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "employee_role";
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- счётчики ограничения частоты запросов, общие для всех экземпляров сервиса (RATE_LIMIT_STORE=database);
+-- время хранится в секундах Unix
+CREATE TABLE IF NOT EXISTS "rate_limit_bucket"
+(
+    "key" text primary key,
+    "tokens" double precision not null,
+    "allowed" boolean not null,
+    "updated_at" double precision not null,
+    "expires_at" double precision not null
+);
+
+CREATE INDEX IF NOT EXISTS "rate_limit_bucket_expires_at_idx" ON "rate_limit_bucket" ("expires_at");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "rate_limit_bucket";
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+-- сервисные аккаунты для интеграций без интерактивного входа; scopes - разрешения через пробел
+CREATE TABLE IF NOT EXISTS "service_account"
+(
+    "id" bigserial primary key,
+    "name" text not null,
+    "description" text not null DEFAULT '',
+    "scopes" text not null,
+    "create_at" timestamptz DEFAULT now(),
+    "update_at" timestamptz DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "service_account_name_lower_key" ON "service_account" (LOWER("name"));
+
+-- API-ключи сервисных аккаунтов: хранится только SHA-256 ключа, prefix - открытая часть ключа для поиска;
+-- у заменённого ключа expires_at сокращается до конца периода перекрытия
+CREATE TABLE IF NOT EXISTS "api_key"
+(
+    "id" bigserial primary key,
+    "service_account_id" bigint NOT NULL REFERENCES "service_account" ("id") ON DELETE CASCADE,
+    "prefix" text not null,
+    "hash" text not null,
+    "expires_at" timestamptz,
+    "last_used_at" timestamptz,
+    "create_at" timestamptz DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "api_key_prefix_key" ON "api_key" ("prefix");
+CREATE INDEX IF NOT EXISTS "api_key_service_account_id_idx" ON "api_key" ("service_account_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "api_key";
+DROP TABLE "service_account";
+-- +goose StatementEnd
@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+-- клиенты встроенного OIDC-провайдера; у публичных клиентов (SPA, CLI) нет секрета,
+-- списки redirect_uris, grant_types и scopes - через пробел
+CREATE TABLE IF NOT EXISTS "oidc_client"
+(
+    "id" bigserial primary key,
+    "client_id" text not null,
+    "name" text not null,
+    "secret_hash" text not null DEFAULT '',
+    "redirect_uris" text not null DEFAULT '',
+    "grant_types" text not null,
+    "scopes" text not null DEFAULT '',
+    "create_at" timestamptz DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "oidc_client_client_id_key" ON "oidc_client" ("client_id");
+
+-- ключи подписи токенов RS256: доступ к таблице равен праву выпускать токены от имени провайдера
+CREATE TABLE IF NOT EXISTS "oidc_signing_key"
+(
+    "kid" text primary key,
+    "private_key" text not null,
+    "create_at" timestamptz not null
+);
+
+-- коды авторизации хранятся как SHA-256 и удаляются при обмене на токены
+CREATE TABLE IF NOT EXISTS "oidc_authorization_code"
+(
+    "hash" text primary key,
+    "client_id" text not null,
+    "employee_id" bigint NOT NULL REFERENCES "employee" ("id") ON DELETE CASCADE,
+    "redirect_uri" text not null,
+    "scope" text not null,
+    "nonce" text not null DEFAULT '',
+    "code_challenge" text not null,
+    "auth_time" timestamptz not null,
+    "expires_at" timestamptz not null
+);
+
+CREATE INDEX IF NOT EXISTS "oidc_authorization_code_expires_at_idx" ON "oidc_authorization_code" ("expires_at");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "oidc_authorization_code";
+DROP TABLE "oidc_signing_key";
+DROP TABLE "oidc_client";
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+-- учётные записи работников для входа по паролю: у работника не больше одной учётной записи,
+-- password_hash - Argon2id (или bcrypt у перенесённых учётных записей) в формате PHC
+CREATE TABLE IF NOT EXISTS "account"
+(
+    "id" bigserial primary key,
+    "employee_id" bigint NOT NULL REFERENCES "employee" ("id") ON DELETE CASCADE,
+    "username" text not null,
+    "password_hash" text not null,
+    "failed_attempts" integer not null DEFAULT 0,
+    "locked_until" timestamptz,
+    "password_changed_at" timestamptz not null,
+    "last_login_at" timestamptz,
+    "create_at" timestamptz DEFAULT now(),
+    "update_at" timestamptz DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "account_employee_id_key" ON "account" ("employee_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "account_username_lower_key" ON "account" (LOWER("username"));
+
+-- одноразовые токены сброса пароля: хранится только SHA-256 токена
+CREATE TABLE IF NOT EXISTS "password_reset"
+(
+    "hash" text primary key,
+    "account_id" bigint NOT NULL REFERENCES "account" ("id") ON DELETE CASCADE,
+    "expires_at" timestamptz not null
+);
+
+CREATE INDEX IF NOT EXISTS "password_reset_account_id_idx" ON "password_reset" ("account_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "password_reset";
+DROP TABLE "account";
+-- +goose StatementEnd
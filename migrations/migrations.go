@@ -0,0 +1,8 @@
+// Package migrations SQL-миграции goose, встроенные в бинарный файл приложения:
+// миграции PostgreSQL лежат в корне папки, миграции SQLite - в папке sqlite
+package migrations
+
+import "embed"
+
+//go:embed *.sql sqlite/*.sql
+var FS embed.FS
@@ -0,0 +1,55 @@
+-- +goose Up
+-- +goose StatementBegin
+-- схема SQLite целиком соответствует схеме PostgreSQL после всех миграций из migrations/
+CREATE TABLE IF NOT EXISTS "employee"
+(
+    "id" integer primary key AUTOINCREMENT,
+    "name" text not null,
+    "create_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "update_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "active" boolean not null DEFAULT true,
+    "version" integer not null DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS "role"
+(
+    "id" integer primary key AUTOINCREMENT,
+    "name" text not null,
+    "create_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "update_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "version" integer not null DEFAULT 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "employee_name_lower_key" ON "employee" (LOWER("name"));
+CREATE UNIQUE INDEX IF NOT EXISTS "role_name_lower_key" ON "role" (LOWER("name"));
+
+CREATE TABLE IF NOT EXISTS "idempotency_key"
+(
+    "key" text primary key,
+    "request_hash" text not null,
+    "status" integer not null DEFAULT 0,
+    "content_type" text not null DEFAULT '',
+    "body" blob,
+    "expires_at" timestamp not null
+);
+
+CREATE INDEX IF NOT EXISTS "idempotency_key_expires_at_idx" ON "idempotency_key" ("expires_at");
+
+CREATE TABLE IF NOT EXISTS "employee_role"
+(
+    "employee_id" integer NOT NULL REFERENCES "employee" ("id") ON DELETE CASCADE,
+    "role_id" integer NOT NULL REFERENCES "role" ("id") ON DELETE CASCADE,
+    "create_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+
+    primary key ("employee_id", "role_id")
+);
+CREATE INDEX IF NOT EXISTS "employee_role_role_id_idx" ON "employee_role" ("role_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "employee_role";
+DROP TABLE "idempotency_key";
+DROP TABLE "role";
+DROP TABLE "employee";
+-- +goose StatementEnd
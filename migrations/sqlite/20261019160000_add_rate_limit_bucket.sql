@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "rate_limit_bucket"
+(
+    "key" text primary key,
+    "tokens" real not null,
+    "allowed" boolean not null,
+    "updated_at" real not null,
+    "expires_at" real not null
+);
+
+CREATE INDEX IF NOT EXISTS "rate_limit_bucket_expires_at_idx" ON "rate_limit_bucket" ("expires_at");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "rate_limit_bucket";
+-- +goose StatementEnd
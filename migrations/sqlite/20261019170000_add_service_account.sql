@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "service_account"
+(
+    "id" integer primary key AUTOINCREMENT,
+    "name" text not null,
+    "description" text not null DEFAULT '',
+    "scopes" text not null,
+    "create_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "update_at" timestamp DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "service_account_name_lower_key" ON "service_account" (LOWER("name"));
+
+CREATE TABLE IF NOT EXISTS "api_key"
+(
+    "id" integer primary key AUTOINCREMENT,
+    "service_account_id" integer NOT NULL REFERENCES "service_account" ("id") ON DELETE CASCADE,
+    "prefix" text not null,
+    "hash" text not null,
+    "expires_at" timestamp,
+    "last_used_at" timestamp,
+    "create_at" timestamp DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "api_key_prefix_key" ON "api_key" ("prefix");
+CREATE INDEX IF NOT EXISTS "api_key_service_account_id_idx" ON "api_key" ("service_account_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "api_key";
+DROP TABLE "service_account";
+-- +goose StatementEnd
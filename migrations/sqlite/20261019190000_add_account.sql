@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "account"
+(
+    "id" integer primary key AUTOINCREMENT,
+    "employee_id" integer NOT NULL REFERENCES "employee" ("id") ON DELETE CASCADE,
+    "username" text not null,
+    "password_hash" text not null,
+    "failed_attempts" integer not null DEFAULT 0,
+    "locked_until" timestamp,
+    "password_changed_at" timestamp not null,
+    "last_login_at" timestamp,
+    "create_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+    "update_at" timestamp DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "account_employee_id_key" ON "account" ("employee_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "account_username_lower_key" ON "account" (LOWER("username"));
+
+CREATE TABLE IF NOT EXISTS "password_reset"
+(
+    "hash" text primary key,
+    "account_id" integer NOT NULL REFERENCES "account" ("id") ON DELETE CASCADE,
+    "expires_at" timestamp not null
+);
+
+CREATE INDEX IF NOT EXISTS "password_reset_account_id_idx" ON "password_reset" ("account_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "password_reset";
+DROP TABLE "account";
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- индексы по LOWER, построенные встроенной функцией SQLite (только латиница), перестраиваются
+-- функцией lower, которую приложение регистрирует для всех подключений SQLite (см. inner/database/database.go)
+REINDEX "employee_name_lower_key";
+REINDEX "role_name_lower_key";
+REINDEX "service_account_name_lower_key";
+REINDEX "account_username_lower_key";
+-- +goose StatementEnd
+
+-- +goose Down
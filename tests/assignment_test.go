@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"idm/inner/assignment"
+	"idm/inner/common"
+	"idm/inner/employee"
+	"idm/inner/storage"
+	"idm/inner/validator"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateEmployeeWithRoles(t *testing.T) {
+	ForEachStorage(t, func(t *testing.T, store *storage.Storage) {
+		a := assert.New(t)
+		var srv = assignment.NewService(store.Tx, store.Employees, store.Roles, validator.NewRequestValidator())
+		var fixture = NewFixtureRole(store.Roles)
+		var adminId = fixture.Role("admin")
+
+		var request = func(name string, roleIds ...int64) assignment.CreateEmployeeRequest {
+			return assignment.CreateEmployeeRequest{
+				Request: employee.Request{Name: name, Create: time.Now(), Update: time.Now()},
+				RoleIds: roleIds,
+			}
+		}
+
+		t.Run("should create employee with roles", func(t *testing.T) {
+			id, err := srv.CreateEmployee(context.Background(), request("Pupkin", adminId))
+			a.NoError(err)
+
+			roles, err := srv.FindRoles(context.Background(), id)
+			a.NoError(err)
+			a.Len(roles, 1)
+			a.Equal("admin", roles[0].Name)
+		})
+
+		t.Run("should not create employee when role does not exist", func(t *testing.T) {
+			_, err := srv.CreateEmployee(context.Background(), request("Ivanov", adminId, adminId+100))
+			a.True(errors.As(err, &common.NotFoundError{}))
+
+			entities, err := store.Employees.GetAll(context.Background(), employee.Filter{Name: "Ivanov"})
+			a.NoError(err)
+			a.Empty(entities)
+		})
+	})
+}
+
+func TestCountAssignments(t *testing.T) {
+	ForEachStorage(t, func(t *testing.T, store *storage.Storage) {
+		a := assert.New(t)
+		var ctx = context.Background()
+		var roles = NewFixtureRole(store.Roles)
+		var adminId, userId = roles.Role("admin"), roles.Role("user")
+		var employeeId = NewFixtureEmployee(store.Employees).Employee("Pupkin")
+		a.NoError(store.Roles.AssignTx(ctx, employeeId, []int64{adminId, userId}))
+
+		employees, err := store.Employees.Count(ctx)
+		a.NoError(err)
+		a.Equal(int64(1), employees)
+		count, err := store.Roles.Count(ctx)
+		a.NoError(err)
+		a.Equal(int64(2), count)
+		assignments, err := store.Roles.CountAssignments(ctx)
+		a.NoError(err)
+		a.Equal(int64(2), assignments)
+
+		t.Run("should not count assignments of deleted role", func(t *testing.T) {
+			a.NoError(store.Roles.DeleteByIds(ctx, []int64{userId}))
+
+			assignments, err := store.Roles.CountAssignments(ctx)
+			a.NoError(err)
+			a.Equal(int64(1), assignments)
+		})
+	})
+}
@@ -26,20 +26,17 @@ func TestConnectionDbСase1(t *testing.T) {
 	dsnName := "DB_DSN"
 	dsnVal := "host=127.0.0.1 port=5430 user=postgres_user password=postgres_password dbname=idm_go sslmode=disable"
 
+	t.Setenv(driverName, driverVal)
+	t.Setenv(dsnName, dsnVal)
+	t.Setenv("APP_NAME", "idm")
+	t.Setenv("APP_VERSION", "1.0.1")
+
+	// отсутствующий .env файл не ошибка: конфигурация берётся из переменных окружения
 	conf, err := common.GetConfig(".env_not_found")
-	if err != nil {
-		os.Setenv(driverName, driverVal)
-		os.Setenv(dsnName, dsnVal)
-
-		conf = common.Config{
-			DbDriverName: os.Getenv(driverName),
-			Dsn:          os.Getenv(dsnName),
-		}
-	}
 
 	as.Equal(driverVal, conf.DbDriverName)
 	as.Equal(dsnVal, conf.Dsn)
-	as.NotEqual(nil, err)
+	as.Nil(err)
 }
 
 // 2 - в проекте есть .env  файл, но в нём нет нужных переменных и в переменных окружения их тоже нет (должны получить пустую структуру idm.inner.common.Config)
@@ -133,6 +130,9 @@ func TestConnectionDbСase6(t *testing.T) {
 
 // 7 - приложение может подключиться к базе данных с корректным конфигом
 func TestConnectionDbСase7(t *testing.T) {
+	if !withPostgres() {
+		t.Skip("требуется база данных: TEST_STORAGE=postgres")
+	}
 	ClearEnv()
 	as := assert.New(t)
 	db, err := database.ConnectDb(".env_5")
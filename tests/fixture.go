@@ -1,10 +1,16 @@
 package tests
 
 import (
+	"context"
+	"idm/inner/common"
 	"idm/inner/database"
 	"idm/inner/employee"
+	"idm/inner/migration"
 	"idm/inner/role"
+	"idm/inner/storage"
 	"os"
+	"path/filepath"
+	"testing"
 )
 
 func ClearEnv() {
@@ -15,18 +21,18 @@ func ClearEnv() {
 }
 
 type FixtureRole struct {
-	roles *role.Repository
+	roles storage.Roles
 }
 
 type FixtureEmployee struct {
-	employee *employee.Repository
+	employee storage.Employees
 }
 
-func NewFixtureRole(roles *role.Repository) *FixtureRole {
+func NewFixtureRole(roles storage.Roles) *FixtureRole {
 	return &FixtureRole{roles}
 }
 
-func NewFixtureEmployee(employee *employee.Repository) *FixtureEmployee {
+func NewFixtureEmployee(employee storage.Employees) *FixtureEmployee {
 	return &FixtureEmployee{employee}
 }
 
@@ -34,7 +40,7 @@ func (f *FixtureRole) Role(name string) int64 {
 	var entity = role.Entity{
 		Name: name,
 	}
-	newId, err := f.roles.Save(&entity)
+	newId, err := f.roles.Save(context.Background(), &entity)
 	if err != nil {
 		panic(err)
 	}
@@ -45,13 +51,14 @@ func (f *FixtureEmployee) Employee(name string) int64 {
 	var entity = employee.Entity{
 		Name: name,
 	}
-	newId, err := f.employee.Save(&entity)
+	newId, err := f.employee.Save(context.Background(), &entity)
 	if err != nil {
 		panic(err)
 	}
 	return newId
 }
 
+// Init применение к базе данных тех же встроенных миграций, что и при запуске приложения
 func Init(envFile string) {
 	db, err := database.ConnectDb(envFile)
 	if err == nil {
@@ -61,12 +68,81 @@ func Init(envFile string) {
 		panic(err)
 	}
 
-	content, err := os.ReadFile("InitTestTables.sql")
+	migrator, err := migration.NewMigrator(db)
 	if err != nil {
 		panic(err)
 	}
-	_, err = db.Exec(string(content))
+	_, err = migrator.Up(context.Background())
 	if err != nil {
 		panic(err)
 	}
 }
+
+// withPostgres тесты хранилищ выполняются и на PostgreSQL из .env (TEST_STORAGE=postgres)
+func withPostgres() bool {
+	return os.Getenv("TEST_STORAGE") == "postgres"
+}
+
+// ForEachStorage выполнение теста на каждом хранилище: в памяти, в SQLite во временном файле
+// и, при TEST_STORAGE=postgres, в базе данных из .env, очищаемой до и после теста
+func ForEachStorage(t *testing.T, test func(t *testing.T, store *storage.Storage)) {
+	t.Run(common.StorageMemory, func(t *testing.T) {
+		test(t, storage.NewMemory())
+	})
+	t.Run(database.DriverSQLite, func(t *testing.T) {
+		test(t, openSQLite(t))
+	})
+	if withPostgres() {
+		t.Run("postgres", func(t *testing.T) {
+			test(t, openPostgres(t))
+		})
+	}
+}
+
+// openSQLite хранилища в новом файле SQLite, схема создаётся миграциями при открытии
+func openSQLite(t *testing.T) *storage.Storage {
+	var cfg = common.Config{
+		Storage:      common.StorageDatabase,
+		DbDriverName: database.DriverSQLite,
+		Dsn:          filepath.Join(t.TempDir(), "idm.db"),
+		Migrations:   common.MigrationsAuto,
+	}
+	store, err := storage.Open(cfg)
+	if err != nil {
+		t.Fatalf("error opening storage: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+// openPostgres хранилища в базе данных из .env, очищаемой до и после теста
+func openPostgres(t *testing.T) *storage.Storage {
+	var env = ".env"
+	Init(env)
+	cfg, err := common.GetConfig(env)
+	if err != nil {
+		t.Fatalf("error getting config: %v", err)
+	}
+	cfg.Storage = common.StorageDatabase
+	store, err := storage.Open(cfg)
+	if err != nil {
+		t.Fatalf("error opening storage: %v", err)
+	}
+
+	db, err := database.ConnectDbWithCfg(cfg)
+	if err != nil {
+		t.Fatalf("error connecting to db: %v", err)
+	}
+	var clearDataBase = func() {
+		db.MustExec("delete from employee_role")
+		db.MustExec("delete from employee")
+		db.MustExec("delete from role")
+	}
+	clearDataBase()
+	t.Cleanup(func() {
+		clearDataBase()
+		_ = db.Close()
+		_ = store.Close()
+	})
+	return store
+}
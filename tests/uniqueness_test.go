@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"idm/inner/common"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"idm/inner/storage"
+	"idm/inner/validator"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// количество одновременных запросов на создание записи с одним и тем же именем
+const parallelCreates = 20
+
+// runParallel запускает create одновременно из нескольких горутин и возвращает ошибки всех вызовов
+func runParallel(create func(i int) error) []error {
+	var errs = make([]error, parallelCreates)
+	var start = make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < parallelCreates; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = create(i)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return errs
+}
+
+// nameVariant одно и то же имя в разном регистре, чтобы проверить регистронезависимый уникальный индекс
+func nameVariant(name string, i int) string {
+	if i%2 == 0 {
+		return strings.ToUpper(name)
+	}
+	return name
+}
+
+func TestParallelCreateEmployee(t *testing.T) {
+	ForEachStorage(t, func(t *testing.T, store *storage.Storage) {
+		a := assert.New(t)
+
+		var srv = employee.NewService(store.Employees, validator.NewRequestValidator())
+
+		t.Run("only one of parallel creates should succeed", func(t *testing.T) {
+			errs := runParallel(func(i int) error {
+				_, err := srv.SaveTx(context.Background(), employee.Request{Name: nameVariant("Concurrent Pupkin", i), Create: time.Now(), Update: time.Now()})
+				return err
+			})
+
+			var created int
+			for _, err := range errs {
+				if err == nil {
+					created++
+					continue
+				}
+				a.True(errors.As(err, &common.AlreadyExistsError{}), fmt.Sprintf("unexpected error: %v", err))
+			}
+			a.Equal(1, created)
+
+			entities, err := store.Employees.GetAll(context.Background(), employee.Filter{Name: "concurrent pupkin"})
+			a.NoError(err)
+			a.Len(entities, 1)
+		})
+	})
+}
+
+func TestParallelCreateRole(t *testing.T) {
+	ForEachStorage(t, func(t *testing.T, store *storage.Storage) {
+		a := assert.New(t)
+
+		var srv = role.NewService(store.Roles, validator.NewRequestValidator())
+
+		t.Run("only one of parallel creates should succeed", func(t *testing.T) {
+			errs := runParallel(func(i int) error {
+				_, err := srv.Save(context.Background(), role.Request{Name: nameVariant("Concurrent Admin", i), Create: time.Now(), Update: time.Now()})
+				return err
+			})
+
+			var created int
+			for _, err := range errs {
+				if err == nil {
+					created++
+					continue
+				}
+				a.True(errors.As(err, &common.AlreadyExistsError{}), fmt.Sprintf("unexpected error: %v", err))
+			}
+			a.Equal(1, created)
+
+			entities, err := store.Roles.GetAll(context.Background(), role.Filter{Name: "concurrent admin"})
+			a.NoError(err)
+			a.Len(entities, 1)
+		})
+	})
+}
+
+// имена в кириллице сравниваются без учёта регистра так же, как латинские: в уникальном индексе,
+// в проверках дубликатов пакетной записи и в поиске по имени
+func TestCyrillicNameUniqueness(t *testing.T) {
+	ForEachStorage(t, func(t *testing.T, store *storage.Storage) {
+		a := assert.New(t)
+		var ctx = context.Background()
+		var srv = employee.NewService(store.Employees, validator.NewRequestValidator())
+
+		_, err := srv.SaveTx(ctx, employee.Request{Name: "Иванов", Create: time.Now(), Update: time.Now()})
+		a.NoError(err)
+
+		existing, err := store.Employees.FindExistingNamesTx(ctx, []string{"иванов"})
+		a.NoError(err)
+		a.Equal([]string{"Иванов"}, existing)
+
+		found, err := store.Employees.FindByNamesTx(ctx, []string{"иванов"})
+		a.NoError(err)
+		a.Len(found, 1)
+
+		entities, err := store.Employees.GetAll(ctx, employee.Filter{Name: "ИВАН"})
+		a.NoError(err)
+		a.Len(entities, 1)
+
+		_, err = store.Employees.SaveTx(ctx, &employee.Entity{Name: "иванов", Create: time.Now(), Update: time.Now()})
+		a.ErrorAs(err, &common.AlreadyExistsError{})
+
+		results, err := srv.SaveBatch(ctx, []employee.Request{
+			{Name: "ИВАНОВ", Create: time.Now(), Update: time.Now()},
+			{Name: "Петров", Create: time.Now(), Update: time.Now()},
+		}, false)
+		a.NoError(err)
+		a.Contains(results[0].Error, "already exists")
+		a.NotZero(results[1].Id)
+	})
+}